@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/veraison/corim-store/pkg/model"
 	storemod "github.com/veraison/corim-store/pkg/store"
+	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
 )
 
@@ -67,6 +69,8 @@ value text is decoded into bytes:
 
 You can use the type to only control the decoding of the value and not be part
 of the match by suffixing it with "*" (just before the ":").
+When --class-id, --instance-id, or --group-id is repeated, the values match
+any of the IDs, and either all or none of them must match the type.
 
 For example, 
 
@@ -74,7 +78,13 @@ For example,
 "--class-id foo:3q2+7w==" will attempt to match the class ID type to "foo" and
 value to 0xDEADBEEF.
 "--class-id hex*:deadbeef" will attempt to match the class ID value to 0xDEADBEEF
-(but will NOT match type).`
+(but will NOT match type).
+
+--extension matches extensions by their field name or JSON tag name. For
+manifests and module tags, their own extensions are matched; for triples, the
+extensions of the triples of the containing module tag are matched. The value
+is matched as text, and also as a number or a boolean if it can be parsed as
+such. For example, "--extension vendor=ACME".`
 
 const timeHelp = `
 
@@ -86,14 +96,48 @@ as "today" or "2 days ago". For more examples of supported formats please see
     https://github.com/client9/nowandlater#supported-formats
 `
 
+const queryHelp = `
+
+--query specifies a query expression, as an alternative to the other filtering
+flags (which cannot be used together with it). An expression is made up of
+conditions combined using "and" and "or" (with parentheses for grouping). A
+condition is a field name, an operator ("=", "<", "<=", ">", ">=", or "in"
+followed by a parenthesised list of values), and a value, e.g.
+
+    vendor = "ACME" and svn >= 3 and label in ("prod", "stage")
+
+Supported fields are label, manifest_id, profile, added, valid_on, valid, and
+ext.NAME (extension fields), as well as module_tag_id, version, and language
+for module tags and triples, and id, active, type, vendor, model, layer, index,
+and svn for triples.`
+
+// queryFilterAnnotation marks the flags added by AddQueryFlags that filter
+// the query results (and so cannot be combined with --query).
+const queryFilterAnnotation = "query-filter"
+
+// AddEnvironmentFlags adds the flags used to match environments (see
+// updateEnvironmentQueryFromFlags).
+func AddEnvironmentFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("class-id", "C", []string{}, "Environment class ID.")
+	cmd.Flags().StringArrayP("vendor", "V", []string{}, "Environment vendor.")
+	cmd.Flags().StringArrayP("model", "M", []string{}, "Environment model.")
+	cmd.Flags().UintSliceP("layer", "L", []uint{}, "Environment layer.")
+	cmd.Flags().UintSliceP("index", "I", []uint{}, "Environment index.")
+	cmd.Flags().StringArrayP("instance-id", "i", []string{}, "Environment instance ID")
+	cmd.Flags().StringArrayP("group-id", "g", []string{}, "Environment group ID")
+
+	cmd.Flags().BoolP("exact", "e", false,
+		"Match environments exactly, including null fields. The default is to assume that "+
+			"null fields (i.e. fields not explicitly specified) can match any value.")
+}
+
 func AddQueryFlags(cmd *cobra.Command) {
-	cmd.Flags().StringP("class-id", "C", "", "Environment class ID.")
-	cmd.Flags().StringP("vendor", "V", "", "Environment vendor.")
-	cmd.Flags().StringP("model", "M", "", "Environment model.")
-	cmd.Flags().Int64P("layer", "L", -1, "Environment layer.")
-	cmd.Flags().Int64P("index", "I", -1, "Environment index.")
-	cmd.Flags().StringP("instance-id", "i", "", "Environment instance ID")
-	cmd.Flags().StringP("group-id", "g", "", "Environment group ID")
+	existing := make(map[string]bool)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		existing[flag.Name] = true
+	})
+
+	AddEnvironmentFlags(cmd)
 
 	cmd.Flags().Int64("id", 0, "Database ID of the item.")
 
@@ -113,10 +157,59 @@ func AddQueryFlags(cmd *cobra.Command) {
 	cmd.Flags().Uint("version", 0, "Module tag (CoMID) version.")
 	cmd.Flags().String("language", "", "Language set in the module tag (CoMID).")
 
-	cmd.Flags().BoolP("exact", "e", false,
-		"Match environments exactly, including null fields. The default is to assume that "+
-			"null fields (i.e. fields not explicitly specified) can match any value.")
+	cmd.Flags().StringArray("extension", []string{},
+		"Extension value in the form NAME=VALUE. May be specified multiple times, in which case "+
+			"all specified extensions must match.")
+
+	cmd.Flags().String("entity-name", "", "Name of an entity of the module tag (CoMID).")
+	cmd.Flags().String("entity-role", "", `Role of the entity (e.g. "tag-creator").`)
+	cmd.Flags().String("entity-regid", "", "Registration ID (URI) of the entity.")
+	cmd.Flags().Bool("manifest-entity", false,
+		"Match --entity-* against the entities of the manifest (CoRIM), rather than of the module tag (CoMID). "+
+			"Only applies to triples.")
 
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if existing[flag.Name] {
+			return
+		}
+
+		if flag.Annotations == nil {
+			flag.Annotations = make(map[string][]string)
+		}
+		flag.Annotations[queryFilterAnnotation] = []string{"true"}
+	})
+
+	cmd.Flags().StringP("query", "q", "", "Query expression (see below).")
+}
+
+// buildQuery returns the query parsed from --query, if it has been specified,
+// and the query built from the other flags otherwise.
+func buildQuery[M model.Model, Q storemod.Query[M]](
+	flags *pflag.FlagSet,
+	parse func(string) (storemod.Query[M], error),
+	build func(*pflag.FlagSet) (Q, error),
+) (storemod.Query[M], error) {
+	text, err := flags.GetString("query")
+	if err != nil {
+		panic(err)
+	}
+
+	if text == "" {
+		return build(flags)
+	}
+
+	var conflicting []string
+	flags.Visit(func(flag *pflag.Flag) {
+		if _, ok := flag.Annotations[queryFilterAnnotation]; ok {
+			conflicting = append(conflicting, "--"+flag.Name)
+		}
+	})
+
+	if len(conflicting) != 0 {
+		return nil, fmt.Errorf("--query cannot be used with %s", strings.Join(conflicting, ", "))
+	}
+
+	return parse(text)
 }
 
 func BuildManifestQuery(flags *pflag.FlagSet) (*storemod.ManifestQuery, error) {
@@ -175,6 +268,22 @@ func BuildManifestQuery(flags *pflag.FlagSet) (*storemod.ManifestQuery, error) {
 		query.AddedAfter(t)
 	}
 
+	extensions, err := extensionUpdatersFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	for _, updater := range extensions {
+		query.Extension(updater)
+	}
+
+	entity, _, err := entityUpdaterFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	if entity != nil {
+		query.Entity(entity)
+	}
+
 	return query, nil
 }
 
@@ -197,6 +306,25 @@ func BuildModuleTagQuery(flags *pflag.FlagSet) (*storemod.ModuleTagQuery, error)
 		query.ModuleTagDbID(id)
 	}
 
+	extensions, err := extensionUpdatersFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	for _, updater := range extensions {
+		query.Extension(updater)
+	}
+
+	entity, manifestEntity, err := entityUpdaterFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	if manifestEntity {
+		return nil, errors.New("--manifest-entity cannot be used with module tags")
+	}
+	if entity != nil {
+		query.Entity(entity)
+	}
+
 	return query, nil
 }
 
@@ -211,6 +339,14 @@ func BuildEntityQuery(flags *pflag.FlagSet) (*storemod.EntityQuery, error) {
 		query.ID(id)
 	}
 
+	entity, _, err := entityUpdaterFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	if entity != nil {
+		entity(query)
+	}
+
 	return query, nil
 }
 
@@ -237,6 +373,26 @@ func BuildValueTripleQuery(flags *pflag.FlagSet) (*storemod.ValueTripleQuery, er
 		query.TripleDbID(id)
 	}
 
+	extensions, err := extensionUpdatersFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	for _, updater := range extensions {
+		query.Extension(updater)
+	}
+
+	entity, manifestEntity, err := entityUpdaterFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	if entity != nil {
+		if manifestEntity {
+			query.ManifestEntity(entity)
+		} else {
+			query.ModuleTagEntity(entity)
+		}
+	}
+
 	return query, nil
 }
 
@@ -263,6 +419,26 @@ func BuildKeyTripleQuery(flags *pflag.FlagSet) (*storemod.KeyTripleQuery, error)
 		query.TripleDbID(id)
 	}
 
+	extensions, err := extensionUpdatersFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	for _, updater := range extensions {
+		query.Extension(updater)
+	}
+
+	entity, manifestEntity, err := entityUpdaterFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	if entity != nil {
+		if manifestEntity {
+			query.ManifestEntity(entity)
+		} else {
+			query.ModuleTagEntity(entity)
+		}
+	}
+
 	return query, nil
 }
 
@@ -452,45 +628,54 @@ func updateEnvironmentQueryFromFlags(query *storemod.EnvironmentQuery, flags *pf
 		query.Exact = true
 	}
 
-	vendor, err := flags.GetString("vendor")
+	vendors, err := flags.GetStringArray("vendor")
 	if err != nil {
 		panic(err)
 	}
-	if vendor != "" {
-		query.Vendor(vendor)
+	if len(vendors) != 0 {
+		query.Vendor(vendors...)
 	}
 
-	model, err := flags.GetString("model")
+	models, err := flags.GetStringArray("model")
 	if err != nil {
 		panic(err)
 	}
-	if model != "" {
-		query.Model(model)
+	if len(models) != 0 {
+		query.Model(models...)
 	}
 
-	layerInt, err := flags.GetInt64("layer")
+	layers, err := flags.GetUintSlice("layer")
 	if err != nil {
 		panic(err)
 	}
-	if layerInt > -1 {
-		query.Layer(uint64(layerInt))
+	for _, value := range layers {
+		query.Layer(uint64(value))
 	}
 
-	indexInt, err := flags.GetInt64("index")
+	indexes, err := flags.GetUintSlice("index")
 	if err != nil {
 		panic(err)
 	}
-	if indexInt > -1 {
-		query.Index(uint64(indexInt))
+	for _, value := range indexes {
+		query.Index(uint64(value))
 	}
 
-	classIDText, err := flags.GetString("class-id")
+	classIDTexts, err := flags.GetStringArray("class-id")
 	if err != nil {
 		panic(err)
 	}
 
-	if classIDText != "" {
-		classIDBytes, classIDType, useType, err := parseEnvironmentID(classIDText)
+	if err := checkEnvironmentIDTyping(classIDTexts); err != nil {
+		return fmt.Errorf("class-id: %w", err)
+	}
+
+	for _, text := range classIDTexts {
+		classID, useType, err := parseEnvironmentID(text, comid.NewClassID)
+		if err != nil {
+			return fmt.Errorf("class-id: %w", err)
+		}
+
+		classIDType, classIDBytes, err := model.ClassIDToModel(classID)
 		if err != nil {
 			return fmt.Errorf("class-id: %w", err)
 		}
@@ -502,13 +687,22 @@ func updateEnvironmentQueryFromFlags(query *storemod.EnvironmentQuery, flags *pf
 		}
 	}
 
-	instanceIDText, err := flags.GetString("instance-id")
+	instanceIDTexts, err := flags.GetStringArray("instance-id")
 	if err != nil {
 		panic(err)
 	}
 
-	if instanceIDText != "" {
-		instanceIDBytes, instanceIDType, useType, err := parseEnvironmentID(instanceIDText)
+	if err := checkEnvironmentIDTyping(instanceIDTexts); err != nil {
+		return fmt.Errorf("instance-id: %w", err)
+	}
+
+	for _, text := range instanceIDTexts {
+		instance, useType, err := parseEnvironmentID(text, comid.NewInstance)
+		if err != nil {
+			return fmt.Errorf("instance-id: %w", err)
+		}
+
+		instanceIDType, instanceIDBytes, err := model.InstanceToModel(instance)
 		if err != nil {
 			return fmt.Errorf("instance-id: %w", err)
 		}
@@ -520,13 +714,22 @@ func updateEnvironmentQueryFromFlags(query *storemod.EnvironmentQuery, flags *pf
 		}
 	}
 
-	groupIDText, err := flags.GetString("group-id")
+	groupIDTexts, err := flags.GetStringArray("group-id")
 	if err != nil {
 		panic(err)
 	}
 
-	if groupIDText != "" {
-		groupIDBytes, groupIDType, useType, err := parseEnvironmentID(groupIDText)
+	if err := checkEnvironmentIDTyping(groupIDTexts); err != nil {
+		return fmt.Errorf("group-id: %w", err)
+	}
+
+	for _, text := range groupIDTexts {
+		group, useType, err := parseEnvironmentID(text, comid.NewGroup)
+		if err != nil {
+			return fmt.Errorf("group-id: %w", err)
+		}
+
+		groupIDType, groupIDBytes, err := model.GroupToModel(group)
 		if err != nil {
 			return fmt.Errorf("group-id: %w", err)
 		}
@@ -541,7 +744,157 @@ func updateEnvironmentQueryFromFlags(query *storemod.EnvironmentQuery, flags *pf
 	return nil
 }
 
-func parseEnvironmentID(text string) ([]byte, string, bool, error) {
+// checkTripleQueryErrors checks the errors returned when building key and
+// value triple queries. As a query string may refer to fields that only apply
+// to one kind of triple (e.g. svn), it is not an error if it cannot be parsed
+// for the other kind; instead, that kind of triple is skipped.
+func checkTripleQueryErrors(keyErr, valueErr error) (bool, bool, error) {
+	for _, err := range []error{keyErr, valueErr} {
+		if err != nil && !errors.Is(err, storemod.ErrQueryString) {
+			return false, false, err
+		}
+	}
+
+	if keyErr != nil && valueErr != nil {
+		return false, false, valueErr
+	}
+
+	return keyErr != nil, valueErr != nil, nil
+}
+
+// extensionUpdatersFromFlags returns ExtensionQuery updaters for the
+// NAME=VALUE pairs specified via --extension.
+func extensionUpdatersFromFlags(flags *pflag.FlagSet) ([]func(*storemod.ExtensionQuery), error) {
+	texts, err := flags.GetStringArray("extension")
+	if err != nil {
+		panic(err)
+	}
+
+	ret := make([]func(*storemod.ExtensionQuery), 0, len(texts))
+	for _, text := range texts {
+		name, value, ok := strings.Cut(text, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("extension: expected NAME=VALUE, found %q", text)
+		}
+
+		ret = append(ret, func(q *storemod.ExtensionQuery) {
+			q.Name(name).Value(value)
+		})
+	}
+
+	return ret, nil
+}
+
+// entityUpdaterFromFlags returns an updater for the entity query specified
+// by --entity-name, --entity-role, and --entity-regid, or nil if none of them
+// have been specified, along with the value of --manifest-entity. The
+// conditions apply to the same entity.
+func entityUpdaterFromFlags(flags *pflag.FlagSet) (func(*storemod.EntityQuery), bool, error) {
+	name, err := flags.GetString("entity-name")
+	if err != nil {
+		panic(err)
+	}
+
+	roleText, err := flags.GetString("entity-role")
+	if err != nil {
+		panic(err)
+	}
+
+	regID, err := flags.GetString("entity-regid")
+	if err != nil {
+		panic(err)
+	}
+
+	manifestEntity, err := flags.GetBool("manifest-entity")
+	if err != nil {
+		panic(err)
+	}
+
+	if name == "" && roleText == "" && regID == "" {
+		if manifestEntity {
+			return nil, false, errors.New("--manifest-entity requires at least one of --entity-*")
+		}
+
+		return nil, false, nil
+	}
+
+	role := parseEntityRole(roleText)
+
+	return func(q *storemod.EntityQuery) {
+		if name != "" {
+			q.NameValue(name)
+		}
+
+		if role != "" {
+			q.Role(role)
+		}
+
+		if regID != "" {
+			q.URI(regID)
+		}
+	}, manifestEntity, nil
+}
+
+// parseEntityRole returns the role as it is stored, accepting the
+// spellings used by the CoRIM spec (e.g. "tag-creator") as well as the stored
+// ones (e.g. "tagCreator"). Unrecognized roles (e.g. "Role(4)") are returned
+// unchanged.
+func parseEntityRole(text string) string {
+	normalized := strings.ReplaceAll(util.Normalize(text), "_", "")
+
+	switch normalized {
+	case "tagcreator":
+		return "tagCreator"
+	case "creator":
+		return "creator"
+	case "maintainer":
+		return "maintainer"
+	case "manifestcreator":
+		return "manifestCreator"
+	case "manifestsigner":
+		return "manifestSigner"
+	default:
+		return text
+	}
+}
+
+// checkEnvironmentIDTyping checks that either all or none of the values of a
+// repeated environment ID flag match the type (see parseEnvironmentID). IDs
+// that match the type and IDs that only match the value are matched by
+// different fields of the query, which are combined conjunctively, so mixing
+// them would never match anything.
+func checkEnvironmentIDTyping(texts []string) error {
+	for _, text := range texts[min(len(texts), 1):] {
+		if environmentIDUsesType(text) != environmentIDUsesType(texts[0]) {
+			return fmt.Errorf(
+				"cannot mix IDs that match the type with IDs that only match the value (%q and %q)",
+				texts[0], text,
+			)
+		}
+	}
+
+	return nil
+}
+
+// environmentIDUsesType returns true if the type of an environment ID
+// specified as [TYPE[*]:]VALUE is to be matched, i.e. if TYPE is specified
+// without a "*" suffix.
+func environmentIDUsesType(text string) bool {
+	typeText, _, found := strings.Cut(text, ":")
+	return found && !strings.HasSuffix(typeText, "*")
+}
+
+// parseEnvironmentID parses an environment ID (class, instance, or group)
+// specified as [TYPE[*]:]VALUE, and constructs it using newID, so that it is
+// encoded in the same way as IDs added from CoRIMs. "uuid" and "oid" values
+// are in their usual textual forms, and "hex" values are hex-encoded bytes.
+// Otherwise, the value is base64-encoded or, if it is not valid base64, in the
+// textual form accepted by the constructor for TYPE (e.g. PEM for
+// "pkix-base64-key"); it defaults to bytes if TYPE is not specified. A "*"
+// suffix on the TYPE means that only the bytes, and not the type, should be
+// matched, which is also the case if TYPE is not specified at all.
+func parseEnvironmentID[T any](text string, newID func(any, string) (T, error)) (T, bool, error) {
+	var zero T
 	var typeText string
 	var valueText string
 	var useType bool
@@ -563,25 +916,56 @@ func parseEnvironmentID(text string) ([]byte, string, bool, error) {
 
 	switch typeText {
 	case "uuid":
-		ret, err := uuid.Parse(valueText)
-		return ret[:], "uuid", useType, err
+		value, err := uuid.Parse(valueText)
+		if err != nil {
+			return zero, false, err
+		}
+
+		ret, err := newID(value, comid.UUIDType)
+		return ret, useType, err
 	case "oid":
-		var ret comid.OID
-		if err := ret.FromString(valueText); err != nil {
-			return nil, "oid", false, err
+		var value comid.OID
+		if err := value.FromString(valueText); err != nil {
+			return zero, false, err
 		}
-		return []byte(ret), "oid", useType, nil
+
+		ret, err := newID(value, comid.OIDType)
+		return ret, useType, err
 	case "hex":
-		ret, err := hex.DecodeString(valueText)
-		return ret, "bytes", useType, err
-	default: // assume base64
-		// remove padding
-		valueText = strings.Trim(valueText, "=")
-		// if URL, convert to standard
-		valueText = strings.ReplaceAll(valueText, "-", "+")
-		valueText = strings.ReplaceAll(valueText, "_", "/")
-
-		ret, err := base64.RawStdEncoding.DecodeString(valueText)
-		return ret, typeText, useType, err
+		value, err := hex.DecodeString(valueText)
+		if err != nil {
+			return zero, false, err
+		}
+
+		ret, err := newID(value, comid.BytesType)
+		return ret, useType, err
+	case "":
+		value, err := decodeBase64(valueText)
+		if err != nil {
+			return zero, false, err
+		}
+
+		ret, err := newID(value, comid.BytesType)
+		return ret, useType, err
+	default:
+		var value any = valueText
+		if decoded, err := decodeBase64(valueText); err == nil {
+			value = decoded
+		}
+
+		ret, err := newID(value, typeText)
+		return ret, useType, err
 	}
 }
+
+// decodeBase64 decodes standard or URL-safe base64 text, with or without
+// padding.
+func decodeBase64(text string) ([]byte, error) {
+	// remove padding
+	text = strings.Trim(text, "=")
+	// if URL, convert to standard
+	text = strings.ReplaceAll(text, "-", "+")
+	text = strings.ReplaceAll(text, "_", "/")
+
+	return base64.RawStdEncoding.DecodeString(text)
+}
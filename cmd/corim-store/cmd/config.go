@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/veraison/corim-store/pkg/db"
@@ -16,10 +17,25 @@ type Config struct {
 	Force        bool
 	HashAlg      string
 	RequireLabel bool
-
-	DBMS     string
-	DSN      string
-	TraceSQL bool
+	ReadOnly     bool
+
+	DBMS       string
+	DSN        string
+	ReplicaDSN string
+	TraceSQL   bool
+
+	MaxOpenConns      int
+	MaxIdleConns      int
+	ConnMaxLifetime   time.Duration
+	ConnMaxIdleTime   time.Duration
+	SQLiteJournalMode string
+	SQLiteBusyTimeout time.Duration
+	StatementTimeout  time.Duration
+	ConnectAttempts   int
+	ConnectBackoff    time.Duration
+
+	ExpiryWarning    time.Duration
+	ExpiredRetention time.Duration
 
 	err error
 }
@@ -42,19 +58,39 @@ func (o *Config) Store() *store.Config {
 		Force:        o.Force,
 		HashAlg:      o.HashAlg,
 		RequireLabel: o.RequireLabel,
-		Config: db.Config{
-			DBMS:     o.DBMS,
-			DSN:      o.DSN,
-			TraceSQL: o.TraceSQL,
-		},
+		ReadOnly:     o.ReadOnly,
+		ReplicaDSN:   o.ReplicaDSN,
+		Config:       *o.DB(),
+
+		ExpiryWarning:    o.ExpiryWarning,
+		ExpiredRetention: o.ExpiredRetention,
 	}
 }
 
+// CheckWritable returns store.ErrReadOnly if the configuration does not
+// permit modifying the database.
+func (o *Config) CheckWritable() error {
+	if o.ReadOnly {
+		return store.ErrReadOnly
+	}
+
+	return nil
+}
+
 func (o *Config) DB() *db.Config {
 	return &db.Config{
-		DBMS:     o.DBMS,
-		DSN:      o.DSN,
-		TraceSQL: o.TraceSQL,
+		DBMS:              o.DBMS,
+		DSN:               o.DSN,
+		TraceSQL:          o.TraceSQL,
+		MaxOpenConns:      o.MaxOpenConns,
+		MaxIdleConns:      o.MaxIdleConns,
+		ConnMaxLifetime:   o.ConnMaxLifetime,
+		ConnMaxIdleTime:   o.ConnMaxIdleTime,
+		SQLiteJournalMode: o.SQLiteJournalMode,
+		SQLiteBusyTimeout: o.SQLiteBusyTimeout,
+		StatementTimeout:  o.StatementTimeout,
+		ConnectAttempts:   o.ConnectAttempts,
+		ConnectBackoff:    o.ConnectBackoff,
 	}
 }
 
@@ -97,10 +133,25 @@ func (o *Config) Init(path string) {
 	o.Insecure = v.GetBool("insecure")
 	o.Force = v.GetBool("force")
 	o.RequireLabel = v.GetBool("require-label")
+	o.ReadOnly = v.GetBool("read-only")
 	o.DBMS = v.GetString("dbms")
 	o.DSN = v.GetString("dsn")
+	o.ReplicaDSN = v.GetString("replica-dsn")
 	o.TraceSQL = v.GetBool("trace-sql")
 
+	o.MaxOpenConns = v.GetInt("max-open-conns")
+	o.MaxIdleConns = v.GetInt("max-idle-conns")
+	o.ConnMaxLifetime = v.GetDuration("conn-max-lifetime")
+	o.ConnMaxIdleTime = v.GetDuration("conn-max-idle-time")
+	o.SQLiteJournalMode = v.GetString("sqlite-journal-mode")
+	o.SQLiteBusyTimeout = v.GetDuration("sqlite-busy-timeout")
+	o.StatementTimeout = v.GetDuration("statement-timeout")
+	o.ConnectAttempts = v.GetInt("connect-attempts")
+	o.ConnectBackoff = v.GetDuration("connect-backoff")
+
+	o.ExpiryWarning = v.GetDuration("expiry-warning")
+	o.ExpiredRetention = v.GetDuration("expired-retention")
+
 	o.HashAlg = v.GetString("hash-alg")
 	if o.HashAlg == "" {
 		o.HashAlg = "sha256"
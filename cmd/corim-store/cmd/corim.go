@@ -37,6 +37,12 @@ var addCmd = &cobra.Command{
 The specified CoRIM(s) will be parsed and added as a "manifest" to the store.
 Currently, CoRIMs containing only CoMID tags, and CoMID tags containing only
 reference-triple's, endorsed-triple's, and attest-key-triple's, are supported.
+
+By default, each CoRIM is added in its own transaction. With --batch, CoRIMs
+are decoded and validated concurrently (see --workers), and are then added
+inside a single transaction. In that case, CoRIMs that could not be added are
+reported at the end, and the remaining ones are committed, unless --atomic is
+also specified, in which case nothing is added if any CoRIM fails.
 	`,
 	Args: cobra.MinimumNArgs(1),
 
@@ -93,12 +99,21 @@ func runAddCommand(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	batch, err := cmd.Flags().GetBool("batch")
+	if err != nil {
+		return err
+	}
+
 	store, err := storemod.Open(context.Background(), cliConfig.Store())
 	if err != nil {
 		return err
 	}
 	defer func() { CheckErr(store.Close()) }()
 
+	if batch {
+		return runBatchAdd(cmd, store, keyStore, args, label, activate)
+	}
+
 	for _, path := range args {
 		bytes, err := os.ReadFile(path)
 		if err != nil {
@@ -122,6 +137,67 @@ func runAddCommand(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runBatchAdd(
+	cmd *cobra.Command,
+	store *storemod.Store,
+	keyStore util.KeyStore,
+	paths []string,
+	label string,
+	activate bool,
+) error {
+	atomic, err := cmd.Flags().GetBool("atomic")
+	if err != nil {
+		return err
+	}
+
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		return err
+	}
+
+	items := make([]storemod.BatchItem, 0, len(paths))
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		items = append(items, storemod.BatchItem{Name: path, Data: bytes})
+	}
+
+	opts := storemod.BatchOptions{
+		Label:    label,
+		Activate: activate,
+		Atomic:   atomic,
+		Workers:  workers,
+		Keys:     keyStore,
+		Progress: printBatchProgress,
+	}
+
+	report, err := store.AddBatch(items, &opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	for _, itemErr := range report.Errors() {
+		fmt.Printf("%s: %s\n", Amber("failed"), itemErr)
+	}
+
+	msg := fmt.Sprintf("added %d, failed %d", report.Added, report.Failed)
+	if report.Failed != 0 {
+		fmt.Println(Amber(msg))
+	} else {
+		fmt.Println(Green(msg))
+	}
+
+	return nil
+}
+
+func printBatchProgress(stage storemod.BatchStage, done, total int) {
+	fmt.Fprintf(os.Stderr, "\r%-6s %d/%d (%d%%)", stage, done, total, done*100/total)
+}
+
 func runDumpCommand(cmd *cobra.Command, args []string) error {
 	label, err := cmd.Flags().GetString("label")
 	if err != nil {
@@ -291,6 +367,12 @@ func init() {
 	addCmd.Flags().StringP("key", "k", "", "Public key use to verify signatures on signed CoRIMs.")
 	addCmd.Flags().StringArrayP("root-cert", "r", []string{},
 		"Root certificate used to validate x5chain COSE header (may be specified multiple times).")
+	addCmd.Flags().BoolP("batch", "b", false,
+		"Decode CoRIMs concurrently and add them inside a single transaction.")
+	addCmd.Flags().Bool("atomic", false,
+		"With --batch, do not add any CoRIMs if any one of them fails.")
+	addCmd.Flags().IntP("workers", "w", 0,
+		"With --batch, the number of concurrent decoders (defaults to the number of CPUs).")
 
 	deleteCmd.Flags().BoolP("corim", "C", false,
 		"force interpretation the positional argument as a path to CoRIM")
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -18,6 +19,7 @@ import (
 	"github.com/veraison/corim-store/pkg/db"
 	"github.com/veraison/corim-store/pkg/migrations"
 	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/store"
 )
 
 var dbCmd = &cobra.Command{
@@ -30,6 +32,8 @@ var initCmd = &cobra.Command{
 	Short: "Initialize the migrations meta-tables.",
 
 	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
 		db, err := db.Open(cliConfig.DB())
 		CheckErr(err)
 		defer func() { CheckErr(db.Close()) }()
@@ -51,6 +55,8 @@ var migrateCmd = &cobra.Command{
 	Short: "Migrate database schema to the latest version.",
 
 	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
 		db, err := db.Open(cliConfig.DB())
 		CheckErr(err)
 		defer func() { CheckErr(db.Close()) }()
@@ -77,6 +83,8 @@ var rollbackCmd = &cobra.Command{
 	Short: "Roll back the last migration group.",
 
 	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
 		db, err := db.Open(cliConfig.DB())
 		CheckErr(err)
 		defer func() { CheckErr(db.Close()) }()
@@ -136,6 +144,8 @@ var clearCmd = &cobra.Command{
 	Short: "Clear all data from the database",
 
 	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
 		db, err := db.Open(cliConfig.DB())
 		CheckErr(err)
 		defer func() { CheckErr(db.Close()) }()
@@ -146,6 +156,191 @@ var clearCmd = &cobra.Command{
 	},
 }
 
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove environments that are no longer referenced by any triples.",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		count, err := store.CollectGarbage()
+		CheckErr(err)
+
+		fmt.Printf("removed %d environment(s)\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the consistency of the store's contents.",
+	Long: `Check the consistency of the store's contents.
+
+Manifests are re-derived from their tokens and compared against the stored
+manifests, manifest digests are validated using the configured hash algorithm,
+and rows referencing non-existent rows (e.g. left behind by interrupted
+ingestion) are identified, as are environments with incorrect reference
+counts. If --repair is specified, issues are repaired where possible.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		repair, err := cmd.Flags().GetBool("repair")
+		CheckErr(err)
+
+		if repair {
+			CheckErr(cliConfig.CheckWritable())
+		}
+
+		opts := store.VerifyOptions{Repair: repair}
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		report, err := store.Verify(&opts)
+		CheckErr(err)
+
+		for _, issue := range report.Issues {
+			if issue.Repaired {
+				fmt.Printf("%s (%s)\n", issue, Green("repaired"))
+			} else {
+				fmt.Println(issue)
+			}
+		}
+
+		fmt.Printf("checked %d manifest(s), found %d issue(s)\n", report.ManifestsChecked, len(report.Issues))
+
+		if report.NumUnrepaired() == 0 {
+			fmt.Println(Green("ok"))
+		} else {
+			fmt.Println(Amber(fmt.Sprintf("%d unrepaired issue(s)", report.NumUnrepaired())))
+		}
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Display statistics about the contents of the store.",
+	Long: `Display statistics about the contents of the store.
+
+Counts of manifests, module tags, tokens, and active and inactive triples of
+each type are reported for each label/profile, along with the total numbers
+of environments, measurements, and tokens, the manifests that will expire
+within the specified number of days (or have already expired), and the number
+of rows and (where supported by the DBMS) size of each table.`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		days, err := cmd.Flags().GetInt("expiring-within")
+		CheckErr(err)
+
+		if days < 0 {
+			CheckErr(fmt.Errorf("invalid number of days: %d", days))
+		}
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		stats, err := store.Stats(time.Duration(days) * 24 * time.Hour)
+		CheckErr(err)
+
+		var groupRows, tripleRows [][]any
+		for _, group := range stats.Groups {
+			groupRows = append(groupRows, []any{
+				group.Label, group.Profile, group.Manifests, group.ModuleTags, group.Tokens,
+			})
+
+			for _, triples := range group.Triples {
+				tripleRows = append(tripleRows, []any{
+					group.Label, group.Profile, triples.Type, triples.Active, triples.Inactive,
+				})
+			}
+		}
+
+		fmt.Println(renderTable([]any{"label", "profile", "manifests", "module_tags", "tokens"}, groupRows))
+		fmt.Println(renderTable([]any{"label", "profile", "triple_type", "active", "inactive"}, tripleRows))
+
+		fmt.Printf("environments: %d\n", stats.Environments)
+		fmt.Printf("measurements: %d\n", stats.Measurements)
+		fmt.Printf("      tokens: %d\n", stats.Tokens)
+
+		var expiringRows [][]any
+		for _, manifest := range stats.Expiring {
+			expiringRows = append(expiringRows, []any{
+				manifest.Label, manifest.ManifestID, manifest.Profile, formatTimeColumn(&manifest.NotAfter),
+			})
+		}
+
+		fmt.Printf("\nmanifests expiring within %d day(s):\n", days)
+		fmt.Println(renderTable([]any{"label", "manifest_id", "profile", "not_after"}, expiringRows))
+
+		var tableRows [][]any
+		for _, table := range stats.Tables {
+			size := "n/a"
+			if table.Size != nil {
+				size = fmt.Sprint(*table.Size)
+			}
+
+			tableRows = append(tableRows, []any{table.Name, table.Rows, size})
+		}
+
+		fmt.Println(renderTable([]any{"table", "rows", "size"}, tableRows))
+
+		if len(stats.Expiring) == 0 {
+			fmt.Println(Green("ok"))
+		} else {
+			fmt.Println(Amber(fmt.Sprintf("%d manifest(s) expiring", len(stats.Expiring))))
+		}
+	},
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup FILE",
+	Short: "Back up the contents of the store to a DBMS-independent archive.",
+	Long: `Back up the contents of the store to a DBMS-independent archive.
+
+The archive contains the original tokens added to the store, along with the
+labels, activation state, and times added of their manifests. It can be
+restored into a store backed by any supported DBMS using the restore command.`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		count, err := backupToFile(store, args[0])
+		CheckErr(err)
+
+		fmt.Printf("backed up %d entries\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore FILE",
+	Short: "Restore the contents of an archive created by the backup command.",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		file, err := os.Open(args[0])
+		CheckErr(err)
+		defer func() { CheckErr(file.Close()) }()
+
+		count, err := store.Restore(file)
+		CheckErr(err)
+
+		fmt.Printf("restored %d entries\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
 var schemaCmd = &cobra.Command{
 	Use:   "schema",
 	Short: "Display database schema as SQL.",
@@ -190,6 +385,8 @@ var loadFixturesCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
 		truncate, err := cmd.Flags().GetBool("truncate")
 		CheckErr(err)
 
@@ -283,6 +480,32 @@ var tableSelectors = []string{
 	"tokens",
 }
 
+// backupToFile backs up the store to a temporary file in the same directory as
+// path, which is then renamed to path. This way, a failed backup does not
+// leave a partial archive behind, or clobber an existing one.
+func backupToFile(st *store.Store, path string) (int, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := st.Backup(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func selectModels(pflags *pflag.FlagSet, db bun.IDB, ctx context.Context) ([]any, error) {
 	all, err := pflags.GetBool("all")
 	if err != nil {
@@ -608,6 +831,9 @@ func fixUpEncoderOutput(text string) (string, error) {
 }
 
 func init() {
+	statsCmd.Flags().Int("expiring-within", 30,
+		"Report manifests expiring within the specified number of days.")
+	checkCmd.Flags().Bool("repair", false, "Repair the issues found, where possible.")
 	loadFixturesCmd.Flags().Bool("truncate", false, "Truncate existing data before loading the fixtures.")
 
 	saveFixturesCmd.Flags().Bool("all", false, "Select all tables.")
@@ -629,6 +855,11 @@ func init() {
 	dbCmd.AddCommand(schemaCmd)
 	dbCmd.AddCommand(fixturesCmd)
 	dbCmd.AddCommand(clearCmd)
+	dbCmd.AddCommand(gcCmd)
+	dbCmd.AddCommand(checkCmd)
+	dbCmd.AddCommand(statsCmd)
+	dbCmd.AddCommand(backupCmd)
+	dbCmd.AddCommand(restoreCmd)
 
 	rootCmd.AddCommand(dbCmd)
 }
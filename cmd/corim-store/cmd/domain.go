@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/veraison/corim-store/pkg/model"
+	storemod "github.com/veraison/corim-store/pkg/store"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+var domainCmd = &cobra.Command{
+	Use:   "domain",
+	Short: "Inspect the domains established by domain triples.",
+}
+
+var domainTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Render the domain hierarchy reachable from the matching environments.",
+	Long: `Render the domain hierarchy reachable from the matching environments.
+
+The environments are specified either using --id, or using the environment
+flags. A tree is rendered for each matching environment, following active
+domain triples selected by --relation:
+
+    members      from domains to their members (membership triples)
+    memberships  from members to their domains (membership triples)
+    dependencies from domains to their trustees (dependency triples)
+
+Each link is annotated with the ID of the triple establishing it. Each
+environment is expanded once, where it first appears in the tree. Links that
+close a cycle (i.e. that lead to an environment reached before them, from which
+the environment they start from can be reached) are marked with "(cycle)";
+other links leading to an environment that has already been shown are marked
+with "(already shown)".`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(runDomainTreeCommand(cmd.Flags()))
+	},
+}
+
+func runDomainTreeCommand(flags *pflag.FlagSet) error {
+	relationText, err := flags.GetString("relation")
+	if err != nil {
+		panic(err)
+	}
+
+	relation, err := parseDomainRelation(relationText)
+	if err != nil {
+		return err
+	}
+
+	id, err := flags.GetInt64("id")
+	if err != nil {
+		panic(err)
+	}
+
+	store, err := storemod.Open(context.Background(), cliConfig.Store())
+	if err != nil {
+		return err
+	}
+	defer func() { CheckErr(store.Close()) }()
+
+	var ids []int64
+	if id != 0 {
+		ids = append(ids, id)
+	} else {
+		query := storemod.NewEnvironmentQuery(false)
+		if err := updateEnvironmentQueryFromFlags(query, flags); err != nil {
+			return err
+		}
+
+		if query.IsEmpty() {
+			return errors.New("no environment specified")
+		}
+
+		envs, err := store.QueryEnvironmentModels(query)
+		if err != nil {
+			return err
+		}
+
+		for _, env := range envs {
+			ids = append(ids, env.ID)
+		}
+	}
+
+	for i, id := range ids {
+		graph, err := store.TraverseDomains(id, relation)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+
+		text, err := renderDomainGraph(graph)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(text)
+	}
+
+	return nil
+}
+
+func parseDomainRelation(text string) (storemod.DomainRelation, error) {
+	switch util.Normalize(text) {
+	case "members", "member":
+		return storemod.DomainMembers, nil
+	case "memberships", "membership":
+		return storemod.DomainMemberships, nil
+	case "dependencies", "dependency":
+		return storemod.DomainDependencies, nil
+	default:
+		return 0, fmt.Errorf("unexpected domain relation: %s", text)
+	}
+}
+
+// renderDomainGraph renders the graph as a tree rooted at the environment the
+// traversal started from. Each environment is expanded once, where it first
+// appears in the tree; further links leading to it are marked as either
+// closing a cycle or leading to an environment that has already been shown.
+func renderDomainGraph(graph *storemod.DomainGraph) (string, error) {
+	var builder strings.Builder
+
+	rootText, err := formatEnvironmentLine(graph.Root)
+	if err != nil {
+		return "", err
+	}
+
+	builder.WriteString(rootText + "\n")
+
+	children := make(map[int64][]*storemod.DomainLink)
+	for _, link := range graph.Links {
+		children[link.FromID] = append(children[link.FromID], link)
+	}
+
+	shown := map[int64]bool{graph.Root.ID: true}
+
+	var render func(environmentID int64, prefix string) error
+	render = func(environmentID int64, prefix string) error {
+		links := children[environmentID]
+
+		for i, link := range links {
+			branch, indent := "├── ", "│   "
+			if i == len(links)-1 {
+				branch, indent = "└── ", "    "
+			}
+
+			envText, err := formatEnvironmentLine(graph.Environments[link.ToID])
+			if err != nil {
+				return err
+			}
+
+			line := fmt.Sprintf("%s%s%s [triple %d]", prefix, branch, envText, link.TripleDbID)
+
+			expand := false
+			switch {
+			case link.Cycle:
+				line += " (cycle)"
+			case shown[link.ToID]:
+				line += " (already shown)"
+			default:
+				shown[link.ToID] = true
+				expand = true
+			}
+
+			builder.WriteString(line + "\n")
+
+			if expand {
+				if err := render(link.ToID, prefix+indent); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := render(graph.Root.ID, ""); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
+
+// formatEnvironmentLine is like formatEnvironment, but renders the environment
+// on a single line.
+func formatEnvironmentLine(env *model.Environment) (string, error) {
+	if env == nil {
+		return "<unknown environment>", nil
+	}
+
+	parts, err := env.RenderParts()
+	if err != nil {
+		return "", err
+	}
+
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		texts = append(texts, fmt.Sprintf("%s: %s", part[0], part[1]))
+	}
+
+	return fmt.Sprintf("(%d) %s", env.ID, strings.Join(texts, ", ")), nil
+}
+
+func init() {
+	AddEnvironmentFlags(domainTreeCmd)
+	domainTreeCmd.Flags().Int64("id", 0, "Database ID of the environment.")
+	domainTreeCmd.Flags().StringP("relation", "r", "members",
+		`Relation to follow: "members", "memberships", or "dependencies".`)
+
+	domainCmd.AddCommand(domainTreeCmd)
+
+	rootCmd.AddCommand(domainCmd)
+}
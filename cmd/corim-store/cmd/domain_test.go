@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	storemod "github.com/veraison/corim-store/pkg/store"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+func TestRenderDomainGraph_diamonds(t *testing.T) {
+	newEnv := func(vendor string) comid.Environment {
+		return comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor(vendor)}
+	}
+
+	// The same chain of diamonds as in TestStore_DomainGraph_diamonds:
+	// each level's domain depends on two domains, which both depend on the
+	// next level's domain.
+	const numDiamonds = 20
+
+	triples := comid.DomainDependencyTriples{}
+	for i := range numDiamonds {
+		top := newEnv(fmt.Sprintf("top-%d", i))
+		left, right := newEnv(fmt.Sprintf("left-%d", i)), newEnv(fmt.Sprintf("right-%d", i))
+		bottom := newEnv(fmt.Sprintf("top-%d", i+1))
+
+		triples = append(triples,
+			comid.DomainDependencyTriple{DomainID: top, Trustees: []comid.Environment{left, right}},
+			comid.DomainDependencyTriple{DomainID: left, Trustees: []comid.Environment{bottom}},
+			comid.DomainDependencyTriple{DomainID: right, Trustees: []comid.Environment{bottom}},
+		)
+	}
+
+	tag := comid.NewComid().SetTagIdentity("diamonds", 0)
+	tag.Triples.DomainDependencies = &triples
+
+	store, err := storemod.OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	manifest := corim.NewUnsignedCorim().SetID("diamonds").AddComid(tag)
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "", true))
+
+	root := newEnv("top-0")
+	graph, err := store.DomainClosure(&root)
+	require.NoError(t, err)
+
+	text, err := renderDomainGraph(graph)
+	require.NoError(t, err)
+
+	// one line for the root, and one for each link
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	require.Len(t, lines, len(graph.Links)+1)
+
+	// the second link to the bottom of each diamond is not expanded again
+	numReached := storemod.MaxDomainDepth / 2
+	assert.Equal(t, numReached, strings.Count(text, "(already shown)"))
+	assert.NotContains(t, text, "(cycle)")
+
+	for id, env := range graph.Environments {
+		envText, err := formatEnvironmentLine(env)
+		require.NoError(t, err)
+
+		if strings.HasPrefix(*env.Vendor, "top-") {
+			assert.Equal(t, 2, strings.Count(text, envText), "environment %d", id)
+		} else {
+			assert.Equal(t, 1, strings.Count(text, envText), "environment %d", id)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	storemod "github.com/veraison/corim-store/pkg/store"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+var findCmd = &cobra.Command{
+	Use:   "find WHAT VALUE",
+	Short: "Find the entries containing a value.",
+	Long: `Find the entries containing a value.
+
+The WHAT can be "digest", in which case VALUE is a hex-encoded digest. Digests
+are searched in measurements, integrity registers, and dependent RIM locators,
+regardless of their algorithm. For each digest found, the triple, environment,
+module tag, manifest, and label containing it are listed.`,
+	Args: cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(runFindCommand(cmd, args))
+	},
+}
+
+func runFindCommand(cmd *cobra.Command, args []string) error {
+	what := util.Normalize(args[0])
+
+	store, err := storemod.Open(context.Background(), cliConfig.Store())
+	if err != nil {
+		return err
+	}
+	defer func() { CheckErr(store.Close()) }()
+
+	var header []any
+	var rows [][]any
+
+	switch what {
+	case "digest":
+		header, rows, err = findDigest(store, args[1])
+	default:
+		return fmt.Errorf("unsupported find target: %s", what)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(renderTable(header, rows))
+
+	return nil
+}
+
+func findDigest(store *storemod.Store, text string) ([]any, [][]any, error) {
+	value, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(text), "0x"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid digest: %w", err)
+	}
+
+	matches, err := store.FindByDigest(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := []any{"label", "source", "triple", "active", "environment", "location", "algorithm"}
+	rows := make([][]any, 0, len(matches))
+	for _, match := range matches {
+		source := fmt.Sprintf("manifest: %s", match.Manifest.ManifestID)
+		if match.ModuleTag != nil {
+			source += fmt.Sprintf("\nmodule: %s", match.ModuleTag.ModuleTagID)
+		}
+
+		var triple, envText string
+		if match.TripleType != "" {
+			triple = fmt.Sprintf("%s(%d)", match.TripleType, match.TripleDbID)
+		}
+
+		if match.Environment != nil {
+			envText, err = formatEnvironment(match.Environment)
+			if err != nil {
+				return nil, nil, fmt.Errorf("environment for digest %d: %w", match.Digest.ID, err)
+			}
+		}
+
+		var location string
+		switch {
+		case match.Locator != nil:
+			hrefs := make([]string, 0, len(match.Locator.Href))
+			for _, href := range match.Locator.Href {
+				hrefs = append(hrefs, href.Value)
+			}
+			location = "locator: " + strings.Join(hrefs, "\n")
+		case match.IntegrityRegister != nil:
+			location = fmt.Sprintf("measurement(%d)\nregister: %s",
+				match.Measurement.ID, match.IntegrityRegister.StringIndex())
+		default:
+			location = fmt.Sprintf("measurement(%d)", match.Measurement.ID)
+		}
+
+		rows = append(rows, []any{
+			match.Label,
+			source,
+			triple,
+			match.IsActive,
+			envText,
+			location,
+			match.Digest.AlgIDString(),
+		})
+	}
+
+	return columns, rows, nil
+}
+
+func init() {
+	rootCmd.AddCommand(findCmd)
+}
@@ -25,7 +25,7 @@ In addition to environment matching, flags can be used to specify that you only
 want to get active triples, and/or only reference values or only trust anchors
 (by default, all triples with matching environments will be returned).
 
-The triples are returned encoded as JSON.` + flagsHelp + timeHelp,
+The triples are returned encoded as JSON.` + flagsHelp + timeHelp + queryHelp,
 	Args: cobra.NoArgs,
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -47,13 +47,16 @@ func runGetCommand(cmd *cobra.Command, args []string) error {
 
 	var result comid.Triples
 
-	if selector.Endorsements || selector.ReferenceValues {
-		query, err := BuildValueTripleQuery(cmd.Flags())
-		if err != nil {
-			return err
-		}
+	keyQuery, keyErr := buildQuery(cmd.Flags(), storemod.ParseKeyTripleQuery, BuildKeyTripleQuery)
+	valueQuery, valueErr := buildQuery(cmd.Flags(), storemod.ParseValueTripleQuery, BuildValueTripleQuery)
 
-		found, err := store.QueryValueTripleModels(query)
+	skipKeys, skipValues, err := checkTripleQueryErrors(keyErr, valueErr)
+	if err != nil {
+		return err
+	}
+
+	if (selector.Endorsements || selector.ReferenceValues) && !skipValues {
+		found, err := store.QueryValueTripleModels(valueQuery)
 		if err != nil {
 			return err
 		}
@@ -69,13 +72,8 @@ func runGetCommand(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if selector.TrustAnchors {
-		query, err := BuildKeyTripleQuery(cmd.Flags())
-		if err != nil {
-			return err
-		}
-
-		found, err := store.QueryKeyTripleModels(query)
+	if selector.TrustAnchors && !skipKeys {
+		found, err := store.QueryKeyTripleModels(keyQuery)
 		if err != nil {
 			return err
 		}
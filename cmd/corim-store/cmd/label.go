@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/veraison/corim-store/pkg/store"
+)
+
+var labelCmd = &cobra.Command{
+	Use:   "label",
+	Short: "Manage the labels under which manifests are stored.",
+	Long: `Manage the labels under which manifests are stored.
+
+Manifests added without a label may be referred to using an empty label ("").`,
+}
+
+var labelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List labels, along with the number of entries under each.",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		labels, err := store.ListLabels()
+		CheckErr(err)
+
+		rows := make([][]any, 0, len(labels))
+		for _, info := range labels {
+			rows = append(rows, []any{
+				info.Label, info.Manifests, info.ModuleTags, info.ActiveTriples, info.InactiveTriples,
+			})
+		}
+
+		header := []any{"label", "manifests", "module_tags", "active_triples", "inactive_triples"}
+		fmt.Println(renderTable(header, rows))
+	},
+}
+
+var labelRenameCmd = &cobra.Command{
+	Use:   "rename FROM TO",
+	Short: "Move all manifests under label FROM to label TO.",
+	Long: `Move all manifests under label FROM to label TO.
+
+If there are already manifests under label TO, the two labels are merged.`,
+	Args: cobra.ExactArgs(2),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		count, err := store.RenameLabel(args[0], args[1])
+		CheckErr(err)
+
+		fmt.Printf("moved %d manifest(s)\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
+var labelMoveCmd = &cobra.Command{
+	Use:   "move FROM TO MANIFEST_ID [MANIFEST_ID ...]",
+	Short: "Move the specified manifests from label FROM to label TO.",
+	Long: `Move the specified manifests from label FROM to label TO.
+
+This is atomic: if any of the manifests cannot be found under label FROM, none
+of them are moved.`,
+	Args: cobra.MinimumNArgs(3),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		count, err := store.MoveManifests(args[0], args[1], args[2:]...)
+		CheckErr(err)
+
+		fmt.Printf("moved %d manifest(s)\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
+var labelDeleteCmd = &cobra.Command{
+	Use:   "delete LABEL",
+	Short: "Delete all manifests under the specified label.",
+	Args:  cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		count, err := store.DeleteLabel(args[0])
+		CheckErr(err)
+
+		fmt.Printf("deleted %d manifest(s)\n", count)
+		fmt.Println(Green("ok"))
+	},
+}
+
+func init() {
+	labelCmd.AddCommand(labelListCmd)
+	labelCmd.AddCommand(labelRenameCmd)
+	labelCmd.AddCommand(labelMoveCmd)
+	labelCmd.AddCommand(labelDeleteCmd)
+
+	rootCmd.AddCommand(labelCmd)
+}
@@ -32,6 +32,10 @@ var deactivateCmd = &cobra.Command{
 }
 
 func setActiveCommand(cmd *cobra.Command, value bool) error {
+	if err := cliConfig.CheckWritable(); err != nil {
+		return err
+	}
+
 	store, err := store.Open(context.Background(), cliConfig.Store())
 	if err != nil {
 		return err
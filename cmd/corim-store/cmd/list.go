@@ -25,7 +25,7 @@ var listCmd = &cobra.Command{
 The WHAT can be "manifests"/"corims", "modules"/"module_tags"/"comids",
 "entities", or "triples" (slashes indicate alternate names for the same type
 of entry). When the  WHAT is \"triples\", flags can be used to filter the
-results by environment elements (e.g. by model or instance ID)."` + flagsHelp + timeHelp,
+results by environment elements (e.g. by model or instance ID)."` + flagsHelp + timeHelp + queryHelp,
 	Args: cobra.ExactArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -63,6 +63,13 @@ func runListCommand(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	fmt.Println(renderTable(header, rows))
+
+	return nil
+}
+
+// renderTable renders the provided rows as a table with the specified header.
+func renderTable(header []any, rows [][]any) string {
 	tw := table.NewWriter()
 	tw.AppendHeader(table.Row(header))
 	for _, row := range rows {
@@ -80,13 +87,11 @@ func runListCommand(cmd *cobra.Command, args []string) error {
 	tw.SetColumnConfigs(colConfigs)
 	tw.SetStyle(table.StyleLight)
 
-	fmt.Println(tw.Render())
-
-	return nil
+	return tw.Render()
 }
 
 func listManifests(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any, error) {
-	query, err := BuildManifestQuery(flags)
+	query, err := buildQuery(flags, storemod.ParseManifestQuery, BuildManifestQuery)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -121,7 +126,7 @@ func listManifests(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any,
 }
 
 func listModuleTags(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any, error) {
-	query, err := BuildModuleTagQuery(flags)
+	query, err := buildQuery(flags, storemod.ParseModuleTagQuery, BuildModuleTagQuery)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -134,7 +139,7 @@ func listModuleTags(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any
 	columns := []any{"tag_id", "version", "language", "entities", "manifest", "label"}
 	rows := make([][]any, 0, len(columns))
 	for _, entry := range entries {
-		moduleTag, err := entry.ToModuleTag(store.Ctx, store.DB)
+		moduleTag, err := entry.ToModuleTag(store.Ctx, store.ReadDB())
 		if err != nil {
 			return nil, nil, err
 		}
@@ -183,33 +188,39 @@ func listEntities(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any,
 }
 
 func listTriples(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any, error) {
-	keyQuery, err := BuildKeyTripleQuery(flags)
-	if err != nil {
-		return nil, nil, err
-	}
+	keyQuery, keyErr := buildQuery(flags, storemod.ParseKeyTripleQuery, BuildKeyTripleQuery)
+	valueQuery, valueErr := buildQuery(flags, storemod.ParseValueTripleQuery, BuildValueTripleQuery)
 
-	valueQuery, err := BuildValueTripleQuery(flags)
+	skipKeys, skipValues, err := checkTripleQueryErrors(keyErr, valueErr)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	keysMatched := true
-	keyTriples, err := store.QueryKeyTripleEntries(keyQuery)
-	if err != nil {
-		if errors.Is(err, storemod.ErrNoMatch) {
-			keysMatched = false
-		} else {
-			return nil, nil, err
+	var keyTriples []*model.KeyTripleEntry
+	keysMatched := false
+	if !skipKeys {
+		keysMatched = true
+		keyTriples, err = store.QueryKeyTripleEntries(keyQuery)
+		if err != nil {
+			if errors.Is(err, storemod.ErrNoMatch) {
+				keysMatched = false
+			} else {
+				return nil, nil, err
+			}
 		}
 	}
 
-	valuesMatched := true
-	valueTriples, err := store.QueryValueTripleEntries(valueQuery)
-	if err != nil {
-		if errors.Is(err, storemod.ErrNoMatch) {
-			valuesMatched = false
-		} else {
-			return nil, nil, err
+	var valueTriples []*model.ValueTripleEntry
+	valuesMatched := false
+	if !skipValues {
+		valuesMatched = true
+		valueTriples, err = store.QueryValueTripleEntries(valueQuery)
+		if err != nil {
+			if errors.Is(err, storemod.ErrNoMatch) {
+				valuesMatched = false
+			} else {
+				return nil, nil, err
+			}
 		}
 	}
 
@@ -220,7 +231,7 @@ func listTriples(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any, e
 	columns := []any{"id", "active", "label", "source", "type", "environment"}
 	rows := make([][]any, 0, len(valueTriples)+len(keyTriples))
 	for _, entry := range keyTriples {
-		model, err := entry.ToTriple(store.Ctx, store.DB)
+		model, err := entry.ToTriple(store.Ctx, store.ReadDB())
 		if err != nil {
 			return nil, nil, fmt.Errorf("key triple %d: %w", entry.TripleDbID, err)
 		}
@@ -241,7 +252,7 @@ func listTriples(store *storemod.Store, flags *pflag.FlagSet) ([]any, [][]any, e
 	}
 
 	for _, entry := range valueTriples {
-		model, err := entry.ToTriple(store.Ctx, store.DB)
+		model, err := entry.ToTriple(store.Ctx, store.ReadDB())
 		if err != nil {
 			return nil, nil, fmt.Errorf("key triple %d: %w", entry.TripleDbID, err)
 		}
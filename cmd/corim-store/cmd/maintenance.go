@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/veraison/corim-store/pkg/store"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Perform periodic maintenance of the store.",
+}
+
+var expireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Deactivate the triples of expired manifests.",
+	Long: `Deactivate the triples of expired manifests.
+
+The triples of manifests whose not_after is in the past are deactivated, so
+that they are no longer available to the verifier. Manifests that will expire
+within --expiry-warning are reported. If --expired-retention is set, manifests
+that expired longer ago than that are deleted.
+
+This command is idempotent, and is intended to be run periodically (e.g. from
+cron).`,
+	Args: cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		CheckErr(cliConfig.CheckWritable())
+
+		store, err := store.Open(context.Background(), cliConfig.Store())
+		CheckErr(err)
+		defer func() { CheckErr(store.Close()) }()
+
+		report, err := store.ExpireManifests(time.Now())
+		CheckErr(err)
+
+		for _, manifest := range report.Deleted {
+			fmt.Printf("deleted %s (label: %q, expired %s)\n",
+				manifest.ManifestID, manifest.Label, manifest.NotAfter.Format(time.RFC3339))
+		}
+
+		for _, manifest := range report.Expired {
+			fmt.Printf("deactivated %s (label: %q, expired %s): %s\n",
+				manifest.ManifestID, manifest.Label, manifest.NotAfter.Format(time.RFC3339),
+				formatTripleCounts(manifest.Deactivated))
+		}
+
+		for _, manifest := range report.Expiring {
+			fmt.Printf("%s: %s (label: %q) expires %s\n", Amber("expiring"),
+				manifest.ManifestID, manifest.Label, manifest.NotAfter.Format(time.RFC3339))
+		}
+
+		fmt.Println(Green("ok"))
+	},
+}
+
+func formatTripleCounts(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[name], name))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	maintenanceCmd.AddCommand(expireCmd)
+
+	rootCmd.AddCommand(maintenanceCmd)
+}
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/veraison/corim-store/pkg/build"
+	"github.com/veraison/corim-store/pkg/store"
 )
 
 var configFile string
@@ -63,6 +66,70 @@ func init() {
 			"the database server. The format of this string is DBMS-specific.",
 	)
 
+	rootCmd.PersistentFlags().String(
+		"replica-dsn", "", "Data Source Name of a read replica of the database. If specified, "+
+			"queries are made against the replica, while modifications are made via --dsn.",
+	)
+
+	rootCmd.PersistentFlags().Bool(
+		"read-only", false, "Open the store in read-only mode, rejecting any operations that "+
+			"would modify its contents.",
+	)
+
+	rootCmd.PersistentFlags().Int(
+		"max-open-conns", 0, "Maximum number of open database connections (0 means unlimited).",
+	)
+
+	rootCmd.PersistentFlags().Int(
+		"max-idle-conns", 0, "Maximum number of idle database connections retained (0 means use "+
+			"the default; a negative value means idle connections are not retained).",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"conn-max-lifetime", 0, "Maximum amount of time a database connection may be reused "+
+			"(0 means no limit).",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"conn-max-idle-time", 0, "Maximum amount of time a database connection may be idle "+
+			"(0 means no limit).",
+	)
+
+	rootCmd.PersistentFlags().String(
+		"sqlite-journal-mode", "", "SQLite journal mode (e.g. \"WAL\"). If not specified, the "+
+			"database's existing mode is used.",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"sqlite-busy-timeout", 0, "How long to wait for an SQLite lock to be released before "+
+			"failing with \"database is locked\".",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"statement-timeout", 0, "Maximum execution time of a statement on Postgres or "+
+			"MySQL/MariaDB (0 means no limit).",
+	)
+
+	rootCmd.PersistentFlags().Int(
+		"connect-attempts", 0, "Number of attempts to connect to the database on start up "+
+			"(0 means the connection is not checked until it is used).",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"connect-backoff", time.Second, "Delay before retrying to connect to the database "+
+			"(doubled on each subsequent attempt).",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"expiry-warning", store.DefaultExpiryWarning, "How long before their expiry manifests "+
+			"are reported as expiring by \"maintenance expire\".",
+	)
+
+	rootCmd.PersistentFlags().Duration(
+		"expired-retention", 0, "How long expired manifests are retained before being deleted "+
+			"by \"maintenance expire\" (0 means they are never deleted).",
+	)
+
 	rootCmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
 		if flag.Name == "config" {
 			// it doesn't make sense to bind the location of the config file to
@@ -3,10 +3,17 @@ package db
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/mysqldialect"
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -20,6 +27,72 @@ type Config struct {
 	DBMS     string
 	DSN      string
 	TraceSQL bool
+
+	// MaxOpenConns is the maximum number of open connections to the
+	// database. If zero, the number is unlimited.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections retained by
+	// the connection pool. If zero, the database/sql default (currently,
+	// 2) is used; if negative, idle connections are not retained.
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum amount of time a connection may be
+	// reused. If zero, connections are not closed due to their age.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is the maximum amount of time a connection may be
+	// idle. If zero, connections are not closed due to being idle.
+	ConnMaxIdleTime time.Duration
+
+	// SQLiteJournalMode, if set, specifies the journal mode (e.g. "WAL")
+	// of sqlite databases. (WAL mode allows readers to proceed
+	// concurrently with a writer.)
+	SQLiteJournalMode string
+	// SQLiteBusyTimeout, if set, is how long a connection to an sqlite
+	// database will wait for a lock to be released before failing with
+	// "database is locked".
+	SQLiteBusyTimeout time.Duration
+
+	// StatementTimeout, if set, is the maximum time a statement may
+	// execute for before being aborted by a Postgres or MariaDB server.
+	// (Note: MySQL only supports timeouts for read-only SELECT
+	// statements.)
+	StatementTimeout time.Duration
+
+	// ConnectAttempts is the number of times connecting to the database is
+	// attempted when it is opened. If zero, the connection is not checked
+	// on opening (and so errors will only be reported once it is used).
+	ConnectAttempts int
+	// ConnectBackoff is the delay before the second connection attempt;
+	// it is doubled for each subsequent attempt. Defaults to one second.
+	ConnectBackoff time.Duration
+}
+
+var sqliteJournalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+
+// Validate returns an error if the Config contains invalid values.
+func (o *Config) Validate() error {
+	if o.SQLiteJournalMode != "" &&
+		!slices.Contains(sqliteJournalModes, strings.ToUpper(o.SQLiteJournalMode)) {
+		return fmt.Errorf("invalid sqlite journal mode: %s", o.SQLiteJournalMode)
+	}
+
+	for _, field := range []struct {
+		name  string
+		value int64
+	}{
+		{"max open connections", int64(o.MaxOpenConns)},
+		{"connection lifetime", int64(o.ConnMaxLifetime)},
+		{"connection idle time", int64(o.ConnMaxIdleTime)},
+		{"sqlite busy timeout", int64(o.SQLiteBusyTimeout)},
+		{"statement timeout", int64(o.StatementTimeout)},
+		{"connect attempts", int64(o.ConnectAttempts)},
+		{"connect retry backoff", int64(o.ConnectBackoff)},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("invalid %s: must not be negative", field.name)
+		}
+	}
+
+	return nil
 }
 
 func Open(cfg *Config) (*bun.DB, error) {
@@ -28,15 +101,19 @@ func Open(cfg *Config) (*bun.DB, error) {
 	var err error
 	var dialect schema.Dialect
 
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	switch cfg.DBMS {
 	case "mysql", "mariadb":
-		sqldb, err = sql.Open("mysql", cfg.DSN)
+		sqldb, err = openMySQL(cfg)
 		dialect = mysqldialect.New()
 	case "sqlite", "sqlite3":
-		sqldb, err = sql.Open(sqliteshim.ShimName, cfg.DSN)
+		sqldb, err = openSQLite(cfg)
 		dialect = sqlitedialect.New()
 	case "postgres", "pq", "pgx", "pg":
-		sqldb, err = sql.Open("pgx", cfg.DSN)
+		sqldb, err = openPostgres(cfg)
 		dialect = pgdialect.New()
 	default:
 		return nil, fmt.Errorf("unsupported DBMS: %s", cfg.DBMS)
@@ -46,6 +123,27 @@ func Open(cfg *Config) (*bun.DB, error) {
 		return nil, err
 	}
 
+	if cfg.MaxOpenConns != 0 {
+		sqldb.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns != 0 {
+		sqldb.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if cfg.ConnMaxLifetime != 0 {
+		sqldb.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if cfg.ConnMaxIdleTime != 0 {
+		sqldb.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if err := checkConnection(sqldb, cfg); err != nil {
+		_ = sqldb.Close()
+		return nil, err
+	}
+
 	ret = bun.NewDB(sqldb, dialect)
 
 	if cfg.TraceSQL {
@@ -57,6 +155,132 @@ func Open(cfg *Config) (*bun.DB, error) {
 	return ret, nil
 }
 
+func openMySQL(cfg *Config) (*sql.DB, error) {
+	if cfg.StatementTimeout == 0 {
+		return sql.Open("mysql", cfg.DSN)
+	}
+
+	mysqlCfg, err := mysql.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if mysqlCfg.Params == nil {
+		mysqlCfg.Params = make(map[string]string)
+	}
+
+	// MariaDB and MySQL use different system variables (with different
+	// units) for statement timeouts.
+	if cfg.DBMS == "mariadb" {
+		mysqlCfg.Params["max_statement_time"] = strconv.FormatFloat(cfg.StatementTimeout.Seconds(), 'f', -1, 64)
+	} else {
+		mysqlCfg.Params["max_execution_time"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	connector, err := mysql.NewConnector(mysqlCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(connector), nil
+}
+
+func openPostgres(cfg *Config) (*sql.DB, error) {
+	if cfg.StatementTimeout == 0 {
+		return sql.Open("pgx", cfg.DSN)
+	}
+
+	pgxCfg, err := pgx.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	pgxCfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+
+	return stdlib.OpenDB(*pgxCfg), nil
+}
+
+func openSQLite(cfg *Config) (*sql.DB, error) {
+	var pragmas []string
+
+	if cfg.SQLiteBusyTimeout != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA busy_timeout = %d", cfg.SQLiteBusyTimeout.Milliseconds()))
+	}
+
+	if cfg.SQLiteJournalMode != "" {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA journal_mode = %s", strings.ToUpper(cfg.SQLiteJournalMode)))
+	}
+
+	if len(pragmas) == 0 {
+		return sql.Open(sqliteshim.ShimName, cfg.DSN)
+	}
+
+	return sql.OpenDB(&sqliteConnector{dsn: cfg.DSN, pragmas: pragmas}), nil
+}
+
+// sqliteConnector executes PRAGMA statements on each new connection. This is
+// necessary because some pragmas (e.g. busy_timeout) are connection-specific,
+// and the DSN parameters for setting them differ between the drivers that
+// may be used by sqliteshim.
+type sqliteConnector struct {
+	dsn     string
+	pragmas []string
+}
+
+func (o *sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := sqliteshim.Driver().Open(o.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		// coverage:ignore
+		_ = conn.Close()
+		return nil, errors.New("sqlite driver does not support ExecerContext")
+	}
+
+	for _, pragma := range o.pragmas {
+		if _, err := execer.ExecContext(ctx, pragma, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s: %w", pragma, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (o *sqliteConnector) Driver() driver.Driver {
+	return sqliteshim.Driver()
+}
+
+// checkConnection attempts to connect to the database up to
+// cfg.ConnectAttempts times, with exponential backoff between attempts.
+func checkConnection(sqldb *sql.DB, cfg *Config) error {
+	if cfg.ConnectAttempts == 0 {
+		return nil
+	}
+
+	backoff := cfg.ConnectBackoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+
+	var err error
+	for attempt := range cfg.ConnectAttempts {
+		if attempt != 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err = sqldb.PingContext(context.Background()); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("could not connect to database after %d attempt(s): %w", cfg.ConnectAttempts, err)
+}
+
 // ExecTx executes provided SQL statements inside a single transaction using
 // the provided Context and DB connection.
 func ExecTx(ctx context.Context, db *bun.DB, opts *sql.TxOptions, statements []string) error {
@@ -2,9 +2,13 @@ package db
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDB_Create(t *testing.T) {
@@ -21,6 +25,58 @@ func TestDB_Create(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestDB_Create_options(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := Open(&Config{
+		DBMS:              "sqlite",
+		DSN:               "file:" + dbFile,
+		MaxOpenConns:      4,
+		MaxIdleConns:      -1,
+		ConnMaxLifetime:   time.Minute,
+		ConnMaxIdleTime:   time.Second,
+		SQLiteJournalMode: "wal",
+		SQLiteBusyTimeout: 5 * time.Second,
+		ConnectAttempts:   1,
+	})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	assert.Equal(t, 4, db.Stats().MaxOpenConnections)
+
+	var journalMode string
+	err = db.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	assert.NoError(t, err)
+	assert.Equal(t, "wal", strings.ToLower(journalMode))
+
+	var busyTimeout int
+	err = db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, busyTimeout)
+
+	_, err = Open(&Config{DBMS: "sqlite", DSN: "file:" + dbFile, SQLiteJournalMode: "foo"})
+	assert.EqualError(t, err, "invalid sqlite journal mode: foo")
+
+	_, err = Open(&Config{DBMS: "sqlite", DSN: "file:" + dbFile, StatementTimeout: -1})
+	assert.EqualError(t, err, "invalid statement timeout: must not be negative")
+
+	// the first invalid field (in declaration order) is reported
+	_, err = Open(&Config{DBMS: "sqlite", DSN: "file:" + dbFile, MaxOpenConns: -1, ConnectBackoff: -1})
+	assert.EqualError(t, err, "invalid max open connections: must not be negative")
+
+	_, err = Open(&Config{DBMS: "pg", DSN: "foo", StatementTimeout: time.Second})
+	assert.ErrorContains(t, err, "cannot parse `foo`")
+
+	_, err = Open(&Config{
+		DBMS:             "mariadb",
+		DSN:              "user:pass@tcp(127.0.0.1:1)/db",
+		StatementTimeout: time.Second,
+		ConnectAttempts:  2,
+		ConnectBackoff:   time.Millisecond,
+	})
+	assert.ErrorContains(t, err, "could not connect to database after 2 attempt(s)")
+}
+
 func TestExecTx(t *testing.T) {
 	db := NewEmptyTestDB(t)
 
@@ -0,0 +1,228 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// environmentOwners_v2 are the tables with an environment_id column
+// referencing the environments table.
+var environmentOwners_v2 = []string{
+	"value_triples",
+	"key_triples",
+	"stateful_environments",
+	"conditional_endorsement_series_triples",
+	"domain_entries",
+	"domain_dependency_triples",
+	"domain_membership_triples",
+}
+
+type environment_v2 struct {
+	bun.BaseModel `bun:"table:environments,alias:env"`
+
+	ID int64 `bun:",pk,autoincrement"`
+
+	ClassType  *string
+	ClassBytes *[]byte
+	Vendor     *string
+	Model      *string
+	Layer      *uint64
+	Index      *uint64
+
+	InstanceType  *string
+	InstanceBytes *[]byte
+
+	GroupType  *string
+	GroupBytes *[]byte
+
+	ContentHash string `bun:",nullzero"`
+	RefCount    int64
+}
+
+func (o *environment_v2) calculateContentHash() string {
+	hash := sha256.New()
+
+	writeOptionalString_v2(hash, o.ClassType)
+	writeOptionalBytes_v2(hash, o.ClassBytes)
+	writeOptionalString_v2(hash, o.Vendor)
+	writeOptionalString_v2(hash, o.Model)
+	writeOptionalUint_v2(hash, o.Layer)
+	writeOptionalUint_v2(hash, o.Index)
+	writeOptionalString_v2(hash, o.InstanceType)
+	writeOptionalBytes_v2(hash, o.InstanceBytes)
+	writeOptionalString_v2(hash, o.GroupType)
+	writeOptionalBytes_v2(hash, o.GroupBytes)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func writeOptionalBytes_v2(w io.Writer, value *[]byte) {
+	if value == nil {
+		_, _ = w.Write([]byte{0})
+		return
+	}
+
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(*value)))
+
+	_, _ = w.Write([]byte{1})
+	_, _ = w.Write(length[:])
+	_, _ = w.Write(*value)
+}
+
+func writeOptionalString_v2(w io.Writer, value *string) {
+	if value == nil {
+		writeOptionalBytes_v2(w, nil)
+		return
+	}
+
+	bytes := []byte(*value)
+	writeOptionalBytes_v2(w, &bytes)
+}
+
+func writeOptionalUint_v2(w io.Writer, value *uint64) {
+	if value == nil {
+		writeOptionalBytes_v2(w, nil)
+		return
+	}
+
+	bytes := binary.BigEndian.AppendUint64(nil, *value)
+	writeOptionalBytes_v2(w, &bytes)
+}
+
+// deduplicateEnvironments sets the content hashes of existing environments.
+// Environments with identical contents are merged into the one with the
+// lowest ID (references to the others are updated to point to it).
+func deduplicateEnvironments(ctx context.Context, db bun.IDB) error {
+	var envs []*environment_v2
+	if err := db.NewSelect().Model(&envs).Order("id").Scan(ctx); err != nil {
+		return err
+	}
+
+	canonical := make(map[string]int64)
+	for _, env := range envs {
+		hash := env.calculateContentHash()
+
+		id, ok := canonical[hash]
+		if !ok {
+			canonical[hash] = env.ID
+
+			_, err := db.NewUpdate().
+				Model((*environment_v2)(nil)).
+				Set("content_hash = ?", hash).
+				Where("id = ?", env.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, table := range environmentOwners_v2 {
+			_, err := db.NewUpdate().
+				Table(table).
+				Set("environment_id = ?", id).
+				Where("environment_id = ?", env.ID).
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+		}
+
+		_, err := db.NewDelete().
+			Model((*environment_v2)(nil)).
+			Where("id = ?", env.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countEnvironmentReferences(ctx context.Context, db bun.IDB) error {
+	counts := make([]string, len(environmentOwners_v2))
+	for i, table := range environmentOwners_v2 {
+		counts[i] = fmt.Sprintf(
+			"(SELECT COUNT(*) FROM %s WHERE %s.environment_id = environments.id)",
+			table, table,
+		)
+	}
+
+	_, err := db.NewUpdate().
+		Table("environments").
+		Set("ref_count = " + strings.Join(counts, " + ")).
+		Where("1 = 1").
+		Exec(ctx)
+
+	return err
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		var err error
+
+		statements := []StatementMap{
+			{
+				"pg|sqlite": "ALTER TABLE environments ADD COLUMN content_hash TEXT",
+				"mysql":     "ALTER TABLE environments ADD COLUMN content_hash VARCHAR(64)",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE environments ADD COLUMN ref_count BIGINT NOT NULL DEFAULT 0",
+			},
+		}
+
+		for _, statementMap := range statements {
+			_, err = execStatement(db, statementMap)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err = deduplicateEnvironments(ctx, db); err != nil {
+			return fmt.Errorf("de-duplicating environments: %w", err)
+		}
+
+		if err = countEnvironmentReferences(ctx, db); err != nil {
+			return fmt.Errorf("counting environment references: %w", err)
+		}
+
+		_, err = execStatement(db, StatementMap{
+			"pg|sqlite|mysql": "CREATE UNIQUE INDEX environments_content_hash_idx ON environments (content_hash)",
+		})
+
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		var err error
+		statements := []StatementMap{
+			{
+				"pg|sqlite": "DROP INDEX environments_content_hash_idx",
+				"mysql":     "DROP INDEX environments_content_hash_idx ON environments",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE environments DROP COLUMN ref_count",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE environments DROP COLUMN content_hash",
+			},
+		}
+
+		for _, statementMap := range statements {
+			_, err = execStatement(db, statementMap)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
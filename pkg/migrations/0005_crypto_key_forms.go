@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/util"
+	"github.com/veraison/corim/comid"
+)
+
+type cryptoKey_v2 struct {
+	bun.BaseModel `bun:"table:cryptokeys,alias:ck"`
+
+	ID int64 `bun:",pk,autoincrement"`
+
+	KeyType  string
+	KeyBytes []byte
+
+	SPKI           []byte `bun:"spki,nullzero"`
+	SPKIThumbprint []byte `bun:"spki_thumbprint,nullzero"`
+	CertThumbprint []byte `bun:"cert_thumbprint,nullzero"`
+}
+
+var cryptoKeyStringFactories_v2 = map[string]comid.ICryptoKeyFactory{
+	comid.PKIXBase64KeyType:      comid.NewPKIXBase64Key,
+	comid.PKIXBase64CertType:     comid.NewPKIXBase64Cert,
+	comid.PKIXBase64CertPathType: comid.NewPKIXBase64CertPath,
+	comid.ThumbprintType:         comid.NewThumbprint,
+	comid.CertThumbprintType:     comid.NewCertThumbprint,
+	comid.CertPathThumbprintType: comid.NewCertPathThumbprint,
+}
+
+// toCoRIM reconstructs the key from its stored encoding (see
+// CryptoKey.FromCoRIM in the model package).
+func (o *cryptoKey_v2) toCoRIM() (*comid.CryptoKey, error) {
+	if factory, ok := cryptoKeyStringFactories_v2[o.KeyType]; ok {
+		return factory(string(o.KeyBytes))
+	}
+
+	switch o.KeyType {
+	case comid.BytesType:
+		return comid.NewCryptoKeyTaggedBytes(o.KeyBytes)
+	case comid.COSEKeyType:
+		return comid.NewCOSEKey(o.KeyBytes)
+	default:
+		ret, err := comid.NewCryptoKey(nil, o.KeyType)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cbor.Unmarshal(o.KeyBytes, ret); err != nil {
+			return nil, err
+		}
+
+		return ret, nil
+	}
+}
+
+// populateCryptoKeyForms derives the canonical forms of existing crypto
+// keys. Keys that cannot be decoded are left without them.
+func populateCryptoKeyForms(ctx context.Context, db bun.IDB) error {
+	var keys []*cryptoKey_v2
+	if err := db.NewSelect().Model(&keys).Order("id").Scan(ctx); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		origin, err := key.toCoRIM()
+		if err != nil {
+			continue
+		}
+
+		forms := util.DeriveKeyForms(origin)
+		if forms.SPKIThumbprint == nil && forms.CertThumbprint == nil {
+			continue
+		}
+
+		key.SPKI = forms.SPKI
+		key.SPKIThumbprint = forms.SPKIThumbprint
+		key.CertThumbprint = forms.CertThumbprint
+
+		_, err = db.NewUpdate().
+			Model(key).
+			Column("spki", "spki_thumbprint", "cert_thumbprint").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("crypto key %d: %w", key.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		var err error
+
+		statements := []StatementMap{
+			{
+				"pg":     "ALTER TABLE cryptokeys ADD COLUMN spki BYTEA",
+				"sqlite": "ALTER TABLE cryptokeys ADD COLUMN spki BLOB",
+				"mysql":  "ALTER TABLE cryptokeys ADD COLUMN spki BLOB",
+			},
+			{
+				"pg":     "ALTER TABLE cryptokeys ADD COLUMN spki_thumbprint BYTEA",
+				"sqlite": "ALTER TABLE cryptokeys ADD COLUMN spki_thumbprint BLOB",
+				"mysql":  "ALTER TABLE cryptokeys ADD COLUMN spki_thumbprint VARBINARY(32)",
+			},
+			{
+				"pg":     "ALTER TABLE cryptokeys ADD COLUMN cert_thumbprint BYTEA",
+				"sqlite": "ALTER TABLE cryptokeys ADD COLUMN cert_thumbprint BLOB",
+				"mysql":  "ALTER TABLE cryptokeys ADD COLUMN cert_thumbprint VARBINARY(32)",
+			},
+		}
+
+		for _, statementMap := range statements {
+			_, err = execStatement(db, statementMap)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err = populateCryptoKeyForms(ctx, db); err != nil {
+			return fmt.Errorf("deriving crypto key forms: %w", err)
+		}
+
+		statements = []StatementMap{
+			{
+				"pg|sqlite|mysql": "CREATE INDEX cryptokeys_spki_thumbprint_idx ON cryptokeys (spki_thumbprint)",
+			},
+			{
+				"pg|sqlite|mysql": "CREATE INDEX cryptokeys_cert_thumbprint_idx ON cryptokeys (cert_thumbprint)",
+			},
+		}
+
+		for _, statementMap := range statements {
+			_, err = execStatement(db, statementMap)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		var err error
+		statements := []StatementMap{
+			{
+				"pg|sqlite": "DROP INDEX cryptokeys_cert_thumbprint_idx",
+				"mysql":     "DROP INDEX cryptokeys_cert_thumbprint_idx ON cryptokeys",
+			},
+			{
+				"pg|sqlite": "DROP INDEX cryptokeys_spki_thumbprint_idx",
+				"mysql":     "DROP INDEX cryptokeys_spki_thumbprint_idx ON cryptokeys",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE cryptokeys DROP COLUMN cert_thumbprint",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE cryptokeys DROP COLUMN spki_thumbprint",
+			},
+			{
+				"pg|sqlite|mysql": "ALTER TABLE cryptokeys DROP COLUMN spki",
+			},
+		}
+
+		for _, statementMap := range statements {
+			_, err = execStatement(db, statementMap)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
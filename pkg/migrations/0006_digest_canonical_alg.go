@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+// populateCanonicalDigestAlgIDs sets the canonical algorithm IDs of existing
+// digests. Integer algorithm IDs are already canonical; text ones are
+// resolved, leaving the ones that are not in the registry unset.
+func populateCanonicalDigestAlgIDs(ctx context.Context, db bun.IDB) error {
+	_, err := db.NewRaw(
+		"UPDATE digests SET canonical_alg_id = alg_id_int WHERE alg_id_int <> 0",
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	err = db.NewRaw(
+		"SELECT DISTINCT alg_id_text FROM digests WHERE alg_id_text IS NOT NULL AND alg_id_text <> ''",
+	).Scan(ctx, &names)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		algID := util.CanonicalDigestAlgID(name)
+		if algID == 0 {
+			continue
+		}
+
+		_, err = db.NewRaw(
+			"UPDATE digests SET canonical_alg_id = ? WHERE alg_id_text = ?", algID, name,
+		).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("%q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := execStatement(db, StatementMap{
+			"pg|mysql": "ALTER TABLE digests ADD COLUMN canonical_alg_id BIGINT",
+			"sqlite":   "ALTER TABLE digests ADD COLUMN canonical_alg_id INTEGER",
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := populateCanonicalDigestAlgIDs(ctx, db); err != nil {
+			return fmt.Errorf("populating canonical digest algorithm IDs: %w", err)
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := execStatement(db, StatementMap{
+			"pg|sqlite|mysql": "ALTER TABLE digests DROP COLUMN canonical_alg_id",
+		})
+
+		return err
+	})
+}
@@ -0,0 +1,143 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim/comid"
+)
+
+const mvalRawValue_v2 int64 = 4
+
+type measurementValueEntry_v2 struct {
+	bun.BaseModel `bun:"table:measurement_value_entries,alias:mve"`
+
+	ID int64 `bun:",pk,autoincrement"`
+
+	CodePoint  int64
+	ValueType  string
+	ValueBytes *[]byte
+	ValueMask  *[]byte
+
+	MeasurementID int64
+}
+
+// selectMaskedRawValues returns the measurement value entries for masked raw
+// values.
+func selectMaskedRawValues(ctx context.Context, db bun.IDB) ([]*measurementValueEntry_v2, error) {
+	var entries []*measurementValueEntry_v2
+	err := db.NewSelect().
+		Model(&entries).
+		Where("code_point = ?", mvalRawValue_v2).
+		Where("value_type = ?", comid.MaskedType).
+		Order("id").
+		Scan(ctx)
+
+	return entries, err
+}
+
+// splitMaskedRawValues replaces the CBOR encoding of existing masked raw
+// values with their value, moving their mask into value_mask.
+func splitMaskedRawValues(ctx context.Context, db bun.IDB) error {
+	entries, err := selectMaskedRawValues(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.ValueBytes == nil {
+			continue
+		}
+
+		rawValue, err := comid.NewRawValue(nil, comid.MaskedType)
+		if err != nil {
+			return err
+		}
+
+		if err := rawValue.UnmarshalCBOR(*entry.ValueBytes); err != nil {
+			return fmt.Errorf("measurement value %d: %w", entry.ID, err)
+		}
+
+		value := rawValue.Bytes()
+		mask := rawValue.Mask()
+		entry.ValueBytes = &value
+		entry.ValueMask = &mask
+
+		_, err = db.NewUpdate().
+			Model(entry).
+			Column("value_bytes", "value_mask").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("measurement value %d: %w", entry.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// joinMaskedRawValues reverses splitMaskedRawValues.
+func joinMaskedRawValues(ctx context.Context, db bun.IDB) error {
+	entries, err := selectMaskedRawValues(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.ValueBytes == nil || entry.ValueMask == nil {
+			continue
+		}
+
+		rawValue, err := comid.NewRawValueWithMask(*entry.ValueBytes, *entry.ValueMask)
+		if err != nil {
+			return fmt.Errorf("measurement value %d: %w", entry.ID, err)
+		}
+
+		encoded, err := rawValue.MarshalCBOR()
+		if err != nil {
+			return fmt.Errorf("measurement value %d: %w", entry.ID, err)
+		}
+
+		entry.ValueBytes = &encoded
+
+		_, err = db.NewUpdate().
+			Model(entry).
+			Column("value_bytes").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("measurement value %d: %w", entry.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := execStatement(db, StatementMap{
+			"pg":           "ALTER TABLE measurement_value_entries ADD COLUMN value_mask BYTEA",
+			"sqlite|mysql": "ALTER TABLE measurement_value_entries ADD COLUMN value_mask BLOB",
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := splitMaskedRawValues(ctx, db); err != nil {
+			return fmt.Errorf("splitting masked raw values: %w", err)
+		}
+
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		if err := joinMaskedRawValues(ctx, db); err != nil {
+			return fmt.Errorf("joining masked raw values: %w", err)
+		}
+
+		_, err := execStatement(db, StatementMap{
+			"pg|sqlite|mysql": "ALTER TABLE measurement_value_entries DROP COLUMN value_mask",
+		})
+
+		return err
+	})
+}
@@ -0,0 +1,271 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+)
+
+// bulkChunkSize is the maximum number of rows inserted by a single
+// multi-row INSERT statement.
+const bulkChunkSize = 500
+
+// bulkIgnoreTables are the tables for which conflicting rows are silently
+// skipped (i.e. the tables whose models' Insert() uses INSERT ... IGNORE).
+var bulkIgnoreTables = map[string]bool{
+	"roles": true,
+}
+
+// bulkRow is a model that can be inserted as part of a multi-row INSERT.
+type bulkRow interface {
+	TableName() string
+}
+
+// bulkParent is a bulkRow that owns other rows. bulkChildren is invoked after
+// the row has been inserted (and so its ID is known); it must set the owner
+// fields of the children and queue them for insertion.
+type bulkParent interface {
+	bulkChildren(ins *bulkInserter)
+}
+
+// bulkEnvironmentOwner is a bulkRow that references an Environment, which
+// must be inserted (or resolved to an existing one) before the row itself.
+type bulkEnvironmentOwner interface {
+	bulkEnvironment() *Environment
+	setBulkEnvironmentID(id int64)
+}
+
+type bulkValidator interface {
+	Validate() error
+}
+
+type bulkQueue interface {
+	rows() []any
+	insert(ctx context.Context, db bun.IDB, ins *bulkInserter) error
+}
+
+type bulkRows[T bulkRow] struct {
+	values []T
+}
+
+func (o *bulkRows[T]) rows() []any {
+	ret := make([]any, len(o.values))
+	for i, v := range o.values {
+		ret[i] = v
+	}
+
+	return ret
+}
+
+func (o *bulkRows[T]) insert(ctx context.Context, db bun.IDB, ins *bulkInserter) error {
+	for i, row := range o.values {
+		if v, ok := any(row).(bulkValidator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("row %d: %w", i, err)
+			}
+		}
+	}
+
+	for start := 0; start < len(o.values); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(o.values))
+		if err := bulkInsertChunk(ctx, db, ins, o.values[start:end]); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range o.values {
+		if parent, ok := any(row).(bulkParent); ok {
+			parent.bulkChildren(ins)
+		}
+	}
+
+	return nil
+}
+
+// bulkInserter inserts trees of models. Rather than inserting each model
+// individually as it is encountered (as the models' Insert() methods do), it
+// proceeds breadth-first, collecting the rows at each level of the tree by
+// table, and inserting them using multi-row INSERT statements. This means that
+// the number of statements executed depends on the depth of the tree rather
+// than on the number of models inside it.
+type bulkInserter struct {
+	queues map[string]bulkQueue
+	order  []string
+
+	// for MySQL/MariaDB: whether the IDs generated for a multi-row INSERT
+	// can be inferred from the last insert ID (nil until established).
+	consecutiveIDs *bool
+}
+
+func newBulkInserter() *bulkInserter {
+	return &bulkInserter{queues: make(map[string]bulkQueue)}
+}
+
+// bulkInsert inserts the provided models, and all models nested inside them,
+// using multi-row INSERTs.
+func bulkInsert[T bulkRow](ctx context.Context, db bun.IDB, rows ...T) error {
+	ins := newBulkInserter()
+	bulkEnqueue(ins, rows...)
+	return ins.run(ctx, db)
+}
+
+// bulkEnqueue queues the provided rows for insertion as part of the next
+// level of the tree.
+func bulkEnqueue[T bulkRow](ins *bulkInserter, rows ...T) {
+	if len(rows) == 0 {
+		return
+	}
+
+	table := rows[0].TableName()
+
+	queue, ok := ins.queues[table]
+	if !ok {
+		queue = &bulkRows[T]{}
+		ins.queues[table] = queue
+		ins.order = append(ins.order, table)
+	}
+
+	typed := queue.(*bulkRows[T])
+	typed.values = append(typed.values, rows...)
+}
+
+func (o *bulkInserter) run(ctx context.Context, db bun.IDB) error {
+	for len(o.order) != 0 {
+		queues, order := o.queues, o.order
+		o.queues, o.order = make(map[string]bulkQueue), nil
+
+		var envOwners []bulkEnvironmentOwner
+		for _, table := range order {
+			for _, row := range queues[table].rows() {
+				if owner, ok := row.(bulkEnvironmentOwner); ok {
+					envOwners = append(envOwners, owner)
+				}
+			}
+		}
+
+		if err := o.insertEnvironments(ctx, db, envOwners); err != nil {
+			return fmt.Errorf("environments: %w", err)
+		}
+
+		for _, table := range order {
+			if err := queues[table].insert(ctx, db, o); err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// insertEnvironments ensures that the environments referenced by the provided
+// owners exist in the database, and sets the owners' environment IDs. Each
+// distinct environment is upserted once, with its reference count incremented
+// by the number of owners referencing it.
+func (o *bulkInserter) insertEnvironments(
+	ctx context.Context,
+	db bun.IDB,
+	owners []bulkEnvironmentOwner,
+) error {
+	if len(owners) == 0 {
+		return nil
+	}
+
+	byHash := make(map[string][]*Environment)
+	var distinct []*Environment
+
+	for _, owner := range owners {
+		env := owner.bulkEnvironment()
+		if err := env.Validate(); err != nil {
+			return err
+		}
+
+		hash := env.CalculateContentHash()
+		if _, ok := byHash[hash]; !ok {
+			env.RefCount = 0
+			distinct = append(distinct, env)
+		}
+
+		byHash[hash] = append(byHash[hash], env)
+		byHash[hash][0].RefCount++
+	}
+
+	for start := 0; start < len(distinct); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(distinct))
+		if err := upsertEnvironments(ctx, db, distinct[start:end]); err != nil {
+			return err
+		}
+	}
+
+	for _, envs := range byHash {
+		for _, env := range envs[1:] {
+			env.ID = envs[0].ID
+			env.ContentHash = envs[0].ContentHash
+			env.RefCount = envs[0].RefCount
+		}
+	}
+
+	for _, owner := range owners {
+		owner.setBulkEnvironmentID(owner.bulkEnvironment().ID)
+	}
+
+	return nil
+}
+
+// bulkInsertChunk inserts the provided rows using a single multi-row INSERT,
+// and populates their IDs. Postgres and sqlite return the generated IDs via
+// RETURNING, as does MariaDB from 10.5 onwards. Otherwise, MySQL/MariaDB only
+// return the ID generated for the first row; the rest are inferred, which is
+// only possible if the server allocates consecutive IDs to a multi-row
+// INSERT. If that is not the case, rows are inserted one at a time.
+func bulkInsertChunk[T bulkRow](ctx context.Context, db bun.IDB, ins *bulkInserter, rows []T) error {
+	ignore := bulkIgnoreTables[rows[0].TableName()]
+
+	if db.Dialect().Name().String() == "mysql" &&
+		!db.Dialect().Features().Has(feature.InsertReturning) &&
+		!ins.hasConsecutiveIDs(ctx, db) {
+		for _, row := range rows {
+			query := db.NewInsert().Model(row)
+			if ignore {
+				query.Ignore()
+			}
+
+			if _, err := query.Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	query := db.NewInsert().Model(&rows)
+	if ignore {
+		// note: rows skipped due to conflicts will not have their IDs
+		// populated (or, on MySQL/MariaDB, may have incorrect IDs); this
+		// is fine, as models inserted this way do not own other models.
+		query.Ignore()
+	}
+
+	_, err := query.Exec(ctx)
+	return err
+}
+
+// hasConsecutiveIDs returns true if a MySQL/MariaDB server will allocate
+// consecutive auto-increment IDs to the rows of a multi-row INSERT. This is
+// the case for InnoDB's "traditional" and "consecutive" lock modes, when the
+// auto-increment step is 1.
+func (o *bulkInserter) hasConsecutiveIDs(ctx context.Context, db bun.IDB) bool {
+	if o.consecutiveIDs != nil {
+		return *o.consecutiveIDs
+	}
+
+	var lockMode, step int
+	err := db.NewRaw("SELECT @@innodb_autoinc_lock_mode, @@auto_increment_increment").
+		Scan(ctx, &lockMode, &step)
+
+	result := err == nil && lockMode != 2 && step == 1
+	o.consecutiveIDs = &result
+
+	return result
+}
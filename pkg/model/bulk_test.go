@@ -0,0 +1,125 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim/comid"
+)
+
+type insertCounter struct {
+	count int
+}
+
+func (o *insertCounter) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (o *insertCounter) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	if strings.HasPrefix(strings.ToUpper(event.Query), "INSERT") {
+		o.count++
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	vendor := "ACME"
+	otherVendor := "Other"
+	numTriples := 20
+	numMeasurements := 50
+
+	moduleTag := ModuleTag{TagIDType: StringTagID, TagID: "bulk"}
+	for i := range numTriples {
+		// all but one of the triples share the same environment
+		triple := ValueTriple{
+			Type:        ReferenceValueTriple,
+			Environment: &Environment{Vendor: &vendor},
+		}
+
+		if i == numTriples-1 {
+			triple.Environment = &Environment{Vendor: &otherVendor}
+		}
+
+		for j := range numMeasurements {
+			key := fmt.Sprintf("measurement-%d", j)
+			keyType := comid.StringType
+			keyBytes := []byte(key)
+
+			triple.Measurements = append(triple.Measurements, &Measurement{
+				KeyType:  &keyType,
+				KeyBytes: &keyBytes,
+				Digests: []*Digest{
+					{AlgIDInt: 1, Value: []byte{0xde, 0xad, 0xbe, 0xef, byte(i), byte(j)}},
+				},
+				Flags: []*Flag{{CodePoint: 0, Value: true}},
+			})
+		}
+
+		moduleTag.ValueTriples = append(moduleTag.ValueTriples, &triple)
+	}
+
+	counter := &insertCounter{}
+	db.AddQueryHook(counter)
+
+	err := moduleTag.Insert(ctx, db)
+	require.NoError(t, err)
+
+	// module tag, environments, and value triples are inserted with a
+	// single statement each; there are 1000 measurements, digests, and
+	// flags, so each of those takes two statements (see bulkChunkSize).
+	assert.Equal(t, 9, counter.count)
+
+	numEnvs, err := db.NewSelect().Model((*Environment)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, numEnvs)
+
+	numDigests, err := db.NewSelect().Model((*Digest)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, numTriples*numMeasurements, numDigests)
+
+	selected := ModuleTag{ID: moduleTag.ID}
+	err = selected.Select(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, selected.ValueTriples, numTriples)
+
+	for i, triple := range selected.ValueTriples {
+		assert.Equal(t, moduleTag.ValueTriples[i].ID, triple.ID)
+		assert.Equal(t, moduleTag.ValueTriples[i].EnvironmentID, triple.EnvironmentID)
+		require.Len(t, triple.Measurements, numMeasurements)
+
+		for j, mea := range triple.Measurements {
+			assert.Equal(t, *moduleTag.ValueTriples[i].Measurements[j].KeyBytes, *mea.KeyBytes)
+			require.Len(t, mea.Digests, 1)
+			assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef, byte(i), byte(j)}, mea.Digests[0].Value)
+		}
+	}
+
+	// existing environments are re-used by subsequent inserts
+	otherTag := ModuleTag{
+		TagIDType: StringTagID,
+		TagID:     "other",
+		KeyTriples: []*KeyTriple{
+			{
+				Type:        AttestKeyTriple,
+				Environment: &Environment{Vendor: &otherVendor},
+				KeyList:     []*CryptoKey{{KeyType: comid.PKIXBase64KeyType, KeyBytes: []byte("key")}},
+			},
+		},
+	}
+
+	err = otherTag.Insert(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, moduleTag.ValueTriples[numTriples-1].EnvironmentID, otherTag.KeyTriples[0].EnvironmentID)
+
+	numEnvs, err = db.NewSelect().Model((*Environment)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, numEnvs)
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"context"
+	"reflect"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
@@ -119,6 +121,19 @@ func RegisterModels(db *bun.DB) {
 	db.RegisterModel(allModels...)
 }
 
+// TableNames returns the names of the tables used by the models, in
+// alphabetical order.
+func TableNames(db bun.IDB) []string {
+	ret := make([]string, 0, len(tableModels))
+	for _, table := range tableModels {
+		ret = append(ret, db.Dialect().Tables().Get(reflect.TypeOf(table)).Name)
+	}
+
+	slices.Sort(ret)
+
+	return ret
+}
+
 func ResetModels(ctx context.Context, db *bun.DB) error {
 	for _, table := range tableModels {
 		if _, err := db.NewTruncateTable().Model(table).Exec(ctx); err != nil {
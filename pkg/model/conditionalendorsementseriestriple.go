@@ -173,6 +173,20 @@ func (o *ConditionalEndorsementSeriesRecord) Insert(ctx context.Context, db bun.
 	return nil
 }
 
+func (o *ConditionalEndorsementSeriesRecord) bulkChildren(ins *bulkInserter) {
+	for _, measurement := range o.Selection {
+		measurement.OwnerID = o.ID
+		measurement.OwnerType = "ces_record_selection"
+	}
+	bulkEnqueue(ins, o.Selection...)
+
+	for _, measurement := range o.Addition {
+		measurement.OwnerID = o.ID
+		measurement.OwnerType = "ces_record_addition"
+	}
+	bulkEnqueue(ins, o.Addition...)
+}
+
 func (o *ConditionalEndorsementSeriesRecord) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -283,6 +297,11 @@ type ConditionalEndorsementSeriesTriple struct {
 	IsActive bool
 
 	ModuleID int64 `bun:",nullzero"`
+
+	// Profile of the manifest containing the triple. This is not stored; it is
+	// populated when the triple is retrieved via a query entry, and is used to
+	// reconstruct typed extensions (see RegisterProfileExtensions).
+	Profile string `bun:"-"`
 }
 
 func NewConditionalEndorsementSeriesTripleFromCoRIM(
@@ -395,6 +414,12 @@ func (o *ConditionalEndorsementSeriesTriple) ToCoRIM() (*comid.CondEndorseSeries
 	}
 	ret.Series = series
 
+	if exts, ok := ProfileExtensions(o.Profile); ok {
+		if err := applyCondEndorseSeriesTripleExtensions(&ret, exts); err != nil {
+			return nil, fmt.Errorf("profile extensions: %w", err)
+		}
+	}
+
 	return &ret, nil
 }
 
@@ -468,6 +493,33 @@ func (o *ConditionalEndorsementSeriesTriple) Insert(ctx context.Context, db bun.
 	return nil
 }
 
+func (o *ConditionalEndorsementSeriesTriple) bulkChildren(ins *bulkInserter) {
+	for _, mea := range o.Measurements {
+		mea.OwnerID = o.ID
+		mea.OwnerType = "ces_condition"
+	}
+	bulkEnqueue(ins, o.Measurements...)
+
+	for _, auth := range o.AuthorizedBy {
+		auth.OwnerID = o.ID
+		auth.OwnerType = "ces_condition"
+	}
+	bulkEnqueue(ins, o.AuthorizedBy...)
+
+	for _, record := range o.Series {
+		record.TripleID = o.ID
+	}
+	bulkEnqueue(ins, o.Series...)
+}
+
+func (o *ConditionalEndorsementSeriesTriple) bulkEnvironment() *Environment {
+	return o.Environment
+}
+
+func (o *ConditionalEndorsementSeriesTriple) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *ConditionalEndorsementSeriesTriple) Select(ctx context.Context, db bun.IDB) error { // nolint:dupl
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -541,7 +593,7 @@ func (o *ConditionalEndorsementSeriesTriple) Delete(ctx context.Context, db bun.
 		return err
 	}
 
-	if err := o.Environment.DeleteIfOrphaned(ctx, db); err != nil {
+	if err := o.Environment.Release(ctx, db); err != nil {
 		// coverage:ignore
 		return fmt.Errorf("environment: %w", err)
 	}
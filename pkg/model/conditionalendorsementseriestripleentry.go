@@ -101,5 +101,7 @@ func (o *ConditionalEndorsementSeriesTripleEntry) ToTriple(
 		return nil, err
 	}
 
+	triple.Profile = o.Profile
+
 	return triple, nil
 }
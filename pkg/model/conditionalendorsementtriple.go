@@ -61,6 +61,11 @@ type ConditionalEndorsementTriple struct {
 	IsActive     bool
 
 	ModuleID int64 `bun:",nullzero"`
+
+	// Profile of the manifest containing the triple. This is not stored; it is
+	// populated when the triple is retrieved via a query entry, and is used to
+	// reconstruct typed extensions (see RegisterProfileExtensions).
+	Profile string `bun:"-"`
 }
 
 func NewConditionalEndorsementTripleFromCoRIM(
@@ -145,6 +150,12 @@ func (o *ConditionalEndorsementTriple) ToCoRIM() (*comid.CondEndorseTriple, erro
 	}
 	ret.Endorsements = *endorsements
 
+	if exts, ok := ProfileExtensions(o.Profile); ok {
+		if err := applyCondEndorseTripleExtensions(&ret, exts); err != nil {
+			return nil, fmt.Errorf("profile extensions: %w", err)
+		}
+	}
+
 	return &ret, nil
 }
 
@@ -204,6 +215,19 @@ func (o *ConditionalEndorsementTriple) Insert(ctx context.Context, db bun.IDB) e
 	return nil
 }
 
+func (o *ConditionalEndorsementTriple) bulkChildren(ins *bulkInserter) {
+	for _, cond := range o.Conditions {
+		cond.TripleID = o.ID
+	}
+	bulkEnqueue(ins, o.Conditions...)
+
+	for _, endorsement := range o.Endorsements {
+		endorsement.OwnerID = o.ID
+		endorsement.OwnerType = "conditional_endorsement_triple"
+	}
+	bulkEnqueue(ins, o.Endorsements...)
+}
+
 func (o *ConditionalEndorsementTriple) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
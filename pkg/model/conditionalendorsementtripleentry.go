@@ -100,5 +100,7 @@ func (o *ConditionalEndorsementTripleEntry) ToTriple(
 		return nil, err
 	}
 
+	triple.Profile = o.Profile
+
 	return triple, nil
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
 )
 
@@ -57,6 +58,13 @@ type CryptoKey struct {
 	KeyType  string
 	KeyBytes []byte
 
+	// Canonical forms of the key, derived from KeyType and KeyBytes by
+	// UpdateKeyForms (see util.KeyForms). These allow keys to be matched
+	// regardless of the encoding they were presented in.
+	SPKI           []byte `bun:"spki,nullzero"`
+	SPKIThumbprint []byte `bun:"spki_thumbprint,nullzero"`
+	CertThumbprint []byte `bun:"cert_thumbprint,nullzero"`
+
 	OwnerID   int64  `bun:",nullzero"`
 	OwnerType string `bun:",nullzero"`
 }
@@ -140,10 +148,30 @@ func (o *CryptoKey) FromCoRIM(origin *comid.CryptoKey) error {
 
 	o.KeyType = keyType
 	o.KeyBytes = keyBytes
+	o.setKeyForms(util.DeriveKeyForms(origin))
 
 	return nil
 }
 
+// UpdateKeyForms (re-)derives the canonical forms of the key from its
+// KeyType and KeyBytes. Forms that cannot be derived are cleared. This is
+// invoked automatically on insertion.
+func (o *CryptoKey) UpdateKeyForms() {
+	origin, err := o.ToCoRIM()
+	if err != nil {
+		o.setKeyForms(util.KeyForms{})
+		return
+	}
+
+	o.setKeyForms(util.DeriveKeyForms(origin))
+}
+
+func (o *CryptoKey) setKeyForms(forms util.KeyForms) {
+	o.SPKI = forms.SPKI
+	o.SPKIThumbprint = forms.SPKIThumbprint
+	o.CertThumbprint = forms.CertThumbprint
+}
+
 func (o *CryptoKey) ToCoRIM() (*comid.CryptoKey, error) {
 	switch o.KeyType {
 	case comid.PKIXBase64KeyType, comid.PKIXBase64CertType, comid.PKIXBase64CertPathType,
@@ -173,6 +201,8 @@ func (o *CryptoKey) ToCoRIM() (*comid.CryptoKey, error) {
 }
 
 func (o *CryptoKey) Insert(ctx context.Context, db bun.IDB) error {
+	o.UpdateKeyForms()
+
 	_, err := db.NewInsert().Model(o).Exec(ctx)
 	return err
 }
@@ -72,6 +72,34 @@ func TestCrypoKey_round_trip(t *testing.T) {
 	}
 }
 
+func TestCryptoKey_key_forms(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	key, err := NewCryptoKeyFromCoRIM(comid.MustNewCryptoKey(comid.TestCert, comid.PKIXBase64CertType))
+	require.NoError(t, err)
+	assert.NotNil(t, key.SPKI)
+	assert.NotNil(t, key.SPKIThumbprint)
+	assert.NotNil(t, key.CertThumbprint)
+
+	expected := *key
+	key.SPKI, key.SPKIThumbprint, key.CertThumbprint = nil, nil, nil
+	require.NoError(t, key.Insert(ctx, db))
+
+	selected, err := SelectCryptoKey(ctx, db, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected.SPKI, selected.SPKI)
+	assert.Equal(t, expected.SPKIThumbprint, selected.SPKIThumbprint)
+	assert.Equal(t, expected.CertThumbprint, selected.CertThumbprint)
+
+	key, err = NewCryptoKeyFromCoRIM(comid.MustNewCryptoKey(comid.TestBytes, comid.BytesType))
+	require.NoError(t, err)
+	require.NoError(t, key.Insert(ctx, db))
+	assert.Nil(t, key.SPKIThumbprint)
+	assert.Nil(t, key.CertThumbprint)
+}
+
 func TestCryptoKey_Select(t *testing.T) {
 	var ck CryptoKey
 	db := NewTestDB(t)
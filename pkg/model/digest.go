@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
 )
 
@@ -62,21 +63,29 @@ type Digest struct {
 	AlgIDText string `bun:"alg_id_text"`
 	Value     []byte `bun:"value"`
 
+	// CanonicalAlgID is the ID of the algorithm in the IANA Named
+	// Information Hash Algorithm Registry, regardless of whether it was
+	// originally specified using AlgIDInt or AlgIDText. It is not set for
+	// algorithms that are not in the registry.
+	CanonicalAlgID int64 `bun:"canonical_alg_id,nullzero"`
+
 	OwnerID   int64  `bun:",nullzero"`
 	OwnerType string `bun:",nullzero"`
 }
 
 func NewDigestInt(alg_id int64, val []byte) *Digest {
 	return &Digest{
-		AlgIDInt: alg_id,
-		Value:    val,
+		AlgIDInt:       alg_id,
+		Value:          val,
+		CanonicalAlgID: util.CanonicalDigestAlgID(alg_id),
 	}
 }
 
 func NewDigestText(alg_id string, val []byte) *Digest {
 	return &Digest{
-		AlgIDText: alg_id,
-		Value:     val,
+		AlgIDText:      alg_id,
+		Value:          val,
+		CanonicalAlgID: util.CanonicalDigestAlgID(alg_id),
 	}
 }
 
@@ -117,6 +126,8 @@ func (o *Digest) AlgIDString() string {
 }
 
 func (o *Digest) Insert(ctx context.Context, db bun.IDB) error {
+	o.CanonicalAlgID = util.CanonicalDigestAlgID(o.AlgID())
+
 	_, err := db.NewInsert().Model(o).Exec(ctx)
 	return err
 }
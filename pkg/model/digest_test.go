@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/veraison/corim/comid"
 )
 
@@ -34,6 +35,30 @@ func TestDigest_algorithms(t *testing.T) {
 	assert.Equal(t, "1", digest.AlgIDString())
 }
 
+func TestDigest_canonical_alg_id(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	bytes := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	assert.Equal(t, int64(0), NewDigestText("foo", bytes).CanonicalAlgID)
+	assert.Equal(t, int64(comid.Sha384), NewDigestInt(int64(comid.Sha384), bytes).CanonicalAlgID)
+
+	digest := &Digest{AlgIDText: "SHA256", Value: bytes}
+	require.NoError(t, digest.Insert(ctx, db))
+	assert.Equal(t, int64(comid.Sha256), digest.CanonicalAlgID)
+
+	selected := Digest{ID: digest.ID}
+	require.NoError(t, selected.Select(ctx, db))
+	assert.Equal(t, int64(comid.Sha256), selected.CanonicalAlgID)
+
+	// the original algorithm is retained for round-trip
+	out, err := DigestsToCoRIM([]*Digest{&selected})
+	require.NoError(t, err)
+	assert.Equal(t, "SHA256", (*out)[0].Algorithm.String())
+}
+
 func TestDigest_Select(t *testing.T) {
 	var digest Digest
 	db := NewTestDB(t)
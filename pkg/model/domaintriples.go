@@ -98,6 +98,14 @@ func (o *DomainEntry) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *DomainEntry) bulkEnvironment() *Environment {
+	return o.Environment
+}
+
+func (o *DomainEntry) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *DomainEntry) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -120,7 +128,7 @@ func (o *DomainEntry) Delete(ctx context.Context, db bun.IDB) error {
 	}
 
 	if o.Environment != nil {
-		if err := o.Environment.DeleteIfOrphaned(ctx, db); err != nil {
+		if err := o.Environment.Release(ctx, db); err != nil {
 			return fmt.Errorf("environment: %w", err)
 		}
 	}
@@ -310,6 +318,22 @@ func (o *DomainMembershipTriple) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *DomainMembershipTriple) bulkChildren(ins *bulkInserter) {
+	for _, entry := range o.Members {
+		entry.OwnerID = o.ID
+		entry.OwnerType = "domain_membership_triple"
+	}
+	bulkEnqueue(ins, o.Members...)
+}
+
+func (o *DomainMembershipTriple) bulkEnvironment() *Environment {
+	return o.DomainID
+}
+
+func (o *DomainMembershipTriple) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *DomainMembershipTriple) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -354,7 +378,7 @@ func (o *DomainMembershipTriple) Delete(ctx context.Context, db bun.IDB) error {
 	}
 
 	if o.DomainID != nil {
-		if err := o.DomainID.DeleteIfOrphaned(ctx, db); err != nil {
+		if err := o.DomainID.Release(ctx, db); err != nil {
 			// coverage:ignore
 			return fmt.Errorf("domain ID: %w", err)
 		}
@@ -547,6 +571,22 @@ func (o *DomainDependencyTriple) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *DomainDependencyTriple) bulkChildren(ins *bulkInserter) {
+	for _, entry := range o.Trustees {
+		entry.OwnerID = o.ID
+		entry.OwnerType = "domain_dependency_triple"
+	}
+	bulkEnqueue(ins, o.Trustees...)
+}
+
+func (o *DomainDependencyTriple) bulkEnvironment() *Environment {
+	return o.DomainID
+}
+
+func (o *DomainDependencyTriple) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *DomainDependencyTriple) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -590,7 +630,7 @@ func (o *DomainDependencyTriple) Delete(ctx context.Context, db bun.IDB) error {
 	}
 
 	if o.DomainID != nil {
-		if err := o.DomainID.DeleteIfOrphaned(ctx, db); err != nil {
+		if err := o.DomainID.Release(ctx, db); err != nil {
 			// coverage:ignore
 			return fmt.Errorf("domain ID: %w", err)
 		}
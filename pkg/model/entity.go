@@ -283,6 +283,19 @@ func (o *Entity) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *Entity) bulkChildren(ins *bulkInserter) {
+	for i := range o.RoleEntries {
+		o.RoleEntries[i].EntityID = o.ID
+		bulkEnqueue(ins, &o.RoleEntries[i])
+	}
+
+	for _, ext := range o.Extensions {
+		ext.OwnerID = o.ID
+		ext.OwnerType = "entity"
+	}
+	bulkEnqueue(ins, o.Extensions...)
+}
+
 func (o *Entity) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
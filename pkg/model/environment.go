@@ -2,10 +2,13 @@ package model
 
 import (
 	"context"
-	"database/sql"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/google/uuid"
@@ -39,6 +42,14 @@ type Environment struct {
 
 	GroupType  *string
 	GroupBytes *[]byte
+
+	// ContentHash is the hex-encoded SHA-256 hash of the environment's
+	// contents (see CalculateContentHash()). It is unique among the
+	// environments in the database, and is used to de-duplicate them.
+	ContentHash string `bun:",nullzero,unique"`
+	// RefCount is the number of models in the database that reference
+	// this environment. When it drops to zero, the environment is deleted.
+	RefCount int64
 }
 
 func NewEnvironmentFromCoRIM(origin *comid.Environment) (*Environment, error) {
@@ -74,21 +85,11 @@ func (o *Environment) IsTable() bool {
 }
 
 func (o *Environment) FromCoRIM(origin *comid.Environment) error {
-	var err error
-
 	if origin.Class != nil {
 		if origin.Class.ClassID != nil {
-			classType := origin.Class.ClassID.Type()
-			var classBytes []byte
-
-			switch classType {
-			case comid.OIDType, comid.UUIDType, comid.BytesType:
-				classBytes = origin.Class.ClassID.Bytes()
-			default:
-				classBytes, err = origin.Class.ClassID.MarshalCBOR()
-				if err != nil {
-					return fmt.Errorf("could not CBOR-encode class ID: %w", err)
-				}
+			classType, classBytes, err := ClassIDToModel(origin.Class.ClassID)
+			if err != nil {
+				return err
 			}
 
 			o.ClassType = &classType
@@ -102,19 +103,9 @@ func (o *Environment) FromCoRIM(origin *comid.Environment) error {
 	}
 
 	if origin.Instance != nil {
-		instanceType := origin.Instance.Type()
-		var instanceBytes []byte
-
-		switch instanceType {
-		case comid.UEIDType, comid.UUIDType, comid.BytesType, comid.PKIXAsn1DerCertType,
-			comid.PKIXBase64KeyType, comid.PKIXBase64CertType, comid.ThumbprintType, comid.CertThumbprintType,
-			comid.COSEKeyType:
-			instanceBytes = origin.Instance.Bytes()
-		default:
-			instanceBytes, err = origin.Instance.MarshalCBOR()
-			if err != nil {
-				return fmt.Errorf("could not CBOR-encode instance: %w", err)
-			}
+		instanceType, instanceBytes, err := InstanceToModel(origin.Instance)
+		if err != nil {
+			return err
 		}
 
 		o.InstanceType = &instanceType
@@ -122,17 +113,9 @@ func (o *Environment) FromCoRIM(origin *comid.Environment) error {
 	}
 
 	if origin.Group != nil {
-		groupType := origin.Group.Type()
-		var groupBytes []byte
-
-		switch groupType {
-		case comid.UUIDType, comid.BytesType:
-			groupBytes = origin.Group.Bytes()
-		default:
-			groupBytes, err = origin.Group.MarshalCBOR()
-			if err != nil {
-				return fmt.Errorf("could not CBOR-encode group: %w", err)
-			}
+		groupType, groupBytes, err := GroupToModel(origin.Group)
+		if err != nil {
+			return err
 		}
 
 		o.GroupType = &groupType
@@ -142,6 +125,63 @@ func (o *Environment) FromCoRIM(origin *comid.Environment) error {
 	return nil
 }
 
+// ClassIDToModel returns the type and the bytes under which the class ID is
+// stored (i.e. Environment.ClassType and Environment.ClassBytes). Queries for
+// class IDs must use the same encoding in order to match.
+func ClassIDToModel(classID *comid.ClassID) (string, []byte, error) {
+	classType := classID.Type()
+
+	switch classType {
+	case comid.OIDType, comid.UUIDType, comid.BytesType:
+		return classType, classID.Bytes(), nil
+	default:
+		classBytes, err := classID.MarshalCBOR()
+		if err != nil {
+			return "", nil, fmt.Errorf("could not CBOR-encode class ID: %w", err)
+		}
+
+		return classType, classBytes, nil
+	}
+}
+
+// InstanceToModel returns the type and the bytes under which the instance ID
+// is stored (i.e. Environment.InstanceType and Environment.InstanceBytes).
+func InstanceToModel(instance *comid.Instance) (string, []byte, error) {
+	instanceType := instance.Type()
+
+	switch instanceType {
+	case comid.UEIDType, comid.UUIDType, comid.BytesType, comid.PKIXAsn1DerCertType,
+		comid.PKIXBase64KeyType, comid.PKIXBase64CertType, comid.ThumbprintType, comid.CertThumbprintType,
+		comid.COSEKeyType:
+		return instanceType, instance.Bytes(), nil
+	default:
+		instanceBytes, err := instance.MarshalCBOR()
+		if err != nil {
+			return "", nil, fmt.Errorf("could not CBOR-encode instance: %w", err)
+		}
+
+		return instanceType, instanceBytes, nil
+	}
+}
+
+// GroupToModel returns the type and the bytes under which the group ID is
+// stored (i.e. Environment.GroupType and Environment.GroupBytes).
+func GroupToModel(group *comid.Group) (string, []byte, error) {
+	groupType := group.Type()
+
+	switch groupType {
+	case comid.UUIDType, comid.BytesType:
+		return groupType, group.Bytes(), nil
+	default:
+		groupBytes, err := group.MarshalCBOR()
+		if err != nil {
+			return "", nil, fmt.Errorf("could not CBOR-encode group: %w", err)
+		}
+
+		return groupType, groupBytes, nil
+	}
+}
+
 func (o Environment) ToCoRIM() (*comid.Environment, error) {
 	var err error
 	ret := comid.Environment{}
@@ -272,18 +312,17 @@ func (o *Environment) Validate() error {
 	return nil
 }
 
+// Insert adds a reference to the environment in the database, inserting it if
+// it does not already exist. On return, the environment's ID is set to that of
+// the corresponding database entry.
 func (o *Environment) Insert(ctx context.Context, db bun.IDB) error {
 	if err := o.Validate(); err != nil {
 		return err
 	}
 
-	query := db.NewSelect().Model(o)
-	err := UpdateSelectQueryFromEnvironment(query, o, true, db.Dialect()).Scan(ctx)
-	if err == sql.ErrNoRows {
-		_, err = db.NewInsert().Model(o).Exec(ctx)
-	}
+	o.RefCount = 1
 
-	return err
+	return upsertEnvironments(ctx, db, []*Environment{o})
 }
 
 func (o *Environment) Select(ctx context.Context, db bun.IDB) error {
@@ -294,27 +333,53 @@ func (o *Environment) Select(ctx context.Context, db bun.IDB) error {
 	return db.NewSelect().Model(o).Where("env.id = ?", o.ID).Scan(ctx)
 }
 
-func (o *Environment) DeleteIfOrphaned(ctx context.Context, db bun.IDB) error {
+// Release removes a reference to the environment from the database (i.e. it
+// should be invoked when a model referencing the environment is deleted). If
+// that was the last reference, the environment is deleted.
+func (o *Environment) Release(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
 	}
 
-	for _, table := range environmentOwners {
-		ownerIDs, err := o.getOwnerIDs(ctx, db, table)
-		if err != nil {
-			return fmt.Errorf("error getting enviroment owners from %q: %w", table, err)
-		}
-
-		if len(ownerIDs) != 0 {
-			// not orphaned, so don't delete
-			return nil
-		}
+	_, err := db.NewUpdate().
+		Model((*Environment)(nil)).
+		Set("ref_count = ref_count - 1").
+		Where("id = ?", o.ID).
+		Exec(ctx)
+	if err != nil {
+		return err
 	}
 
-	_, err := db.NewDelete().Model(o).WherePK().Exec(ctx)
+	_, err = db.NewDelete().
+		Model((*Environment)(nil)).
+		Where("id = ?", o.ID).
+		Where("ref_count <= 0").
+		Exec(ctx)
+
 	return err
 }
 
+// CalculateContentHash returns the hex-encoded SHA-256 hash of a canonical
+// encoding of the environment's contents. Two environments have the same
+// content hash if, and only if, all their fields (other than database
+// bookkeeping fields such as the ID) match.
+func (o *Environment) CalculateContentHash() string {
+	hash := sha256.New()
+
+	writeOptionalString(hash, o.ClassType)
+	writeOptionalBytes(hash, o.ClassBytes)
+	writeOptionalString(hash, o.Vendor)
+	writeOptionalString(hash, o.Model)
+	writeOptionalUint(hash, o.Layer)
+	writeOptionalUint(hash, o.Index)
+	writeOptionalString(hash, o.InstanceType)
+	writeOptionalBytes(hash, o.InstanceBytes)
+	writeOptionalString(hash, o.GroupType)
+	writeOptionalBytes(hash, o.GroupBytes)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func (o Environment) RenderParts() ([][2]string, error) {
 	if o.IsEmpty() {
 		return nil, nil
@@ -395,20 +460,162 @@ func (o Environment) RenderParts() ([][2]string, error) {
 	return ret, nil
 }
 
-func (o *Environment) getOwnerIDs(ctx context.Context, db bun.IDB, ownerTable string) ([]int64, error) {
-	var ownerIDs []int64
+// GarbageCollectEnvironments re-calculates the reference counts of all
+// environments in the database from the tables of the models that reference
+// them, and deletes environments that are not referenced by anything. The
+// number of deleted environments is returned.
+func GarbageCollectEnvironments(ctx context.Context, db bun.IDB) (int64, error) {
+	_, err := db.NewUpdate().
+		Table("environments").
+		Set("ref_count = " + environmentReferencesExpr()).
+		Where("1 = 1").
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error updating reference counts: %w", err)
+	}
+
+	res, err := db.NewDelete().
+		Table("environments").
+		Where("ref_count = 0").
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting orphaned environments: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// EnvironmentReferences contains the reference count recorded for an
+// environment, along with the actual number of rows referencing it.
+type EnvironmentReferences struct {
+	ID         int64
+	RefCount   int64
+	References int64 `bun:"refs"`
+}
+
+// FindInconsistentEnvironments returns the environments whose reference
+// counts do not match the number of rows referencing them, and the
+// environments that are not referenced by any rows.
+func FindInconsistentEnvironments(ctx context.Context, db bun.IDB) ([]*EnvironmentReferences, error) {
+	var ret []*EnvironmentReferences
+
 	err := db.NewSelect().
-		Model(&ownerIDs).
-		Table(ownerTable).
-		Column("id").
-		Where("environment_id = ?", o.ID).
+		TableExpr("(SELECT id, ref_count, ? AS refs FROM environments) AS env",
+			bun.Safe(environmentReferencesExpr())).
+		ColumnExpr("env.id AS id").
+		ColumnExpr("env.ref_count AS ref_count").
+		ColumnExpr("env.refs AS refs").
+		Where("env.ref_count <> env.refs OR env.refs = 0").
+		Order("env.id").
+		Scan(ctx, &ret)
+
+	return ret, err
+}
+
+// environmentReferencesExpr returns an SQL expression that evaluates to the
+// number of rows referencing an environment (the expression must be used in
+// the context of a query on the environments table).
+func environmentReferencesExpr() string {
+	counts := make([]string, len(environmentOwners))
+	for i, table := range environmentOwners {
+		counts[i] = fmt.Sprintf(
+			"(SELECT COUNT(*) FROM %s WHERE %s.environment_id = environments.id)",
+			table, table,
+		)
+	}
+
+	return strings.Join(counts, " + ")
+}
+
+// upsertEnvironments ensures that the provided environments exist in the
+// database, adding the value of their RefCount fields to the reference counts
+// of the corresponding database entries. Concurrent upserts of the same
+// environment are safe, as uniqueness is enforced by the database (via
+// content_hash). On return, the IDs and RefCounts of the environments are
+// updated to match their database entries. The environments must have
+// distinct contents.
+func upsertEnvironments(ctx context.Context, db bun.IDB, envs []*Environment) error {
+	hashes := make([]string, len(envs))
+	for i, env := range envs {
+		env.ID = 0
+		env.ContentHash = env.CalculateContentHash()
+		hashes[i] = env.ContentHash
+	}
+
+	query := db.NewInsert().Model(&envs).Returning("")
+	if db.Dialect().Name().String() == "mysql" {
+		query.On("DUPLICATE KEY UPDATE").
+			Set("ref_count = ref_count + VALUES(ref_count)")
+	} else {
+		query.On("CONFLICT (content_hash) DO UPDATE").
+			Set("ref_count = ?TableAlias.ref_count + EXCLUDED.ref_count")
+	}
+
+	if _, err := query.Exec(ctx); err != nil {
+		return err
+	}
+
+	var stored []*Environment
+	err := db.NewSelect().
+		Model(&stored).
+		Column("id", "content_hash", "ref_count").
+		Where("content_hash IN (?)", bun.In(hashes)).
 		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	byHash := make(map[string]*Environment, len(stored))
+	for _, env := range stored {
+		byHash[env.ContentHash] = env
+	}
+
+	for _, env := range envs {
+		entry, ok := byHash[env.ContentHash]
+		if !ok {
+			// coverage:ignore
+			return fmt.Errorf("environment with content hash %s not found after upsert", env.ContentHash)
+		}
+
+		env.ID = entry.ID
+		env.RefCount = entry.RefCount
+	}
+
+	return nil
+}
+
+func writeOptionalBytes(w io.Writer, value *[]byte) {
+	if value == nil {
+		_, _ = w.Write([]byte{0})
+		return
+	}
+
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(*value)))
+
+	_, _ = w.Write([]byte{1})
+	_, _ = w.Write(length[:])
+	_, _ = w.Write(*value)
+}
+
+func writeOptionalString(w io.Writer, value *string) {
+	if value == nil {
+		writeOptionalBytes(w, nil)
+		return
+	}
+
+	bytes := []byte(*value)
+	writeOptionalBytes(w, &bytes)
+}
 
-	if err == nil || errors.Is(err, sql.ErrNoRows) {
-		return ownerIDs, nil
+func writeOptionalUint(w io.Writer, value *uint64) {
+	if value == nil {
+		writeOptionalBytes(w, nil)
+		return
 	}
 
-	return nil, err
+	bytes := binary.BigEndian.AppendUint64(nil, *value)
+	writeOptionalBytes(w, &bytes)
 }
 
 func (o *Environment) IsEmpty() bool {
@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"database/sql"
 	"encoding/binary"
 	"fmt"
 	"testing"
@@ -400,16 +401,121 @@ func TestEnvironment_Select(t *testing.T) {
 	assert.True(t, env.IsTable())
 }
 
-func TestEnvironment_DeleteIfOrphaned(t *testing.T) {
+func TestEnvironment_Release(t *testing.T) {
 	var env Environment
+	ctx := context.Background()
 	db := NewTestDB(t)
 
-	err := env.DeleteIfOrphaned(context.Background(), db)
+	err := env.Release(ctx, db)
 	assert.ErrorContains(t, err, "ID not set")
 
 	env.ID = 1
-	err = env.DeleteIfOrphaned(context.Background(), db)
+	err = env.Release(ctx, db)
+	assert.NoError(t, err)
+
+	testVendor := "acme"
+	env = Environment{Vendor: &testVendor}
+	require.NoError(t, env.Insert(ctx, db))
+	require.NoError(t, env.Insert(ctx, db))
+	assert.Equal(t, int64(2), env.RefCount)
+
+	require.NoError(t, env.Release(ctx, db))
+	_, err = SelectEnvironment(ctx, db, env.ID)
 	assert.NoError(t, err)
+
+	require.NoError(t, env.Release(ctx, db))
+	_, err = SelectEnvironment(ctx, db, env.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestEnvironment_CalculateContentHash(t *testing.T) {
+	vendor := "acme"
+	empty := ""
+	layer := uint64(1)
+	bytesType := comid.BytesType
+	bytes := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	envs := []Environment{
+		{},
+		{Vendor: &empty},
+		{Vendor: &vendor},
+		{Model: &testModel},
+		{Vendor: &vendor, Model: &testModel},
+		{Vendor: &vendor, Layer: &layer},
+		{InstanceType: &bytesType, InstanceBytes: &bytes},
+		{GroupType: &bytesType, GroupBytes: &bytes},
+	}
+
+	seen := make(map[string]int)
+	for i, env := range envs {
+		hash := env.CalculateContentHash()
+		assert.Len(t, hash, 64)
+
+		other, ok := seen[hash]
+		assert.False(t, ok, "%d collides with %d", i, other)
+		seen[hash] = i
+
+		env.ID = 42
+		env.RefCount = 7
+		assert.Equal(t, hash, env.CalculateContentHash())
+	}
+}
+
+func TestGarbageCollectEnvironments(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDBWithFixtures(t, map[string][]byte{
+		"sample-key-and-value.yaml": keyAndValueSampleFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	testVendor := "orphan"
+	orphan := Environment{Vendor: &testVendor}
+	require.NoError(t, orphan.Insert(ctx, db))
+
+	numEnvs, err := db.NewSelect().Model((*Environment)(nil)).Count(ctx)
+	require.NoError(t, err)
+
+	deleted, err := GarbageCollectEnvironments(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var envs []*Environment
+	require.NoError(t, db.NewSelect().Model(&envs).Scan(ctx))
+	assert.Len(t, envs, numEnvs-1)
+
+	for _, env := range envs {
+		assert.Positive(t, env.RefCount)
+	}
+}
+
+func TestFindInconsistentEnvironments(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDBWithFixtures(t, map[string][]byte{
+		"sample-key-and-value.yaml": keyAndValueSampleFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	envs, err := FindInconsistentEnvironments(ctx, db)
+	require.NoError(t, err)
+	assert.Empty(t, envs)
+
+	testVendor := "orphan"
+	orphan := Environment{Vendor: &testVendor}
+	require.NoError(t, orphan.Insert(ctx, db))
+
+	_, err = db.NewUpdate().
+		Model((*Environment)(nil)).
+		Set("ref_count = 5").
+		Where("id = 1").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	envs, err = FindInconsistentEnvironments(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []*EnvironmentReferences{
+		{ID: 1, RefCount: 5, References: 2},
+		{ID: orphan.ID, RefCount: 1, References: 0},
+	}, envs)
 }
 
 func TestEnvironment_IsEmpty(t *testing.T) {
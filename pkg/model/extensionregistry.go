@@ -0,0 +1,290 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+	"github.com/veraison/corim/extensions"
+)
+
+var (
+	profileExtensions     = map[string]extensions.Map{}
+	profileExtensionsLock sync.RWMutex
+)
+
+// RegisterProfileExtensions registers the types of the extensions used by the
+// specified profile (an OID or a URI, as stored in Manifest.Profile). When
+// models belonging to manifests with that profile are converted back into
+// CoRIM structures, their extensions are reconstructed as instances of the
+// registered types, rather than as generic structures. The extension values
+// must be pointers to structs, as with corim.RegisterProfile. An error is
+// returned if extensions have already been registered for the profile.
+func RegisterProfileExtensions(profile string, exts extensions.Map) error {
+	if profile == "" {
+		return errors.New("profile not specified")
+	}
+
+	for point, val := range exts {
+		if _, ok := corim.AllExtensionPoints[point]; !ok {
+			return fmt.Errorf("%w: %q", extensions.ErrUnexpectedPoint, point)
+		}
+
+		typ := reflect.TypeOf(val)
+		if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("extensions for %q must be a pointer to a struct", point)
+		}
+	}
+
+	profileExtensionsLock.Lock()
+	defer profileExtensionsLock.Unlock()
+
+	if _, ok := profileExtensions[profile]; ok {
+		return fmt.Errorf("extensions for profile %q already registered", profile)
+	}
+
+	profileExtensions[profile] = exts
+
+	return nil
+}
+
+// UnregisterProfileExtensions removes extensions previously registered for
+// the profile via RegisterProfileExtensions. It returns true if extensions
+// were registered, and false otherwise.
+func UnregisterProfileExtensions(profile string) bool {
+	profileExtensionsLock.Lock()
+	defer profileExtensionsLock.Unlock()
+
+	if _, ok := profileExtensions[profile]; !ok {
+		return false
+	}
+
+	delete(profileExtensions, profile)
+
+	return true
+}
+
+// ProfileExtensions returns the extension types registered for the profile.
+// Extensions registered via RegisterProfileExtensions take precedence;
+// otherwise, those of the profile registered with the corim package (e.g. by
+// importing github.com/veraison/corim/profiles/cca) are returned. The second
+// return value indicates whether extensions have been found.
+func ProfileExtensions(profile string) (extensions.Map, bool) {
+	if profile == "" {
+		return nil, false
+	}
+
+	profileExtensionsLock.RLock()
+	exts, ok := profileExtensions[profile]
+	profileExtensionsLock.RUnlock()
+
+	if ok {
+		return exts, true
+	}
+
+	id, err := corim.NewProfileFromString(profile)
+	if err != nil {
+		return nil, false
+	}
+
+	manifest, ok := corim.GetProfileManifest(id)
+	if !ok || len(manifest.MapExtensions) == 0 {
+		return nil, false
+	}
+
+	return manifest.MapExtensions, true
+}
+
+// ApplyProfileExtensionsToCoMID replaces the generic extensions inside the
+// CoMID (as returned by ModuleTag.ToCoRIM) with instances of the types
+// registered for the profile. This is a no-op if no extensions are registered
+// for the profile.
+func ApplyProfileExtensionsToCoMID(c *comid.Comid, profile string) error {
+	exts, ok := ProfileExtensions(profile)
+	if !ok {
+		return nil
+	}
+
+	if err := retypeExtensions(&c.Extensions.Extensions, exts[comid.ExtComid]); err != nil {
+		return fmt.Errorf("comid: %w", err)
+	}
+
+	if c.Entities != nil {
+		for i := range c.Entities.Values {
+			err := retypeExtensions(&c.Entities.Values[i].Extensions.Extensions, exts[comid.ExtEntity])
+			if err != nil {
+				return fmt.Errorf("entity at index %d: %w", i, err)
+			}
+		}
+	}
+
+	if err := retypeExtensions(&c.Triples.Extensions.Extensions, exts[comid.ExtTriples]); err != nil {
+		return fmt.Errorf("triples: %w", err)
+	}
+
+	if c.Triples.ReferenceValues != nil {
+		for i := range c.Triples.ReferenceValues.Values {
+			if err := applyValueTripleExtensions(
+				&c.Triples.ReferenceValues.Values[i], ReferenceValueTriple, exts,
+			); err != nil {
+				return fmt.Errorf("reference value at index %d: %w", i, err)
+			}
+		}
+	}
+
+	if c.Triples.EndorsedValues != nil {
+		for i := range c.Triples.EndorsedValues.Values {
+			if err := applyValueTripleExtensions(
+				&c.Triples.EndorsedValues.Values[i], EndorsedValueTriple, exts,
+			); err != nil {
+				return fmt.Errorf("endorsed value at index %d: %w", i, err)
+			}
+		}
+	}
+
+	if c.Triples.CondEndorsements != nil {
+		for i := range c.Triples.CondEndorsements.Values {
+			if err := applyCondEndorseTripleExtensions(&c.Triples.CondEndorsements.Values[i], exts); err != nil {
+				return fmt.Errorf("conditional endorsement at index %d: %w", i, err)
+			}
+		}
+	}
+
+	if c.Triples.CondEndorseSeries != nil {
+		for i := range c.Triples.CondEndorseSeries.Values {
+			if err := applyCondEndorseSeriesTripleExtensions(&c.Triples.CondEndorseSeries.Values[i], exts); err != nil {
+				return fmt.Errorf("conditional endorsement series at index %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ApplyProfileExtensionsToCoRIM replaces the generic extensions inside the
+// unsigned CoRIM (as returned by Manifest.ToCoRIM) with instances of the types
+// registered for the profile. Contained CoMIDs are already CBOR-encoded, and
+// so are not affected. This is a no-op if no extensions are registered for
+// the profile.
+func ApplyProfileExtensionsToCoRIM(c *corim.UnsignedCorim, profile string) error {
+	exts, ok := ProfileExtensions(profile)
+	if !ok {
+		return nil
+	}
+
+	if err := retypeExtensions(&c.Extensions.Extensions, exts[corim.ExtUnsignedCorim]); err != nil {
+		return fmt.Errorf("corim: %w", err)
+	}
+
+	if c.Entities != nil {
+		for i := range c.Entities.Values {
+			err := retypeExtensions(&c.Entities.Values[i].Extensions.Extensions, exts[corim.ExtEntity])
+			if err != nil {
+				return fmt.Errorf("entity at index %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyValueTripleExtensions(triple *comid.ValueTriple, typ ValueTripleType, exts extensions.Map) error {
+	switch typ {
+	case ReferenceValueTriple:
+		return applyMeasurementExtensions(&triple.Measurements,
+			exts[comid.ExtReferenceValue], exts[comid.ExtReferenceValueFlags])
+	case EndorsedValueTriple:
+		return applyMeasurementExtensions(&triple.Measurements,
+			exts[comid.ExtEndorsedValue], exts[comid.ExtEndorsedValueFlags])
+	default:
+		return fmt.Errorf("unexpected value triple type: %s", typ)
+	}
+}
+
+func applyCondEndorseTripleExtensions(triple *comid.CondEndorseTriple, exts extensions.Map) error {
+	mval := exts[comid.ExtCondEndorseValue]
+	flags := exts[comid.ExtCondEndorseValueFlags]
+
+	for i := range triple.Conditions.Values {
+		if err := applyMeasurementExtensions(&triple.Conditions.Values[i].Measurements, mval, flags); err != nil {
+			return fmt.Errorf("condition at index %d: %w", i, err)
+		}
+	}
+
+	for i := range triple.Endorsements.Values {
+		if err := applyMeasurementExtensions(&triple.Endorsements.Values[i].Measurements, mval, flags); err != nil {
+			return fmt.Errorf("endorsement at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func applyCondEndorseSeriesTripleExtensions(triple *comid.CondEndorseSeriesTriple, exts extensions.Map) error {
+	mval := exts[comid.ExtCondEndorseSeriesValue]
+	flags := exts[comid.ExtCondEndorseSeriesValueFlags]
+
+	if err := applyMeasurementExtensions(&triple.Condition.Measurements, mval, flags); err != nil {
+		return fmt.Errorf("condition: %w", err)
+	}
+
+	for i := range triple.Series.Values {
+		record := &triple.Series.Values[i]
+
+		if err := applyMeasurementExtensions(&record.Selection, mval, flags); err != nil {
+			return fmt.Errorf("series record at index %d: selection: %w", i, err)
+		}
+
+		if err := applyMeasurementExtensions(&record.Addition, mval, flags); err != nil {
+			return fmt.Errorf("series record at index %d: addition: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMeasurementExtensions(measurements *comid.Measurements, mval, flags extensions.IMapValue) error {
+	for i := range measurements.Values {
+		val := &measurements.Values[i].Val
+
+		if err := retypeExtensions(&val.Extensions.Extensions, mval); err != nil {
+			return fmt.Errorf("measurement at index %d: %w", i, err)
+		}
+
+		if val.Flags != nil {
+			if err := retypeExtensions(&val.Flags.Extensions.Extensions, flags); err != nil {
+				return fmt.Errorf("measurement at index %d: flags: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// retypeExtensions replaces the IMapValue of the extensions with a new
+// instance of the type of typ, populated with the same values. This relies on
+// the generic structure created by CoMIDExtensionsToCoRIM retaining the CBOR
+// tags of the original fields. Cached values are left as they are.
+func retypeExtensions(exts *extensions.Extensions, typ extensions.IMapValue) error {
+	if typ == nil || exts.IMapValue == nil {
+		return nil
+	}
+
+	data, err := cbor.Marshal(exts.IMapValue)
+	if err != nil {
+		return fmt.Errorf("CBOR encoding extensions: %w", err)
+	}
+
+	ret := reflect.New(reflect.TypeOf(typ).Elem()).Interface()
+	if err := cbor.Unmarshal(data, ret); err != nil {
+		return fmt.Errorf("CBOR decoding extensions as %T: %w", typ, err)
+	}
+
+	exts.IMapValue = ret
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+	"github.com/veraison/corim/extensions"
+	"github.com/veraison/swid"
+)
+
+type testConfig struct {
+	ID    string `cbor:"0,keyasint" json:"id"`
+	Level int    `cbor:"1,keyasint" json:"level"`
+}
+
+type testMvalExtensions struct {
+	Config *testConfig `cbor:"-70,keyasint,omitempty" json:"config,omitempty"`
+	Vendor *string     `cbor:"-71,keyasint,omitempty" json:"vendor,omitempty"`
+}
+
+type testComidExtensions struct {
+	Owner string `cbor:"-1,keyasint,omitempty" json:"owner,omitempty"`
+}
+
+const testProfile = "http://example.com/test-profile"
+
+func TestRegisterProfileExtensions(t *testing.T) {
+	exts := extensions.NewMap().
+		Add(comid.ExtReferenceValue, &testMvalExtensions{}).
+		Add(comid.ExtComid, &testComidExtensions{})
+
+	err := RegisterProfileExtensions(testProfile, exts)
+	require.NoError(t, err)
+	defer UnregisterProfileExtensions(testProfile)
+
+	err = RegisterProfileExtensions(testProfile, exts)
+	assert.ErrorContains(t, err, "already registered")
+
+	err = RegisterProfileExtensions("", exts)
+	assert.ErrorContains(t, err, "profile not specified")
+
+	err = RegisterProfileExtensions("http://example.com/other",
+		extensions.NewMap().Add(comid.ExtReferenceValue, testMvalExtensions{}))
+	assert.ErrorContains(t, err, "must be a pointer to a struct")
+
+	err = RegisterProfileExtensions("http://example.com/other",
+		extensions.NewMap().Add("Unknown", &testMvalExtensions{}))
+	assert.ErrorIs(t, err, extensions.ErrUnexpectedPoint)
+
+	ret, ok := ProfileExtensions(testProfile)
+	assert.True(t, ok)
+	assert.Equal(t, exts, ret)
+
+	_, ok = ProfileExtensions("http://example.com/other")
+	assert.False(t, ok)
+
+	_, ok = ProfileExtensions("")
+	assert.False(t, ok)
+
+	assert.False(t, UnregisterProfileExtensions("http://example.com/other"))
+}
+
+func TestProfileExtensions_corim_registry(t *testing.T) {
+	profileID, err := corim.NewProfile(testProfile, "uri")
+	require.NoError(t, err)
+
+	exts := extensions.NewMap().Add(comid.ExtComid, &testComidExtensions{})
+	require.NoError(t, corim.RegisterProfile(profileID, exts))
+	defer corim.UnregisterProfile(profileID)
+
+	ret, ok := ProfileExtensions(testProfile)
+	assert.True(t, ok)
+	assert.Equal(t, exts, ret)
+}
+
+func TestModuleTag_ToCoRIM_profile_extensions(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	vendor := "ACME"
+	config := testConfig{ID: "cfg-1", Level: 3}
+
+	measurement := comid.Measurement{
+		Val: comid.Mval{
+			RawValue: comid.NewRawValueFromBytes(comid.MustHexDecode(t, "deadbeef")),
+		},
+	}
+	measurement.Val.Register(&testMvalExtensions{Config: &config, Vendor: &vendor})
+
+	origin := comid.Comid{
+		TagIdentity: comid.TagIdentity{TagID: *swid.NewTagID("foo")},
+		Triples: comid.Triples{
+			ReferenceValues: comid.NewValueTriples().Add(&comid.ValueTriple{
+				Environment: comid.Environment{
+					Instance: comid.MustNewUUIDInstance(comid.TestUUID),
+				},
+				Measurements: *comid.NewMeasurements().Add(&measurement),
+			}),
+		},
+	}
+	origin.Register(&testComidExtensions{Owner: "acme"})
+
+	mt, err := NewModuleTagFromCoRIM(&origin)
+	require.NoError(t, err)
+	require.NoError(t, mt.Insert(ctx, db))
+
+	selected := ModuleTag{ID: mt.ID}
+	require.NoError(t, selected.Select(ctx, db))
+
+	// without registered extensions, generic structures are returned
+	generic, err := selected.ToCoRIM()
+	require.NoError(t, err)
+	_, ok := generic.Extensions.IMapValue.(*testComidExtensions)
+	assert.False(t, ok)
+
+	require.NoError(t, RegisterProfileExtensions(testProfile, extensions.NewMap().
+		Add(comid.ExtReferenceValue, &testMvalExtensions{}).
+		Add(comid.ExtComid, &testComidExtensions{})))
+	defer UnregisterProfileExtensions(testProfile)
+
+	selected.Profile = testProfile
+	typed, err := selected.ToCoRIM()
+	require.NoError(t, err)
+
+	comidExts, ok := typed.Extensions.IMapValue.(*testComidExtensions)
+	require.True(t, ok)
+	assert.Equal(t, "acme", comidExts.Owner)
+
+	mval := typed.Triples.ReferenceValues.Values[0].Measurements.Values[0].Val
+	mvalExts, ok := mval.Extensions.IMapValue.(*testMvalExtensions)
+	require.True(t, ok)
+	assert.Equal(t, config, *mvalExts.Config)
+	assert.Equal(t, vendor, *mvalExts.Vendor)
+
+	triple := ValueTriple{ID: selected.ValueTriples[0].ID}
+	require.NoError(t, triple.Select(ctx, db))
+	triple.Profile = testProfile
+
+	vt, err := triple.ToCoRIM()
+	require.NoError(t, err)
+	_, ok = vt.Measurements.Values[0].Val.Extensions.IMapValue.(*testMvalExtensions)
+	assert.True(t, ok)
+}
+
+func TestManifest_ToCoRIM_profile_extensions(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	testComid := comid.Comid{
+		TagIdentity: comid.TagIdentity{TagID: *swid.NewTagID("foo")},
+		Triples: comid.Triples{
+			ReferenceValues: comid.NewValueTriples().Add(&comid.ValueTriple{
+				Environment: comid.Environment{
+					Instance: comid.MustNewUUIDInstance(comid.TestUUID),
+				},
+				Measurements: *comid.NewMeasurements().Add(&comid.Measurement{
+					Val: comid.Mval{
+						RawValue: comid.NewRawValueFromBytes(comid.MustHexDecode(t, "deadbeef")),
+					},
+				}),
+			}),
+		},
+	}
+
+	origin := corim.NewUnsignedCorim().
+		SetID("bar").
+		SetProfile(testProfile).
+		AddComid(&testComid)
+	origin.Register(&testComidExtensions{Owner: "acme"})
+
+	man, err := NewManifestFromCoRIM(origin)
+	require.NoError(t, err)
+	require.NoError(t, man.Insert(ctx, db))
+
+	require.NoError(t, RegisterProfileExtensions(testProfile, extensions.NewMap().
+		Add(corim.ExtUnsignedCorim, &testComidExtensions{})))
+	defer UnregisterProfileExtensions(testProfile)
+
+	selected, err := SelectManifest(ctx, db, man.ID)
+	require.NoError(t, err)
+	require.Len(t, selected.Extensions, 1)
+	assert.Equal(t, testProfile, selected.ModuleTags[0].Profile)
+
+	uc, err := selected.ToCoRIM()
+	require.NoError(t, err)
+
+	exts, ok := uc.Extensions.IMapValue.(*testComidExtensions)
+	require.True(t, ok)
+	assert.Equal(t, "acme", exts.Owner)
+
+	require.NoError(t, selected.Delete(ctx, db))
+
+	count, err := db.NewSelect().Model((*ExtensionValue)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/uptrace/bun"
+)
+
+// Reference describes a column of Table whose values are IDs of rows inside
+// Target. For polymorphic references (where Column is "owner_id"), OwnerType
+// is the value of the owner_type column for which the reference applies.
+type Reference struct {
+	Table     string
+	Column    string
+	OwnerType string
+	Target    string
+}
+
+// polymorphicReferences lists, for each table with polymorphic owners, the
+// tables corresponding to each of the possible owner types.
+var polymorphicReferences = map[string]map[string]string{
+	"cryptokeys": {
+		"token":            "tokens",
+		"key_triple":       "key_triples",
+		"key_triple_auth":  "key_triples",
+		"measurement":      "measurements",
+		"measurement_auth": "measurements",
+		"ces_condition":    "conditional_endorsement_series_triples",
+	},
+	"digests": {
+		"integrity_register": "integrity_registers",
+		"locator":            "locators",
+		"measurement":        "measurements",
+	},
+	"domain_entries": {
+		"domain_dependency_triple": "domain_dependency_triples",
+		"domain_membership_triple": "domain_membership_triples",
+	},
+	"entities": {
+		"manifest":   "manifests",
+		"module_tag": "module_tags",
+	},
+	"extensions": {
+		"entity":      "entities",
+		"manifest":    "manifests",
+		"measurement": "measurements",
+		"module_tag":  "module_tags",
+		"triples":     "module_tags",
+	},
+	"measurements": {
+		"ces_condition":        "conditional_endorsement_series_triples",
+		"ces_record_addition":  "conditional_endorsement_series_records",
+		"ces_record_selection": "conditional_endorsement_series_records",
+		"stateful_environment": "stateful_environments",
+		"value_triple":         "value_triples",
+	},
+	"value_triples": {
+		"conditional_endorsement_triple": "conditional_endorsement_triples",
+		"module_tag":                     "module_tags",
+	},
+}
+
+// directReferences lists the non-polymorphic references (other than
+// environment IDs, which are added from environmentOwners).
+var directReferences = []Reference{
+	{Table: "conditional_endorsement_series_records", Column: "triple_id", Target: "conditional_endorsement_series_triples"},
+	{Table: "conditional_endorsement_series_triples", Column: "module_id", Target: "module_tags"},
+	{Table: "conditional_endorsement_triples", Column: "module_id", Target: "module_tags"},
+	{Table: "domain_dependency_triples", Column: "module_id", Target: "module_tags"},
+	{Table: "domain_membership_triples", Column: "module_id", Target: "module_tags"},
+	{Table: "flags", Column: "measurement_id", Target: "measurements"},
+	{Table: "hrefs", Column: "locator_id", Target: "locators"},
+	{Table: "integrity_registers", Column: "measurement_id", Target: "measurements"},
+	{Table: "key_triples", Column: "module_id", Target: "module_tags"},
+	{Table: "linked_tags", Column: "module_id", Target: "module_tags"},
+	{Table: "locators", Column: "manifest_id", Target: "manifests"},
+	{Table: "module_tags", Column: "manifest_id", Target: "manifests"},
+	{Table: "roles", Column: "entity_id", Target: "entities"},
+	{Table: "stateful_environments", Column: "triple_id", Target: "conditional_endorsement_triples"},
+}
+
+// References returns all references between the store's tables.
+func References() []Reference {
+	ret := slices.Clone(directReferences)
+
+	for _, table := range environmentOwners {
+		ret = append(ret, Reference{Table: table, Column: "environment_id", Target: "environments"})
+	}
+
+	for _, table := range sortedKeys(polymorphicReferences) {
+		owners := polymorphicReferences[table]
+		for _, ownerType := range sortedKeys(owners) {
+			ret = append(ret, Reference{
+				Table:     table,
+				Column:    "owner_id",
+				OwnerType: ownerType,
+				Target:    owners[ownerType],
+			})
+		}
+	}
+
+	return ret
+}
+
+// DanglingRow is a row that references a row that does not exist. If the
+// row's owner_type is not one of the expected values, Reference.Target is
+// empty.
+type DanglingRow struct {
+	Reference
+	ID    int64
+	Value int64
+}
+
+func (o *DanglingRow) String() string {
+	if o.Target == "" {
+		return fmt.Sprintf("%s %d: unexpected owner type %q", o.Table, o.ID, o.OwnerType)
+	}
+
+	if o.OwnerType != "" {
+		return fmt.Sprintf("%s %d: %s owner %d does not exist", o.Table, o.ID, o.OwnerType, o.Value)
+	}
+
+	return fmt.Sprintf("%s %d: %s %d does not exist in %s", o.Table, o.ID, o.Column, o.Value, o.Target)
+}
+
+// FindDanglingRows returns the rows that reference rows that do not exist
+// (e.g. because they were left behind by an interrupted insertion or
+// deletion), and rows with polymorphic owners of unexpected types.
+func FindDanglingRows(ctx context.Context, db bun.IDB) ([]*DanglingRow, error) {
+	var ret []*DanglingRow
+
+	for _, ref := range References() {
+		var rows []struct {
+			ID    int64
+			Value int64
+		}
+
+		query := db.NewSelect().
+			TableExpr("? AS t", bun.Ident(ref.Table)).
+			ColumnExpr("t.id AS id").
+			ColumnExpr("t.? AS value", bun.Ident(ref.Column)).
+			Where("t.? IS NOT NULL", bun.Ident(ref.Column)).
+			Where("NOT EXISTS (SELECT 1 FROM ? AS o WHERE o.id = t.?)",
+				bun.Ident(ref.Target), bun.Ident(ref.Column)).
+			Order("t.id")
+
+		if ref.OwnerType != "" {
+			query.Where("t.owner_type = ?", ref.OwnerType)
+		}
+
+		if err := query.Scan(ctx, &rows); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", ref.Table, ref.Column, err)
+		}
+
+		for _, row := range rows {
+			ret = append(ret, &DanglingRow{Reference: ref, ID: row.ID, Value: row.Value})
+		}
+	}
+
+	for _, table := range sortedKeys(polymorphicReferences) {
+		var rows []struct {
+			ID        int64
+			OwnerType string
+		}
+
+		err := db.NewSelect().
+			TableExpr("? AS t", bun.Ident(table)).
+			ColumnExpr("t.id AS id").
+			ColumnExpr("t.owner_type AS owner_type").
+			Where("t.owner_type NOT IN (?)", bun.In(sortedKeys(polymorphicReferences[table]))).
+			Order("t.id").
+			Scan(ctx, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s.owner_type: %w", table, err)
+		}
+
+		for _, row := range rows {
+			ret = append(ret, &DanglingRow{
+				Reference: Reference{Table: table, Column: "owner_type", OwnerType: row.OwnerType},
+				ID:        row.ID,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+// DeleteDanglingRows deletes the rows returned by FindDanglingRows. As this
+// may, in turn, leave other rows dangling, this is repeated until no dangling
+// rows remain. The total number of deleted rows is returned. Note that
+// environment reference counts are not updated (see
+// GarbageCollectEnvironments).
+func DeleteDanglingRows(ctx context.Context, db bun.IDB) (int64, error) {
+	var total int64
+
+	for {
+		rows, err := FindDanglingRows(ctx, db)
+		if err != nil {
+			return total, err
+		}
+
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		for _, row := range rows {
+			res, err := db.NewDelete().
+				TableExpr("?", bun.Ident(row.Table)).
+				Where("id = ?", row.ID).
+				Exec(ctx)
+			if err != nil {
+				return total, fmt.Errorf("%s: %w", row, err)
+			}
+
+			count, err := res.RowsAffected()
+			if err != nil {
+				// coverage:ignore
+				return total, err
+			}
+
+			total += count
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+
+	slices.Sort(ret)
+
+	return ret
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDanglingRows(t *testing.T) {
+	ctx := context.Background()
+
+	db := NewTestDBWithFixtures(t, map[string][]byte{
+		"sample-conditional-and-domain.yaml": conditionalAndDomainSampleFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	rows, err := FindDanglingRows(ctx, db)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	var measurementIDs []int64
+	err = db.NewSelect().
+		Model((*Measurement)(nil)).
+		Column("id").
+		Where("owner_type = ?", "value_triple").
+		Order("id").
+		Scan(ctx, &measurementIDs)
+	require.NoError(t, err)
+	require.NotEmpty(t, measurementIDs)
+
+	// simulate an interrupted deletion of a value triple
+	var triple ValueTriple
+	err = db.NewSelect().
+		Model(&triple).
+		Where("id = (SELECT owner_id FROM measurements WHERE id = ?)", measurementIDs[0]).
+		Scan(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewDelete().Model(&triple).WherePK().Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewUpdate().
+		Model((*CryptoKey)(nil)).
+		Set("owner_type = ?", "bogus").
+		Where("id = (SELECT MIN(id) FROM cryptokeys)").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	rows, err = FindDanglingRows(ctx, db)
+	require.NoError(t, err)
+	require.NotEmpty(t, rows)
+
+	assert.Equal(t, "measurements", rows[0].Table)
+	assert.Equal(t, "value_triple", rows[0].OwnerType)
+	assert.Equal(t, triple.ID, rows[0].Value)
+	assert.Contains(t, rows[0].String(), "value_triple owner")
+
+	last := rows[len(rows)-1]
+	assert.Equal(t, "cryptokeys", last.Table)
+	assert.Equal(t, "bogus", last.OwnerType)
+	assert.Contains(t, last.String(), "unexpected owner type")
+
+	deleted, err := DeleteDanglingRows(ctx, db)
+	require.NoError(t, err)
+	// the dangling rows, along with any rows owned by them
+	assert.GreaterOrEqual(t, deleted, int64(len(rows)))
+
+	rows, err = FindDanglingRows(ctx, db)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
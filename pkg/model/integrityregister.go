@@ -149,6 +149,14 @@ func (o *IntegrityRegister) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *IntegrityRegister) bulkChildren(ins *bulkInserter) {
+	for _, digest := range o.Digests {
+		digest.OwnerID = o.ID
+		digest.OwnerType = "integrity_register"
+	}
+	bulkEnqueue(ins, o.Digests...)
+}
+
 func (o *IntegrityRegister) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -11,7 +11,8 @@ import (
 )
 
 var testDigest = Digest{
-	AlgIDInt: 1,
+	AlgIDInt:       1,
+	CanonicalAlgID: 1,
 	Value: []byte{
 		0xe4, 0x5b, 0x72, 0xf5, 0xc0, 0xc0, 0xb5, 0x72,
 		0xdb, 0x4d, 0x8d, 0x3a, 0xb7, 0xe9, 0x7f, 0x36,
@@ -262,6 +262,28 @@ func (o *KeyTriple) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *KeyTriple) bulkChildren(ins *bulkInserter) {
+	for _, key := range o.KeyList {
+		key.OwnerID = o.ID
+		key.OwnerType = "key_triple"
+	}
+	bulkEnqueue(ins, o.KeyList...)
+
+	for _, key := range o.AuthorizedBy {
+		key.OwnerID = o.ID
+		key.OwnerType = "key_triple_auth"
+	}
+	bulkEnqueue(ins, o.AuthorizedBy...)
+}
+
+func (o *KeyTriple) bulkEnvironment() *Environment {
+	return o.Environment
+}
+
+func (o *KeyTriple) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *KeyTriple) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -303,7 +325,7 @@ func (o *KeyTriple) Delete(ctx context.Context, db bun.IDB) error {
 		return err
 	}
 
-	return o.Environment.DeleteIfOrphaned(ctx, db)
+	return o.Environment.Release(ctx, db)
 }
 
 func (o *KeyTriple) TripleType() string {
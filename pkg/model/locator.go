@@ -137,6 +137,19 @@ func (o *Locator) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *Locator) bulkChildren(ins *bulkInserter) {
+	for _, href := range o.Href {
+		href.LocatorID = o.ID
+	}
+	bulkEnqueue(ins, o.Href...)
+
+	for _, digest := range o.Thumbprint {
+		digest.OwnerID = o.ID
+		digest.OwnerType = "locator"
+	}
+	bulkEnqueue(ins, o.Thumbprint...)
+}
+
 func (o *Locator) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
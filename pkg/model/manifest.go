@@ -46,7 +46,7 @@ type Manifest struct {
 
 	ModuleTags []*ModuleTag `bun:"rel:has-many,join:id=manifest_id"`
 
-	Extensions []*ExtensionValue `bun:"rel:has-many,join:id=owner_id,join:type=owner_type,polymorphic:module_tag"`
+	Extensions []*ExtensionValue `bun:"rel:has-many,join:id=owner_id,join:type=owner_type,polymorphic:manifest"`
 }
 
 func NewManifestFromCoRIM(origin *corim.UnsignedCorim) (*Manifest, error) {
@@ -217,6 +217,10 @@ func (o *Manifest) ToCoRIM() (*corim.UnsignedCorim, error) {
 		return nil, err
 	}
 
+	if err := ApplyProfileExtensionsToCoRIM(&ret, o.Profile); err != nil {
+		return nil, fmt.Errorf("profile extensions: %w", err)
+	}
+
 	return &ret, nil
 }
 
@@ -231,45 +235,33 @@ func (o *Manifest) Insert(ctx context.Context, db bun.IDB) error {
 		return err
 	}
 
-	if _, err := db.NewInsert().Model(o).Exec(ctx); err != nil {
-		return err
-	}
+	// the nested models are inserted level by level, using multi-row
+	// INSERTs for each table (see bulkInserter).
+	return bulkInsert(ctx, db, o)
+}
 
+func (o *Manifest) bulkChildren(ins *bulkInserter) {
 	for _, entity := range o.Entities {
 		entity.OwnerID = o.ID
 		entity.OwnerType = "manifest"
-
-		if err := entity.Insert(ctx, db); err != nil {
-			return fmt.Errorf("error inserting linked tag %+v: %w", entity, err)
-		}
 	}
+	bulkEnqueue(ins, o.Entities...)
 
 	for _, locator := range o.DependentRIMs {
 		locator.ManifestID = o.ID
-
-		if err := locator.Insert(ctx, db); err != nil {
-			return fmt.Errorf("error inserting locator %+v: %w", locator, err)
-		}
 	}
+	bulkEnqueue(ins, o.DependentRIMs...)
 
 	for _, moduleTag := range o.ModuleTags {
 		moduleTag.ManifestID = o.ID
-
-		if err := moduleTag.Insert(ctx, db); err != nil {
-			return fmt.Errorf("error inserting module tag %+v: %w", moduleTag, err)
-		}
 	}
+	bulkEnqueue(ins, o.ModuleTags...)
 
 	for _, ext := range o.Extensions {
 		ext.OwnerID = o.ID
 		ext.OwnerType = "manifest"
-
-		if err := ext.Insert(ctx, db); err != nil {
-			return fmt.Errorf("error inserting extension %+v: %w", ext, err)
-		}
 	}
-
-	return nil
+	bulkEnqueue(ins, o.Extensions...)
 }
 
 func (o *Manifest) Select(ctx context.Context, db bun.IDB) error { // nolint:dupl
@@ -300,6 +292,8 @@ func (o *Manifest) Select(ctx context.Context, db bun.IDB) error { // nolint:dup
 		if err := moduleTag.Select(ctx, db); err != nil {
 			return fmt.Errorf("module tag at index %d: %w", i, err)
 		}
+
+		moduleTag.Profile = o.Profile
 	}
 
 	for i, locator := range o.DependentRIMs {
@@ -334,6 +328,13 @@ func (o *Manifest) Delete(ctx context.Context, db bun.IDB) error {
 		}
 	}
 
+	for i, extension := range o.Extensions {
+		if err := extension.Delete(ctx, db); err != nil {
+			// coverage:ignore
+			return fmt.Errorf("extension at index %d: %w", i, err)
+		}
+	}
+
 	_, err := db.NewDelete().Model(o).WherePK().Exec(ctx)
 	return err
 }
@@ -416,6 +416,54 @@ func TestManifest_Delete(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestManifest_extensions(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDB(t)
+
+	// the manifest and its module tag get the same database ID, so their
+	// extensions may only be told apart by owner type
+	manifest := Manifest{
+		ManifestIDType: StringTagID,
+		ManifestID:     "foo",
+		ModuleTags: []*ModuleTag{
+			{
+				TagIDType: StringTagID,
+				TagID:     "bar",
+				ValueTriples: []*ValueTriple{
+					{
+						Type:         ReferenceValueTriple,
+						Environment:  &Environment{},
+						Measurements: []*Measurement{{}},
+					},
+				},
+				Extensions: []*ExtensionValue{
+					{FieldKind: reflect.Int64, FieldName: "fum", ValueInt: 3},
+				},
+			},
+		},
+		Extensions: []*ExtensionValue{
+			{FieldKind: reflect.Int64, FieldName: "zot", ValueInt: 7},
+		},
+	}
+
+	require.NoError(t, manifest.Insert(ctx, db))
+	require.Equal(t, manifest.ID, manifest.ModuleTags[0].ID)
+
+	selected, err := SelectManifest(ctx, db, manifest.ID)
+	require.NoError(t, err)
+	require.Len(t, selected.Extensions, 1)
+	assert.Equal(t, "zot", selected.Extensions[0].FieldName)
+	require.Len(t, selected.ModuleTags, 1)
+	require.Len(t, selected.ModuleTags[0].Extensions, 1)
+	assert.Equal(t, "fum", selected.ModuleTags[0].Extensions[0].FieldName)
+
+	require.NoError(t, selected.Delete(ctx, db))
+
+	count, err := db.NewSelect().Model((*ExtensionValue)(nil)).Count(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
 func TestManifest_model_methods(t *testing.T) {
 	val := Manifest{ID: 1}
 	assert.Equal(t, val.ID, val.DbID())
@@ -42,6 +42,9 @@ type MeasurementValueEntry struct {
 	ValueBytes *[]byte
 	ValueText  *string
 	ValueInt   *int64
+	// ValueMask is the mask of a raw value. It is set for masked raw
+	// values, and for bytes raw values with a raw-value-mask.
+	ValueMask *[]byte
 
 	MeasurementID int64
 }
@@ -250,9 +253,23 @@ func (o *Measurement) FromCoRIM(origin *comid.Measurement) error {
 
 	if origin.Val.RawValue != nil {
 		var bytes []byte
-		if origin.Val.RawValue.Type() == comid.BytesType {
+		var mask *[]byte
+
+		switch origin.Val.RawValue.Type() {
+		case comid.BytesType:
 			bytes = origin.Val.RawValue.Bytes()
-		} else {
+			// the deprecated raw-value-mask applies to bytes
+			// raw values
+			mask = origin.Val.RawValueMask
+		case comid.MaskedType:
+			// value and mask are stored separately so that they
+			// can be matched against (see
+			// MeasurementQuery.RawValueMatches in the store
+			// package).
+			bytes = origin.Val.RawValue.Bytes()
+			maskBytes := origin.Val.RawValue.Mask()
+			mask = &maskBytes
+		default:
 			var err error
 			bytes, err = origin.Val.RawValue.MarshalCBOR()
 			if err != nil {
@@ -264,6 +281,7 @@ func (o *Measurement) FromCoRIM(origin *comid.Measurement) error {
 			CodePoint:  MvalRawValue,
 			ValueType:  origin.Val.RawValue.Type(),
 			ValueBytes: &bytes,
+			ValueMask:  mask,
 		})
 	}
 
@@ -411,6 +429,16 @@ func (o *Measurement) ToCoRIM() (*comid.Measurement, error) {
 			switch entry.ValueType {
 			case comid.BytesType:
 				mval.RawValue = comid.NewRawValueFromBytes(*entry.ValueBytes)
+				mval.RawValueMask = entry.ValueMask
+			case comid.MaskedType:
+				if entry.ValueMask == nil {
+					return nil, fmt.Errorf("missing RawValue mask: %+v", entry)
+				}
+
+				mval.RawValue, err = comid.NewRawValueWithMask(*entry.ValueBytes, *entry.ValueMask)
+				if err != nil {
+					return nil, fmt.Errorf("raw-value: %w", err)
+				}
 			default:
 				out, err := comid.NewRawValue(nil, entry.ValueType)
 				if err != nil {
@@ -621,6 +649,47 @@ func (o *Measurement) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *Measurement) bulkChildren(ins *bulkInserter) {
+	for _, entry := range o.ValueEntries {
+		entry.MeasurementID = o.ID
+	}
+	bulkEnqueue(ins, o.ValueEntries...)
+
+	for _, digest := range o.Digests {
+		digest.OwnerID = o.ID
+		digest.OwnerType = "measurement"
+	}
+	bulkEnqueue(ins, o.Digests...)
+
+	for _, flag := range o.Flags {
+		flag.MeasurementID = o.ID
+	}
+	bulkEnqueue(ins, o.Flags...)
+
+	for _, reg := range o.IntegrityRegisters {
+		reg.MeasurementID = o.ID
+	}
+	bulkEnqueue(ins, o.IntegrityRegisters...)
+
+	for _, ext := range o.Extensions {
+		ext.OwnerID = o.ID
+		ext.OwnerType = "measurement"
+	}
+	bulkEnqueue(ins, o.Extensions...)
+
+	for _, key := range o.CryptoKeys {
+		key.OwnerID = o.ID
+		key.OwnerType = "measurement"
+	}
+	bulkEnqueue(ins, o.CryptoKeys...)
+
+	for _, key := range o.AuthorizedBy {
+		key.OwnerID = o.ID
+		key.OwnerType = "measurement_auth"
+	}
+	bulkEnqueue(ins, o.AuthorizedBy...)
+}
+
 func (o *Measurement) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
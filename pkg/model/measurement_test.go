@@ -246,16 +246,29 @@ func TestMeasurement_convert(t *testing.T) {
 			expected: Measurement{
 				ValueEntries: []*MeasurementValueEntry{
 					{
-						CodePoint: MvalRawValue,
-						ValueType: comid.MaskedType,
-						ValueBytes: &[]byte{
-							0xd9, 0x02, 0x33, // tag(563)
-							0x82, //             . array(2)
-							0x41, //             . . [0]bstr(1)
-							0x01, //             . . . 01
-							0x41, //             . . [2]bstr(1)
-							0xff, //             . . . ff
-						},
+						CodePoint:  MvalRawValue,
+						ValueType:  comid.MaskedType,
+						ValueBytes: &[]byte{0x01},
+						ValueMask:  &[]byte{0xff},
+					},
+				},
+			},
+		},
+		{
+			title: "ok RawValue bytes with raw-value-mask",
+			origin: comid.Measurement{
+				Val: comid.Mval{
+					RawValue:     comid.NewRawValueFromBytes([]byte{0x01, 0x02}),
+					RawValueMask: &[]byte{0xff, 0x00},
+				},
+			},
+			expected: Measurement{
+				ValueEntries: []*MeasurementValueEntry{
+					{
+						CodePoint:  MvalRawValue,
+						ValueType:  comid.BytesType,
+						ValueBytes: &[]byte{0x01, 0x02},
+						ValueMask:  &[]byte{0xff, 0x00},
 					},
 				},
 			},
@@ -652,7 +665,7 @@ func TestMeasurement_ToCoRIM_nok(t *testing.T) {
 			err: "unexpected RawValue type: foo",
 		},
 		{
-			title: "bad RawValue bad masked",
+			title: "bad RawValue missing mask",
 			mea: Measurement{
 				ValueEntries: []*MeasurementValueEntry{
 					{
@@ -662,7 +675,7 @@ func TestMeasurement_ToCoRIM_nok(t *testing.T) {
 					},
 				},
 			},
-			err: "cannot unmarshal positive integer into Go value",
+			err: "missing RawValue mask",
 		},
 	}
 
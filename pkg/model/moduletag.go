@@ -38,6 +38,11 @@ type ModuleTag struct {
 	TriplesExtensions []*ExtensionValue `bun:"rel:has-many,join:id=owner_id,join:type=owner_type,polymorphic:triples"`
 
 	ManifestID int64
+
+	// Profile of the manifest containing the module tag. This is not stored; it is
+	// populated when the module tag is retrieved via its manifest or a query entry, and is used to
+	// reconstruct typed extensions (see RegisterProfileExtensions).
+	Profile string `bun:"-"`
 }
 
 func NewModuleTagFromCoRIM(origin *comid.Comid) (*ModuleTag, error) {
@@ -254,6 +259,10 @@ func (o *ModuleTag) ToCoRIM() (*comid.Comid, error) {
 		return nil, err
 	}
 
+	if err := ApplyProfileExtensionsToCoMID(ret, o.Profile); err != nil {
+		return nil, fmt.Errorf("profile extensions: %w", err)
+	}
+
 	return ret, nil
 }
 
@@ -288,106 +297,65 @@ func (o *ModuleTag) Insert(ctx context.Context, db bun.IDB) error {
 		return err
 	}
 
-	if _, err := db.NewInsert().Model(o).Exec(ctx); err != nil {
-		// coverage:ignore
-		return err
-	}
+	// the nested models are inserted level by level, using multi-row
+	// INSERTs for each table (see bulkInserter).
+	return bulkInsert(ctx, db, o)
+}
 
+func (o *ModuleTag) bulkChildren(ins *bulkInserter) {
 	for _, entity := range o.Entities {
 		entity.OwnerID = o.ID
 		entity.OwnerType = "module_tag"
-
-		if err := entity.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting linked tag %+v: %w", entity, err)
-		}
 	}
+	bulkEnqueue(ins, o.Entities...)
 
 	for _, link := range o.LinkedTags {
 		link.ModuleID = o.ID
-
-		if err := link.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting linked tag %+v: %w", link, err)
-		}
 	}
+	bulkEnqueue(ins, o.LinkedTags...)
 
 	for _, triple := range o.ValueTriples {
 		triple.OwnerID = o.ID
 		triple.OwnerType = "module_tag"
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting value triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.ValueTriples...)
 
 	for _, triple := range o.KeyTriples {
 		triple.ModuleID = o.ID
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting key triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.KeyTriples...)
 
 	for _, triple := range o.ConditionalEndorsementTriples {
 		triple.ModuleID = o.ID
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting conditional endorsement triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.ConditionalEndorsementTriples...)
 
 	for _, triple := range o.ConditionalEndorsementSeriesTriples {
 		triple.ModuleID = o.ID
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting conditional endorsement series triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.ConditionalEndorsementSeriesTriples...)
 
 	for _, triple := range o.DomainDependencyTriples {
 		triple.ModuleID = o.ID
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting domain dependency triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.DomainDependencyTriples...)
 
 	for _, triple := range o.DomainMembershipTriples {
 		triple.ModuleID = o.ID
-
-		if err := triple.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting domain membership triple %+v: %w", triple, err)
-		}
 	}
+	bulkEnqueue(ins, o.DomainMembershipTriples...)
 
 	for _, ext := range o.Extensions {
 		ext.OwnerID = o.ID
 		ext.OwnerType = "module_tag"
-
-		if err := ext.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting extension %+v: %w", ext, err)
-		}
 	}
+	bulkEnqueue(ins, o.Extensions...)
 
 	for _, ext := range o.TriplesExtensions {
 		ext.OwnerID = o.ID
 		ext.OwnerType = "triples"
-
-		if err := ext.Insert(ctx, db); err != nil {
-			// coverage:ignore
-			return fmt.Errorf("error inserting extension %+v: %w", ext, err)
-		}
 	}
-
-	return nil
+	bulkEnqueue(ins, o.TriplesExtensions...)
 }
 
 func (o *ModuleTag) Select(ctx context.Context, db bun.IDB) error {
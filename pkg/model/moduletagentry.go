@@ -80,5 +80,7 @@ func (o *ModuleTagEntry) ToModuleTag(ctx context.Context, db bun.IDB) (*ModuleTa
 		return nil, err
 	}
 
+	mt.Profile = o.Profile
+
 	return mt, nil
 }
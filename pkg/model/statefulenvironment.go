@@ -184,6 +184,22 @@ func (o *StatefulEnvironment) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *StatefulEnvironment) bulkChildren(ins *bulkInserter) {
+	for _, mea := range o.Measurements {
+		mea.OwnerID = o.ID
+		mea.OwnerType = "stateful_environment"
+	}
+	bulkEnqueue(ins, o.Measurements...)
+}
+
+func (o *StatefulEnvironment) bulkEnvironment() *Environment {
+	return o.Environment
+}
+
+func (o *StatefulEnvironment) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *StatefulEnvironment) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -227,7 +243,7 @@ func (o *StatefulEnvironment) Delete(ctx context.Context, db bun.IDB) error {
 	}
 
 	if o.Environment != nil {
-		if err := o.Environment.DeleteIfOrphaned(ctx, db); err != nil {
+		if err := o.Environment.Release(ctx, db); err != nil {
 			// coverage:ignore
 			return fmt.Errorf("environment: %w", err)
 		}
@@ -37,7 +37,7 @@ func (o *Token) Select(ctx context.Context, db bun.IDB) error {
 		return errors.New("ID not set")
 	}
 
-	return db.NewSelect().Model(o).Where("tok.id = ?", o.ID).Scan(ctx)
+	return db.NewSelect().Model(o).Relation("Authority").Where("tok.id = ?", o.ID).Scan(ctx)
 }
 
 func (o *Token) Insert(ctx context.Context, db bun.IDB) error {
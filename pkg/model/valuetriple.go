@@ -74,6 +74,11 @@ type ValueTriple struct {
 
 	OwnerID   int64  `bun:",nullzero"`
 	OwnerType string `bun:",nullzero"`
+
+	// Profile of the manifest containing the triple. This is not stored; it is
+	// populated when the triple is retrieved via a query entry, and is used to
+	// reconstruct typed extensions (see RegisterProfileExtensions).
+	Profile string `bun:"-"`
 }
 
 func NewValueTripleFromCoRIM(origin *comid.ValueTriple) (*ValueTriple, error) {
@@ -148,6 +153,12 @@ func (o *ValueTriple) ToCoRIM() (*comid.ValueTriple, error) {
 	}
 	ret.Measurements = meas
 
+	if exts, ok := ProfileExtensions(o.Profile); ok {
+		if err := applyValueTripleExtensions(&ret, o.Type, exts); err != nil {
+			return nil, fmt.Errorf("profile extensions: %w", err)
+		}
+	}
+
 	return &ret, nil
 }
 
@@ -197,6 +208,22 @@ func (o *ValueTriple) Insert(ctx context.Context, db bun.IDB) error {
 	return nil
 }
 
+func (o *ValueTriple) bulkChildren(ins *bulkInserter) {
+	for _, mea := range o.Measurements {
+		mea.OwnerID = o.ID
+		mea.OwnerType = "value_triple"
+	}
+	bulkEnqueue(ins, o.Measurements...)
+}
+
+func (o *ValueTriple) bulkEnvironment() *Environment {
+	return o.Environment
+}
+
+func (o *ValueTriple) setBulkEnvironmentID(id int64) {
+	o.EnvironmentID = id
+}
+
 func (o *ValueTriple) Select(ctx context.Context, db bun.IDB) error {
 	if o.ID == 0 {
 		return errors.New("ID not set")
@@ -238,7 +265,7 @@ func (o *ValueTriple) Delete(ctx context.Context, db bun.IDB) error {
 	}
 
 	if o.Environment != nil {
-		if err := o.Environment.DeleteIfOrphaned(ctx, db); err != nil {
+		if err := o.Environment.Release(ctx, db); err != nil {
 			return fmt.Errorf("environment: %w", err)
 		}
 	}
@@ -100,5 +100,7 @@ func (o *ValueTripleEntry) ToTriple(ctx context.Context, db bun.IDB) (*ValueTrip
 		return nil, err
 	}
 
+	triple.Profile = o.Profile
+
 	return triple, nil
 }
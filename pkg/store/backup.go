@@ -0,0 +1,436 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/corim"
+)
+
+// BackupFormat identifies archives produced by Store.Backup.
+const BackupFormat = "corim-store-backup"
+
+// BackupVersion is the version of the archive format produced by
+// Store.Backup. Restore accepts archives with this version or lower.
+const BackupVersion = 1
+
+// Backup is the archive produced by Store.Backup. It does not depend on the
+// DBMS underpinning the store, as the contents are restored by replaying them
+// through the Store (see Store.Restore).
+type Backup struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// HashAlg is the algorithm used to calculate manifest digests inside
+	// the backed up store.
+	HashAlg string         `json:"hash-alg"`
+	Entries []*BackupEntry `json:"entries"`
+}
+
+// BackupEntry contains a manifest, and/or the token it was extracted from.
+type BackupEntry struct {
+	ManifestID string `json:"manifest-id"`
+	// TokenOnly is set if the entry contains a token with no associated
+	// manifest.
+	TokenOnly bool         `json:"token-only,omitempty"`
+	Token     *BackupToken `json:"token,omitempty"`
+	// CoRIM contains the unsigned CoRIM reconstructed from the manifest.
+	// This is only set for manifests that were not added from a token.
+	CoRIM []byte `json:"corim,omitempty"`
+
+	Label     string     `json:"label,omitempty"`
+	Digest    []byte     `json:"digest,omitempty"`
+	TimeAdded *time.Time `json:"time-added,omitempty"`
+	// ModuleTags records the activation state of the triples inside each
+	// of the manifest's module tags, in the order they appear in the
+	// manifest.
+	ModuleTags []*BackupModuleTag `json:"module-tags,omitempty"`
+}
+
+// BackupToken contains a token (the original CBOR-encoded CoRIM) along with
+// the keys that were used to verify its signature.
+type BackupToken struct {
+	IsSigned  bool         `json:"signed"`
+	Data      []byte       `json:"data"`
+	Authority []*BackupKey `json:"authority,omitempty"`
+}
+
+// BackupKey is a crypto key used to verify a token's signature.
+type BackupKey struct {
+	Type  string `json:"type"`
+	Bytes []byte `json:"bytes"`
+}
+
+// BackupModuleTag records the activation state of a module tag's triples. Each
+// slice contains the state of the triples of the corresponding type, in the
+// order they appear in the module tag.
+type BackupModuleTag struct {
+	TagID                               string `json:"tag-id"`
+	KeyTriples                          []bool `json:"key-triples,omitempty"`
+	ValueTriples                        []bool `json:"value-triples,omitempty"`
+	ConditionalEndorsementTriples       []bool `json:"conditional-endorsement-triples,omitempty"`
+	ConditionalEndorsementSeriesTriples []bool `json:"conditional-endorsement-series-triples,omitempty"`
+	DomainDependencyTriples             []bool `json:"domain-dependency-triples,omitempty"`
+	DomainMembershipTriples             []bool `json:"domain-membership-triples,omitempty"`
+}
+
+// Backup writes an archive containing the contents of the store to the
+// provided writer. The contents are read inside a single transaction, so that
+// the backup is consistent. The number of archived entries is returned.
+func (o *Store) Backup(w io.Writer) (int, error) {
+	if err := o.checkUnscoped("Backup"); err != nil {
+		return 0, err
+	}
+
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	if o.DB.Dialect().Name().String() == "sqlite" {
+		// sqlite transactions are always serializable
+		opts = nil
+	}
+
+	txStore, err := o.administrative().BeginTx(opts)
+	if err != nil {
+		return 0, err
+	}
+	defer txStore.Tx().Rollback() // nolint:errcheck
+
+	backup, err := txStore.newBackup()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		return 0, err
+	}
+
+	return len(backup.Entries), nil
+}
+
+func (o *Store) newBackup() (*Backup, error) {
+	ret := Backup{
+		Format:  BackupFormat,
+		Version: BackupVersion,
+		Created: time.Now().UTC(),
+		HashAlg: strings.ToLower(o.cfg.HashAlg),
+	}
+
+	tokens := make(map[string]*model.Token)
+	var tokenIDs []string
+
+	tokenModels, err := o.QueryTokenModels(nil)
+	if err != nil && !errors.Is(err, ErrNoMatch) {
+		return nil, err
+	}
+
+	for _, token := range tokenModels {
+		if err := token.Select(o.Ctx, o.DB); err != nil {
+			return nil, fmt.Errorf("token for %q: %w", token.ManifestID, err)
+		}
+
+		tokens[token.ManifestID] = token
+		tokenIDs = append(tokenIDs, token.ManifestID)
+	}
+
+	manifests, err := o.QueryManifestModels(nil)
+	if err != nil && !errors.Is(err, ErrNoMatch) {
+		return nil, err
+	}
+
+	sortByID(manifests)
+
+	for _, manifest := range manifests {
+		entry, err := newBackupEntry(manifest, tokens[manifest.ManifestID])
+		if err != nil {
+			return nil, fmt.Errorf("manifest %q: %w", manifest.ManifestID, err)
+		}
+
+		ret.Entries = append(ret.Entries, entry)
+		delete(tokens, manifest.ManifestID)
+	}
+
+	// tokens whose manifests have been deleted
+	for _, manifestID := range tokenIDs {
+		if token, ok := tokens[manifestID]; ok {
+			ret.Entries = append(ret.Entries, &BackupEntry{
+				ManifestID: manifestID,
+				TokenOnly:  true,
+				Token:      newBackupToken(token),
+			})
+		}
+	}
+
+	return &ret, nil
+}
+
+func newBackupEntry(manifest *model.Manifest, token *model.Token) (*BackupEntry, error) {
+	timeAdded := manifest.TimeAdded.UTC()
+	ret := BackupEntry{
+		ManifestID: manifest.ManifestID,
+		Label:      manifest.Label,
+		Digest:     manifest.Digest,
+		TimeAdded:  &timeAdded,
+	}
+
+	// Triples are ordered by their database IDs, which reflects the order
+	// in which they were inserted, and so their order in the manifest.
+	sortByID(manifest.ModuleTags)
+
+	for _, moduleTag := range manifest.ModuleTags {
+		sortByID(moduleTag.KeyTriples)
+		sortByID(moduleTag.ValueTriples)
+		sortByID(moduleTag.ConditionalEndorsementTriples)
+		sortByID(moduleTag.ConditionalEndorsementSeriesTriples)
+		sortByID(moduleTag.DomainDependencyTriples)
+		sortByID(moduleTag.DomainMembershipTriples)
+
+		ret.ModuleTags = append(ret.ModuleTags, &BackupModuleTag{
+			TagID:                               moduleTag.TagID,
+			KeyTriples:                          activeStates(moduleTag.KeyTriples),
+			ValueTriples:                        activeStates(moduleTag.ValueTriples),
+			ConditionalEndorsementTriples:       activeStates(moduleTag.ConditionalEndorsementTriples),
+			ConditionalEndorsementSeriesTriples: activeStates(moduleTag.ConditionalEndorsementSeriesTriples),
+			DomainDependencyTriples:             activeStates(moduleTag.DomainDependencyTriples),
+			DomainMembershipTriples:             activeStates(moduleTag.DomainMembershipTriples),
+		})
+	}
+
+	if token != nil {
+		ret.Token = newBackupToken(token)
+		return &ret, nil
+	}
+
+	unsigned, err := manifest.ToCoRIM()
+	if err != nil {
+		return nil, err
+	}
+
+	ret.CoRIM, err = unsigned.ToCBOR()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+func newBackupToken(token *model.Token) *BackupToken {
+	ret := BackupToken{IsSigned: token.IsSigned, Data: token.Data}
+
+	for _, key := range token.Authority {
+		ret.Authority = append(ret.Authority, &BackupKey{Type: key.KeyType, Bytes: key.KeyBytes})
+	}
+
+	return &ret
+}
+
+// Restore adds the contents of an archive produced by Backup to the store.
+// Entries are added inside a single transaction, so either all or none of
+// them are restored. The number of restored entries is returned.
+func (o *Store) Restore(r io.Reader) (int, error) {
+	if err := o.checkWritable("Restore"); err != nil {
+		return 0, err
+	}
+
+	if err := o.checkUnscoped("Restore"); err != nil {
+		return 0, err
+	}
+
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return 0, fmt.Errorf("decoding backup: %w", err)
+	}
+
+	if backup.Format != BackupFormat {
+		return 0, fmt.Errorf("unexpected backup format: %q", backup.Format)
+	}
+
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return 0, fmt.Errorf("unsupported backup version: %d", backup.Version)
+	}
+
+	// manifest digests only need to be re-calculated if this store uses a
+	// different algorithm.
+	recalculateDigests := !strings.EqualFold(backup.HashAlg, o.cfg.HashAlg)
+
+	txStore, err := o.administrative().BeginTx(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range backup.Entries {
+		if err := txStore.restoreEntry(entry, recalculateDigests); err != nil {
+			_ = txStore.Tx().Rollback()
+			return 0, fmt.Errorf("entry %d (%s): %w", i, entry.ManifestID, err)
+		}
+	}
+
+	if err := txStore.Tx().Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(backup.Entries), nil
+}
+
+func (o *Store) restoreEntry(entry *BackupEntry, recalculateDigest bool) error {
+	var unsigned *corim.UnsignedCorim
+	var err error
+
+	switch {
+	case entry.Token != nil && entry.Token.IsSigned:
+		signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(entry.Token.Data)
+		if err != nil {
+			return err
+		}
+
+		unsigned = &signed.UnsignedCorim
+	case entry.Token != nil:
+		unsigned, err = corim.UnmarshalAndValidateUnsignedCorimFromCBOR(entry.Token.Data)
+	case entry.CoRIM != nil:
+		unsigned, err = corim.UnmarshalAndValidateUnsignedCorimFromCBOR(entry.CoRIM)
+	default:
+		return errors.New("neither token nor CoRIM present")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if entry.Token != nil {
+		token := model.Token{
+			ManifestID: entry.ManifestID,
+			IsSigned:   entry.Token.IsSigned,
+			Data:       entry.Token.Data,
+		}
+
+		for _, key := range entry.Token.Authority {
+			token.Authority = append(token.Authority, &model.CryptoKey{
+				KeyType:  key.Type,
+				KeyBytes: key.Bytes,
+			})
+		}
+
+		if err := o.AddToken(&token); err != nil {
+			return err
+		}
+	}
+
+	if entry.TokenOnly {
+		return nil
+	}
+
+	manifest, err := model.NewManifestFromCoRIM(unsigned)
+	if err != nil {
+		return err
+	}
+
+	manifest.Label = entry.Label
+	manifest.Digest = entry.Digest
+	if recalculateDigest && entry.Token != nil {
+		manifest.Digest = o.Digest(entry.Token.Data)
+	}
+
+	if entry.TimeAdded != nil {
+		manifest.TimeAdded = *entry.TimeAdded
+	}
+
+	if err := entry.applyActiveStates(manifest); err != nil {
+		return err
+	}
+
+	return o.AddManifest(manifest)
+}
+
+func (o *BackupEntry) applyActiveStates(manifest *model.Manifest) error {
+	if len(o.ModuleTags) != len(manifest.ModuleTags) {
+		return fmt.Errorf(
+			"activation state for %d module tag(s), but manifest contains %d",
+			len(o.ModuleTags), len(manifest.ModuleTags),
+		)
+	}
+
+	for i, moduleTag := range manifest.ModuleTags {
+		states := o.ModuleTags[i]
+
+		if states.TagID != moduleTag.TagID {
+			return fmt.Errorf("module tag %d: expected ID %q, found %q", i, states.TagID, moduleTag.TagID)
+		}
+
+		err := errors.Join(
+			setActiveStates("key triples", moduleTag.KeyTriples, states.KeyTriples),
+			setActiveStates("value triples", moduleTag.ValueTriples, states.ValueTriples),
+			setActiveStates("conditional endorsement triples",
+				moduleTag.ConditionalEndorsementTriples, states.ConditionalEndorsementTriples),
+			setActiveStates("conditional endorsement series triples",
+				moduleTag.ConditionalEndorsementSeriesTriples, states.ConditionalEndorsementSeriesTriples),
+			setActiveStates("domain dependency triples",
+				moduleTag.DomainDependencyTriples, states.DomainDependencyTriples),
+			setActiveStates("domain membership triples",
+				moduleTag.DomainMembershipTriples, states.DomainMembershipTriples),
+		)
+		if err != nil {
+			return fmt.Errorf("module tag %q: %w", moduleTag.TagID, err)
+		}
+	}
+
+	return nil
+}
+
+func sortByID[T model.Model](values []T) {
+	slices.SortFunc(values, func(a, b T) int {
+		return cmp.Compare(a.DbID(), b.DbID())
+	})
+}
+
+// isActiveField returns a pointer to the IsActive field of the provided
+// triple model.
+func isActiveField(triple any) *bool {
+	switch t := triple.(type) {
+	case *model.KeyTriple:
+		return &t.IsActive
+	case *model.ValueTriple:
+		return &t.IsActive
+	case *model.ConditionalEndorsementTriple:
+		return &t.IsActive
+	case *model.ConditionalEndorsementSeriesTriple:
+		return &t.IsActive
+	case *model.DomainDependencyTriple:
+		return &t.IsActive
+	case *model.DomainMembershipTriple:
+		return &t.IsActive
+	default:
+		// coverage:ignore
+		panic(fmt.Sprintf("unexpected triple type: %T", triple))
+	}
+}
+
+func activeStates[T any](triples []*T) []bool {
+	if len(triples) == 0 {
+		return nil
+	}
+
+	ret := make([]bool, len(triples))
+	for i, triple := range triples {
+		ret[i] = *isActiveField(triple)
+	}
+
+	return ret
+}
+
+func setActiveStates[T any](what string, triples []*T, states []bool) error {
+	if len(triples) != len(states) {
+		return fmt.Errorf("%s: activation state for %d, but found %d", what, len(states), len(triples))
+	}
+
+	for i, triple := range triples {
+		*isActiveField(triple) = states[i]
+	}
+
+	return nil
+}
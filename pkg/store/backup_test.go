@@ -0,0 +1,121 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+	"github.com/veraison/corim/corim"
+)
+
+func TestStore_Backup_Restore(t *testing.T) {
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	signed, err := os.ReadFile("../../sample/corim/signed-cca-ta.cose")
+	require.NoError(t, err)
+
+	keys, err := util.KeyStoreFromJWKPath("../../sample/corim/key.pub.jwk")
+	require.NoError(t, err)
+
+	err = store.VerifyAndAddBytes(signed, keys, "signed", true)
+	require.NoError(t, err)
+
+	unsigned, err := os.ReadFile("../../sample/corim/unsigned-cca-ref-plat.cbor")
+	require.NoError(t, err)
+
+	err = store.AddBytes(unsigned, "unsigned", false)
+	require.NoError(t, err)
+
+	noToken, err := os.ReadFile("../../sample/corim/unsigned-cca-ref-realm.cbor")
+	require.NoError(t, err)
+
+	var c corim.UnsignedCorim
+	require.NoError(t, c.FromCBOR(noToken))
+
+	err = store.AddCoRIM(&c, []byte{0xde, 0xad, 0xbe, 0xef}, "no-token", true)
+	require.NoError(t, err)
+
+	_, err = store.SetValueTriplesActive(NewValueTripleQuery().Label("no-token"), false)
+	require.NoError(t, err)
+
+	before, err := store.QueryManifestModels(nil)
+	require.NoError(t, err)
+	require.Len(t, before, 3)
+
+	activeBefore, err := store.QueryKeyTripleEntries(NewKeyTripleQuery().IsActive(true))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	count, err := store.Backup(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	var backup Backup
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &backup))
+	assert.Equal(t, BackupVersion, backup.Version)
+	require.Len(t, backup.Entries, 3)
+	assert.True(t, backup.Entries[0].Token.IsSigned)
+	assert.Len(t, backup.Entries[0].Token.Authority, 1)
+	assert.Nil(t, backup.Entries[2].Token)
+	assert.NotEmpty(t, backup.Entries[2].CoRIM)
+
+	require.NoError(t, store.Clear())
+
+	count, err = store.Restore(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	after, err := store.QueryManifestModels(nil)
+	require.NoError(t, err)
+	require.Len(t, after, 3)
+
+	for i := range before {
+		assert.Equal(t, before[i].ManifestID, after[i].ManifestID)
+		assert.Equal(t, before[i].Label, after[i].Label)
+		assert.Equal(t, before[i].Digest, after[i].Digest)
+		assert.True(t, before[i].TimeAdded.Equal(after[i].TimeAdded))
+	}
+
+	activeAfter, err := store.QueryKeyTripleEntries(NewKeyTripleQuery().IsActive(true))
+	require.NoError(t, err)
+	assert.Len(t, activeAfter, len(activeBefore))
+
+	_, err = store.QueryValueTripleEntries(NewValueTripleQuery().IsActive(true))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	tokens, err := store.QueryTokenModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+
+	// restoring is atomic, so the conflicting entries cause nothing to be
+	// added
+	_, err = store.Restore(bytes.NewReader(buf.Bytes()))
+	assert.ErrorContains(t, err, "entry 0 (cca-ta): token already in store")
+
+	backup.Version = BackupVersion + 1
+	newer, err := json.Marshal(&backup)
+	require.NoError(t, err)
+
+	_, err = store.Restore(bytes.NewReader(newer))
+	assert.ErrorContains(t, err, "unsupported backup version")
+
+	backup.Version = BackupVersion
+	backup.Entries[1].ModuleTags[0].ValueTriples = nil
+	mismatched, err := json.Marshal(&backup)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Clear())
+	_, err = store.Restore(bytes.NewReader(mismatched))
+	assert.ErrorContains(t, err, "value triples: activation state for 0, but found 1")
+
+	_, err = store.QueryManifestEntries(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
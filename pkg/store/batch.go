@@ -0,0 +1,300 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+	"github.com/veraison/corim/corim"
+)
+
+// BatchStage identifies the stage of a batch addition that a progress update
+// refers to.
+type BatchStage string
+
+const (
+	// BatchDecodeStage is the (concurrent) stage during which tokens are
+	// decoded and validated, and manifests are constructed from them.
+	BatchDecodeStage BatchStage = "decode"
+	// BatchInsertStage is the (sequential) stage during which decoded
+	// manifests are inserted into the store.
+	BatchInsertStage BatchStage = "insert"
+)
+
+// BatchItem is a single CoRIM token to be added as part of a batch.
+type BatchItem struct {
+	// Name identifies the item in the report (e.g. the path the token was
+	// read from).
+	Name string
+	// Data contains the CBOR-encoded (signed or unsigned) CoRIM token.
+	Data []byte
+}
+
+// BatchOptions control how AddBatch processes a batch.
+type BatchOptions struct {
+	// Label that will be applied to all added manifests.
+	Label string
+	// Activate the triples of added manifests.
+	Activate bool
+	// Atomic, if true, causes the entire batch to be rolled back if any
+	// item fails. Otherwise, failing items are skipped and reported, and
+	// the remaining items are added.
+	Atomic bool
+	// Workers is the number of concurrent workers used for decoding. If
+	// not positive, the number of available CPUs is used.
+	Workers int
+	// Keys, if not nil, will be used to verify signatures of signed
+	// CoRIMs (as with VerifyAndAddBytes).
+	Keys util.KeyStore
+	// Progress, if not nil, is invoked each time an item completes a stage
+	// with the number of items that have completed that stage so far.
+	Progress func(stage BatchStage, done, total int)
+}
+
+// BatchItemResult is the outcome of adding a single BatchItem.
+type BatchItemResult struct {
+	Name       string
+	ManifestID string
+	// Err is nil if the item was successfully added.
+	Err error
+}
+
+// BatchReport summarises the outcome of AddBatch.
+type BatchReport struct {
+	// Results contains the result for each item, in the same order as
+	// the items were provided.
+	Results []*BatchItemResult
+	// Added is the number of items that were added to the store. This is
+	// zero if the batch was aborted.
+	Added int
+	// Failed is the number of items whose result contains an error. If the
+	// batch was aborted because of a transaction error, that error is
+	// returned by AddBatch rather than being recorded against an item.
+	Failed int
+}
+
+// count sets Added and Failed from Results; committed indicates whether the
+// items without errors were added to the store.
+func (o *BatchReport) count(committed bool) {
+	o.Added = 0
+	o.Failed = 0
+
+	for _, res := range o.Results {
+		if res.Err != nil {
+			o.Failed++
+		} else if committed {
+			o.Added++
+		}
+	}
+}
+
+// Errors returns the errors for the failed items, each wrapped with the
+// name of the corresponding item.
+func (o *BatchReport) Errors() []error {
+	var ret []error
+
+	for _, res := range o.Results {
+		if res.Err != nil {
+			ret = append(ret, fmt.Errorf("%s: %w", res.Name, res.Err))
+		}
+	}
+
+	return ret
+}
+
+type decodedBatchItem struct {
+	token    *model.Token
+	manifest *model.Manifest
+}
+
+// AddBatch adds the CoRIM tokens in the provided items to the store. Tokens
+// are decoded, validated and converted into manifests concurrently using a
+// pool of workers, and are then inserted inside a single transaction. If
+// opts.Atomic is set, a failure of any item causes none of them to be added,
+// and an error is returned alongside the report; otherwise, items that could
+// not be added are recorded in the returned report and the rest are
+// committed.
+func (o *Store) AddBatch(items []BatchItem, opts *BatchOptions) (*BatchReport, error) {
+	if err := o.checkWritable("AddBatch"); err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	label, err := o.scopeLabel("AddBatch", opts.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.cfg.RequireLabel && label == "" {
+		return nil, ErrNoLabel
+	}
+
+	scoped := *opts
+	scoped.Label = label
+	opts = &scoped
+
+	report := BatchReport{Results: make([]*BatchItemResult, len(items))}
+	decoded := o.decodeBatch(items, opts, report.Results)
+
+	if opts.Atomic {
+		if err := errors.Join(report.Errors()...); err != nil {
+			report.count(false)
+			return &report, err
+		}
+	}
+
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		report.count(false)
+		return &report, err
+	}
+
+	for i, item := range decoded {
+		if item != nil {
+			report.Results[i].Err = txStore.addDecodedBatchItem(item, opts.Atomic)
+
+			if report.Results[i].Err != nil && opts.Atomic {
+				_ = txStore.Tx().Rollback()
+				report.count(false)
+				return &report, fmt.Errorf("%s: %w", report.Results[i].Name, report.Results[i].Err)
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(BatchInsertStage, i+1, len(items))
+		}
+	}
+
+	if err := txStore.Tx().Commit(); err != nil {
+		report.count(false)
+		return &report, err
+	}
+
+	report.count(true)
+
+	return &report, nil
+}
+
+func (o *Store) decodeBatch(
+	items []BatchItem,
+	opts *BatchOptions,
+	results []*BatchItemResult,
+) []*decodedBatchItem {
+	ret := make([]*decodedBatchItem, len(items))
+
+	numWorkers := opts.Workers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				item, err := o.decodeBatchItem(&items[i], opts)
+				results[i] = &BatchItemResult{Name: items[i].Name, Err: err}
+				if err == nil {
+					results[i].ManifestID = item.manifest.ManifestID
+				}
+				ret[i] = item
+
+				if opts.Progress != nil {
+					mu.Lock()
+					done++
+					opts.Progress(BatchDecodeStage, done, len(items))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	return ret
+}
+
+func (o *Store) decodeBatchItem(item *BatchItem, opts *BatchOptions) (*decodedBatchItem, error) {
+	var token *model.Token
+	var unsigned *corim.UnsignedCorim
+	var err error
+
+	if o.tenant != nil && o.tenant.SignerKeys != nil {
+		token, unsigned, err = verifyBytes(item.Data, o.tenant.SignerKeys)
+	} else if util.IsSignedCoRIM(item.Data) && opts.Keys != nil {
+		token, unsigned, err = verifyBytes(item.Data, opts.Keys)
+	} else {
+		token, unsigned, err = o.decodeBytes(item.Data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := decodedBatchItem{token: token}
+
+	ret.manifest, err = model.NewManifestFromCoRIM(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.manifest.Digest = o.Digest(item.Data)
+	ret.manifest.Label = opts.Label
+
+	if opts.Activate {
+		ret.manifest.SetActive(true)
+	}
+
+	if err = ret.manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// addDecodedBatchItem adds the item's token and manifest to the store. Unless
+// atomic is set, this is done inside a nested transaction, so that a failure
+// does not affect previously added items.
+func (o *Store) addDecodedBatchItem(item *decodedBatchItem, atomic bool) error {
+	if atomic {
+		if err := o.AddToken(item.token); err != nil {
+			return err
+		}
+
+		return o.AddManifest(item.manifest)
+	}
+
+	itemStore, err := o.BeginTx(nil)
+	if err != nil {
+		return err
+	}
+
+	if err := itemStore.AddToken(item.token); err != nil {
+		_ = itemStore.Tx().Rollback()
+		return err
+	}
+
+	if err := itemStore.AddManifest(item.manifest); err != nil {
+		_ = itemStore.Tx().Rollback()
+		return err
+	}
+
+	return itemStore.Tx().Commit()
+}
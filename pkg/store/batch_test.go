@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+func readBatchItems(t *testing.T, paths ...string) []BatchItem {
+	ret := make([]BatchItem, 0, len(paths))
+
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		ret = append(ret, BatchItem{Name: path, Data: bytes})
+	}
+
+	return ret
+}
+
+func TestStore_AddBatch(t *testing.T) {
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	items := readBatchItems(t,
+		"../../sample/corim/unsigned-cca-ta.cbor",
+		"../../sample/corim/unsigned-cca-ref-plat.cbor",
+	)
+	items = append(items, BatchItem{Name: "bad", Data: []byte{0x01, 0x02}})
+
+	progress := map[BatchStage]int{}
+	report, err := store.AddBatch(items, &BatchOptions{
+		Label:    "test",
+		Activate: true,
+		Atomic:   true,
+		Workers:  2,
+		Progress: func(stage BatchStage, done, total int) {
+			assert.Equal(t, 3, total)
+			progress[stage] = done
+		},
+	})
+	assert.ErrorContains(t, err, "bad: input too short")
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 0, report.Added)
+	assert.Equal(t, 3, progress[BatchDecodeStage])
+	assert.Equal(t, 0, progress[BatchInsertStage])
+
+	_, err = store.QueryManifestEntries(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	report, err = store.AddBatch(items, &BatchOptions{Label: "test", Activate: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Added)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, "cca-ta", report.Results[0].ManifestID)
+	assert.Equal(t, "cca-ref-plat", report.Results[1].ManifestID)
+	assert.ErrorContains(t, report.Results[2].Err, "input too short")
+	assert.Len(t, report.Errors(), 1)
+
+	manifests, err := store.QueryManifestEntries(NewManifestQuery().Label("test"))
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 2)
+
+	triples, err := store.QueryValueTripleEntries(NewValueTripleQuery().IsActive(true))
+	assert.NoError(t, err)
+	assert.Len(t, triples, 1)
+
+	items = readBatchItems(t,
+		"../../sample/corim/unsigned-cca-ref-realm.cbor",
+		"../../sample/corim/unsigned-cca-ta.cbor", // already added
+	)
+
+	report, err = store.AddBatch(items, &BatchOptions{Label: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Added)
+	assert.Equal(t, 1, report.Failed)
+	assert.ErrorContains(t, report.Results[1].Err, "token already in store")
+
+	report, err = store.AddBatch(items, &BatchOptions{Label: "test", Atomic: true})
+	assert.ErrorContains(t, err, "token already in store")
+	assert.Equal(t, 0, report.Added)
+	assert.Equal(t, 1, report.Failed)
+	assert.Len(t, report.Results, 2)
+
+	items = readBatchItems(t,
+		"../../sample/corim/signed-cca-ta.cose",
+	)
+
+	report, err = store.AddBatch(items, &BatchOptions{Atomic: true})
+	assert.ErrorContains(t, err, "CoRIM signature must be verified")
+	assert.Equal(t, 1, report.Failed)
+
+	store.cfg.RequireLabel = true
+	_, err = store.AddBatch(items, nil)
+	assert.ErrorIs(t, err, ErrNoLabel)
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+)
+
+// ErrCertPath is wrapped by the errors explaining why a candidate cert path
+// has been rejected (see CertPathCandidate).
+var ErrCertPath = errors.New("invalid cert path")
+
+// CertPathOptions control the behaviour of Store.FindCertPathKeyTriples.
+type CertPathOptions struct {
+	// Query restricts the key triples that are considered. If nil, all key
+	// triples (in the store's tenant scope) are considered.
+	Query Query[*model.KeyTripleEntry]
+	// Intermediates are additional certificates, presented alongside the
+	// leaf certificate, that may be used to chain it to a stored cert
+	// path.
+	Intermediates []*x509.Certificate
+	// Time at which the validity of the certificates is checked. If zero,
+	// the current time is used.
+	Time time.Time
+}
+
+// CertPathCandidate is a stored cert path that the leaf certificate claims to
+// chain to (i.e. the path contains the issuer of the leaf certificate, or of
+// one of its intermediates).
+type CertPathCandidate struct {
+	Triple *model.KeyTriple
+	Key    *model.CryptoKey
+	// Path contains the parsed certificates of the stored cert path, with
+	// the end-entity certificate first. It is nil if the path could not be
+	// parsed.
+	Path []*x509.Certificate
+	// Chains contains the validated chains from the leaf certificate to the
+	// trust anchor of the stored cert path.
+	Chains [][]*x509.Certificate
+	// Err explains why the candidate has been rejected. It wraps
+	// ErrCertPath, and, where validation failed, the error returned by
+	// x509.Certificate.Verify.
+	Err error
+}
+
+// CertPathReport summarises the outcome of Store.FindCertPathKeyTriples.
+type CertPathReport struct {
+	// Accepted contains the candidates the leaf certificate validly chains
+	// to.
+	Accepted []*CertPathCandidate
+	// Rejected contains the candidates the leaf certificate failed to
+	// validate against, with the reasons for rejection.
+	Rejected []*CertPathCandidate
+}
+
+// FindCertPathKeyTriples finds the key triples with PKIX cert path keys that
+// the provided leaf certificate (e.g. the signing certificate of attestation
+// evidence) chains to. The last certificate of a stored cert path is used as
+// the trust anchor, and the remaining ones as intermediates. The chain is
+// validated at opts.Time, checking certificate validity periods and basic
+// constraints (extended key usages are not checked). Stored cert paths that do
+// not contain the issuer of the leaf certificate, or of any of
+// opts.Intermediates, are not considered.
+func (o *Store) FindCertPathKeyTriples(leaf *x509.Certificate, opts *CertPathOptions) (*CertPathReport, error) {
+	if leaf == nil {
+		return nil, errors.New("leaf certificate not specified")
+	}
+
+	if opts == nil {
+		opts = &CertPathOptions{}
+	}
+
+	now := opts.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	certPathQuery := NewKeyTripleQuery().CryptoKey(func(kq *CryptoKeyQuery) {
+		kq.KeyType(comid.PKIXBase64CertPathType)
+	})
+
+	var query Query[*model.KeyTripleEntry] = certPathQuery
+	if opts.Query != nil {
+		query = And(opts.Query, certPathQuery)
+	}
+
+	var report CertPathReport
+
+	triples, err := o.QueryKeyTripleModels(query)
+	if err != nil {
+		if errors.Is(err, ErrNoMatch) {
+			return &report, nil
+		}
+
+		return nil, err
+	}
+
+	issuers := [][]byte{leaf.RawIssuer}
+	for _, cert := range opts.Intermediates {
+		issuers = append(issuers, cert.RawIssuer)
+	}
+
+	for _, triple := range triples {
+		for _, key := range triple.KeyList {
+			if key.KeyType != comid.PKIXBase64CertPathType {
+				continue
+			}
+
+			candidate := &CertPathCandidate{Triple: triple, Key: key}
+
+			candidate.Path, err = parseCertPath(string(key.KeyBytes))
+			if err != nil {
+				// the path cannot be checked for the issuer, so
+				// report it, rather than silently skipping it.
+				candidate.Err = fmt.Errorf("%w: %v", ErrCertPath, err)
+				report.Rejected = append(report.Rejected, candidate)
+				continue
+			}
+
+			if !certPathContainsIssuer(candidate.Path, issuers) {
+				continue
+			}
+
+			candidate.Chains, err = verifyCertPath(leaf, opts.Intermediates, candidate.Path, now)
+			if err != nil {
+				candidate.Err = fmt.Errorf("%w: %w", ErrCertPath, err)
+				report.Rejected = append(report.Rejected, candidate)
+				continue
+			}
+
+			report.Accepted = append(report.Accepted, candidate)
+		}
+	}
+
+	return &report, nil
+}
+
+func verifyCertPath(
+	leaf *x509.Certificate,
+	intermediates []*x509.Certificate,
+	path []*x509.Certificate,
+	now time.Time,
+) ([][]*x509.Certificate, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(path[len(path)-1])
+
+	pool := x509.NewCertPool()
+	for _, cert := range path[:len(path)-1] {
+		pool.AddCert(cert)
+	}
+
+	for _, cert := range intermediates {
+		pool.AddCert(cert)
+	}
+
+	return leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+}
+
+func certPathContainsIssuer(path []*x509.Certificate, issuers [][]byte) bool {
+	for _, cert := range path {
+		for _, issuer := range issuers {
+			if bytes.Equal(cert.RawSubject, issuer) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseCertPath parses the PEM-encoded certificates of a PKIX cert path key.
+func parseCertPath(text string) ([]*x509.Certificate, error) {
+	var ret []*x509.Certificate
+
+	rest := bytes.TrimSpace([]byte(text))
+	for len(rest) != 0 {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("could not decode PEM block %d", len(ret))
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected type for PEM block %d: %q", len(ret), block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", len(ret), err)
+		}
+
+		ret = append(ret, cert)
+		rest = bytes.TrimSpace(rest)
+	}
+
+	if len(ret) == 0 {
+		return nil, errors.New("empty cert path")
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+type testCertKey struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertKey(
+	t *testing.T,
+	name string,
+	isCA bool,
+	notAfter time.Time,
+	parent *testCertKey,
+) *testCertKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCertKey{cert: cert, key: key}
+}
+
+func newCertPathKey(certs ...*testCertKey) *comid.CryptoKey {
+	var text []byte
+	for _, ck := range certs {
+		text = append(text, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ck.cert.Raw})...)
+	}
+
+	return comid.MustNewCryptoKey(string(text), comid.PKIXBase64CertPathType)
+}
+
+func TestStore_FindCertPathKeyTriples(t *testing.T) {
+	now := time.Now()
+	inAYear := now.Add(365 * 24 * time.Hour)
+
+	root := newTestCertKey(t, "root", true, inAYear, nil)
+	intermediate := newTestCertKey(t, "intermediate", true, now.Add(24*time.Hour), root)
+	leaf := newTestCertKey(t, "leaf", false, inAYear, intermediate)
+
+	notCA := newTestCertKey(t, "not CA", false, inAYear, root)
+	notCALeaf := newTestCertKey(t, "not CA leaf", false, inAYear, notCA)
+
+	other := newTestCertKey(t, "other", true, inAYear, nil)
+
+	paths := map[string]*comid.CryptoKey{
+		"intermediate": newCertPathKey(intermediate, root),
+		"root":         newCertPathKey(root),
+		"not-ca":       newCertPathKey(notCA, root),
+		"other":        newCertPathKey(other),
+	}
+
+	manifest := corim.NewUnsignedCorim().SetID("cert-paths")
+	for vendor, key := range paths {
+		class := comid.NewClassBytes([]byte(vendor)).SetVendor(vendor)
+		tag := comid.NewComid().
+			SetTagIdentity(vendor, 0).
+			AddAttestVerifKey(&comid.KeyTriple{
+				Environment: comid.Environment{Class: class},
+				VerifKeys:   *comid.NewCryptoKeys().Add(key),
+			})
+		manifest.AddComid(tag)
+	}
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "", true))
+
+	vendorOf := func(candidate *CertPathCandidate) string {
+		return *candidate.Triple.Environment.Vendor
+	}
+
+	report, err := store.FindCertPathKeyTriples(leaf.cert, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Accepted, 1)
+	assert.Empty(t, report.Rejected)
+	assert.Equal(t, "intermediate", vendorOf(report.Accepted[0]))
+	require.Len(t, report.Accepted[0].Chains, 1)
+	assert.Equal(t, []*x509.Certificate{leaf.cert, intermediate.cert, root.cert}, report.Accepted[0].Chains[0])
+
+	report, err = store.FindCertPathKeyTriples(leaf.cert, &CertPathOptions{
+		Intermediates: []*x509.Certificate{intermediate.cert},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, report.Rejected)
+	vendors := make([]string, len(report.Accepted))
+	for i, candidate := range report.Accepted {
+		vendors[i] = vendorOf(candidate)
+	}
+	// root is the trust anchor of the "not-ca" path as well
+	assert.ElementsMatch(t, []string{"intermediate", "root", "not-ca"}, vendors)
+
+	report, err = store.FindCertPathKeyTriples(leaf.cert, &CertPathOptions{
+		Query: NewKeyTripleQuery().Vendor("root"),
+		Time:  now.Add(48 * time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, report.Accepted)
+	assert.Empty(t, report.Rejected)
+
+	report, err = store.FindCertPathKeyTriples(leaf.cert, &CertPathOptions{
+		Time: now.Add(48 * time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, report.Accepted)
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, "intermediate", vendorOf(report.Rejected[0]))
+	assert.ErrorIs(t, report.Rejected[0].Err, ErrCertPath)
+	var invalidErr x509.CertificateInvalidError
+	require.ErrorAs(t, report.Rejected[0].Err, &invalidErr)
+	assert.Equal(t, x509.Expired, invalidErr.Reason)
+
+	report, err = store.FindCertPathKeyTriples(notCALeaf.cert, nil)
+	require.NoError(t, err)
+	assert.Empty(t, report.Accepted)
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, "not-ca", vendorOf(report.Rejected[0]))
+	var authorityErr x509.UnknownAuthorityError
+	assert.ErrorAs(t, report.Rejected[0].Err, &authorityErr)
+	assert.ErrorContains(t, report.Rejected[0].Err, "parent certificate cannot sign")
+
+	report, err = store.FindCertPathKeyTriples(other.cert, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Accepted, 1)
+	assert.Equal(t, "other", vendorOf(report.Accepted[0]))
+
+	_, err = store.FindCertPathKeyTriples(nil, nil)
+	assert.ErrorContains(t, err, "leaf certificate not specified")
+}
+
+func TestParseCertPath(t *testing.T) {
+	certs, err := parseCertPath(comid.TestCertPath)
+	require.NoError(t, err)
+	assert.NotEmpty(t, certs)
+
+	_, err = parseCertPath("")
+	assert.ErrorContains(t, err, "empty cert path")
+
+	_, err = parseCertPath("garbage")
+	assert.ErrorContains(t, err, "could not decode PEM block 0")
+
+	_, err = parseCertPath(comid.TestECPubKey)
+	assert.ErrorContains(t, err, `unexpected type for PEM block 0: "PUBLIC KEY"`)
+}
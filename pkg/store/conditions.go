@@ -0,0 +1,564 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+)
+
+// conditionEvidence is a stateful environment presented in evidence, converted
+// into the form in which conditions are stored.
+type conditionEvidence struct {
+	environment  *model.Environment
+	measurements []*model.Measurement
+	// registers contains the canonical integrity registers of each of the
+	// measurements (see canonicalRegisters).
+	registers []map[any][]registerDigest
+}
+
+func newConditionEvidence(evidence *comid.StatefulEnvironment) (*conditionEvidence, error) {
+	env, err := model.NewEnvironmentFromCoRIM(&evidence.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("environment: %w", err)
+	}
+
+	ret := conditionEvidence{environment: env}
+
+	for i, origin := range evidence.Measurements.Values {
+		measurement, err := model.NewMeasurementFromCoRIM(&origin)
+		if err != nil {
+			return nil, fmt.Errorf("measurement %d: %w", i, err)
+		}
+
+		for _, key := range measurement.CryptoKeys {
+			key.UpdateKeyForms()
+		}
+
+		registers := make(map[any][]registerDigest)
+		if origin.Val.IntegrityRegisters != nil {
+			registers, err = canonicalRegisters(origin.Val.IntegrityRegisters)
+			if err != nil {
+				return nil, fmt.Errorf("measurement %d: %w", i, err)
+			}
+		}
+
+		ret.measurements = append(ret.measurements, measurement)
+		ret.registers = append(ret.registers, registers)
+	}
+
+	return &ret, nil
+}
+
+// FindConditionalEndorsementTriples finds the conditional endorsement triples
+// whose conditions are all satisfied by the evidence, i.e. the triples whose
+// endorsements should be added once the evidence has been accepted.
+//
+// A condition is satisfied if its environment matches the evidence
+// environment, with fields not specified by the condition matching any value,
+// and each of its measurements is satisfied by an evidence measurement with
+// the same key (see FindConditionalEndorsementSeriesTriples for how
+// measurement values are compared). Evidence measurements not specified by a
+// condition do not affect whether it is satisfied.
+//
+// The query, if not nil, further restricts the triples that are returned. If
+// the store is scoped to a tenant, only the tenant's triples are returned. If
+// no triples match, the returned error wraps ErrNoMatch.
+func (o *Store) FindConditionalEndorsementTriples(
+	evidence *comid.StatefulEnvironment,
+	query Query[*model.ConditionalEndorsementTripleEntry],
+) ([]*model.ConditionalEndorsementTripleEntry, error) {
+	if evidence == nil {
+		return nil, errors.New("evidence not specified")
+	}
+
+	if err := o.checkTenant("FindConditionalEndorsementTriples"); err != nil {
+		return nil, err
+	}
+
+	ev, err := newConditionEvidence(evidence)
+	if err != nil {
+		return nil, err
+	}
+
+	envIDs, err := o.conditionEnvironmentIDs(ev.environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []*model.StatefulEnvironment
+	err = o.ReadDB().NewSelect().
+		Model(&conditions).
+		Column("id", "environment_id", "triple_id").
+		Where("triple_id IN (?)", o.ReadDB().NewSelect().
+			Model((*model.StatefulEnvironment)(nil)).
+			Column("triple_id").
+			Where("environment_id IN (?)", bun.In(envIDs))).
+		Scan(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conditionIDs := make([]int64, 0, len(conditions))
+	for _, condition := range conditions {
+		conditionIDs = append(conditionIDs, condition.ID)
+	}
+
+	measurements, err := o.selectConditionMeasurements("stateful_environment", conditionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// all of the triple's conditions must be satisfied
+	satisfied := make(map[int64]bool)
+	for _, condition := range conditions {
+		ok := slices.Contains(envIDs, condition.EnvironmentID)
+		if ok {
+			ok, err = ev.satisfies(measurements[condition.ID])
+			if err != nil {
+				return nil, fmt.Errorf("stateful environment %d: %w", condition.ID, err)
+			}
+		}
+
+		prev, seen := satisfied[condition.TripleID]
+		satisfied[condition.TripleID] = ok && (prev || !seen)
+	}
+
+	tripleIDs := satisfiedIDs(satisfied)
+	if len(tripleIDs) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	// conditional endorsement queries cannot be combined into a single
+	// statement (see And()), so the entries are filtered instead
+	if query == nil {
+		query = NewConditionalEndorsementTripleQuery().TripleDbID(tripleIDs...)
+	}
+
+	entries, err := o.QueryConditionalEndorsementTripleEntries(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterTripleEntries(entries, tripleIDs, func(entry *model.ConditionalEndorsementTripleEntry) int64 {
+		return entry.TripleDbID
+	})
+}
+
+// FindConditionalEndorsementSeriesTriples finds the conditional endorsement
+// series triples whose condition is satisfied by the evidence. The series
+// records of the returned triples are not matched against the evidence.
+//
+// The condition is satisfied if its environment matches the evidence
+// environment, with fields not specified by the condition matching any value,
+// and each of its measurements is satisfied by an evidence measurement with
+// the same key. A measurement is satisfied if every value it specifies is
+// present in the evidence measurement: minimum SVNs, masked raw values and
+// integer ranges are compared as such; digests must match for all algorithms
+// they have in common with the evidence (of which there must be at least one);
+// integrity registers must be present with at least one matching digest; and
+// flags and crypto keys must be present in the evidence. Measurement
+// extensions are not compared.
+//
+// The query, if not nil, further restricts the triples that are returned. If
+// the store is scoped to a tenant, only the tenant's triples are returned. If
+// no triples match, the returned error wraps ErrNoMatch.
+func (o *Store) FindConditionalEndorsementSeriesTriples(
+	evidence *comid.StatefulEnvironment,
+	query Query[*model.ConditionalEndorsementSeriesTripleEntry],
+) ([]*model.ConditionalEndorsementSeriesTripleEntry, error) {
+	if evidence == nil {
+		return nil, errors.New("evidence not specified")
+	}
+
+	if err := o.checkTenant("FindConditionalEndorsementSeriesTriples"); err != nil {
+		return nil, err
+	}
+
+	ev, err := newConditionEvidence(evidence)
+	if err != nil {
+		return nil, err
+	}
+
+	envIDs, err := o.conditionEnvironmentIDs(ev.environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidateIDs []int64
+	err = o.ReadDB().NewSelect().
+		Model((*model.ConditionalEndorsementSeriesTriple)(nil)).
+		Column("id").
+		Where("environment_id IN (?)", bun.In(envIDs)).
+		Scan(o.Ctx, &candidateIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	measurements, err := o.selectConditionMeasurements("ces_condition", candidateIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	satisfied := make(map[int64]bool, len(candidateIDs))
+	for _, tripleID := range candidateIDs {
+		satisfied[tripleID], err = ev.satisfies(measurements[tripleID])
+		if err != nil {
+			return nil, fmt.Errorf("conditional endorsement series triple %d: %w", tripleID, err)
+		}
+	}
+
+	tripleIDs := satisfiedIDs(satisfied)
+	if len(tripleIDs) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	if query == nil {
+		query = NewConditionalEndorsementSeriesTripleQuery().TripleDbID(tripleIDs...)
+	}
+
+	entries, err := o.QueryConditionalEndorsementSeriesTripleEntries(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterTripleEntries(entries, tripleIDs, func(entry *model.ConditionalEndorsementSeriesTripleEntry) int64 {
+		return entry.TripleDbID
+	})
+}
+
+// conditionEnvironmentIDs returns the IDs of the stored environments matching
+// the evidence environment, treating the fields they do not specify as
+// wildcards. If there are none, ErrNoMatch is returned.
+func (o *Store) conditionEnvironmentIDs(evidence *model.Environment) ([]int64, error) {
+	dialect := o.ReadDB().Dialect()
+	query := o.ReadDB().NewSelect().
+		Model((*model.Environment)(nil)).
+		Column("id")
+
+	addWildcardWhereClause(query, "class_type", evidence.ClassType)
+	addWildcardWhereClause(query, "class_bytes", evidence.ClassBytes)
+	addWildcardWhereClause(query, "vendor", evidence.Vendor)
+	addWildcardWhereClause(query, "model", evidence.Model)
+	addWildcardWhereClause(query, "layer", evidence.Layer)
+	addWildcardWhereClause(query, identQuote("index", dialect), evidence.Index)
+	addWildcardWhereClause(query, "instance_type", evidence.InstanceType)
+	addWildcardWhereClause(query, "instance_bytes", evidence.InstanceBytes)
+	addWildcardWhereClause(query, "group_type", evidence.GroupType)
+	addWildcardWhereClause(query, "group_bytes", evidence.GroupBytes)
+
+	var ret []int64
+	if err := query.Scan(o.Ctx, &ret); err != nil {
+		return nil, err
+	}
+
+	if len(ret) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return ret, nil
+}
+
+// addWildcardWhereClause restricts the query to rows where the column is
+// either NULL or equal to the value. If the value is nil, the column must be
+// NULL.
+func addWildcardWhereClause[T any](query *bun.SelectQuery, column string, value *T) {
+	if value == nil {
+		query.Where(fmt.Sprintf("%s IS NULL", column))
+	} else {
+		query.Where(fmt.Sprintf("(%s IS NULL OR %s = ?)", column, column), *value)
+	}
+}
+
+// selectConditionMeasurements returns the measurements with the specified
+// owner type and IDs, keyed by owner ID.
+func (o *Store) selectConditionMeasurements(
+	ownerType string,
+	ownerIDs []int64,
+) (map[int64][]*model.Measurement, error) {
+	ret := make(map[int64][]*model.Measurement)
+	if len(ownerIDs) == 0 {
+		return ret, nil
+	}
+
+	var measurements []*model.Measurement
+	err := o.ReadDB().NewSelect().
+		Model(&measurements).
+		Relation("Digests").
+		Relation("Flags").
+		Relation("IntegrityRegisters.Digests").
+		Relation("ValueEntries").
+		Relation("CryptoKeys").
+		Where("owner_type = ?", ownerType).
+		Where("owner_id IN (?)", bun.In(ownerIDs)).
+		Scan(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, measurement := range measurements {
+		ret[measurement.OwnerID] = append(ret[measurement.OwnerID], measurement)
+	}
+
+	return ret, nil
+}
+
+// filterTripleEntries returns the entries for the triples with the specified
+// (sorted) IDs. If there are none, ErrNoMatch is returned.
+func filterTripleEntries[T any](entries []T, tripleIDs []int64, tripleID func(T) int64) ([]T, error) {
+	ret := slices.DeleteFunc(entries, func(entry T) bool {
+		_, found := slices.BinarySearch(tripleIDs, tripleID(entry))
+		return !found
+	})
+
+	if len(ret) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return ret, nil
+}
+
+// satisfiedIDs returns the sorted IDs that are mapped to true.
+func satisfiedIDs(satisfied map[int64]bool) []int64 {
+	var ret []int64
+	for id, ok := range satisfied {
+		if ok {
+			ret = append(ret, id)
+		}
+	}
+
+	slices.Sort(ret)
+
+	return ret
+}
+
+// satisfies returns true if each of the reference measurements is satisfied by
+// an evidence measurement with the same key.
+func (o *conditionEvidence) satisfies(references []*model.Measurement) (bool, error) {
+	for _, reference := range references {
+		found := false
+
+		for i, measurement := range o.measurements {
+			if !ptrEqual(reference.KeyType, measurement.KeyType) ||
+				!bytesPtrEqual(reference.KeyBytes, measurement.KeyBytes) {
+				continue
+			}
+
+			ok, err := measurementSatisfied(measurement, o.registers[i], reference)
+			if err != nil {
+				return false, fmt.Errorf("measurement %d: %w", reference.ID, err)
+			}
+
+			if ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func measurementSatisfied(
+	evidence *model.Measurement,
+	registers map[any][]registerDigest,
+	reference *model.Measurement,
+) (bool, error) {
+	for _, entry := range reference.ValueEntries {
+		found := false
+
+		for _, candidate := range evidence.ValueEntries {
+			if candidate.CodePoint != entry.CodePoint {
+				continue
+			}
+
+			ok, err := valueEntrySatisfied(candidate, entry)
+			if err != nil {
+				return false, err
+			}
+
+			if ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	if len(reference.Digests) != 0 && !digestsSatisfied(evidence.Digests, reference.Digests) {
+		return false, nil
+	}
+
+	for _, flag := range reference.Flags {
+		if !slices.ContainsFunc(evidence.Flags, func(candidate *model.Flag) bool {
+			return candidate.CodePoint == flag.CodePoint && candidate.Value == flag.Value
+		}) {
+			return false, nil
+		}
+	}
+
+	for _, reg := range reference.IntegrityRegisters {
+		ok, err := registerSatisfied(reg, registers)
+		if err != nil {
+			return false, fmt.Errorf("integrity register %d: %w", reg.ID, err)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	for _, key := range reference.CryptoKeys {
+		if !slices.ContainsFunc(evidence.CryptoKeys, func(candidate *model.CryptoKey) bool {
+			return cryptoKeysEqual(candidate, key)
+		}) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func valueEntrySatisfied(evidence, reference *model.MeasurementValueEntry) (bool, error) {
+	switch reference.CodePoint {
+	case model.MvalSvn:
+		if evidence.ValueInt == nil || reference.ValueInt == nil {
+			return false, nil
+		}
+
+		if reference.ValueType == comid.MinValueType {
+			return *evidence.ValueInt >= *reference.ValueInt, nil
+		}
+
+		return evidence.ValueType == comid.ExactValueType && *evidence.ValueInt == *reference.ValueInt, nil
+	case model.MvalRawValue:
+		if evidence.ValueBytes == nil || reference.ValueBytes == nil {
+			return false, nil
+		}
+
+		if reference.ValueMask != nil {
+			return comid.NewRawValueFromBytes(*evidence.ValueBytes).
+				CompareAgainstReference(*reference.ValueBytes, *reference.ValueMask), nil
+		}
+
+		return bytes.Equal(*evidence.ValueBytes, *reference.ValueBytes), nil
+	case model.MvalIntRange:
+		if evidence.ValueBytes == nil || reference.ValueBytes == nil {
+			return false, nil
+		}
+
+		return intRangeSatisfied(*evidence.ValueBytes, *reference.ValueBytes)
+	default:
+		return evidence.ValueType == reference.ValueType &&
+			ptrEqual(evidence.ValueText, reference.ValueText) &&
+			ptrEqual(evidence.ValueInt, reference.ValueInt) &&
+			bytesPtrEqual(evidence.ValueBytes, reference.ValueBytes), nil
+	}
+}
+
+// intRangeSatisfied compares CBOR-encoded int-range values (see
+// comid.RawInt).
+func intRangeSatisfied(evidence, reference []byte) (bool, error) {
+	var ev, ref comid.RawInt
+
+	if err := ev.UnmarshalCBOR(evidence); err != nil {
+		return false, fmt.Errorf("evidence int-range: %w", err)
+	}
+
+	if err := ref.UnmarshalCBOR(reference); err != nil {
+		return false, fmt.Errorf("reference int-range: %w", err)
+	}
+
+	switch e := ev.Value.(type) {
+	case *comid.RawIntInteger:
+		switch r := ref.Value.(type) {
+		case *comid.RawIntInteger:
+			return e.CompareAgainstRefInteger(*r), nil
+		case *comid.TaggedRawIntRange:
+			return e.CompareAgainstRefRange(*r), nil
+		}
+	case *comid.TaggedRawIntRange:
+		switch r := ref.Value.(type) {
+		case *comid.RawIntInteger:
+			return e.CompareAgainstRefInteger(*r), nil
+		case *comid.TaggedRawIntRange:
+			return e.CompareAgainstRefRange(*r), nil
+		}
+	}
+
+	return false, fmt.Errorf("unexpected int-range types: %T, %T", ev.Value, ref.Value)
+}
+
+// digestsSatisfied returns true if the evidence contains at least one digest
+// using one of the reference algorithms, and all such digests match the
+// reference. Algorithms are compared using their canonical IDs where known.
+func digestsSatisfied(evidence, reference []*model.Digest) bool {
+	algKey := func(digest *model.Digest) string {
+		if digest.CanonicalAlgID != 0 {
+			return fmt.Sprint(digest.CanonicalAlgID)
+		}
+
+		return fmt.Sprintf("%d:%s", digest.AlgIDInt, digest.AlgIDText)
+	}
+
+	expected := make(map[string][][]byte)
+	for _, digest := range reference {
+		key := algKey(digest)
+		expected[key] = append(expected[key], digest.Value)
+	}
+
+	matched := false
+	for _, digest := range evidence {
+		values, ok := expected[algKey(digest)]
+		if !ok {
+			continue
+		}
+
+		if !slices.ContainsFunc(values, func(value []byte) bool {
+			return bytes.Equal(value, digest.Value)
+		}) {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+// cryptoKeysEqual returns true if the keys have the same type and value, or
+// the same SPKI (see util.KeyForms).
+func cryptoKeysEqual(lhs, rhs *model.CryptoKey) bool {
+	if lhs.KeyType == rhs.KeyType && bytes.Equal(lhs.KeyBytes, rhs.KeyBytes) {
+		return true
+	}
+
+	return len(lhs.SPKI) != 0 && bytes.Equal(lhs.SPKI, rhs.SPKI)
+}
+
+func ptrEqual[T comparable](lhs, rhs *T) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == rhs
+	}
+
+	return *lhs == *rhs
+}
+
+func bytesPtrEqual(lhs, rhs *[]byte) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == rhs
+	}
+
+	return bytes.Equal(*lhs, *rhs)
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+func TestStore_FindConditionalEndorsementTriples(t *testing.T) {
+	foo := sha256.Sum256([]byte("foo"))
+	bar := sha512.Sum384([]byte("bar"))
+
+	newEnv := func(vendor, model string) comid.Environment {
+		class := comid.Class{Vendor: &vendor}
+		if model != "" {
+			class.Model = &model
+		}
+
+		return comid.Environment{Class: &class}
+	}
+
+	newMeasurements := func(values ...*comid.Measurement) comid.Measurements {
+		var ret comid.Measurements
+		for _, value := range values {
+			ret.Add(value)
+		}
+
+		return ret
+	}
+
+	endorsements := func(name string) comid.ValueTriples {
+		ret := comid.NewValueTriples()
+		ret.Add(&comid.ValueTriple{
+			Environment:  newEnv("ACME", "Widget"),
+			Measurements: newMeasurements(comid.MustNewUintMeasurement(uint64(9)).SetName(name)),
+		})
+
+		return *ret
+	}
+
+	newCondEndorse := func(name string, conditions ...*comid.StatefulEnvironment) *comid.CondEndorseTriple {
+		ret := comid.CondEndorseTriple{Endorsements: endorsements(name)}
+		for _, condition := range conditions {
+			ret.Conditions.Add(condition)
+		}
+
+		return &ret
+	}
+
+	newSeries := func(name string, env comid.Environment, measurements comid.Measurements) *comid.CondEndorseSeriesTriple {
+		series := comid.NewCondEndorseSeriesRecords()
+		series.Add(&comid.CondEndorseSeriesRecord{
+			Selection: newMeasurements(comid.MustNewUintMeasurement(uint64(1)).SetSVN(5)),
+			Addition:  newMeasurements(comid.MustNewUintMeasurement(uint64(9)).SetName(name)),
+		})
+
+		return &comid.CondEndorseSeriesTriple{
+			Condition: comid.CondEndorseSeriesCondition{Environment: env, Measurements: measurements},
+			Series:    *series,
+		}
+	}
+
+	masked, err := comid.NewRawValueWithMask([]byte{0x01, 0x00, 0xf0}, []byte{0xff, 0x00, 0xf0})
+	require.NoError(t, err)
+
+	maskedMeasurement := comid.MustNewUintMeasurement(uint64(1))
+	maskedMeasurement.Val.RawValue = masked
+
+	tag := comid.NewComid().SetTagIdentity("foo", 0)
+	tag.Triples.CondEndorsements = comid.NewCondEndorseTriples()
+	tag.Triples.CondEndorsements.
+		Add(newCondEndorse("min-svn", &comid.StatefulEnvironment{
+			Environment: newEnv("ACME", ""),
+			Measurements: newMeasurements(comid.MustNewUintMeasurement(uint64(1)).
+				SetMinSVN(3).
+				AddDigest(comid.Sha256, foo[:])),
+		})).
+		Add(newCondEndorse("masked", &comid.StatefulEnvironment{
+			Environment:  newEnv("ACME", "Widget"),
+			Measurements: newMeasurements(maskedMeasurement),
+		})).
+		Add(newCondEndorse("two-environments", &comid.StatefulEnvironment{
+			Environment:  newEnv("ACME", ""),
+			Measurements: newMeasurements(comid.MustNewUintMeasurement(uint64(1)).SetSVN(5)),
+		}, &comid.StatefulEnvironment{
+			Environment:  newEnv("Globex", ""),
+			Measurements: newMeasurements(comid.MustNewUintMeasurement(uint64(1)).SetSVN(5)),
+		})).
+		Add(newCondEndorse("other-model", &comid.StatefulEnvironment{
+			Environment:  newEnv("ACME", "Gadget"),
+			Measurements: newMeasurements(comid.MustNewUintMeasurement(uint64(1)).SetSVN(5)),
+		}))
+	tag.AddCondEndorseSeries(newSeries("flags", newEnv("ACME", ""), newMeasurements(
+		comid.MustNewUintMeasurement(uint64(1)).SetFlagsFalse(comid.FlagIsDebug),
+	)))
+	tag.AddCondEndorseSeries(newSeries("other-key", newEnv("ACME", ""), newMeasurements(
+		comid.MustNewUintMeasurement(uint64(2)).SetSVN(5),
+	)))
+
+	manifest := corim.NewUnsignedCorim().SetID("bar").AddComid(tag)
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "qux", true))
+
+	newEvidence := func(svn uint64, rawValue []byte) *comid.StatefulEnvironment {
+		env := newEnv("ACME", "Widget")
+		env.Class.ClassID = comid.MustNewBytesClassID([]byte{0x01})
+
+		measurement := comid.MustNewUintMeasurement(uint64(1)).
+			SetSVN(svn).
+			AddDigest(comid.Sha256, foo[:]).
+			AddDigest(comid.Sha384, bar[:]).
+			SetRawValueBytes(rawValue, nil).
+			SetFlagsFalse(comid.FlagIsDebug)
+
+		return &comid.StatefulEnvironment{
+			Environment:  env,
+			Measurements: newMeasurements(measurement, comid.MustNewUintMeasurement(uint64(3)).SetSVN(1)),
+		}
+	}
+
+	cetNames := func(entries []*model.ConditionalEndorsementTripleEntry) []string {
+		ids := make([]int64, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.TripleDbID)
+		}
+
+		triples, err := store.QueryConditionalEndorsementTriples(
+			NewConditionalEndorsementTripleQuery().TripleDbID(ids...))
+		require.NoError(t, err)
+
+		ret := make([]string, 0, len(triples))
+		for _, triple := range triples {
+			ret = append(ret, *triple.Endorsements.Values[0].Measurements.Values[0].Val.Name)
+		}
+
+		return ret
+	}
+
+	cesNames := func(entries []*model.ConditionalEndorsementSeriesTripleEntry) []string {
+		ids := make([]int64, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.TripleDbID)
+		}
+
+		triples, err := store.QueryConditionalEndorsementSeriesTriples(
+			NewConditionalEndorsementSeriesTripleQuery().TripleDbID(ids...))
+		require.NoError(t, err)
+
+		ret := make([]string, 0, len(triples))
+		for _, triple := range triples {
+			ret = append(ret, *triple.Series.Values[0].Addition.Values[0].Val.Name)
+		}
+
+		return ret
+	}
+
+	entries, err := store.FindConditionalEndorsementTriples(newEvidence(5, []byte{0x01, 0x02, 0xf3}), nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"min-svn", "masked"}, cetNames(entries))
+
+	entries, err = store.FindConditionalEndorsementTriples(newEvidence(2, []byte{0x01, 0x02, 0xf3}), nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"masked"}, cetNames(entries))
+
+	entries, err = store.FindConditionalEndorsementTriples(
+		newEvidence(5, []byte{0x02, 0x02, 0xf3}),
+		NewConditionalEndorsementTripleQuery().Label("qux"),
+	)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"min-svn"}, cetNames(entries))
+
+	_, err = store.FindConditionalEndorsementTriples(newEvidence(2, []byte{0x02}), nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.FindConditionalEndorsementTriples(newEvidence(5, []byte{0x01, 0x02, 0xf3}),
+		NewConditionalEndorsementTripleQuery().Label("other"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	globex := newEvidence(5, nil)
+	globex.Environment = newEnv("Globex", "")
+	_, err = store.FindConditionalEndorsementTriples(globex, nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.FindConditionalEndorsementTriples(nil, nil)
+	assert.ErrorContains(t, err, "evidence not specified")
+
+	seriesEntries, err := store.FindConditionalEndorsementSeriesTriples(newEvidence(5, []byte{0x01}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"flags"}, cesNames(seriesEntries))
+
+	debug := newEvidence(5, []byte{0x01})
+	debug.Measurements.Values[0].SetFlagsTrue(comid.FlagIsDebug)
+	_, err = store.FindConditionalEndorsementSeriesTriples(debug, nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	tenant, err := store.ForTenant("baz")
+	require.NoError(t, err)
+
+	_, err = tenant.FindConditionalEndorsementTriples(newEvidence(5, []byte{0x01, 0x02, 0xf3}), nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = tenant.FindConditionalEndorsementSeriesTriples(newEvidence(5, []byte{0x01}), nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestDigestsSatisfied(t *testing.T) {
+	foo := sha256.Sum256([]byte("foo"))
+	bar := sha512.Sum384([]byte("bar"))
+
+	sha256Foo := model.NewDigestInt(int64(comid.Sha256), foo[:])
+	sha384Bar := model.NewDigestInt(int64(comid.Sha384), bar[:])
+	sha384Foo := model.NewDigestInt(int64(comid.Sha384), foo[:])
+
+	assert.True(t, digestsSatisfied([]*model.Digest{sha256Foo, sha384Bar}, []*model.Digest{sha256Foo}))
+	assert.True(t, digestsSatisfied([]*model.Digest{sha256Foo}, []*model.Digest{sha256Foo, sha384Bar}))
+	assert.False(t, digestsSatisfied([]*model.Digest{sha256Foo, sha384Foo}, []*model.Digest{sha256Foo, sha384Bar}))
+	assert.False(t, digestsSatisfied([]*model.Digest{sha384Bar}, []*model.Digest{sha256Foo}))
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"fmt"
 	"slices"
+	"time"
 
 	"github.com/veraison/corim-store/pkg/db"
 )
@@ -22,6 +23,25 @@ type Config struct {
 	// RequireLabel indicates whether a label must be specified when adding
 	// or looking up values from the Store.
 	RequireLabel bool
+	// RequireTenant indicates whether queries and modifications of the
+	// Store's contents must be made via a tenant-scoped Store (see
+	// Store.ForTenant). Administrative operations (e.g. Verify) are not
+	// affected.
+	RequireTenant bool
+	// ReadOnly indicates whether the Store should reject all operations
+	// that would modify its contents (with ErrReadOnly).
+	ReadOnly bool
+	// ReplicaDSN, if set, is the Data Source Name of a read replica of the
+	// database. Queries will be routed to the replica, while operations
+	// that modify the Store's contents will use the primary (DSN).
+	ReplicaDSN string
+	// ExpiryWarning is the window before a manifest's NotAfter during which
+	// ExpireManifests reports it as expiring.
+	ExpiryWarning time.Duration
+	// ExpiredRetention is how long manifests are retained after their
+	// NotAfter. Once it has elapsed, ExpireManifests deletes them. Zero
+	// means expired manifests are retained indefinitely.
+	ExpiredRetention time.Duration
 }
 
 func NewConfig(dbms, dsn string, options ...ConfigOption) *Config {
@@ -31,10 +51,11 @@ func NewConfig(dbms, dsn string, options ...ConfigOption) *Config {
 			DSN:      dsn,
 			TraceSQL: false,
 		},
-		HashAlg:      "sha256",
-		RequireLabel: false,
-		Insecure:     false,
-		Force:        false,
+		HashAlg:       "sha256",
+		RequireLabel:  false,
+		Insecure:      false,
+		Force:         false,
+		ExpiryWarning: DefaultExpiryWarning,
 	}
 
 	ret.WithOptions(options...)
@@ -63,13 +84,34 @@ func (o *Config) Validate() error {
 		return fmt.Errorf("invalid hash algorithm: %s", o.HashAlg)
 	}
 
-	return nil
+	if o.ExpiryWarning < 0 {
+		return fmt.Errorf("invalid expiry warning: %s", o.ExpiryWarning)
+	}
+
+	if o.ExpiredRetention < 0 {
+		return fmt.Errorf("invalid expired retention: %s", o.ExpiredRetention)
+	}
+
+	return o.Config.Validate()
 }
 
 func (o *Config) DB() *db.Config {
 	return &o.Config
 }
 
+// ReplicaDB returns the db.Config for connecting to the read replica, or nil,
+// if a replica has not been configured.
+func (o *Config) ReplicaDB() *db.Config {
+	if o.ReplicaDSN == "" {
+		return nil
+	}
+
+	ret := o.Config
+	ret.DSN = o.ReplicaDSN
+
+	return &ret
+}
+
 type ConfigOption func(c *Config)
 
 func OptionSHA256(c *Config) {
@@ -99,3 +141,29 @@ func OptionForce(c *Config) {
 func OptionRequireLabel(c *Config) {
 	c.RequireLabel = true
 }
+
+func OptionRequireTenant(c *Config) {
+	c.RequireTenant = true
+}
+
+func OptionReadOnly(c *Config) {
+	c.ReadOnly = true
+}
+
+func OptionReplicaDSN(dsn string) ConfigOption {
+	return func(c *Config) {
+		c.ReplicaDSN = dsn
+	}
+}
+
+func OptionExpiryWarning(window time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.ExpiryWarning = window
+	}
+}
+
+func OptionExpiredRetention(retention time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.ExpiredRetention = retention
+	}
+}
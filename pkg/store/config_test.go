@@ -2,6 +2,7 @@ package store
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +48,26 @@ func TestConfig_Validate(t *testing.T) {
 			}},
 			err: "invalid hash algorithm: bar",
 		},
+		{
+			title: "ok expiry",
+			dbms:  "mysql",
+			opts: []ConfigOption{
+				OptionExpiryWarning(7 * 24 * time.Hour),
+				OptionExpiredRetention(90 * 24 * time.Hour),
+			},
+		},
+		{
+			title: "invalid expiry warning",
+			dbms:  "mysql",
+			opts:  []ConfigOption{OptionExpiryWarning(-time.Hour)},
+			err:   "invalid expiry warning: -1h0m0s",
+		},
+		{
+			title: "invalid expired retention",
+			dbms:  "mysql",
+			opts:  []ConfigOption{OptionExpiredRetention(-time.Hour)},
+			err:   "invalid expired retention: -1h0m0s",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -74,3 +95,15 @@ func TestConfig_DB(t *testing.T) {
 	assert.Equal(t, "bar", dbConfig.DSN)
 	assert.True(t, dbConfig.TraceSQL)
 }
+
+func TestConfig_ReplicaDB(t *testing.T) {
+	assert.Nil(t, NewConfig("postgres", "foo").ReplicaDB())
+
+	cfg := NewConfig("postgres", "foo", OptionReplicaDSN("bar"), OptionReadOnly)
+	assert.True(t, cfg.ReadOnly)
+
+	replicaConfig := cfg.ReplicaDB()
+	assert.Equal(t, "postgres", replicaConfig.DBMS)
+	assert.Equal(t, "bar", replicaConfig.DSN)
+	assert.Equal(t, "foo", cfg.DB().DSN)
+}
@@ -112,7 +112,7 @@ func (o *CoSERVService) runEnvironmentQuery(
 		for i, entry := range tripleEntries {
 			updateExpiry(&expiry, entry.NotAfter)
 
-			model, err := entry.ToTriple(o.Store.Ctx, o.Store.DB)
+			model, err := entry.ToTriple(o.Store.Ctx, o.Store.ReadDB())
 			if err != nil {
 				return nil, nil, fmt.Errorf("value triple with ID %d: %w", entry.TripleDbID, err)
 			}
@@ -170,7 +170,7 @@ func (o *CoSERVService) runEnvironmentQuery(
 		for i, entry := range tripleEntries {
 			updateExpiry(&expiry, entry.NotAfter)
 
-			model, err := entry.ToTriple(o.Store.Ctx, o.Store.DB)
+			model, err := entry.ToTriple(o.Store.Ctx, o.Store.ReadDB())
 			if err != nil {
 				return nil, nil, fmt.Errorf("value triple with ID %d: %w", entry.TripleDbID, err)
 			}
@@ -194,7 +194,7 @@ func (o *CoSERVService) runEnvironmentQuery(
 		for i, entry := range condTripleEntries {
 			updateExpiry(&expiry, entry.NotAfter)
 
-			model, err := entry.ToTriple(o.Store.Ctx, o.Store.DB)
+			model, err := entry.ToTriple(o.Store.Ctx, o.Store.ReadDB())
 			if err != nil {
 				return nil, nil, fmt.Errorf("conditional endorsement triple with ID %d: %w",
 					entry.TripleDbID, err)
@@ -236,7 +236,7 @@ func (o *CoSERVService) runEnvironmentQuery(
 		for i, entry := range tripleEntries {
 			updateExpiry(&expiry, entry.NotAfter)
 
-			model, err := entry.ToTriple(o.Store.Ctx, o.Store.DB)
+			model, err := entry.ToTriple(o.Store.Ctx, o.Store.ReadDB())
 			if err != nil {
 				return nil, nil, fmt.Errorf("key triple with ID %d: %w", entry.TripleDbID, err)
 			}
@@ -363,7 +363,7 @@ func (o *CoSERVService) getRIMEntryFromID(
 		}
 
 		manifest := model.Manifest{ID: dbID}
-		if err := manifest.Select(o.Store.Ctx, o.Store.DB); err != nil {
+		if err := manifest.Select(o.Store.Ctx, o.Store.ReadDB()); err != nil {
 			return nil, err
 		}
 
@@ -103,6 +103,28 @@ Unlike the Get methods, the Query methods do not make assumptions about what is
 wanted; e.g. if you're only interested in active triples, you need to specify
 this as part of the query.
 
+Multiple tenants may share a store. A tenant-scoped Store transparently
+restricts all queries, additions and deletions to the tenant's contents (which
+are kept under a label matching the tenant's name):
+
+	tenant, err := repo.ForTenant("acme", store.TenantAllowedProfiles(profile))
+	if err != nil {
+	    return err
+	}
+
+	// added under label "acme"
+	if err := tenant.AddBytes(bytes, "", true); err != nil {
+	    return err
+	}
+
+Entities are scoped via the manifests and module tags they belong to, and
+environments (which may be shared between tenants) via the triples that
+reference them. Attempting to access another tenant's contents via a
+tenant-scoped Store results in an error wrapping ErrTenantScope. If the store
+is opened with `store.OptionRequireTenant`, contents may only be accessed via
+tenant-scoped Stores (administrative operations, such as Verify, are still
+available on the unscoped Store).
+
 A CoSERV service wrapper for the Store allows running CoSERV queries and
 generating coserv.ResultSet's:
 
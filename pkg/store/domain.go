@@ -0,0 +1,286 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+)
+
+// MaxDomainDepth is the maximum number of links followed from the starting
+// environment when traversing the domain graph.
+const MaxDomainDepth = 32
+
+// DomainRelation selects the domain triples that are followed when traversing
+// the domain graph (see Store.TraverseDomains), and the direction in which
+// they are followed.
+type DomainRelation int
+
+const (
+	// DomainDependencies follows domain dependency triples from domains
+	// to their trustees.
+	DomainDependencies DomainRelation = iota
+	// DomainMembers follows domain membership triples from domains to
+	// their members.
+	DomainMembers
+	// DomainMemberships follows domain membership triples from members to
+	// the domains they are members of.
+	DomainMemberships
+)
+
+func (o DomainRelation) String() string {
+	switch o {
+	case DomainDependencies:
+		return "dependencies"
+	case DomainMembers:
+		return "members"
+	case DomainMemberships:
+		return "memberships"
+	default:
+		return fmt.Sprintf("DomainRelation(%d)", int(o))
+	}
+}
+
+// DomainLink is a link between two environments, established by a domain
+// triple, that has been followed while traversing the domain graph.
+type DomainLink struct {
+	// FromID is the ID of the environment the link was followed from, and
+	// ToID the ID of the environment it leads to.
+	FromID int64
+	ToID   int64
+	// TripleDbID is the ID of the domain triple establishing the link.
+	TripleDbID int64
+	// Depth is the number of links between the starting environment and
+	// ToID (i.e. it is 1 for the links from the starting environment).
+	Depth int
+	// Cycle is true if the link closes a cycle, i.e. if ToID was reached
+	// before the link (at a smaller depth), and FromID can be reached from
+	// ToID.
+	Cycle bool
+}
+
+// DomainGraph is the part of the domain graph reachable from an environment.
+type DomainGraph struct {
+	Relation DomainRelation
+	// Root is the environment the traversal started from.
+	Root *model.Environment
+	// Links contains the links that have been followed, ordered by depth.
+	// Each link appears once, at the smallest depth it was reached at.
+	Links []*DomainLink
+	// Environments contains the environments that links lead to, keyed by
+	// their IDs.
+	Environments map[int64]*model.Environment
+}
+
+// Children returns the links followed from the environment with the specified
+// ID, reached at the specified depth (0 being the root).
+func (o *DomainGraph) Children(environmentID int64, depth int) []*DomainLink {
+	var ret []*DomainLink
+
+	for _, link := range o.Links {
+		if link.FromID == environmentID && link.Depth == depth+1 {
+			ret = append(ret, link)
+		}
+	}
+
+	return ret
+}
+
+// DomainClosure returns the environments transitively required by the domain
+// (i.e. its trustees, their trustees, and so on), following active domain
+// dependency triples. The domain is matched exactly against stored
+// environments. If the store is scoped to a tenant, only the tenant's triples
+// are followed. If the domain is not in the store, the returned error wraps
+// ErrNoMatch.
+func (o *Store) DomainClosure(domain *comid.Environment) (*DomainGraph, error) {
+	return o.traverseDomainsFrom(domain, DomainDependencies)
+}
+
+// DomainMembersOf returns the domains the component is a member of, either
+// directly or via nested domains, following active domain membership triples.
+// The component is matched exactly against stored environments. If the store
+// is scoped to a tenant, only the tenant's triples are followed. If the
+// component is not in the store, the returned error wraps ErrNoMatch.
+func (o *Store) DomainMembersOf(component *comid.Environment) (*DomainGraph, error) {
+	return o.traverseDomainsFrom(component, DomainMemberships)
+}
+
+// TraverseDomains returns the part of the domain graph reachable from the
+// environment with the specified ID, following active domain triples selected
+// by the relation. The graph is traversed in the database using a recursive
+// common table expression that tracks the links reached, rather than the paths
+// leading to them, so that shared sub-domains do not multiply the rows it
+// produces. Each link is reported once, at the smallest depth it is reached
+// at, and links closing a cycle are marked as such (see DomainLink.Cycle). No
+// more than MaxDomainDepth links are followed from the starting environment.
+// If the store is scoped to a tenant, only the tenant's triples are followed.
+func (o *Store) TraverseDomains(environmentID int64, relation DomainRelation) (*DomainGraph, error) {
+	if err := o.checkTenant("TraverseDomains"); err != nil {
+		return nil, err
+	}
+
+	root, err := model.SelectEnvironment(o.Ctx, o.ReadDB(), environmentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNoMatch
+		}
+
+		return nil, fmt.Errorf("environment %d: %w", environmentID, err)
+	}
+
+	query, args, err := o.domainLinksQuery(environmentID, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		FromID   int64 `bun:"from_id"`
+		ToID     int64 `bun:"to_id"`
+		TripleID int64 `bun:"triple_id"`
+		Depth    int   `bun:"depth"`
+		IsCycle  int   `bun:"is_cycle"`
+	}
+
+	if err := o.ReadDB().NewRaw(query, args...).Scan(o.Ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	ret := DomainGraph{
+		Relation:     relation,
+		Root:         root,
+		Environments: make(map[int64]*model.Environment),
+	}
+
+	var ids []int64
+	for _, row := range rows {
+		ret.Links = append(ret.Links, &DomainLink{
+			FromID:     row.FromID,
+			ToID:       row.ToID,
+			TripleDbID: row.TripleID,
+			Depth:      row.Depth,
+			Cycle:      row.IsCycle != 0,
+		})
+
+		if _, ok := ret.Environments[row.ToID]; !ok {
+			ret.Environments[row.ToID] = nil
+			ids = append(ids, row.ToID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return &ret, nil
+	}
+
+	envs, err := NewEnvironmentQuery(false).ID(ids...).Run(o.Ctx, o.ReadDB())
+	if err != nil {
+		return nil, fmt.Errorf("environments: %w", err)
+	}
+
+	for _, env := range envs {
+		ret.Environments[env.ID] = env
+	}
+
+	return &ret, nil
+}
+
+func (o *Store) traverseDomainsFrom(origin *comid.Environment, relation DomainRelation) (*DomainGraph, error) {
+	if origin == nil {
+		return nil, errors.New("environment not specified")
+	}
+
+	query := NewEnvironmentQuery(true)
+	if err := query.UpdateFromCoRIM(origin); err != nil {
+		return nil, err
+	}
+
+	envs, err := query.Run(o.Ctx, o.ReadDB())
+	if err != nil {
+		return nil, err
+	}
+
+	return o.TraverseDomains(envs[0].ID, relation)
+}
+
+// domainLinksQuery returns the recursive query selecting the links reachable
+// from the environment, along with its arguments. domain_paths pairs links
+// with the depths they are reached at; as it is built with UNION rather than
+// UNION ALL, a link reached at the same depth via different paths yields a
+// single row, so it has no more than MaxDomainDepth rows per link. A link
+// closes a cycle if its ToID was reached at a smaller depth than the link, and
+// its FromID can be reached from its ToID (domain_reach being the transitive
+// closure of the links).
+func (o *Store) domainLinksQuery(environmentID int64, relation DomainRelation) (string, []any, error) {
+	var view, ownerType, fromColumn, toColumn string
+
+	switch relation {
+	case DomainDependencies:
+		view, ownerType = "domain_dependency_triple_entries", "domain_dependency_triple"
+		fromColumn, toColumn = "t.environment_db_id", "de.environment_id"
+	case DomainMembers:
+		view, ownerType = "domain_membership_triple_entries", "domain_membership_triple"
+		fromColumn, toColumn = "t.environment_db_id", "de.environment_id"
+	case DomainMemberships:
+		view, ownerType = "domain_membership_triple_entries", "domain_membership_triple"
+		fromColumn, toColumn = "de.environment_id", "t.environment_db_id"
+	default:
+		return "", nil, fmt.Errorf("unexpected domain relation: %s", relation)
+	}
+
+	args := []any{ownerType, true}
+
+	labelFilter := ""
+	if o.tenant != nil {
+		labelFilter = " AND t.label = ?"
+		args = append(args, o.tenant.Name)
+	}
+
+	args = append(args, environmentID, MaxDomainDepth, environmentID)
+
+	query := fmt.Sprintf(`WITH RECURSIVE domain_links AS (
+	SELECT DISTINCT %s AS from_id, %s AS to_id, t.triple_db_id AS triple_id
+	FROM %s AS t
+	INNER JOIN domain_entries AS de
+	  ON de.owner_id = t.triple_db_id AND de.owner_type = ?
+	WHERE t.is_active = ?%s
+), domain_paths AS (
+	SELECT from_id, to_id, triple_id, 1 AS depth
+	FROM domain_links
+	WHERE from_id = ?
+	UNION
+	SELECT l.from_id, l.to_id, l.triple_id, p.depth + 1
+	FROM domain_links AS l
+	INNER JOIN domain_paths AS p
+	  ON l.from_id = p.to_id
+	WHERE p.depth < ?
+), reached_links AS (
+	SELECT from_id, to_id, triple_id, MIN(depth) AS depth
+	FROM domain_paths
+	GROUP BY from_id, to_id, triple_id
+), domain_reach AS (
+	SELECT from_id AS start_id, to_id AS end_id
+	FROM reached_links
+	UNION
+	SELECT r.start_id, l.to_id
+	FROM reached_links AS l
+	INNER JOIN domain_reach AS r
+	  ON l.from_id = r.end_id
+)
+SELECT l.from_id, l.to_id, l.triple_id, l.depth,
+  CASE WHEN (
+	l.to_id = ? OR EXISTS (
+	  SELECT 1 FROM reached_links AS s
+	  WHERE s.to_id = l.to_id AND s.depth < l.depth
+	)
+  ) AND EXISTS (
+	SELECT 1 FROM domain_reach AS r
+	WHERE r.start_id = l.to_id AND r.end_id = l.from_id
+  ) THEN 1 ELSE 0 END AS is_cycle
+FROM reached_links AS l
+ORDER BY l.depth, l.from_id, l.to_id, l.triple_id`,
+		fromColumn, toColumn, view, labelFilter,
+	)
+
+	return query, args, nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+func TestStore_DomainGraph(t *testing.T) {
+	newEnv := func(vendor string) comid.Environment {
+		return comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor(vendor)}
+	}
+
+	board, soc, nic, cpu, gpu := newEnv("Board"), newEnv("SoC"), newEnv("NIC"), newEnv("CPU"), newEnv("GPU")
+	rot, hsm := newEnv("RoT"), newEnv("HSM")
+
+	topology := comid.NewComid().SetTagIdentity("topology", 0)
+	topology.Triples.DomainMemberships = comid.NewDomainMebershipTriples().
+		Add(comid.DomainMembershipTriple{DomainID: board, Members: []comid.Environment{soc, nic}}).
+		Add(comid.DomainMembershipTriple{DomainID: soc, Members: []comid.Environment{cpu, gpu}})
+	topology.Triples.DomainDependencies = &comid.DomainDependencyTriples{
+		{DomainID: board, Trustees: []comid.Environment{rot}},
+		{DomainID: rot, Trustees: []comid.Environment{hsm}},
+	}
+
+	// Closes the membership cycle Board -> SoC -> CPU -> Board, which is
+	// only detectable across tags.
+	loop := comid.NewComid().SetTagIdentity("loop", 0)
+	loop.Triples.DomainMemberships = comid.NewDomainMebershipTriples().
+		Add(comid.DomainMembershipTriple{DomainID: cpu, Members: []comid.Environment{board}})
+
+	manifest := corim.NewUnsignedCorim().SetID("bar").AddComid(topology).AddComid(loop)
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "qux", true))
+
+	envID := func(env comid.Environment) int64 {
+		query := NewEnvironmentQuery(true)
+		require.NoError(t, query.UpdateFromCoRIM(&env))
+
+		envs, err := query.Run(store.Ctx, store.ReadDB())
+		require.NoError(t, err)
+		require.Len(t, envs, 1)
+
+		return envs[0].ID
+	}
+
+	type link struct {
+		from, to string
+		depth    int
+		cycle    bool
+	}
+
+	links := func(graph *DomainGraph) []link {
+		names := map[int64]string{graph.Root.ID: *graph.Root.Vendor}
+		for id, env := range graph.Environments {
+			require.NotNil(t, env)
+			names[id] = *env.Vendor
+		}
+
+		var ret []link
+		for _, l := range graph.Links {
+			ret = append(ret, link{names[l.FromID], names[l.ToID], l.Depth, l.Cycle})
+		}
+
+		return ret
+	}
+
+	graph, err := store.DomainClosure(&board)
+	require.NoError(t, err)
+	assert.Equal(t, DomainDependencies, graph.Relation)
+	assert.Equal(t, []link{
+		{"Board", "RoT", 1, false},
+		{"RoT", "HSM", 2, false},
+	}, links(graph))
+
+	graph, err = store.TraverseDomains(envID(board), DomainMembers)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []link{
+		{"Board", "SoC", 1, false},
+		{"Board", "NIC", 1, false},
+		{"SoC", "CPU", 2, false},
+		{"SoC", "GPU", 2, false},
+		{"CPU", "Board", 3, true},
+	}, links(graph))
+	assert.Len(t, graph.Children(envID(board), 0), 2)
+	assert.Len(t, graph.Children(envID(soc), 1), 2)
+	assert.Empty(t, graph.Children(envID(soc), 0))
+
+	graph, err = store.DomainMembersOf(&gpu)
+	require.NoError(t, err)
+	assert.Equal(t, []link{
+		{"GPU", "SoC", 1, false},
+		{"SoC", "Board", 2, false},
+		{"Board", "CPU", 3, false},
+		{"CPU", "SoC", 4, true},
+	}, links(graph))
+
+	graph, err = store.DomainClosure(&hsm)
+	require.NoError(t, err)
+	assert.Empty(t, graph.Links)
+
+	unknown := newEnv("Umbrella")
+	_, err = store.DomainClosure(&unknown)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.TraverseDomains(-1, DomainMembers)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.TraverseDomains(envID(board), DomainRelation(42))
+	assert.ErrorContains(t, err, "unexpected domain relation")
+
+	tenant, err := store.ForTenant("baz")
+	require.NoError(t, err)
+
+	graph, err = tenant.DomainClosure(&board)
+	require.NoError(t, err)
+	assert.Empty(t, graph.Links)
+}
+
+func TestStore_DomainGraph_diamonds(t *testing.T) {
+	newEnv := func(vendor string) comid.Environment {
+		return comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor(vendor)}
+	}
+
+	// A chain of diamonds, in which each level's domain depends on two
+	// domains, which both depend on the next level's domain. There are
+	// 2^numDiamonds paths from the first domain to the last.
+	const numDiamonds = 20
+
+	triples := comid.DomainDependencyTriples{}
+	for i := range numDiamonds {
+		top := newEnv(fmt.Sprintf("top-%d", i))
+		left, right := newEnv(fmt.Sprintf("left-%d", i)), newEnv(fmt.Sprintf("right-%d", i))
+		bottom := newEnv(fmt.Sprintf("top-%d", i+1))
+
+		triples = append(triples,
+			comid.DomainDependencyTriple{DomainID: top, Trustees: []comid.Environment{left, right}},
+			comid.DomainDependencyTriple{DomainID: left, Trustees: []comid.Environment{bottom}},
+			comid.DomainDependencyTriple{DomainID: right, Trustees: []comid.Environment{bottom}},
+		)
+	}
+
+	tag := comid.NewComid().SetTagIdentity("diamonds", 0)
+	tag.Triples.DomainDependencies = &triples
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	manifest := corim.NewUnsignedCorim().SetID("diamonds").AddComid(tag)
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "", true))
+
+	root := newEnv("top-0")
+	graph, err := store.DomainClosure(&root)
+	require.NoError(t, err)
+
+	// MaxDomainDepth limits the traversal to the first 16 diamonds
+	numReached := MaxDomainDepth / 2
+	assert.Len(t, graph.Environments, 3*numReached)
+	assert.Len(t, graph.Links, 4*numReached)
+
+	// each environment is reported, and expanded, once
+	expanded := make(map[int64]int)
+	for _, link := range graph.Links {
+		assert.False(t, link.Cycle)
+		require.Contains(t, graph.Environments, link.ToID)
+		expanded[link.FromID]++
+	}
+
+	for id, count := range expanded {
+		if id == graph.Root.ID || strings.HasPrefix(*graph.Environments[id].Vendor, "top-") {
+			assert.Equal(t, 2, count)
+		} else {
+			assert.Equal(t, 1, count)
+		}
+	}
+}
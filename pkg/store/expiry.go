@@ -0,0 +1,209 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+// DefaultExpiryWarning is the default value of Config.ExpiryWarning.
+const DefaultExpiryWarning = 30 * 24 * time.Hour
+
+// ExpiryReport summarises the outcome of Store.ExpireManifests.
+type ExpiryReport struct {
+	// Expired lists the manifests past their NotAfter that had active
+	// triples, which have now been deactivated.
+	Expired []*ExpiredManifest
+	// Expiring lists the manifests that will expire within
+	// Config.ExpiryWarning.
+	Expiring []*ExpiringManifest
+	// Deleted lists the manifests that were deleted because they expired
+	// longer than Config.ExpiredRetention ago.
+	Deleted []*ExpiringManifest
+}
+
+// ExpiredManifest identifies a manifest whose triples have been deactivated
+// because it has expired.
+type ExpiredManifest struct {
+	ExpiringManifest
+	// Deactivated contains the number of triples of each type (see
+	// TripleTypes) that have been deactivated.
+	Deactivated map[string]int64
+}
+
+// ExpireManifests deactivates the triples of manifests whose NotAfter is
+// before now, so that stale values are not returned even by queries that do
+// not check validity. If Config.ExpiredRetention is set, manifests that
+// expired longer ago than that are deleted, along with their tokens. The
+// returned report also lists manifests that will expire within
+// Config.ExpiryWarning. ExpireManifests is idempotent, so may be invoked
+// periodically.
+func (o *Store) ExpireManifests(now time.Time) (*ExpiryReport, error) {
+	if err := o.checkWritable("ExpireManifests"); err != nil {
+		return nil, err
+	}
+
+	if err := o.checkUnscoped("ExpireManifests"); err != nil {
+		return nil, err
+	}
+
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := txStore.expireManifests(now)
+	if err != nil {
+		_ = txStore.Tx().Rollback()
+		return nil, err
+	}
+
+	return report, txStore.Tx().Commit()
+}
+
+func (o *Store) expireManifests(now time.Time) (*ExpiryReport, error) {
+	var report ExpiryReport
+	var err error
+
+	if o.cfg.ExpiredRetention != 0 {
+		report.Deleted, err = o.deleteExpiredManifests(now.Add(-o.cfg.ExpiredRetention))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	expired, err := o.expiringManifests(now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range expired {
+		// NotAfter is inclusive (see ValidOn)
+		if !manifest.NotAfter.Before(now) {
+			continue
+		}
+
+		deactivated, err := o.deactivateManifestTriples(manifest.dbID)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %q: %w", manifest.ManifestID, err)
+		}
+
+		if len(deactivated) != 0 {
+			report.Expired = append(report.Expired, &ExpiredManifest{
+				ExpiringManifest: *manifest,
+				Deactivated:      deactivated,
+			})
+		}
+	}
+
+	expiring, err := o.expiringManifests(now.Add(o.cfg.ExpiryWarning))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range expiring {
+		if !manifest.NotAfter.Before(now) {
+			report.Expiring = append(report.Expiring, manifest)
+		}
+	}
+
+	return &report, nil
+}
+
+func (o *Store) deactivateManifestTriples(manifestDbID int64) (map[string]int64, error) {
+	ret := map[string]int64{}
+
+	for _, source := range tripleSources {
+		query := o.DB.NewUpdate().
+			TableExpr("?", bun.Ident(source.table)).
+			Set("is_active = ?", false).
+			Where("is_active = ?", true).
+			Where("? IN (SELECT mt.id FROM module_tags AS mt WHERE mt.manifest_id = ?)",
+				bun.Ident(source.moduleColumn), manifestDbID)
+
+		if source.where != "" {
+			query.Where(source.where)
+		}
+
+		res, err := query.Exec(o.Ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s triples: %w", source.name, err)
+		}
+
+		count, err := res.RowsAffected()
+		if err != nil {
+			// coverage:ignore
+			return nil, err
+		}
+
+		if count != 0 {
+			ret[source.name] = count
+		}
+	}
+
+	return ret, nil
+}
+
+func (o *Store) deleteExpiredManifests(cutoff time.Time) ([]*ExpiringManifest, error) {
+	expired, err := o.expiringManifests(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*ExpiringManifest
+
+	for _, entry := range expired {
+		if !entry.NotAfter.Before(cutoff) {
+			continue
+		}
+
+		manifest, err := model.SelectManifest(o.Ctx, o.DB, entry.dbID)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %q: %w", entry.ManifestID, err)
+		}
+
+		if err := manifest.Delete(o.Ctx, o.DB); err != nil {
+			return nil, fmt.Errorf("manifest %q: %w", entry.ManifestID, err)
+		}
+
+		if err := o.deleteUnusedToken(entry.ManifestID); err != nil {
+			return nil, fmt.Errorf("manifest %q: token: %w", entry.ManifestID, err)
+		}
+
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// deleteUnusedToken deletes the token for the specified manifest ID, unless a
+// manifest with that ID is still stored.
+func (o *Store) deleteUnusedToken(manifestID string) error {
+	exists, err := o.DB.NewSelect().
+		Model((*model.Manifest)(nil)).
+		Where("manifest_id = ?", manifestID).
+		Exists(o.Ctx)
+	if err != nil || exists {
+		return err
+	}
+
+	var tokens []*model.Token
+	err = o.DB.NewSelect().
+		Model(&tokens).
+		Relation("Authority").
+		Where("tok.manifest_id = ?", manifestID).
+		Scan(o.Ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := token.Delete(o.Ctx, o.DB); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+func TestStore_ExpireManifests(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	_, err := store.SetKeyTriplesActive(NewKeyTripleQuery().Label("cca"), true)
+	require.NoError(t, err)
+	_, err = store.SetValueTriplesActive(NewValueTripleQuery().Label("cca"), true)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	manifests, err := store.QueryManifestModels(nil)
+	require.NoError(t, err)
+	require.Len(t, manifests, 3)
+	sortByID(manifests)
+
+	for i, notAfter := range []time.Time{
+		now.Add(-48 * time.Hour),
+		now.Add(5 * 24 * time.Hour),
+		now.Add(-100 * 24 * time.Hour),
+	} {
+		_, err = store.DB.NewUpdate().
+			Model((*model.Manifest)(nil)).
+			Set("not_after = ?", notAfter).
+			Where("id = ?", manifests[i].ID).
+			Exec(store.Ctx)
+		require.NoError(t, err)
+	}
+
+	store.cfg.ExpiryWarning = 7 * 24 * time.Hour
+
+	report, err := store.ExpireManifests(now)
+	require.NoError(t, err)
+
+	require.Len(t, report.Expired, 2)
+	assert.Equal(t, manifests[2].ManifestID, report.Expired[0].ManifestID)
+	assert.Equal(t, manifests[0].ManifestID, report.Expired[1].ManifestID)
+	assert.NotEmpty(t, report.Expired[0].Deactivated)
+
+	require.Len(t, report.Expiring, 1)
+	assert.Equal(t, manifests[1].ManifestID, report.Expiring[0].ManifestID)
+	assert.Empty(t, report.Deleted)
+
+	for _, expired := range []*model.Manifest{manifests[0], manifests[2]} {
+		selected, err := model.SelectManifest(store.Ctx, store.DB, expired.ID)
+		require.NoError(t, err)
+
+		for _, moduleTag := range selected.ModuleTags {
+			for _, triple := range moduleTag.KeyTriples {
+				assert.False(t, triple.IsActive)
+			}
+
+			for _, triple := range moduleTag.ValueTriples {
+				assert.False(t, triple.IsActive)
+			}
+		}
+	}
+
+	active, err := store.QueryValueTripleEntries(NewValueTripleQuery().IsActive(true))
+	require.NoError(t, err)
+	assert.NotEmpty(t, active)
+
+	// already deactivated triples are not reported again
+	report, err = store.ExpireManifests(now)
+	require.NoError(t, err)
+	assert.Empty(t, report.Expired)
+	assert.Len(t, report.Expiring, 1)
+
+	store.cfg.ExpiredRetention = 30 * 24 * time.Hour
+
+	report, err = store.ExpireManifests(now)
+	require.NoError(t, err)
+	require.Len(t, report.Deleted, 1)
+	assert.Equal(t, manifests[2].ManifestID, report.Deleted[0].ManifestID)
+
+	remaining, err := store.QueryManifestModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, remaining, 2)
+
+	tokens, err := store.QueryTokenModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+
+	// NotAfter was modified above, so the remaining manifests no longer
+	// match their tokens; however, nothing should have been left behind.
+	check, err := store.Verify(nil)
+	require.NoError(t, err)
+	for _, issue := range check.Issues {
+		assert.Equal(t, ManifestMismatchIssue, issue.Kind)
+	}
+
+	store.cfg.ReadOnly = true
+
+	_, err = store.ExpireManifests(now)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
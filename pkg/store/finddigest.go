@@ -0,0 +1,405 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+// DigestMatch is a digest found by Store.FindByDigest, along with the objects
+// containing it.
+type DigestMatch struct {
+	Digest *model.Digest
+
+	// Measurement containing the digest, either directly, or via one of its
+	// IntegrityRegisters. This is nil if the digest is the thumbprint of a
+	// Locator.
+	Measurement       *model.Measurement
+	IntegrityRegister *model.IntegrityRegister
+	Locator           *model.Locator
+
+	// TripleType is the type of the triple containing Measurement (see
+	// TripleTypes), and TripleDbID its ID. These are not set for locators.
+	TripleType string
+	TripleDbID int64
+	IsActive   bool
+
+	// Environment the measurement applies to. This is nil for locators.
+	Environment *model.Environment
+	// ModuleTag containing the triple. This is nil for locators.
+	ModuleTag *model.ModuleTagEntry
+	Manifest  *model.ManifestEntry
+	Label     string
+}
+
+// digestOwner identifies the triple, environment, and module tag a digest
+// belongs to.
+type digestOwner struct {
+	tripleType    string
+	tripleID      int64
+	isActive      bool
+	environmentID int64
+	moduleTagID   int64
+}
+
+// FindByDigest finds digests with the specified value in measurements,
+// integrity registers, and locators (i.e. dependent RIM thumbprints), along
+// with the triples, environments, module tags, and manifests containing them.
+// Digests are matched regardless of their algorithm. If the store is scoped to
+// a tenant, only the tenant's digests are selected. If no digests are found,
+// the returned error wraps ErrNoMatch.
+func (o *Store) FindByDigest(value []byte) ([]*DigestMatch, error) {
+	if len(value) == 0 {
+		return nil, errors.New("digest value not specified")
+	}
+
+	if err := o.checkTenant("FindByDigest"); err != nil {
+		return nil, err
+	}
+
+	var digests []*model.Digest
+	query := o.ReadDB().NewSelect().Model(&digests)
+	NewDigestQuery().Value(value).UpdateSelectQuery(query, o.ReadDB().Dialect())
+
+	if o.tenant != nil {
+		addTenantDigestClause(query, o.ReadDB().Dialect(), o.tenant.Name)
+	}
+
+	if _, err := scanQuery(o.Ctx, query, &digests); err != nil {
+		return nil, err
+	}
+
+	ret, err := o.resolveDigests(digests)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return ret, nil
+}
+
+// resolveDigests returns the matches for the digests. The objects containing
+// them are selected together for all the digests, one owner type at a time.
+// Digests that are not reachable from a manifest (see Store.Verify) are
+// omitted.
+func (o *Store) resolveDigests(digests []*model.Digest) ([]*DigestMatch, error) {
+	var measurementIDs, registerIDs, locatorIDs []int64
+
+	for _, digest := range digests {
+		switch digest.OwnerType {
+		case "measurement":
+			measurementIDs = append(measurementIDs, digest.OwnerID)
+		case "integrity_register":
+			registerIDs = append(registerIDs, digest.OwnerID)
+		case "locator":
+			locatorIDs = append(locatorIDs, digest.OwnerID)
+		}
+	}
+
+	registers, err := selectRowsByID[model.IntegrityRegister](o, "?TableAlias.id", registerIDs)
+	if err != nil {
+		return nil, fmt.Errorf("integrity registers: %w", err)
+	}
+
+	for _, reg := range registers {
+		measurementIDs = append(measurementIDs, reg.MeasurementID)
+	}
+
+	locators, err := selectRowsByID[model.Locator](o, "?TableAlias.id", locatorIDs, "Href", "Thumbprint")
+	if err != nil {
+		return nil, fmt.Errorf("locators: %w", err)
+	}
+
+	measurements, err := selectRowsByID[model.Measurement](o, "?TableAlias.id", measurementIDs,
+		"AuthorizedBy", "Digests", "Flags", "IntegrityRegisters.Digests", "ValueEntries", "Extensions")
+	if err != nil {
+		return nil, fmt.Errorf("measurements: %w", err)
+	}
+
+	owners, err := o.measurementOwners(measurements)
+	if err != nil {
+		return nil, err
+	}
+
+	var environmentIDs, moduleTagIDs, manifestIDs []int64
+
+	for _, owner := range owners {
+		if owner.environmentID != 0 {
+			environmentIDs = append(environmentIDs, owner.environmentID)
+		}
+
+		moduleTagIDs = append(moduleTagIDs, owner.moduleTagID)
+	}
+
+	environments, err := selectRowsByID[model.Environment](o, "?TableAlias.id", environmentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("environments: %w", err)
+	}
+
+	moduleTags, err := selectRowsByID[model.ModuleTagEntry](o, "module_tag_db_id", moduleTagIDs)
+	if err != nil {
+		return nil, fmt.Errorf("module tags: %w", err)
+	}
+
+	for _, moduleTag := range moduleTags {
+		manifestIDs = append(manifestIDs, moduleTag.ManifestDbID)
+	}
+
+	for _, locator := range locators {
+		manifestIDs = append(manifestIDs, locator.ManifestID)
+	}
+
+	manifests, err := selectRowsByID[model.ManifestEntry](o, "manifest_db_id", manifestIDs)
+	if err != nil {
+		return nil, fmt.Errorf("manifests: %w", err)
+	}
+
+	ret := make([]*DigestMatch, 0, len(digests))
+
+	for _, digest := range digests {
+		match := DigestMatch{Digest: digest}
+		var manifestID int64
+
+		switch digest.OwnerType {
+		case "measurement", "integrity_register":
+			measurementID := digest.OwnerID
+
+			if digest.OwnerType == "integrity_register" {
+				reg, ok := registers[digest.OwnerID]
+				if !ok {
+					continue
+				}
+
+				match.IntegrityRegister = reg
+				measurementID = reg.MeasurementID
+			}
+
+			measurement, ok := measurements[measurementID]
+			if !ok {
+				continue
+			}
+
+			owner, ok := owners[measurementID]
+			if !ok {
+				continue
+			}
+
+			match.Measurement = measurement
+			match.TripleType = owner.tripleType
+			match.TripleDbID = owner.tripleID
+			match.IsActive = owner.isActive
+
+			if owner.environmentID != 0 {
+				match.Environment, ok = environments[owner.environmentID]
+				if !ok {
+					return nil, fmt.Errorf("digest %d: environment %d: %w",
+						digest.ID, owner.environmentID, sql.ErrNoRows)
+				}
+			}
+
+			match.ModuleTag, ok = moduleTags[owner.moduleTagID]
+			if !ok {
+				continue
+			}
+
+			manifestID = match.ModuleTag.ManifestDbID
+		case "locator":
+			locator, ok := locators[digest.OwnerID]
+			if !ok {
+				continue
+			}
+
+			match.Locator = locator
+			manifestID = locator.ManifestID
+		default:
+			continue
+		}
+
+		manifest, ok := manifests[manifestID]
+		if !ok {
+			continue
+		}
+
+		match.Manifest = manifest
+		match.Label = manifest.Label
+
+		ret = append(ret, &match)
+	}
+
+	return ret, nil
+}
+
+// measurementOwners returns the triples containing the measurements, keyed by
+// measurement ID. Measurements that are not contained in a triple are
+// omitted.
+func (o *Store) measurementOwners(measurements map[int64]*model.Measurement) (map[int64]*digestOwner, error) {
+	var valueTripleIDs, statefulEnvIDs, recordIDs []int64
+
+	for _, measurement := range measurements {
+		switch measurement.OwnerType {
+		case "value_triple":
+			valueTripleIDs = append(valueTripleIDs, measurement.OwnerID)
+		case "stateful_environment":
+			statefulEnvIDs = append(statefulEnvIDs, measurement.OwnerID)
+		case "ces_record_selection", "ces_record_addition":
+			recordIDs = append(recordIDs, measurement.OwnerID)
+		}
+	}
+
+	valueTriples, err := selectRowsByID[model.ValueTriple](o, "?TableAlias.id", valueTripleIDs)
+	if err != nil {
+		return nil, fmt.Errorf("value triples: %w", err)
+	}
+
+	statefulEnvs, err := selectRowsByID[model.StatefulEnvironment](o, "?TableAlias.id", statefulEnvIDs)
+	if err != nil {
+		return nil, fmt.Errorf("stateful environments: %w", err)
+	}
+
+	records, err := selectRowsByID[model.ConditionalEndorsementSeriesRecord](o, "?TableAlias.id", recordIDs)
+	if err != nil {
+		return nil, fmt.Errorf("conditional endorsement series records: %w", err)
+	}
+
+	var cetIDs, cesIDs []int64
+
+	for _, vt := range valueTriples {
+		if vt.OwnerType == "conditional_endorsement_triple" {
+			cetIDs = append(cetIDs, vt.OwnerID)
+		}
+	}
+
+	for _, senv := range statefulEnvs {
+		cetIDs = append(cetIDs, senv.TripleID)
+	}
+
+	for _, measurement := range measurements {
+		if measurement.OwnerType == "ces_condition" {
+			cesIDs = append(cesIDs, measurement.OwnerID)
+		}
+	}
+
+	for _, record := range records {
+		cesIDs = append(cesIDs, record.TripleID)
+	}
+
+	cets, err := selectRowsByID[model.ConditionalEndorsementTriple](o, "?TableAlias.id", cetIDs)
+	if err != nil {
+		return nil, fmt.Errorf("conditional endorsement triples: %w", err)
+	}
+
+	cess, err := selectRowsByID[model.ConditionalEndorsementSeriesTriple](o, "?TableAlias.id", cesIDs)
+	if err != nil {
+		return nil, fmt.Errorf("conditional endorsement series triples: %w", err)
+	}
+
+	ret := make(map[int64]*digestOwner, len(measurements))
+
+	for id, measurement := range measurements {
+		var owner *digestOwner
+
+		switch measurement.OwnerType {
+		case "value_triple":
+			vt, ok := valueTriples[measurement.OwnerID]
+			if !ok {
+				continue
+			}
+
+			switch vt.OwnerType {
+			case "module_tag":
+				tripleType := "reference-value"
+				if vt.Type == model.EndorsedValueTriple {
+					tripleType = "endorsed-value"
+				}
+
+				owner = &digestOwner{tripleType, vt.ID, vt.IsActive, vt.EnvironmentID, vt.OwnerID}
+			case "conditional_endorsement_triple":
+				// the endorsement's environment is reported, rather
+				// than the environments of the conditions.
+				owner = conditionalEndorsementOwner(cets[vt.OwnerID], vt.EnvironmentID)
+			}
+		case "stateful_environment":
+			senv, ok := statefulEnvs[measurement.OwnerID]
+			if !ok {
+				continue
+			}
+
+			owner = conditionalEndorsementOwner(cets[senv.TripleID], senv.EnvironmentID)
+		case "ces_condition":
+			owner = conditionalEndorsementSeriesOwner(cess[measurement.OwnerID])
+		case "ces_record_selection", "ces_record_addition":
+			record, ok := records[measurement.OwnerID]
+			if !ok {
+				continue
+			}
+
+			owner = conditionalEndorsementSeriesOwner(cess[record.TripleID])
+		}
+
+		if owner != nil {
+			ret[id] = owner
+		}
+	}
+
+	return ret, nil
+}
+
+func conditionalEndorsementOwner(triple *model.ConditionalEndorsementTriple, environmentID int64) *digestOwner {
+	if triple == nil {
+		return nil
+	}
+
+	return &digestOwner{"conditional-endorsement", triple.ID, triple.IsActive, environmentID, triple.ModuleID}
+}
+
+func conditionalEndorsementSeriesOwner(triple *model.ConditionalEndorsementSeriesTriple) *digestOwner {
+	if triple == nil {
+		return nil
+	}
+
+	return &digestOwner{
+		"conditional-endorsement-series",
+		triple.ID,
+		triple.IsActive,
+		triple.EnvironmentID,
+		triple.ModuleID,
+	}
+}
+
+// selectRowsByID selects the rows whose column is one of the specified IDs,
+// along with the specified relations, keyed by their database IDs. Rows that
+// do not exist are omitted.
+func selectRowsByID[T any, PT interface {
+	*T
+	DbID() int64
+}](o *Store, column string, ids []int64, relations ...string) (map[int64]PT, error) {
+	ret := make(map[int64]PT, len(ids))
+	if len(ids) == 0 {
+		return ret, nil
+	}
+
+	var rows []PT
+	query := o.ReadDB().NewSelect().
+		Model(&rows).
+		Where(fmt.Sprintf("%s IN (?)", column), bun.In(ids))
+
+	for _, relation := range relations {
+		query.Relation(relation)
+	}
+
+	if err := query.Scan(o.Ctx); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		ret[row.DbID()] = row
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+func TestStore_FindByDigest(t *testing.T) {
+	value := comid.MustHexDecode(t, "e45b72f5c0c0b572db4d8d3ab7e97f368ff74e62347a824decb67a84e5224d75")
+	other := comid.MustHexDecode(t, "0001020304050607000102030405060700010203040506070001020304050607")
+
+	registers := comid.NewIntegrityRegisters()
+	require.NoError(t, registers.AddDigest(uint64(0), *comid.NewDigestStringAlg("sha-256", value)))
+
+	measurement := comid.MustNewUintMeasurement(uint64(1)).AddDigest(comid.Sha256, value)
+	withRegisters := comid.MustNewUintMeasurement(uint64(2)).AddDigest(comid.Sha256, other)
+	withRegisters.Val.IntegrityRegisters = registers
+
+	var measurements comid.Measurements
+	measurements.Add(measurement).Add(withRegisters)
+
+	tag := comid.NewComid().
+		SetTagIdentity("foo", 0).
+		AddReferenceValue(&comid.ValueTriple{
+			Environment:  comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor("ACME")},
+			Measurements: measurements,
+		})
+
+	manifest := corim.NewUnsignedCorim().
+		SetID("bar").
+		AddComid(tag).
+		AddDependentRim("https://example.com/rim", comid.NewDigestIntAlg(comid.Sha256, value))
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "qux", true))
+
+	matches, err := store.FindByDigest(value)
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+
+	var measurementMatch, registerMatch, locatorMatch *DigestMatch
+	for _, match := range matches {
+		assert.Equal(t, "qux", match.Label)
+		assert.Equal(t, "bar", match.Manifest.ManifestID)
+		assert.Equal(t, value, match.Digest.Value)
+
+		switch {
+		case match.Locator != nil:
+			locatorMatch = match
+		case match.IntegrityRegister != nil:
+			registerMatch = match
+		default:
+			measurementMatch = match
+		}
+	}
+
+	require.NotNil(t, measurementMatch)
+	assert.Equal(t, "reference-value", measurementMatch.TripleType)
+	assert.True(t, measurementMatch.IsActive)
+	assert.Equal(t, "foo", measurementMatch.ModuleTag.ModuleTagID)
+	assert.Equal(t, "ACME", *measurementMatch.Environment.Vendor)
+	require.Len(t, measurementMatch.Measurement.Digests, 1)
+
+	require.NotNil(t, registerMatch)
+	assert.Equal(t, measurementMatch.TripleDbID, registerMatch.TripleDbID)
+	assert.Equal(t, int64(comid.Sha256), registerMatch.Digest.CanonicalAlgID)
+	assert.Equal(t, uint64(0), *registerMatch.IntegrityRegister.IndexUint)
+	assert.Equal(t, registerMatch.IntegrityRegister.MeasurementID, registerMatch.Measurement.ID)
+	assert.NotEqual(t, measurementMatch.Measurement.ID, registerMatch.Measurement.ID)
+
+	require.NotNil(t, locatorMatch)
+	assert.Nil(t, locatorMatch.Measurement)
+	assert.Nil(t, locatorMatch.Environment)
+	assert.Nil(t, locatorMatch.ModuleTag)
+	assert.Empty(t, locatorMatch.TripleType)
+
+	matches, err = store.FindByDigest(other)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Nil(t, matches[0].IntegrityRegister)
+
+	_, err = store.FindByDigest([]byte{0xde, 0xad})
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.FindByDigest(nil)
+	assert.ErrorContains(t, err, "digest value not specified")
+
+	tenant, err := store.ForTenant("baz")
+	require.NoError(t, err)
+
+	_, err = tenant.FindByDigest(value)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	tenant, err = store.ForTenant("qux")
+	require.NoError(t, err)
+
+	matches, err = tenant.FindByDigest(value)
+	require.NoError(t, err)
+	assert.Len(t, matches, 3)
+}
+
+func TestStore_FindByDigest_conditional_endorsements(t *testing.T) {
+	value := comid.MustHexDecode(t, "e45b72f5c0c0b572db4d8d3ab7e97f368ff74e62347a824decb67a84e5224d75")
+	env := comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor("ACME")}
+
+	newMeasurements := func() comid.Measurements {
+		var ret comid.Measurements
+		ret.Add(comid.MustNewUintMeasurement(uint64(1)).AddDigest(comid.Sha256, value))
+		return ret
+	}
+
+	// The digest appears in a stateful environment condition and an
+	// endorsement of a conditional endorsement triple, and in the
+	// condition, a selection, and an addition of a conditional endorsement
+	// series triple.
+	newManifest := func(id string) *corim.UnsignedCorim {
+		condEndorse := comid.CondEndorseTriple{
+			Endorsements: *comid.NewValueTriples().Add(&comid.ValueTriple{
+				Environment:  env,
+				Measurements: newMeasurements(),
+			}),
+		}
+		condEndorse.Conditions.Add(&comid.StatefulEnvironment{Environment: env, Measurements: newMeasurements()})
+
+		series := comid.NewCondEndorseSeriesRecords()
+		series.Add(&comid.CondEndorseSeriesRecord{Selection: newMeasurements(), Addition: newMeasurements()})
+
+		tag := comid.NewComid().SetTagIdentity(id, 0)
+		tag.Triples.CondEndorsements = comid.NewCondEndorseTriples().Add(&condEndorse)
+		tag.AddCondEndorseSeries(&comid.CondEndorseSeriesTriple{
+			Condition: comid.CondEndorseSeriesCondition{Environment: env, Measurements: newMeasurements()},
+			Series:    *series,
+		})
+
+		return corim.NewUnsignedCorim().SetID(id).AddComid(tag)
+	}
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(newManifest("foo"), []byte{0x01}, "qux", true))
+	require.NoError(t, store.AddCoRIM(newManifest("bar"), []byte{0x02}, "baz", true))
+
+	matches, err := store.FindByDigest(value)
+	require.NoError(t, err)
+	assert.Len(t, matches, 10)
+
+	for _, label := range []string{"qux", "baz"} {
+		tenant, err := store.ForTenant(label)
+		require.NoError(t, err)
+
+		matches, err := tenant.FindByDigest(value)
+		require.NoError(t, err)
+
+		var tripleTypes []string
+		for _, match := range matches {
+			assert.Equal(t, label, match.Label)
+			assert.Equal(t, "ACME", *match.Environment.Vendor)
+			tripleTypes = append(tripleTypes, match.TripleType)
+		}
+
+		assert.ElementsMatch(t, []string{
+			"conditional-endorsement",
+			"conditional-endorsement",
+			"conditional-endorsement-series",
+			"conditional-endorsement-series",
+			"conditional-endorsement-series",
+		}, tripleTypes)
+	}
+
+	tenant, err := store.ForTenant("zot")
+	require.NoError(t, err)
+
+	_, err = tenant.FindByDigest(value)
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
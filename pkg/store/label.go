@@ -0,0 +1,255 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+// LabelInfo contains the counts of the contents of the store under a
+// particular label. An empty Label corresponds to manifests added without a
+// label.
+type LabelInfo struct {
+	Label           string
+	Manifests       int64
+	ModuleTags      int64
+	ActiveTriples   int64
+	InactiveTriples int64
+}
+
+// ListLabels returns the labels used inside the store, along with the counts
+// of manifests, module tags and triples under each, ordered by label.
+func (o *Store) ListLabels() ([]*LabelInfo, error) {
+	if err := o.checkUnscoped("ListLabels"); err != nil {
+		return nil, err
+	}
+
+	labels := map[string]*LabelInfo{}
+
+	getLabel := func(label string) *LabelInfo {
+		info, ok := labels[label]
+		if !ok {
+			info = &LabelInfo{Label: label}
+			labels[label] = info
+		}
+
+		return info
+	}
+
+	counts, err := o.countGroups("manifests AS man", "")
+	if err != nil {
+		return nil, fmt.Errorf("manifests: %w", err)
+	}
+
+	for _, count := range counts {
+		getLabel(count.Label).Manifests += count.Count
+	}
+
+	counts, err = o.countGroups("module_tags AS mt", "JOIN manifests AS man ON man.id = mt.manifest_id")
+	if err != nil {
+		return nil, fmt.Errorf("module tags: %w", err)
+	}
+
+	for _, count := range counts {
+		getLabel(count.Label).ModuleTags += count.Count
+	}
+
+	for _, source := range tripleSources {
+		counts, err := o.countTriples(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s triples: %w", source.name, err)
+		}
+
+		for _, count := range counts {
+			if count.IsActive {
+				getLabel(count.Label).ActiveTriples += count.Count
+			} else {
+				getLabel(count.Label).InactiveTriples += count.Count
+			}
+		}
+	}
+
+	ret := make([]*LabelInfo, 0, len(labels))
+	for _, info := range labels {
+		ret = append(ret, info)
+	}
+
+	slices.SortFunc(ret, func(a, b *LabelInfo) int {
+		return strings.Compare(a.Label, b.Label)
+	})
+
+	return ret, nil
+}
+
+// RenameLabel moves all manifests under label from to label to. If there are
+// already manifests under label to, the labels are effectively merged. The
+// number of moved manifests is returned; if there are no manifests under label
+// from, ErrNoMatch is returned.
+func (o *Store) RenameLabel(from, to string) (int64, error) {
+	if err := o.checkWritable("RenameLabel"); err != nil {
+		return 0, err
+	}
+
+	if err := o.checkUnscoped("RenameLabel"); err != nil {
+		return 0, err
+	}
+
+	if o.cfg.RequireLabel && to == "" {
+		return 0, ErrNoLabel
+	}
+
+	query := o.DB.NewUpdate().
+		Model((*model.Manifest)(nil)).
+		Set("label = ?", nullableLabel(to))
+
+	res, err := whereLabel(query, from).Exec(o.Ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		// coverage:ignore
+		return 0, err
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("label %q: %w", from, ErrNoMatch)
+	}
+
+	return count, nil
+}
+
+// MoveManifests moves the manifests with the specified manifest IDs from
+// label from to label to. This is atomic: either all of the manifests are
+// moved, or (if any of them could not be found under label from) none are.
+func (o *Store) MoveManifests(from, to string, manifestIDs ...string) (int64, error) {
+	if err := o.checkWritable("MoveManifests"); err != nil {
+		return 0, err
+	}
+
+	if err := o.checkUnscoped("MoveManifests"); err != nil {
+		return 0, err
+	}
+
+	if o.cfg.RequireLabel && to == "" {
+		return 0, ErrNoLabel
+	}
+
+	if len(manifestIDs) == 0 {
+		return 0, nil
+	}
+
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := txStore.DB.NewUpdate().
+		Model((*model.Manifest)(nil)).
+		Set("label = ?", nullableLabel(to)).
+		Where("manifest_id IN (?)", bun.In(manifestIDs))
+
+	res, err := whereLabel(query, from).Exec(o.Ctx)
+	if err != nil {
+		_ = txStore.Tx().Rollback()
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		// coverage:ignore
+		_ = txStore.Tx().Rollback()
+		return 0, err
+	}
+
+	if count != int64(len(manifestIDs)) {
+		_ = txStore.Tx().Rollback()
+		return 0, fmt.Errorf("found %d of %d manifest(s) under label %q: %w",
+			count, len(manifestIDs), from, ErrNoMatch)
+	}
+
+	return count, txStore.Tx().Commit()
+}
+
+// DeleteLabel deletes all manifests under the specified label, along with
+// their tokens, returning the number of deleted manifests. If there are no
+// manifests under the label, ErrNoMatch is returned.
+func (o *Store) DeleteLabel(label string) (int64, error) {
+	if err := o.checkWritable("DeleteLabel"); err != nil {
+		return 0, err
+	}
+
+	if err := o.checkUnscoped("DeleteLabel"); err != nil {
+		return 0, err
+	}
+
+	if o.cfg.RequireLabel && label == "" {
+		return 0, ErrNoLabel
+	}
+
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := txStore.deleteLabel(label)
+	if err != nil {
+		_ = txStore.Tx().Rollback()
+		return 0, err
+	}
+
+	return count, txStore.Tx().Commit()
+}
+
+func (o *Store) deleteLabel(label string) (int64, error) {
+	var manifests []*model.Manifest
+
+	query := o.DB.NewSelect().Model(&manifests).Order("id")
+	if err := whereLabel(query, label).Scan(o.Ctx); err != nil {
+		return 0, err
+	}
+
+	if len(manifests) == 0 {
+		return 0, fmt.Errorf("label %q: %w", label, ErrNoMatch)
+	}
+
+	for _, manifest := range manifests {
+		if err := manifest.Select(o.Ctx, o.DB); err != nil {
+			return 0, fmt.Errorf("manifest %q: %w", manifest.ManifestID, err)
+		}
+
+		if err := manifest.Delete(o.Ctx, o.DB); err != nil {
+			return 0, fmt.Errorf("manifest %q: %w", manifest.ManifestID, err)
+		}
+
+		if err := o.deleteUnusedToken(manifest.ManifestID); err != nil {
+			return 0, fmt.Errorf("manifest %q: token: %w", manifest.ManifestID, err)
+		}
+	}
+
+	return int64(len(manifests)), nil
+}
+
+// whereLabel restricts the query to manifests under the specified label (or
+// without a label, if it is empty).
+func whereLabel[Q interface {
+	Where(string, ...any) Q
+}](query Q, label string) Q {
+	if label == "" {
+		return query.Where("label IS NULL")
+	}
+
+	return query.Where("label = ?", label)
+}
+
+func nullableLabel(label string) *string {
+	if label == "" {
+		return nil
+	}
+
+	return &label
+}
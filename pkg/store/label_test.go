@@ -0,0 +1,96 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_labels(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	labels, err := store.ListLabels()
+	require.NoError(t, err)
+	require.Len(t, labels, 1)
+	assert.Equal(t, "cca", labels[0].Label)
+	assert.Equal(t, int64(3), labels[0].Manifests)
+	assert.Equal(t, int64(3), labels[0].ModuleTags)
+	assert.Zero(t, labels[0].ActiveTriples)
+	assert.NotZero(t, labels[0].InactiveTriples)
+
+	_, err = store.MoveManifests("cca", "realm", "cca-ref-realm", "no-such-manifest")
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	count, err := store.MoveManifests("cca", "realm", "cca-ref-realm")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = store.RenameLabel("cca", "platform")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = store.RenameLabel("cca", "platform")
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	labels, err = store.ListLabels()
+	require.NoError(t, err)
+	require.Len(t, labels, 2)
+	assert.Equal(t, "platform", labels[0].Label)
+	assert.Equal(t, int64(2), labels[0].Manifests)
+	assert.Equal(t, "realm", labels[1].Label)
+	assert.Equal(t, int64(1), labels[1].Manifests)
+
+	// merge
+	count, err = store.RenameLabel("realm", "platform")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = store.MoveManifests("platform", "", "cca-ref-plat")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	labels, err = store.ListLabels()
+	require.NoError(t, err)
+	require.Len(t, labels, 2)
+	assert.Equal(t, "", labels[0].Label)
+	assert.Equal(t, int64(1), labels[0].Manifests)
+	assert.Equal(t, int64(2), labels[1].Manifests)
+
+	store.cfg.RequireLabel = true
+
+	_, err = store.RenameLabel("platform", "")
+	assert.ErrorIs(t, err, ErrNoLabel)
+
+	_, err = store.MoveManifests("platform", "", "cca-ref-realm")
+	assert.ErrorIs(t, err, ErrNoLabel)
+
+	_, err = store.DeleteLabel("")
+	assert.ErrorIs(t, err, ErrNoLabel)
+
+	count, err = store.RenameLabel("", "plat")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = store.DeleteLabel("platform")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = store.DeleteLabel("platform")
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	manifests, err := store.QueryManifestModels(nil)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, "plat", manifests[0].Label)
+
+	tokens, err := store.QueryTokenModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 1)
+
+	store.cfg.ReadOnly = true
+
+	_, err = store.RenameLabel("plat", "other")
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
@@ -1,17 +1,26 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/x509"
 	"database/sql"
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
 	"slices"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/schema"
 	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
 	"github.com/veraison/eat"
 	"github.com/veraison/swid"
@@ -200,6 +209,10 @@ type EntityQuery struct {
 
 	uris  []string
 	roles []string
+
+	// tenant, if set, restricts the query to entities of manifests and
+	// module tags under the tenant's label (see Store.ForTenant).
+	tenant *string
 }
 
 func NewEntityQuery() *EntityQuery {
@@ -260,6 +273,15 @@ func (o *EntityQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.D
 	updateQueryWithEntries(o.names, query, dialect)
 
 	addOrGroupWhereClause("uri", o.uris, false, query, dialect)
+
+	if o.tenant != nil {
+		addTenantEntityClause(query, dialect, *o.tenant)
+	}
+}
+
+func (o *EntityQuery) restrictToTenant(name string) error {
+	o.tenant = &name
+	return nil
 }
 
 func (o *EntityQuery) Run(ctx context.Context, db bun.IDB) ([]*model.Entity, error) {
@@ -304,6 +326,7 @@ func (o *EntityQuery) IsEmpty() bool {
 		len(o.names) == 0 &&
 		len(o.uris) == 0 &&
 		len(o.roles) == 0 &&
+		o.tenant == nil &&
 		o.modelQuery.IsEmpty() &&
 		o.ownedQuery.IsEmpty()
 }
@@ -315,6 +338,7 @@ type ManifestQuery struct {
 
 	entityQuery        *EntityQuery
 	dependentRIMsQuery *LocatorQuery
+	extensionGroup     *ExtensionQueryGroup
 }
 
 func NewManifestQuery() *ManifestQuery {
@@ -441,6 +465,23 @@ func (o *ManifestQuery) DependentRIMs(updater func(*LocatorQuery)) *ManifestQuer
 	return o
 }
 
+func (o *ManifestQuery) ExtensionGroup() *ExtensionQueryGroup {
+	if o.extensionGroup == nil {
+		o.extensionGroup = NewExtensionQueryGroup("manifest")
+	}
+
+	return o.extensionGroup
+}
+
+// Extension adds a query for the manifest's extensions. When Extension is called
+// multiple times, each of the queries must be matched.
+func (o *ManifestQuery) Extension(updater func(*ExtensionQuery)) *ManifestQuery {
+	eq := NewExtensionQuery().OwnerType("manifest")
+	updater(eq)
+	o.ExtensionGroup().Add(eq)
+	return o
+}
+
 func (o *ManifestQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
 	o.ManifestCommonQuery.UpdateSelectQuery(query, dialect)
 	addOrGroupWhereClause("digest", o.digests, false, query, dialect)
@@ -491,6 +532,26 @@ func (o *ManifestQuery) Run(ctx context.Context, db bun.IDB) ([]*model.ManifestE
 		}
 	}
 
+	if !o.ExtensionGroup().IsEmpty() {
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.OwnerID(o.manifestDbIDs...)
+		})
+
+		ownerIDs, err := o.ExtensionGroup().RunOwnerConjunction(ctx, db)
+
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.ownerIDs = nil
+		})
+
+		if err != nil {
+			o.restoreManifestDbIDs()
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+
+		o.saveManifestDbIDs()
+		o.manifestDbIDs = ownerIDs
+	}
+
 	ret, err := runQuery(ctx, db, o)
 	o.restoreManifestDbIDs()
 	return ret, err
@@ -500,7 +561,8 @@ func (o *ManifestQuery) IsEmpty() bool {
 	return len(o.digests) == 0 &&
 		o.ManifestCommonQuery.IsEmpty() &&
 		o.EntitiesSubquery().IsEmpty() &&
-		o.DependentRIMsSubquery().IsEmpty()
+		o.DependentRIMsSubquery().IsEmpty() &&
+		o.ExtensionGroup().IsEmpty()
 }
 
 type LinkedTagQuery struct {
@@ -578,6 +640,7 @@ type ModuleTagQuery struct {
 
 	entitiesQuery   *EntityQuery
 	linkedTagsQuery *LinkedTagQuery
+	extensionGroup  *ExtensionQueryGroup
 }
 
 func NewModuleTagQuery() *ModuleTagQuery {
@@ -735,6 +798,23 @@ func (o *ModuleTagQuery) LinkedTag(updater func(*LinkedTagQuery)) *ModuleTagQuer
 	return o
 }
 
+func (o *ModuleTagQuery) ExtensionGroup() *ExtensionQueryGroup {
+	if o.extensionGroup == nil {
+		o.extensionGroup = NewExtensionQueryGroup("module_tag")
+	}
+
+	return o.extensionGroup
+}
+
+// Extension adds a query for the module tag's (top-level) extensions. When Extension is called
+// multiple times, each of the queries must be matched.
+func (o *ModuleTagQuery) Extension(updater func(*ExtensionQuery)) *ModuleTagQuery {
+	eq := NewExtensionQuery().OwnerType("module_tag")
+	updater(eq)
+	o.ExtensionGroup().Add(eq)
+	return o
+}
+
 func (o *ModuleTagQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
 	o.ManifestCommonQuery.UpdateSelectQuery(query, dialect)
 	o.ModuleTagCommonQuery.UpdateSelectQuery(query, dialect)
@@ -785,6 +865,26 @@ func (o *ModuleTagQuery) Run(ctx context.Context, db bun.IDB) ([]*model.ModuleTa
 		}
 	}
 
+	if !o.ExtensionGroup().IsEmpty() {
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.OwnerID(o.moduleTagDbIDs...)
+		})
+
+		ownerIDs, err := o.ExtensionGroup().RunOwnerConjunction(ctx, db)
+
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.ownerIDs = nil
+		})
+
+		if err != nil {
+			o.restoreModuleTagDbIDs()
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+
+		o.saveModuleTagDbIDs()
+		o.moduleTagDbIDs = ownerIDs
+	}
+
 	ret, err := runQuery(ctx, db, o)
 	o.restoreModuleTagDbIDs()
 	return ret, err
@@ -795,7 +895,8 @@ func (o *ModuleTagQuery) IsEmpty() bool {
 		o.ManifestCommonQuery.IsEmpty() &&
 		o.ModuleTagCommonQuery.IsEmpty() &&
 		o.LinkedTagsSubquery().IsEmpty() &&
-		o.EntitiesSubquery().IsEmpty()
+		o.EntitiesSubquery().IsEmpty() &&
+		o.ExtensionGroup().IsEmpty()
 }
 
 type EnvironmentQuery struct {
@@ -821,6 +922,10 @@ type EnvironmentQuery struct {
 	groupTypes []string
 	groupBytes [][]byte
 	groups     []*groupQueryEntry
+
+	// tenant, if set, restricts the query to environments referenced by
+	// the contents under the tenant's label (see Store.ForTenant).
+	tenant *string
 }
 
 func NewEnvironmentQuery(exact bool) *EnvironmentQuery {
@@ -904,6 +1009,138 @@ func (o *EnvironmentQuery) Group(typ string, value []byte) *EnvironmentQuery {
 	return o
 }
 
+// addClassIDValue matches the specified class IDs, encoded in the same way as
+// when they are stored (see model.ClassIDToModel). Encoding only fails for
+// profile-defined types, which are never added by the typed builders below.
+func (o *EnvironmentQuery) addClassIDValue(value ...*comid.ClassID) *EnvironmentQuery {
+	for _, classID := range value {
+		typ, bytes, err := model.ClassIDToModel(classID)
+		if err != nil {
+			// coverage:ignore
+			panic(err)
+		}
+
+		o.ClassID(typ, bytes)
+	}
+	return o
+}
+
+// ClassOID matches class IDs stored with type "oid" (i.e. as the DER encoding
+// of the OID).
+func (o *EnvironmentQuery) ClassOID(value ...comid.OID) *EnvironmentQuery {
+	for _, oid := range value {
+		tagged := comid.TaggedOID(oid)
+		o.addClassIDValue(&comid.ClassID{Value: &tagged})
+	}
+	return o
+}
+
+// ClassUUID matches class IDs stored with type "uuid".
+func (o *EnvironmentQuery) ClassUUID(value ...uuid.UUID) *EnvironmentQuery {
+	for _, u := range value {
+		tagged := comid.TaggedUUID(u)
+		o.addClassIDValue(&comid.ClassID{Value: &tagged})
+	}
+	return o
+}
+
+// ClassTaggedBytes matches class IDs stored with type "bytes" (i.e. tagged
+// bytes). Unlike ClassIDBytes, class IDs of other types with the same bytes do
+// not match.
+func (o *EnvironmentQuery) ClassTaggedBytes(value ...[]byte) *EnvironmentQuery {
+	for _, b := range value {
+		tagged := comid.TaggedBytes(b)
+		o.addClassIDValue(&comid.ClassID{Value: &tagged})
+	}
+	return o
+}
+
+// addInstanceValue is like addClassIDValue, but for instance IDs.
+func (o *EnvironmentQuery) addInstanceValue(value ...*comid.Instance) *EnvironmentQuery {
+	for _, instance := range value {
+		typ, bytes, err := model.InstanceToModel(instance)
+		if err != nil {
+			// coverage:ignore
+			panic(err)
+		}
+
+		o.Instance(typ, bytes)
+	}
+	return o
+}
+
+// InstanceUEID matches instance IDs stored with type "ueid".
+func (o *EnvironmentQuery) InstanceUEID(value ...eat.UEID) *EnvironmentQuery {
+	for _, ueid := range value {
+		tagged := comid.TaggedUEID(ueid)
+		o.addInstanceValue(&comid.Instance{Value: &tagged})
+	}
+	return o
+}
+
+// InstanceUUID matches instance IDs stored with type "uuid".
+func (o *EnvironmentQuery) InstanceUUID(value ...uuid.UUID) *EnvironmentQuery {
+	for _, u := range value {
+		tagged := comid.TaggedUUID(u)
+		o.addInstanceValue(&comid.Instance{Value: &tagged})
+	}
+	return o
+}
+
+// InstanceTaggedBytes matches instance IDs stored with type "bytes" (i.e.
+// tagged bytes). Unlike InstanceBytes, instance IDs of other types with the
+// same bytes do not match.
+func (o *EnvironmentQuery) InstanceTaggedBytes(value ...[]byte) *EnvironmentQuery {
+	for _, b := range value {
+		tagged := comid.TaggedBytes(b)
+		o.addInstanceValue(&comid.Instance{Value: &tagged})
+	}
+	return o
+}
+
+// InstanceCryptoKey matches instance IDs that are the specified keys (or
+// certificates, or thumbprints), of the same type.
+func (o *EnvironmentQuery) InstanceCryptoKey(value ...*comid.CryptoKey) *EnvironmentQuery {
+	for _, key := range value {
+		o.addInstanceValue(&comid.Instance{Value: key.Value})
+	}
+	return o
+}
+
+// addGroupValue is like addClassIDValue, but for group IDs.
+func (o *EnvironmentQuery) addGroupValue(value ...*comid.Group) *EnvironmentQuery {
+	for _, group := range value {
+		typ, bytes, err := model.GroupToModel(group)
+		if err != nil {
+			// coverage:ignore
+			panic(err)
+		}
+
+		o.Group(typ, bytes)
+	}
+	return o
+}
+
+// GroupUUID matches group IDs stored with type "uuid".
+func (o *EnvironmentQuery) GroupUUID(value ...uuid.UUID) *EnvironmentQuery {
+	for _, u := range value {
+		tagged := comid.TaggedUUID(u)
+		o.addGroupValue(&comid.Group{Value: &tagged})
+	}
+	return o
+}
+
+// GroupTaggedBytes matches group IDs stored with type "bytes" (i.e. tagged
+// bytes). Unlike GroupBytes, group IDs of other types with the same bytes do
+// not match.
+func (o *EnvironmentQuery) GroupTaggedBytes(value ...[]byte) *EnvironmentQuery {
+	for _, b := range value {
+		tagged := comid.TaggedBytes(b)
+		o.addGroupValue(&comid.Group{Value: &tagged})
+	}
+	return o
+}
+
 func (o *EnvironmentQuery) UpdateFromModel(env *model.Environment) *EnvironmentQuery {
 	if env.ClassType != nil {
 		if env.ClassBytes != nil {
@@ -1003,6 +1240,15 @@ func (o *EnvironmentQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect sch
 	addOrGroupWhereClause("instance_type", o.instanceTypes, false, query, dialect)
 	addOrGroupWhereClause("instance_bytes", o.instanceBytes, exactInstance, query, dialect)
 	updateQueryWithEntries(o.instances, query, dialect)
+
+	if o.tenant != nil {
+		addTenantEnvironmentClause(query, dialect, *o.tenant)
+	}
+}
+
+func (o *EnvironmentQuery) restrictToTenant(name string) error {
+	o.tenant = &name
+	return nil
 }
 
 func (o *EnvironmentQuery) Run(ctx context.Context, db bun.IDB) ([]*model.Environment, error) {
@@ -1024,6 +1270,7 @@ func (o *EnvironmentQuery) IsEmpty() bool {
 		len(o.groupTypes) == 0 &&
 		len(o.groupBytes) == 0 &&
 		len(o.groups) == 0 &&
+		o.tenant == nil &&
 		o.modelQuery.IsEmpty()
 }
 
@@ -1146,7 +1393,11 @@ type TripleQuery[T model.Model, TT any] struct {
 	cryptoKeyQuery   *CryptoKeyQuery
 	authByQuery      *CryptoKeyQuery
 
+	manifestEntityQuery  *EntityQuery
+	moduleTagEntityQuery *EntityQuery
+
 	measurementGroup *MeasurementQueryGroup
+	extensionGroup   *ExtensionQueryGroup
 
 	tripleDbIDs    []int64
 	environmentIDs []int64
@@ -1331,6 +1582,25 @@ func (o *TripleQuery[T, TT]) Measurement(updater func(e *MeasurementQuery)) *Tri
 	return o
 }
 
+func (o *TripleQuery[T, TT]) ExtensionGroup() *ExtensionQueryGroup {
+	if o.extensionGroup == nil {
+		o.extensionGroup = NewExtensionQueryGroup("triples")
+	}
+
+	return o.extensionGroup
+}
+
+// Extension adds a query for extensions of the triples of the module
+// tag containing the triple (extensions of individual measurements may be
+// queried via Measurement). When Extension is called
+// multiple times, each of the queries must be matched.
+func (o *TripleQuery[T, TT]) Extension(updater func(*ExtensionQuery)) *TripleQuery[T, TT] {
+	eq := NewExtensionQuery().OwnerType("triples")
+	updater(eq)
+	o.ExtensionGroup().Add(eq)
+	return o
+}
+
 func (o *TripleQuery[T, TT]) CryptoKeysSubquery() *CryptoKeyQuery {
 	if o.cryptoKeyQuery == nil {
 		o.cryptoKeyQuery = NewCryptoKeyQuery()
@@ -1357,6 +1627,38 @@ func (o *TripleQuery[T, TT]) AuthorizedBy(updater func(e *CryptoKeyQuery)) *Trip
 	return o
 }
 
+func (o *TripleQuery[T, TT]) ManifestEntitiesSubquery() *EntityQuery {
+	if o.manifestEntityQuery == nil {
+		o.manifestEntityQuery = NewEntityQuery()
+	}
+
+	return o.manifestEntityQuery
+}
+
+// ManifestEntity restricts the triples to those from manifests (CoRIMs) with
+// an entity matching the query. Name and role queries apply to the same
+// entity, so e.g. NameValue("ACME").Role("manifestSigner") matches manifests
+// signed by ACME.
+func (o *TripleQuery[T, TT]) ManifestEntity(updater func(*EntityQuery)) *TripleQuery[T, TT] {
+	updater(o.ManifestEntitiesSubquery())
+	return o
+}
+
+func (o *TripleQuery[T, TT]) ModuleTagEntitiesSubquery() *EntityQuery {
+	if o.moduleTagEntityQuery == nil {
+		o.moduleTagEntityQuery = NewEntityQuery()
+	}
+
+	return o.moduleTagEntityQuery
+}
+
+// ModuleTagEntity is like ManifestEntity, but for the entities of the module
+// tag (CoMID) containing the triple, e.g. its "tagCreator".
+func (o *TripleQuery[T, TT]) ModuleTagEntity(updater func(*EntityQuery)) *TripleQuery[T, TT] {
+	updater(o.ModuleTagEntitiesSubquery())
+	return o
+}
+
 func (o *TripleQuery[T, TT]) ClassType(value string) *TripleQuery[T, TT] {
 	o.EnvironmentSubquery().ClassIDType(value)
 	return o
@@ -1506,7 +1808,85 @@ func (o *TripleQuery[T, TT]) Run(ctx context.Context, db bun.IDB) ([]T, error) {
 		o.tripleDbIDs = tripleIDs
 	}
 
+	if !o.ManifestEntitiesSubquery().IsEmpty() {
+		o.saveManifestDbIDs()
+
+		entities, err := o.ManifestEntitiesSubquery().
+			OwnerType("manifest").
+			OwnerID(o.manifestDbIDs...).
+			Run(ctx, db)
+
+		// reset so that it doesn't affect the IsEmpty() test if
+		// the query is repeated.
+		o.ManifestEntitiesSubquery().ownerTypes = nil
+		o.ManifestEntitiesSubquery().ownerIDs = nil
+
+		if err != nil {
+			o.restoreManifestDbIDs()
+			o.restoreTripleIDs()
+			o.restoreEnvIDs()
+			return nil, fmt.Errorf("manifest entities: %w", err)
+		}
+
+		o.manifestDbIDs = make([]int64, len(entities))
+		for i, entity := range entities {
+			o.manifestDbIDs[i] = entity.OwnerID
+		}
+	}
+
+	if !o.ModuleTagEntitiesSubquery().IsEmpty() {
+		o.saveModuleTagDbIDs()
+
+		entities, err := o.ModuleTagEntitiesSubquery().
+			OwnerType("module_tag").
+			OwnerID(o.moduleTagDbIDs...).
+			Run(ctx, db)
+
+		// reset so that it doesn't affect the IsEmpty() test if
+		// the query is repeated.
+		o.ModuleTagEntitiesSubquery().ownerTypes = nil
+		o.ModuleTagEntitiesSubquery().ownerIDs = nil
+
+		if err != nil {
+			o.restoreModuleTagDbIDs()
+			o.restoreManifestDbIDs()
+			o.restoreTripleIDs()
+			o.restoreEnvIDs()
+			return nil, fmt.Errorf("module tag entities: %w", err)
+		}
+
+		o.moduleTagDbIDs = make([]int64, len(entities))
+		for i, entity := range entities {
+			o.moduleTagDbIDs[i] = entity.OwnerID
+		}
+	}
+
+	if !o.ExtensionGroup().IsEmpty() {
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.OwnerID(o.moduleTagDbIDs...)
+		})
+
+		ownerIDs, err := o.ExtensionGroup().RunOwnerConjunction(ctx, db)
+
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.ownerIDs = nil
+		})
+
+		if err != nil {
+			o.restoreModuleTagDbIDs()
+			o.restoreManifestDbIDs()
+			o.restoreTripleIDs()
+			o.restoreEnvIDs()
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+
+		o.saveModuleTagDbIDs()
+		o.moduleTagDbIDs = ownerIDs
+	}
+
 	ret, err := runQuery(ctx, db, o)
+	o.restoreModuleTagDbIDs()
+	o.restoreManifestDbIDs()
 	o.restoreTripleIDs()
 	o.restoreEnvIDs()
 	return ret, err
@@ -1526,7 +1906,10 @@ func (o *TripleQuery[T, TT]) IsEmpty() bool {
 		o.ModuleTagCommonQuery.IsEmpty() &&
 		o.CryptoKeysSubquery().IsEmpty() &&
 		o.AuthorizedBySubquery().IsEmpty() &&
+		o.ManifestEntitiesSubquery().IsEmpty() &&
+		o.ModuleTagEntitiesSubquery().IsEmpty() &&
 		o.MeasurementGroup().IsEmpty() &&
+		o.ExtensionGroup().IsEmpty() &&
 		o.EnvironmentSubquery().IsEmpty()
 }
 
@@ -3223,6 +3606,14 @@ type CryptoKeyQuery struct {
 	keyTypes []string
 	keyBytes [][]byte
 	keys     []*keyQueryEntry
+
+	spkiThumbprints [][]byte
+	certThumbprints [][]byte
+	matches         []*keyMatchQueryEntry
+
+	// err records an invalid value provided to a chaining method, and is
+	// returned when the query is run.
+	err error
 }
 
 func NewCryptoKeyQuery() *CryptoKeyQuery {
@@ -3278,6 +3669,52 @@ func (o *CryptoKeyQuery) KeyFromModel(value ...*model.CryptoKey) *CryptoKeyQuery
 	return o
 }
 
+// SPKIThumbprint matches keys whose SubjectPublicKeyInfo has the specified
+// SHA-256 digest (see util.KeyForms).
+func (o *CryptoKeyQuery) SPKIThumbprint(value ...[]byte) *CryptoKeyQuery {
+	o.spkiThumbprints = append(o.spkiThumbprints, value...)
+	return o
+}
+
+// CertThumbprint matches keys from certificates with the specified SHA-256
+// digest (see util.KeyForms).
+func (o *CryptoKeyQuery) CertThumbprint(value ...[]byte) *CryptoKeyQuery {
+	o.certThumbprints = append(o.certThumbprints, value...)
+	return o
+}
+
+// MatchingPublicKey matches keys equivalent to the provided public key,
+// regardless of how they are encoded. This includes PKIX keys, COSE keys,
+// certificates containing the key, and SHA-256 thumbprints of the key. If the
+// key cannot be encoded as an X.509 SubjectPublicKeyInfo (i.e. if it is nil or
+// of a type not supported by x509.MarshalPKIXPublicKey), running the query
+// returns an error.
+func (o *CryptoKeyQuery) MatchingPublicKey(pub crypto.PublicKey) *CryptoKeyQuery {
+	thumbprint, err := util.PublicKeyThumbprint(pub)
+	if err != nil {
+		if o.err == nil {
+			o.err = fmt.Errorf("public key: %w", err)
+		}
+
+		return o
+	}
+
+	o.matches = append(o.matches, &keyMatchQueryEntry{spkiThumbprint: thumbprint})
+	return o
+}
+
+// MatchingCertificate matches keys equivalent to the provided certificate:
+// the certificate itself (in any encoding), a SHA-256 thumbprint of the
+// certificate, or the public key it contains (see MatchingPublicKey).
+func (o *CryptoKeyQuery) MatchingCertificate(cert *x509.Certificate) *CryptoKeyQuery {
+	forms := util.CertificateKeyForms(cert)
+	o.matches = append(o.matches, &keyMatchQueryEntry{
+		spkiThumbprint: forms.SPKIThumbprint,
+		certThumbprint: forms.CertThumbprint,
+	})
+	return o
+}
+
 func (o *CryptoKeyQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
 	o.modelQuery.UpdateSelectQuery(query, dialect)
 	o.ownedQuery.UpdateSelectQuery(query, dialect)
@@ -3285,16 +3722,28 @@ func (o *CryptoKeyQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schem
 	addOrGroupWhereClause("key_type", o.keyTypes, false, query, dialect)
 	addOrGroupWhereClause("key_bytes", o.keyBytes, false, query, dialect)
 	updateQueryWithEntries(o.keys, query, dialect)
+
+	addOrGroupWhereClause("spki_thumbprint", o.spkiThumbprints, false, query, dialect)
+	addOrGroupWhereClause("cert_thumbprint", o.certThumbprints, false, query, dialect)
+	updateQueryWithEntries(o.matches, query, dialect)
 }
 
 func (o *CryptoKeyQuery) Run(ctx context.Context, db bun.IDB) ([]*model.CryptoKey, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
 	return runQuery(ctx, db, o)
 }
 
 func (o *CryptoKeyQuery) IsEmpty() bool {
-	return len(o.keyTypes) == 0 &&
+	return o.err == nil &&
+		len(o.keyTypes) == 0 &&
 		len(o.keyBytes) == 0 &&
 		len(o.keys) == 0 &&
+		len(o.spkiThumbprints) == 0 &&
+		len(o.certThumbprints) == 0 &&
+		len(o.matches) == 0 &&
 		o.modelQuery.IsEmpty() &&
 		o.ownedQuery.IsEmpty()
 }
@@ -3401,14 +3850,26 @@ func (o *DigestQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.D
 
 	if len(o.IntAlgIDs) != 0 || len(o.TextAlgIDs) != 0 {
 		query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			// algorithms are matched regardless of how they were
+			// specified when the digests were added.
+			canonicalText := fmt.Sprintf(`%s = ?`, identQuote("canonical_alg_id", dialect))
+
 			whereText := fmt.Sprintf(`%s = ?`, identQuote("alg_id_int", dialect))
 			for _, value := range o.IntAlgIDs {
 				q.WhereOr(whereText, value)
+
+				if algID := util.CanonicalDigestAlgID(value); algID != 0 {
+					q.WhereOr(canonicalText, algID)
+				}
 			}
 
 			whereText = fmt.Sprintf(`%s = ?`, identQuote("alg_id_text", dialect))
 			for _, value := range o.TextAlgIDs {
 				q.WhereOr(whereText, value)
+
+				if algID := util.CanonicalDigestAlgID(value); algID != 0 {
+					q.WhereOr(canonicalText, algID)
+				}
 			}
 
 			return q
@@ -3622,6 +4083,183 @@ func (o *FlagQuery) IsEmpty() bool {
 		o.modelQuery.IsEmpty()
 }
 
+// ExtensionQuery matches ExtensionValue's (the values of fields of
+// extensions registered with CoRIM structures). Name matches either the Go
+// field name or the name in the JSON tag of the extension field. Values of
+// different types are matched disjunctively, i.e. an extension value matches
+// if it matches any of the specified values of the corresponding type.
+type ExtensionQuery struct {
+	modelQuery
+	ownedQuery
+
+	names  []string
+	texts  []string
+	ints   []int64
+	floats []float64
+	bytes  [][]byte
+}
+
+func NewExtensionQuery() *ExtensionQuery {
+	return &ExtensionQuery{}
+}
+
+func (o *ExtensionQuery) ID(value ...int64) *ExtensionQuery {
+	o.modelQuery.ID(value...)
+	return o
+}
+
+func (o *ExtensionQuery) OwnerType(value ...string) *ExtensionQuery {
+	o.ownedQuery.OwnerType(value...)
+	return o
+}
+
+func (o *ExtensionQuery) OwnerID(value ...int64) *ExtensionQuery {
+	o.ownedQuery.OwnerID(value...)
+	return o
+}
+
+func (o *ExtensionQuery) Owner(typ string, id int64) *ExtensionQuery {
+	o.ownedQuery.Owner(typ, id)
+	return o
+}
+
+func (o *ExtensionQuery) Name(value ...string) *ExtensionQuery {
+	o.names = append(o.names, value...)
+	return o
+}
+
+func (o *ExtensionQuery) Text(value ...string) *ExtensionQuery {
+	o.texts = append(o.texts, value...)
+	return o
+}
+
+func (o *ExtensionQuery) Int(value ...int64) *ExtensionQuery {
+	o.ints = append(o.ints, value...)
+	return o
+}
+
+func (o *ExtensionQuery) Float(value ...float64) *ExtensionQuery {
+	o.floats = append(o.floats, value...)
+	return o
+}
+
+func (o *ExtensionQuery) Bytes(value ...[]byte) *ExtensionQuery {
+	o.bytes = append(o.bytes, value...)
+	return o
+}
+
+// Value matches the provided text against the extension value. In addition
+// to text values, the text is matched against integer (and boolean) and
+// float values if it can be parsed as such. Cached extension values (those
+// for which no type was registered when the CoRIM was decoded) are stored
+// CBOR-encoded, and so are matched against the CBOR encoding of the value.
+func (o *ExtensionQuery) Value(value ...string) *ExtensionQuery {
+	for _, text := range value {
+		o.addCachedValue(text)
+		o.texts = append(o.texts, text)
+
+		if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+			o.addCachedValue(i)
+			o.ints = append(o.ints, i)
+		} else if b, err := strconv.ParseBool(text); err == nil {
+			o.addCachedValue(b)
+			if b {
+				o.ints = append(o.ints, 1)
+			} else {
+				o.ints = append(o.ints, 0)
+			}
+		}
+
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			o.floats = append(o.floats, f)
+		}
+	}
+
+	return o
+}
+
+func (o *ExtensionQuery) addCachedValue(value any) {
+	encoded, err := cbor.Marshal(value)
+	if err != nil {
+		// values passed here are always CBOR-encodable
+		panic(err)
+	}
+
+	o.bytes = append(o.bytes, encoded)
+}
+
+func (o *ExtensionQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
+	o.modelQuery.UpdateSelectQuery(query, dialect)
+	o.ownedQuery.UpdateSelectQuery(query, dialect)
+
+	if len(o.names) != 0 {
+		query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, name := range o.names {
+				q.WhereOr("? = ?", bun.Ident("field_name"), name)
+				q.WhereOr("? = ?", bun.Ident("json_tag"), name)
+				// json_tag may have options following the name (e.g.
+				// "name,omitempty"); SUBSTR is used rather than LIKE so
+				// that the name does not need escaping.
+				q.WhereOr("SUBSTR(?, 1, ?) = ?", bun.Ident("json_tag"),
+					utf8.RuneCountInString(name)+1, name+",")
+			}
+
+			return q
+		})
+	}
+
+	if len(o.texts) == 0 && len(o.ints) == 0 && len(o.floats) == 0 && len(o.bytes) == 0 {
+		return
+	}
+
+	// value columns that do not correspond to the field kind are set to
+	// their zero values, so the kind must be matched alongside the value.
+	query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, text := range o.texts {
+			q.WhereOr("? = ? AND ? = ?",
+				bun.Ident("value_text"), text, bun.Ident("field_kind"), reflect.String)
+		}
+
+		for _, i := range o.ints {
+			q.WhereOr("? = ? AND ? IN (?)",
+				bun.Ident("value_int"), i, bun.Ident("field_kind"), bun.In(extensionIntKinds))
+		}
+
+		for _, f := range o.floats {
+			q.WhereOr("? = ? AND ? IN (?)",
+				bun.Ident("value_float"), f, bun.Ident("field_kind"), bun.In(extensionFloatKinds))
+		}
+
+		for _, b := range o.bytes {
+			q.WhereOr("? = ?", bun.Ident("value_bytes"), b)
+		}
+
+		return q
+	})
+}
+
+func (o *ExtensionQuery) Run(ctx context.Context, db bun.IDB) ([]*model.ExtensionValue, error) {
+	return runQuery(ctx, db, o)
+}
+
+func (o *ExtensionQuery) IsEmpty() bool {
+	return len(o.names) == 0 &&
+		len(o.texts) == 0 &&
+		len(o.ints) == 0 &&
+		len(o.floats) == 0 &&
+		len(o.bytes) == 0 &&
+		o.modelQuery.IsEmpty() &&
+		o.ownedQuery.IsEmpty()
+}
+
+var extensionIntKinds = []reflect.Kind{
+	reflect.Bool,
+	reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+}
+
+var extensionFloatKinds = []reflect.Kind{reflect.Float32, reflect.Float64}
+
 type MeasurementValueQuery struct {
 	modelQuery
 
@@ -3630,6 +4268,8 @@ type MeasurementValueQuery struct {
 	valueBytes     [][]byte
 	valueTexts     []string
 	valueInts      []int64
+	minValueInts   []int64
+	maxValueInts   []int64
 	values         []*valueQueryEntry
 	measurementIDs []int64
 }
@@ -3668,6 +4308,21 @@ func (o *MeasurementValueQuery) ValueInt(value ...int64) *MeasurementValueQuery
 	return o
 }
 
+// MinValueInt matches integer values that are greater than or equal to the
+// provided value. Unlike ValueInt, multiple calls are combined conjunctively
+// (so that, together with MaxValueInt, it may be used to specify a range).
+func (o *MeasurementValueQuery) MinValueInt(value int64) *MeasurementValueQuery {
+	o.minValueInts = append(o.minValueInts, value)
+	return o
+}
+
+// MaxValueInt matches integer values that are less than or equal to the
+// provided value. Multiple calls are combined conjunctively.
+func (o *MeasurementValueQuery) MaxValueInt(value int64) *MeasurementValueQuery {
+	o.maxValueInts = append(o.maxValueInts, value)
+	return o
+}
+
 func (o *MeasurementValueQuery) Value(typ string, value any) *MeasurementValueQuery {
 	qv, err := newValueQueryEntry(typ, value)
 	if err != nil {
@@ -3730,6 +4385,14 @@ func (o *MeasurementValueQuery) UpdateSelectQuery(query *bun.SelectQuery, dialec
 	addOrGroupWhereClause("value_int", o.valueInts, false, query, dialect)
 	updateQueryWithEntries(o.values, query, dialect)
 
+	for _, value := range o.minValueInts {
+		query.Where(fmt.Sprintf("%s >= ?", identQuote("value_int", dialect)), value)
+	}
+
+	for _, value := range o.maxValueInts {
+		query.Where(fmt.Sprintf("%s <= ?", identQuote("value_int", dialect)), value)
+	}
+
 	addOrGroupWhereClause("measurement_id", o.measurementIDs, false, query, dialect)
 }
 
@@ -3743,6 +4406,8 @@ func (o *MeasurementValueQuery) IsEmpty() bool {
 		len(o.valueBytes) == 0 &&
 		len(o.valueTexts) == 0 &&
 		len(o.valueInts) == 0 &&
+		len(o.minValueInts) == 0 &&
+		len(o.maxValueInts) == 0 &&
 		len(o.values) == 0 &&
 		len(o.measurementIDs) == 0 &&
 		o.modelQuery.IsEmpty()
@@ -3755,12 +4420,14 @@ type MeasurementQuery struct {
 	mkeyTypes []string
 	mkeyBytes [][]byte
 	mkeys     []*keyQueryEntry
+	rawValues [][]byte
 
 	authByQuery            *CryptoKeyQuery
 	mvalQuery              *MeasurementValueQuery
 	digestQuery            *DigestQuery
 	integrityRegisterQuery *IntegrityRegisterQuery
 	flagQuery              *FlagQuery
+	extensionGroup         *ExtensionQueryGroup
 }
 
 func NewMeasurementQuery() *MeasurementQuery {
@@ -3802,6 +4469,20 @@ func (o *MeasurementQuery) Mkey(typ string, value []byte) *MeasurementQuery {
 	return o
 }
 
+// RawValueMatches matches measurements with a raw value that the provided
+// evidence value satisfies. Where the stored raw value has a mask (either as
+// a masked raw value, or as a bytes raw value with a raw-value-mask), only the
+// bits set in the mask are compared; otherwise, the values must be equal.
+// Multiple values are combined disjunctively.
+//
+// Masks that select some, but not all, of the bits of a value are applied
+// after the candidate measurements have been selected, so raw value matches
+// cannot be used inside QueryTree operands.
+func (o *MeasurementQuery) RawValueMatches(evidence ...[]byte) *MeasurementQuery {
+	o.rawValues = append(o.rawValues, evidence...)
+	return o
+}
+
 func (o *MeasurementQuery) AuthorizedBySubquery() *CryptoKeyQuery {
 	if o.authByQuery == nil {
 		o.authByQuery = NewCryptoKeyQuery()
@@ -3902,6 +4583,23 @@ func (o *MeasurementQuery) Flag(updater func(*FlagQuery)) *MeasurementQuery {
 	return o
 }
 
+func (o *MeasurementQuery) ExtensionGroup() *ExtensionQueryGroup {
+	if o.extensionGroup == nil {
+		o.extensionGroup = NewExtensionQueryGroup("measurement")
+	}
+
+	return o.extensionGroup
+}
+
+// Extension adds a query for the extensions of the measurement value. When
+// Extension is called multiple times, each of the queries must be matched.
+func (o *MeasurementQuery) Extension(updater func(*ExtensionQuery)) *MeasurementQuery {
+	eq := NewExtensionQuery().OwnerType("measurement")
+	updater(eq)
+	o.ExtensionGroup().Add(eq)
+	return o
+}
+
 func (o *MeasurementQuery) UpdateFromModel(value *model.Measurement) *MeasurementQuery {
 	if value.KeyType != nil && value.KeyBytes != nil {
 		o.Mkey(*value.KeyType, *value.KeyBytes)
@@ -4010,6 +4708,18 @@ func (o *MeasurementQuery) Run(ctx context.Context, db bun.IDB) ([]*model.Measur
 		}
 	}
 
+	if len(o.rawValues) != 0 {
+		o.saveIDs()
+
+		ids, err := o.runRawValueMatches(ctx, db)
+		if err != nil {
+			o.restoreIDs()
+			return nil, fmt.Errorf("raw value: %w", err)
+		}
+
+		o.ids = ids
+	}
+
 	if !o.IntegrityRegistersSubquery().IsEmpty() {
 		o.saveIDs()
 
@@ -4050,22 +4760,109 @@ func (o *MeasurementQuery) Run(ctx context.Context, db bun.IDB) ([]*model.Measur
 		}
 	}
 
+	if !o.ExtensionGroup().IsEmpty() {
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.OwnerID(o.ids...)
+		})
+
+		ownerIDs, err := o.ExtensionGroup().RunOwnerConjunction(ctx, db)
+
+		o.ExtensionGroup().ForEach(func(q *ExtensionQuery) {
+			q.ownerIDs = nil
+		})
+
+		if err != nil {
+			o.restoreIDs()
+			return nil, fmt.Errorf("extensions: %w", err)
+		}
+
+		o.saveIDs()
+		o.ids = ownerIDs
+	}
+
 	ret, err := runQuery(ctx, db, o)
 	o.restoreIDs()
 	return ret, err
 }
 
+// runRawValueMatches returns the IDs of the measurements (out of the ones
+// already selected, if any) with raw values matching o.rawValues. Unmasked
+// values, and values whose mask selects either all or none of the bits, are
+// matched exactly in SQL. Other masked values are only filtered by length in
+// SQL, as bitwise operations on binary strings are not supported by all
+// dialects, so masks are (re-)applied to the selected values afterwards.
+func (o *MeasurementQuery) runRawValueMatches(ctx context.Context, db bun.IDB) ([]int64, error) {
+	dialect := db.Dialect()
+	maskColumn := identQuote("value_mask", dialect)
+	bytesColumn := identQuote("value_bytes", dialect)
+
+	var entries []*model.MeasurementValueEntry
+	query := db.NewSelect().
+		Model(&entries).
+		Where(fmt.Sprintf("%s = ?", identQuote("code_point", dialect)), model.MvalRawValue).
+		Where(fmt.Sprintf("%s IN (?)", identQuote("value_type", dialect)),
+			bun.In([]string{comid.BytesType, comid.MaskedType})).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, value := range o.rawValues {
+				all := bytes.Repeat([]byte{0xff}, len(value))
+				none := make([]byte, len(value))
+
+				q.WhereOr(fmt.Sprintf("(%s IS NULL AND %s = ?)", maskColumn, bytesColumn), value)
+				q.WhereOr(fmt.Sprintf("(%s = ? AND %s = ?)", maskColumn, bytesColumn), all, value)
+				q.WhereOr(fmt.Sprintf("(%s = ? AND LENGTH(%s) = ?)", maskColumn, bytesColumn),
+					none, len(value))
+				q.WhereOr(fmt.Sprintf("(%s NOT IN (?, ?) AND LENGTH(%s) = ?)", maskColumn, bytesColumn),
+					all, none, len(value))
+			}
+
+			return q
+		})
+	addOrGroupWhereClause("measurement_id", o.ids, false, query, dialect)
+
+	if _, err := scanQuery(ctx, query, &entries); err != nil {
+		return nil, err
+	}
+
+	var ret []int64
+	for _, entry := range entries {
+		if entry.ValueBytes == nil {
+			continue
+		}
+
+		if entry.ValueMask == nil {
+			// already compared in SQL
+			ret = append(ret, entry.MeasurementID)
+			continue
+		}
+
+		for _, value := range o.rawValues {
+			if comid.NewRawValueFromBytes(value).CompareAgainstReference(*entry.ValueBytes, *entry.ValueMask) {
+				ret = append(ret, entry.MeasurementID)
+				break
+			}
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return ret, nil
+}
+
 func (o *MeasurementQuery) IsEmpty() bool {
 	return len(o.mkeyTypes) == 0 &&
 		len(o.mkeyBytes) == 0 &&
 		len(o.mkeys) == 0 &&
+		len(o.rawValues) == 0 &&
 		o.modelQuery.IsEmpty() &&
 		o.ownedQuery.IsEmpty() &&
 		o.AuthorizedBySubquery().IsEmpty() &&
 		o.ValueSubquery().IsEmpty() &&
 		o.DigestsSubquery().IsEmpty() &&
 		o.IntegrityRegistersSubquery().IsEmpty() &&
-		o.FlagsSubquery().IsEmpty()
+		o.FlagsSubquery().IsEmpty() &&
+		o.ExtensionGroup().IsEmpty()
 }
 
 type TokenQuery struct {
@@ -4075,6 +4872,10 @@ type TokenQuery struct {
 	isSigned    []bool
 	data        [][]byte
 
+	// tenant, if set, restricts the query to tokens of manifests under
+	// the tenant's label (see Store.ForTenant).
+	tenant *string
+
 	authoritySubquery *CryptoKeyQuery
 }
 
@@ -4121,6 +4922,16 @@ func (o *TokenQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Di
 	addOrGroupWhereClause("manifest_id", o.manifestIDs, false, query, dialect)
 	addOrGroupWhereClause("is_signed", o.isSigned, false, query, dialect)
 	addOrGroupWhereClause("data", o.data, false, query, dialect)
+
+	if o.tenant != nil {
+		query.Where("manifest_id IN (SELECT man.manifest_id FROM manifests AS man WHERE man.label = ?)",
+			*o.tenant)
+	}
+}
+
+func (o *TokenQuery) restrictToTenant(name string) error {
+	o.tenant = &name
+	return nil
 }
 
 func (o *TokenQuery) Run(ctx context.Context, db bun.IDB) ([]*model.Token, error) {
@@ -4158,6 +4969,7 @@ func (o *TokenQuery) IsEmpty() bool {
 	return o.modelQuery.IsEmpty() &&
 		len(o.manifestIDs) == 0 &&
 		len(o.isSigned) == 0 &&
+		o.tenant == nil &&
 		len(o.data) == 0 &&
 		o.AuthoritySubquery().IsEmpty()
 }
@@ -4235,6 +5047,9 @@ func (o *ownedQuery) IsEmpty() bool {
 
 type ManifestCommonQuery struct {
 	labels []string
+	// tenant, if set, restricts the query to the tenant's label,
+	// regardless of any other parameters (see Store.ForTenant).
+	tenant *string
 
 	manifestDbIDs []int64
 
@@ -4405,9 +5220,23 @@ func (o *ManifestCommonQuery) restoreManifestDbIDs() {
 	o.saved = false
 }
 
+func (o *ManifestCommonQuery) restrictToTenant(name string) error {
+	for _, label := range o.labels {
+		if label != name {
+			return fmt.Errorf("label %q: %w", label, ErrTenantScope)
+		}
+	}
+
+	o.tenant = &name
+	return nil
+}
+
 func (o *ManifestCommonQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
 	addOrGroupWhereClause("manifest_db_id", o.manifestDbIDs, false, query, dialect)
 	addOrGroupWhereClause("label", o.labels, false, query, dialect)
+	if o.tenant != nil {
+		query.Where(fmt.Sprintf("%s = ?", identQuote("label", dialect)), *o.tenant)
+	}
 
 	addOrGroupWhereClause("manifest_id_type", o.manifestIDTypes, false, query, dialect)
 	addOrGroupWhereClause("manifest_id", o.manifestIDValues, false, query, dialect)
@@ -4424,6 +5253,7 @@ func (o *ManifestCommonQuery) UpdateSelectQuery(query *bun.SelectQuery, dialect
 func (o *ManifestCommonQuery) IsEmpty() bool {
 	return len(o.manifestDbIDs) == 0 &&
 		len(o.labels) == 0 &&
+		o.tenant == nil &&
 		len(o.profileTypes) == 0 &&
 		len(o.profileValues) == 0 &&
 		len(o.profiles) == 0 &&
@@ -4670,6 +5500,19 @@ func (o *keyQueryEntry) UpdateQuery(whereFunc whereFunc, dialect schema.Dialect)
 	whereFunc("key_type = ? AND key_bytes = ?", o.typ, o.bytes)
 }
 
+type keyMatchQueryEntry struct {
+	spkiThumbprint []byte
+	certThumbprint []byte
+}
+
+func (o *keyMatchQueryEntry) UpdateQuery(whereFunc whereFunc, dialect schema.Dialect) {
+	if o.certThumbprint == nil {
+		whereFunc("spki_thumbprint = ?", o.spkiThumbprint)
+	} else {
+		whereFunc("spki_thumbprint = ? OR cert_thumbprint = ?", o.spkiThumbprint, o.certThumbprint)
+	}
+}
+
 type digestQueryEntry struct {
 	algIDInt  int64
 	algIDText string
@@ -4677,13 +5520,23 @@ type digestQueryEntry struct {
 }
 
 func (o *digestQueryEntry) UpdateQuery(whereFunc whereFunc, dialect schema.Dialect) {
-	if o.algIDText == "" {
+	if algID := util.CanonicalDigestAlgID(o.algID()); algID != 0 {
+		whereFunc("canonical_alg_id = ? AND value = ?", algID, o.value)
+	} else if o.algIDText == "" {
 		whereFunc("alg_id_int = ? AND value = ?", o.algIDInt, o.value)
 	} else {
 		whereFunc("alg_id_text = ? AND value = ?", o.algIDText, o.value)
 	}
 }
 
+func (o *digestQueryEntry) algID() any {
+	if o.algIDText == "" {
+		return o.algIDInt
+	}
+
+	return o.algIDText
+}
+
 type classIDQueryEntry struct {
 	typ   string
 	value []byte
@@ -4791,6 +5644,12 @@ func runQuery[T model.Model](ctx context.Context, db bun.IDB, query Query[T]) ([
 	bunQuery := db.NewSelect().Model(&ret)
 	query.UpdateSelectQuery(bunQuery, db.Dialect())
 
+	return scanQuery(ctx, bunQuery, &ret)
+}
+
+// scanQuery runs the bun.SelectQuery, whose model must be ret, returning
+// ErrNoMatch if no rows were selected.
+func scanQuery[T any](ctx context.Context, bunQuery *bun.SelectQuery, ret *[]T) ([]T, error) {
 	if err := bunQuery.Scan(ctx); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoMatch
@@ -4799,11 +5658,11 @@ func runQuery[T model.Model](ctx context.Context, db bun.IDB, query Query[T]) ([
 		return nil, err
 	}
 
-	if len(ret) == 0 {
-		return ret, ErrNoMatch
+	if len(*ret) == 0 {
+		return *ret, ErrNoMatch
 	}
 
-	return ret, nil
+	return *ret, nil
 }
 
 func identQuote(column string, dialect schema.Dialect) string {
@@ -2,15 +2,25 @@ package store
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/veraison/corim-store/pkg/model"
 	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
 	"github.com/veraison/eat"
+	"github.com/veraison/go-cose"
 	"github.com/veraison/swid"
 )
 
@@ -202,6 +212,93 @@ func TestFlagQuery(t *testing.T) {
 	assert.Len(t, result, 0)
 }
 
+func TestExtensionQuery(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"extensions.yaml": extensionsFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	query := NewExtensionQuery()
+	assert.True(t, query.IsEmpty())
+
+	result, err := query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 7)
+
+	query = NewExtensionQuery().Name("Vendor")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	query = NewExtensionQuery().Name("vendor")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	// LIKE metacharacters in names are matched literally
+	query = NewExtensionQuery().Name("vend_r")
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	query = NewExtensionQuery().Name("%")
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	query = NewExtensionQuery().Name("-70")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	query = NewExtensionQuery().Value("ACME")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	query = NewExtensionQuery().Name("vendor").Value("ACME").OwnerType("manifest")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, int64(1), result[0].OwnerID)
+
+	query = NewExtensionQuery().Value("3")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Level", result[0].FieldName)
+
+	query = NewExtensionQuery().Value("true")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Enabled", result[0].FieldName)
+
+	query = NewExtensionQuery().Value("0.5")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Ratio", result[0].FieldName)
+
+	query = NewExtensionQuery().Text("ACME", "Other")
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	query = NewExtensionQuery().Bytes(comid.MustHexDecode(t, "6441434d45"))
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	// value columns not corresponding to the field kind are not matched
+	query = NewExtensionQuery().Int(0)
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	query = NewExtensionQuery().Text("3")
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
 func TestMeasurementValueQuery(t *testing.T) {
 	ctx := context.Background()
 	db := model.NewTestDBWithFixtures(t, map[string][]byte{
@@ -312,6 +409,50 @@ func TestMeasurementValueQuery(t *testing.T) {
 	assert.Len(t, result, 1)
 }
 
+func TestDigestQuery_canonical_alg(t *testing.T) {
+	bytes := comid.MustHexDecode(t, "0001020304050607000102030405060700010203040506070001020304050607")
+	ctx := context.Background()
+	db := model.NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	for _, digest := range []*model.Digest{
+		model.NewDigestInt(1, bytes),
+		model.NewDigestText("sha-256", bytes),
+		model.NewDigestText("SHA256", bytes),
+		model.NewDigestText("md5", bytes),
+		model.NewDigestInt(7, bytes),
+	} {
+		require.NoError(t, digest.Insert(ctx, db))
+	}
+
+	testCases := []struct {
+		title    string
+		query    *DigestQuery
+		expected []int64
+	}{
+		{"int digest", NewDigestQuery().Digest(1, bytes), []int64{1, 2, 3}},
+		{"text digest", NewDigestQuery().Digest("sha-256", bytes), []int64{1, 2, 3}},
+		{"alias digest", NewDigestQuery().DigestTextAlg("Sha256", bytes), []int64{1, 2, 3}},
+		{"unknown alg digest", NewDigestQuery().Digest("md5", bytes), []int64{4}},
+		{"int alg", NewDigestQuery().IntAlgID(7), []int64{5}},
+		{"text alg", NewDigestQuery().TextAlgID("sha-384"), []int64{5}},
+		{"both algs", NewDigestQuery().IntAlgID(1).TextAlgID("md5"), []int64{1, 2, 3, 4}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			result, err := tc.query.Run(ctx, db)
+			require.NoError(t, err)
+
+			ids := make([]int64, len(result))
+			for i, digest := range result {
+				ids[i] = digest.ID
+			}
+			assert.ElementsMatch(t, tc.expected, ids)
+		})
+	}
+}
+
 func TestCryptoKeyQuery(t *testing.T) {
 	ctx := context.Background()
 	bytes := comid.MustHexDecode(t, "0001020304050607000102030405060700010203040506070001020304050607")
@@ -374,6 +515,123 @@ func TestCryptoKeyQuery(t *testing.T) {
 	assert.Len(t, result, 1)
 }
 
+func TestCryptoKeyQuery_matching(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	spki, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	spkiSum := sha256.Sum256(spki)
+	certSum := sha256.Sum256(der)
+
+	key, err := cose.NewKeyFromPublic(&priv.PublicKey)
+	require.NoError(t, err)
+	coseKey, err := key.MarshalCBOR()
+	require.NoError(t, err)
+
+	keys := []*comid.CryptoKey{
+		comid.MustNewCryptoKey(
+			string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: spki})),
+			comid.PKIXBase64KeyType,
+		),
+		comid.MustNewCryptoKey(
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			comid.PKIXBase64CertType,
+		),
+		comid.MustNewCryptoKey(
+			comid.Digest{Algorithm: comid.IntDigestAlgorithm(comid.Sha256), Value: spkiSum[:]},
+			comid.ThumbprintType,
+		),
+		comid.MustNewCryptoKey(
+			comid.Digest{Algorithm: comid.IntDigestAlgorithm(comid.Sha256), Value: certSum[:]},
+			comid.CertThumbprintType,
+		),
+		comid.MustNewCryptoKey(comid.TestECPubKey, comid.PKIXBase64KeyType),
+		comid.MustNewCOSEKey(coseKey),
+	}
+
+	for _, key := range keys {
+		modelKey, err := model.NewCryptoKeyFromCoRIM(key)
+		require.NoError(t, err)
+		require.NoError(t, modelKey.Insert(ctx, db))
+	}
+
+	query := NewCryptoKeyQuery().MatchingPublicKey(&priv.PublicKey)
+	assert.False(t, query.IsEmpty())
+	result, err := query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3, 6}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().MatchingCertificate(cert)
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4, 6}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().MatchingPublicKey(&priv.PublicKey).KeyType(comid.COSEKeyType)
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{6}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().MatchingCertificate(cert).KeyType(comid.ThumbprintType)
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{3}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().CertThumbprint(certSum[:])
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{2, 4}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().SPKIThumbprint(spkiSum[:])
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3, 6}, cryptoKeyIDs(result))
+
+	query = NewCryptoKeyQuery().MatchingPublicKey(nil)
+	assert.False(t, query.IsEmpty())
+	_, err = query.Run(ctx, db)
+	assert.EqualError(t, err, "public key: nil public key")
+
+	// unsupported key types are reported when the query is run, including
+	// when it is used as a sub-query
+	query = NewCryptoKeyQuery().MatchingPublicKey("not a key").KeyType(comid.COSEKeyType)
+	_, err = query.Run(ctx, db)
+	assert.ErrorContains(t, err, "public key: ")
+	assert.ErrorContains(t, err, "unsupported public key type")
+
+	err = updateSelectQueryInline(query, db.NewSelect().Model((*model.CryptoKey)(nil)), db.Dialect())
+	assert.ErrorContains(t, err, "unsupported public key type")
+
+	tripleQuery := NewKeyTripleQuery().CryptoKey(func(kq *CryptoKeyQuery) {
+		kq.MatchingPublicKey("not a key")
+	})
+	_, err = tripleQuery.Run(ctx, db)
+	assert.ErrorContains(t, err, "unsupported public key type")
+}
+
+func cryptoKeyIDs(keys []*model.CryptoKey) []int64 {
+	ret := make([]int64, len(keys))
+	for i, key := range keys {
+		ret[i] = key.ID
+	}
+	return ret
+}
+
 func TestMeasurementQuery(t *testing.T) {
 	ctx := context.Background()
 	bytes := comid.MustHexDecode(t, "0001020304050607000102030405060700010203040506070001020304050607")
@@ -438,6 +696,92 @@ func TestMeasurementQuery(t *testing.T) {
 	assert.Len(t, result, 1)
 }
 
+func TestMeasurementQuery_raw_value_matches(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDB(t)
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	measurements := []*comid.Measurement{
+		comid.MustNewUintMeasurement(uint64(1)).
+			SetRawValueBytes([]byte{0x01, 0x02, 0x03, 0x04}, nil),
+		comid.MustNewUintMeasurement(uint64(2)).
+			SetRawValueBytes([]byte{0x01, 0x02, 0xff, 0xff}, []byte{0xff, 0xff, 0x00, 0x00}),
+		comid.MustNewUintMeasurement(uint64(3)),
+		comid.MustNewUintMeasurement(uint64(4)),
+		comid.MustNewUintMeasurement(uint64(5)).
+			SetRawValueBytes([]byte{0x0a, 0x0b}, []byte{0xff, 0xff}),
+		comid.MustNewUintMeasurement(uint64(6)).
+			SetRawValueBytes([]byte{0x0c, 0x0d}, []byte{0x00, 0x00}),
+	}
+	measurements[2].Val.RawValue = comid.MustNewRawValueWithMask(
+		[]byte{0x00, 0x00, 0x03, 0x04},
+		[]byte{0x00, 0x00, 0xff, 0x0f},
+	)
+	measurements[3].Val.RawValue = comid.MustNewRawValueWithMask([]byte{0x01}, []byte{0x01})
+
+	for _, origin := range measurements {
+		mea, err := model.NewMeasurementFromCoRIM(origin)
+		require.NoError(t, err)
+		require.NoError(t, mea.Insert(ctx, db))
+	}
+
+	measurementIDs := func(result []*model.Measurement) []int64 {
+		ret := make([]int64, len(result))
+		for i, mea := range result {
+			ret[i] = mea.ID
+		}
+		return ret
+	}
+
+	query := NewMeasurementQuery().RawValueMatches([]byte{0x01, 0x02, 0x03, 0x04})
+	assert.False(t, query.IsEmpty())
+	result, err := query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, measurementIDs(result))
+
+	query = NewMeasurementQuery().RawValueMatches([]byte{0x01, 0x02, 0x00, 0x00})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{2}, measurementIDs(result))
+
+	query = NewMeasurementQuery().RawValueMatches([]byte{0xff, 0xff, 0x03, 0xf4}, []byte{0x03})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{3, 4}, measurementIDs(result))
+
+	query = NewMeasurementQuery().RawValueMatches([]byte{0x0a, 0x0b})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{5, 6}, measurementIDs(result))
+
+	query = NewMeasurementQuery().RawValueMatches([]byte{0x0a, 0x0c})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{6}, measurementIDs(result))
+
+	query = NewMeasurementQuery().ID(1, 2).RawValueMatches([]byte{0x01, 0x02, 0x03, 0x04})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, measurementIDs(result))
+	assert.Equal(t, []int64{1, 2}, query.ids)
+
+	query = NewMeasurementQuery().RawValueMatches([]byte{0x02})
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	selected, err := model.SelectMeasurement(ctx, db, 3)
+	require.NoError(t, err)
+	other, err := selected.ToCoRIM()
+	require.NoError(t, err)
+	assert.Equal(t, measurements[2].Val.RawValue, other.Val.RawValue)
+
+	selected, err = model.SelectMeasurement(ctx, db, 2)
+	require.NoError(t, err)
+	other, err = selected.ToCoRIM()
+	require.NoError(t, err)
+	assert.Equal(t, measurements[1].Val.RawValueMask, other.Val.RawValueMask)
+}
+
 func TestHrefQuery(t *testing.T) {
 	ctx := context.Background()
 	db := model.NewTestDBWithFixtures(t, map[string][]byte{
@@ -561,9 +905,10 @@ func TestEntitiesQuery(t *testing.T) {
 func TestManifestQuery(t *testing.T) {
 	ctx := context.Background()
 	db := model.NewTestDBWithFixtures(t, map[string][]byte{
-		"manifests.yaml": manifestsFixture,
-		"entities.yaml":  entitiesFixture,
-		"locators.yaml":  locatorsFixture,
+		"manifests.yaml":  manifestsFixture,
+		"entities.yaml":   entitiesFixture,
+		"locators.yaml":   locatorsFixture,
+		"extensions.yaml": extensionsFixture,
 	})
 	defer func() { assert.NoError(t, db.Close()) }()
 
@@ -709,6 +1054,28 @@ func TestManifestQuery(t *testing.T) {
 		})
 	_, err = query.Run(ctx, db)
 	assert.ErrorContains(t, err, "dependent RIMs: href: no match found")
+
+	query = NewManifestQuery().
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("vendor").Value("ACME")
+		}).
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("level").Value("3")
+		})
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, int64(1), result[0].ManifestDbID)
+
+	query = NewManifestQuery().
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("vendor").Value("ACME")
+		}).
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("level").Value("4")
+		})
+	_, err = query.Run(ctx, db)
+	assert.ErrorContains(t, err, "extensions: no match found")
 }
 
 func TestLinkedTagQuery(t *testing.T) {
@@ -752,6 +1119,7 @@ func TestModuleTagQuery(t *testing.T) {
 		"module_tags.yaml": moduleTagsFixture,
 		"entities.yaml":    entitiesFixture,
 		"linked_tags.yaml": linkedTagsFixture,
+		"extensions.yaml":  extensionsFixture,
 	})
 	defer func() { assert.NoError(t, db.Close()) }()
 
@@ -868,6 +1236,22 @@ func TestModuleTagQuery(t *testing.T) {
 		})
 	_, err = query.Run(ctx, db)
 	assert.ErrorContains(t, err, "linked tags: no match found")
+
+	query = NewModuleTagQuery().
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("Enabled").Value("true")
+		})
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, int64(1), result[0].ModuleTagDbID)
+
+	query = NewModuleTagQuery().
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("Enabled").Value("false")
+		})
+	_, err = query.Run(ctx, db)
+	assert.ErrorContains(t, err, "extensions: no match found")
 }
 
 func TestEnvironmentQuery(t *testing.T) {
@@ -1024,6 +1408,75 @@ func TestEnvironmentQuery_exact(t *testing.T) {
 	assert.Len(t, result, 1)
 }
 
+func TestEnvironmentQuery_typed(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"environments.yaml": environmentsFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	instanceID, err := uuid.FromBytes(comid.MustHexDecode(t, "10111213141516178011121314151617"))
+	require.NoError(t, err)
+
+	groupIDBytes := comid.MustHexDecode(t, "2021222324252627202122232425262720212223242526272021222324252627")
+
+	query := NewEnvironmentQuery(true).
+		ClassOID(comid.OID{0x01, 0x02, 0x03, 0x04}).
+		Vendor("baz", "qux").
+		Layer(2).
+		Index(1).
+		InstanceUUID(instanceID).
+		GroupTaggedBytes(groupIDBytes)
+	result, err := query.Run(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	query = NewEnvironmentQuery(false).ClassTaggedBytes(comid.MustHexDecode(t, "01020304"))
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	query = NewEnvironmentQuery(false).InstanceTaggedBytes(instanceID[:])
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	ueid := eat.UEID(comid.MustHexDecode(t, "0110111213141516171011121314151617"))
+	key := comid.MustNewPKIXBase64Key(comid.TestECPubKey)
+	classUUID := uuid.MustParse(comid.TestUUIDString)
+
+	var oid comid.OID
+	require.NoError(t, oid.FromString(comid.TestOID))
+
+	for _, tc := range []struct {
+		env   comid.Environment
+		query *EnvironmentQuery
+	}{
+		{
+			env: comid.Environment{
+				Class:    comid.NewClassUUID(comid.TestUUID),
+				Instance: comid.MustNewUEIDInstance(ueid),
+				Group:    comid.MustNewUUIDGroup(comid.TestUUID),
+			},
+			query: NewEnvironmentQuery(true).
+				ClassUUID(classUUID).
+				InstanceUEID(ueid).
+				GroupUUID(classUUID),
+		},
+		{
+			env: comid.Environment{
+				Class:    comid.NewClassOID(comid.TestOID),
+				Instance: &comid.Instance{Value: key.Value},
+			},
+			query: NewEnvironmentQuery(true).
+				ClassOID(oid).
+				InstanceCryptoKey(key),
+		},
+	} {
+		expected := NewEnvironmentQuery(true)
+		require.NoError(t, expected.UpdateFromCoRIM(&tc.env))
+		assert.Equal(t, expected, tc.query)
+	}
+}
+
 func TestClassSubquery(t *testing.T) {
 	query := &ClassSubquery{}
 	assert.True(t, query.IsEmpty())
@@ -1193,6 +1646,7 @@ func TestValueTripleQuery(t *testing.T) {
 		"environments.yaml":       environmentsFixture,
 		"measurements.yaml":       measurementsFixture,
 		"measurement_values.yaml": measurementValuesFixture,
+		"extensions.yaml":         extensionsFixture,
 	})
 	defer func() { assert.NoError(t, db.Close()) }()
 
@@ -1310,6 +1764,105 @@ func TestValueTripleQuery(t *testing.T) {
 	result, err = query.Run(ctx, db)
 	assert.NoError(t, err)
 	assert.Len(t, result, 1)
+
+	query = NewValueTripleQuery().
+		Extension(func(eq *ExtensionQuery) {
+			eq.Name("vendor").Value("ACME")
+		})
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, int64(1), result[0].TripleDbID)
+
+	query = NewValueTripleQuery().
+		Measurement(func(mq *MeasurementQuery) {
+			mq.Extension(func(eq *ExtensionQuery) {
+				eq.Name("ratio").Value("0.5")
+			})
+		})
+	result, err = query.Run(ctx, db)
+	assert.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, int64(2), result[0].TripleDbID)
+}
+
+func TestValueTripleQuery_entities(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"manifests.yaml":    manifestsFixture,
+		"module_tags.yaml":  moduleTagsFixture,
+		"triples.yaml":      triplesFixture,
+		"environments.yaml": environmentsFixture,
+		"entities.yaml":     entitiesFixture,
+		"roles.yaml":        rolesFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	tripleIDs := func(entries []*model.ValueTripleEntry) []int64 {
+		ret := make([]int64, len(entries))
+		for i, entry := range entries {
+			ret[i] = entry.TripleDbID
+		}
+		return ret
+	}
+
+	query := NewValueTripleQuery().
+		ModuleTagEntity(func(eq *EntityQuery) {
+			eq.NameValue("qux").Role("tagCreator")
+		})
+	assert.False(t, query.IsEmpty())
+
+	result, err := query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, tripleIDs(result))
+
+	// running the query again must not be affected by the previous run
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, tripleIDs(result))
+
+	query = NewValueTripleQuery().
+		ManifestEntity(func(eq *EntityQuery) {
+			eq.Role("manifestCreator")
+		})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, tripleIDs(result))
+
+	query = NewValueTripleQuery().
+		ManifestEntity(func(eq *EntityQuery) {
+			eq.URI("http://acme.com")
+		}).
+		ModuleTagEntity(func(eq *EntityQuery) {
+			eq.Role("maintainer")
+		})
+	result, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, tripleIDs(result))
+
+	query = NewValueTripleQuery().
+		ManifestEntity(func(eq *EntityQuery) {
+			eq.NameValue("baz")
+		}).
+		ModuleTagEntity(func(eq *EntityQuery) {
+			eq.Role("tagCreator")
+		})
+	_, err = query.Run(ctx, db)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	query = NewValueTripleQuery().
+		ModuleTagEntity(func(eq *EntityQuery) {
+			eq.NameValue("qux").Role("manifestCreator")
+		})
+	_, err = query.Run(ctx, db)
+	assert.ErrorContains(t, err, "module tag entities: no match found")
+
+	query = NewValueTripleQuery().
+		ManifestEntity(func(eq *EntityQuery) {
+			eq.NameValue("doesnotexist")
+		})
+	_, err = query.Run(ctx, db)
+	assert.ErrorContains(t, err, "manifest entities: no match found")
 }
 
 func TestTokenQuery(t *testing.T) {
@@ -44,6 +44,16 @@ func (o *QueryGroup[M, Q]) UpdateSelectQuery(query *bun.SelectQuery, dialect sch
 	})
 }
 
+func (o *QueryGroup[M, Q]) restrictToTenant(name string) error {
+	for _, sub := range o.subqueries {
+		if err := restrictToTenant(sub, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (o *QueryGroup[M, Q]) Run(ctx context.Context, db bun.IDB) ([]M, error) {
 	results, err := o.RunGroup(ctx, db)
 	if err != nil {
@@ -244,6 +254,12 @@ func NewMeasurementQueryGroup(ownerName string) *MeasurementQueryGroup {
 	return NewOwnedModelQueryGroup[*model.Measurement, *MeasurementQuery](ownerName)
 }
 
+type ExtensionQueryGroup = OwnedModelQueryGroup[*model.ExtensionValue, *ExtensionQuery]
+
+func NewExtensionQueryGroup(ownerName string) *ExtensionQueryGroup {
+	return NewOwnedModelQueryGroup[*model.ExtensionValue, *ExtensionQuery](ownerName)
+}
+
 type StatefulEnvironmentQueryGroup = OwnedModelQueryGroup[*model.StatefulEnvironment, *StatefulEnvironmentQuery]
 
 func NewStatefulEnvironmentQueryGroup() *StatefulEnvironmentQueryGroup {
@@ -0,0 +1,1131 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+// ErrQueryString is wrapped by errors returned when a query string cannot be
+// parsed, or cannot be translated into a query.
+var ErrQueryString = errors.New("invalid query string")
+
+// maxQueryStringConjunctions limits the number of queries a query string may
+// expand into (see queryStringExpr.conjunctions).
+const maxQueryStringConjunctions = 64
+
+// QueryStringError describes a problem with a query string, and where in the
+// string it has been found.
+type QueryStringError struct {
+	// Column is the one-based position in the query string of the token
+	// that caused the error.
+	Column int
+	// Message describes the error.
+	Message string
+}
+
+func (o *QueryStringError) Error() string {
+	return fmt.Sprintf("%s: column %d: %s", ErrQueryString, o.Column, o.Message)
+}
+
+func (o *QueryStringError) Unwrap() error {
+	return ErrQueryString
+}
+
+// QueryStringTarget identifies the type of entries a query string is run
+// against.
+type QueryStringTarget string
+
+const (
+	ManifestTarget    QueryStringTarget = "manifests"
+	ModuleTagTarget   QueryStringTarget = "module_tags"
+	ValueTripleTarget QueryStringTarget = "value_triples"
+	KeyTripleTarget   QueryStringTarget = "key_triples"
+)
+
+// QueryString parses the text as a query (see ParseManifestQuery) against the
+// specified target, and returns the matching entries. The returned models
+// are *model.ManifestEntry's, *model.ModuleTagEntry's,
+// *model.ValueTripleEntry's, or *model.KeyTripleEntry's, depending on the
+// target.
+func (o *Store) QueryString(target QueryStringTarget, text string) ([]model.Model, error) {
+	switch target {
+	case ManifestTarget:
+		query, err := ParseManifestQuery(text)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := o.QueryManifestEntries(query)
+		return toModels(entries), err
+	case ModuleTagTarget:
+		query, err := ParseModuleTagQuery(text)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := o.QueryModuleTagEntries(query)
+		return toModels(entries), err
+	case ValueTripleTarget:
+		query, err := ParseValueTripleQuery(text)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := o.QueryValueTripleEntries(query)
+		return toModels(entries), err
+	case KeyTripleTarget:
+		query, err := ParseKeyTripleQuery(text)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := o.QueryKeyTripleEntries(query)
+		return toModels(entries), err
+	default:
+		return nil, fmt.Errorf("unsupported query string target: %q", target)
+	}
+}
+
+// ParseManifestQuery parses a query string into a query for manifest entries.
+// A query string is a combination of conditions using "and" and "or"
+// (parentheses may be used for grouping), e.g.
+//
+//	label in ("prod", "stage") and (profile = "http://example.com" or ext.vendor = ACME)
+//
+// Each condition is a field name, an operator ("=", "<", "<=", ">", ">=", or
+// "in" followed by a parenthesised list of values), and a value. Values may be
+// double- or single-quoted, and must be if they contain whitespace or any of
+// the characters (),=<>!"'. The following fields are supported for manifests:
+//
+//   - id: manifest database ID
+//   - label
+//   - manifest_id (or corim_id)
+//   - profile
+//   - added (with "<" or ">"): the time the manifest was added
+//   - valid_on: a time within the manifest's validity period
+//   - valid (= true): the manifest is currently valid
+//   - ext.NAME: manifest extension with the field or JSON tag name NAME
+//
+// Times are specified as RFC3339 timestamps or dates (YYYY-MM-DD). A field
+// may only be specified once within a conjunction, with the exception of
+// ext. fields.
+//
+// Where the query string contains "or", the returned query is a
+// ManifestQueryGroup; otherwise, it is a ManifestQuery. An empty query
+// string matches all entries.
+func ParseManifestQuery(text string) (Query[*model.ManifestEntry], error) {
+	return parseQueryString(text, manifestQueryStringTarget)
+}
+
+// ParseModuleTagQuery parses a query string (see ParseManifestQuery) into a
+// query for module tag entries. In addition to the manifest fields (which
+// apply to the manifest containing the module tag), the following fields are
+// supported:
+//
+//   - id: module tag database ID
+//   - module_tag_id (or comid_id)
+//   - version
+//   - language
+//
+// ext. fields match the module tag's extensions.
+func ParseModuleTagQuery(text string) (Query[*model.ModuleTagEntry], error) {
+	return parseQueryString(text, moduleTagQueryStringTarget)
+}
+
+// ParseValueTripleQuery parses a query string (see ParseManifestQuery and
+// ParseModuleTagQuery) into a query for value triple entries. In addition to
+// the manifest and module tag fields (which apply to the containing manifest
+// and module tag), the following fields are supported:
+//
+//   - id: triple database ID
+//   - active: true or false
+//   - type: "reference" or "endorsement"
+//   - vendor, model, layer, index: environment class fields
+//   - svn: security version number of a measurement (all operators are
+//     supported)
+//
+// ext. fields match the extensions of the triples of the containing module
+// tag.
+func ParseValueTripleQuery(text string) (Query[*model.ValueTripleEntry], error) {
+	return parseQueryString(text, valueTripleQueryStringTarget)
+}
+
+// ParseKeyTripleQuery parses a query string (see ParseValueTripleQuery) into a
+// query for key triple entries. The same fields as for value triples are
+// supported, except for svn; type is either "attest" or "identity".
+func ParseKeyTripleQuery(text string) (Query[*model.KeyTripleEntry], error) {
+	return parseQueryString(text, keyTripleQueryStringTarget)
+}
+
+func toModels[M model.Model](entries []M) []model.Model {
+	if entries == nil {
+		return nil
+	}
+
+	ret := make([]model.Model, len(entries))
+	for i, entry := range entries {
+		ret[i] = entry
+	}
+
+	return ret
+}
+
+// queryStringTarget describes how query string conditions are applied to
+// queries of type Q.
+type queryStringTarget[M model.Model, Q Query[M]] struct {
+	newQuery  func() Q
+	fields    map[string]*queryStringField[Q]
+	extension func(query Q, updater func(*ExtensionQuery))
+	// measurement, if not nil, adds a measurement query to the query.
+	measurement func(query Q) *MeasurementQuery
+}
+
+type queryStringField[Q any] struct {
+	ops   []string
+	apply func(c *queryStringConjunction[Q], cond *queryStringCondition) error
+}
+
+// queryStringConjunction is the query being built from the conditions of a
+// conjunction within a query string.
+type queryStringConjunction[Q any] struct {
+	query Q
+
+	measurement    *MeasurementQuery
+	newMeasurement func(query Q) *MeasurementQuery
+}
+
+// Measurement returns the measurement query shared by the conditions in the
+// conjunction, so that they are all matched by the same measurement.
+func (o *queryStringConjunction[Q]) Measurement() *MeasurementQuery {
+	if o.measurement == nil {
+		o.measurement = o.newMeasurement(o.query)
+	}
+
+	return o.measurement
+}
+
+func parseQueryString[M model.Model, Q Query[M]](
+	text string,
+	target *queryStringTarget[M, Q],
+) (Query[M], error) {
+	expr, err := newQueryStringParser(text).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if expr == nil {
+		return target.newQuery(), nil
+	}
+
+	conjunctions, err := expr.conjunctions()
+	if err != nil {
+		return nil, err
+	}
+
+	group := NewQueryGroup[M, Q]()
+	for _, conditions := range conjunctions {
+		query, err := buildQueryFromConditions(conditions, target)
+		if err != nil {
+			return nil, err
+		}
+
+		group.Add(query)
+	}
+
+	if group.Length() == 1 {
+		return group.subqueries[0], nil
+	}
+
+	return group, nil
+}
+
+func buildQueryFromConditions[M model.Model, Q Query[M]](
+	conditions []*queryStringCondition,
+	target *queryStringTarget[M, Q],
+) (Q, error) {
+	conj := &queryStringConjunction[Q]{
+		query:          target.newQuery(),
+		newMeasurement: target.measurement,
+	}
+	seen := make(map[string]bool)
+
+	for _, cond := range conditions {
+		if name, ok := cond.extensionName(); ok {
+			if name == "" {
+				return conj.query, cond.fieldError("extension name not specified")
+			}
+
+			if cond.op != "=" && cond.op != "in" {
+				return conj.query, cond.opError()
+			}
+
+			target.extension(conj.query, func(eq *ExtensionQuery) {
+				eq.Name(name).Value(cond.texts()...)
+			})
+
+			continue
+		}
+
+		name := util.Normalize(cond.field.text)
+		field, ok := target.fields[name]
+		if !ok {
+			return conj.query, cond.fieldError(fmt.Sprintf("unknown field %q", cond.field.text))
+		}
+
+		if !slices.Contains(field.ops, cond.op) {
+			return conj.query, cond.opError()
+		}
+
+		// "=" and "in" values are combined disjunctively, so they may
+		// only be specified once.
+		key := name + " " + cond.op
+		if cond.op == "in" {
+			key = name + " ="
+		}
+		if seen[key] {
+			return conj.query, cond.fieldError(fmt.Sprintf(
+				`%q specified more than once in a conjunction (use "in" to match one of several values)`,
+				cond.field.text))
+		}
+		seen[key] = true
+
+		if err := field.apply(conj, cond); err != nil {
+			return conj.query, err
+		}
+	}
+
+	return conj.query, nil
+}
+
+var (
+	equalityOps   = []string{"=", "in"}
+	comparisonOps = []string{"=", "in", "<", "<=", ">", ">="}
+)
+
+func manifestCommonQueryStringFields[Q any](
+	common func(Q) *ManifestCommonQuery,
+) map[string]*queryStringField[Q] {
+	manifestID := &queryStringField[Q]{
+		ops: equalityOps,
+		apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+			common(c.query).ManifestIDValue(cond.texts()...)
+			return nil
+		},
+	}
+
+	return map[string]*queryStringField[Q]{
+		"label": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				common(c.query).Label(cond.texts()...)
+				return nil
+			},
+		},
+		"manifest_id": manifestID,
+		"corim_id":    manifestID,
+		"profile": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				common(c.query).ProfileValue(cond.texts()...)
+				return nil
+			},
+		},
+		"added": {
+			ops: []string{"<", ">"},
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				t, err := cond.values[0].toTime()
+				if err != nil {
+					return err
+				}
+
+				if cond.op == "<" {
+					common(c.query).AddedBefore(t)
+				} else {
+					common(c.query).AddedAfter(t)
+				}
+
+				return nil
+			},
+		},
+		"valid_on": {
+			ops: []string{"="},
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				t, err := cond.values[0].toTime()
+				if err != nil {
+					return err
+				}
+
+				common(c.query).ValidOn(t)
+				return nil
+			},
+		},
+		"valid": {
+			ops: []string{"="},
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				valid, err := cond.values[0].toBool()
+				if err != nil {
+					return err
+				}
+
+				if !valid {
+					return cond.values[0].error(`only "valid = true" is supported`)
+				}
+
+				common(c.query).ValidOn(time.Now())
+				return nil
+			},
+		},
+	}
+}
+
+func moduleTagCommonQueryStringFields[Q any](
+	common func(Q) *ModuleTagCommonQuery,
+) map[string]*queryStringField[Q] {
+	moduleTagID := &queryStringField[Q]{
+		ops: equalityOps,
+		apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+			common(c.query).ModuleTagIDValue(cond.texts()...)
+			return nil
+		},
+	}
+
+	return map[string]*queryStringField[Q]{
+		"module_tag_id": moduleTagID,
+		"comid_id":      moduleTagID,
+		"version": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				for _, val := range cond.values {
+					version, err := val.toUint()
+					if err != nil {
+						return err
+					}
+
+					common(c.query).ModuleTagVersion(uint(version))
+				}
+
+				return nil
+			},
+		},
+		"language": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				common(c.query).Language(cond.texts()...)
+				return nil
+			},
+		},
+	}
+}
+
+func tripleQueryStringFields[T model.Model, TT ~string](
+	types []TT,
+) map[string]*queryStringField[*TripleQuery[T, TT]] {
+	type Q = *TripleQuery[T, TT]
+
+	envUint := func(set func(*EnvironmentQuery, uint64)) *queryStringField[Q] {
+		return &queryStringField[Q]{
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				for _, val := range cond.values {
+					i, err := val.toUint()
+					if err != nil {
+						return err
+					}
+
+					set(c.query.EnvironmentSubquery(), i)
+				}
+
+				return nil
+			},
+		}
+	}
+
+	return map[string]*queryStringField[Q]{
+		"id": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				ids, err := cond.ints()
+				if err != nil {
+					return err
+				}
+
+				c.query.TripleDbID(ids...)
+				return nil
+			},
+		},
+		"active": {
+			ops: []string{"="},
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				active, err := cond.values[0].toBool()
+				if err != nil {
+					return err
+				}
+
+				c.query.IsActive(active)
+				return nil
+			},
+		},
+		"type": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				for _, val := range cond.values {
+					typ := TT(val.text)
+					if !slices.Contains(types, typ) {
+						return val.error(fmt.Sprintf("invalid triple type %q (expected one of: %s)",
+							val.text, joinQueryStringValues(types)))
+					}
+
+					c.query.TripleType(typ)
+				}
+
+				return nil
+			},
+		},
+		"vendor": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				c.query.Vendor(cond.texts()...)
+				return nil
+			},
+		},
+		"model": {
+			ops: equalityOps,
+			apply: func(c *queryStringConjunction[Q], cond *queryStringCondition) error {
+				c.query.Model(cond.texts()...)
+				return nil
+			},
+		},
+		"layer": envUint(func(eq *EnvironmentQuery, i uint64) { eq.Layer(i) }),
+		"index": envUint(func(eq *EnvironmentQuery, i uint64) { eq.Index(i) }),
+	}
+}
+
+var svnQueryStringField = &queryStringField[*ValueTripleQuery]{
+	ops: comparisonOps,
+	apply: func(c *queryStringConjunction[*ValueTripleQuery], cond *queryStringCondition) error {
+		svns, err := cond.ints()
+		if err != nil {
+			return err
+		}
+
+		mval := c.Measurement().ValueSubquery().CodePoint(model.MvalSvn)
+
+		switch cond.op {
+		case "=", "in":
+			mval.ValueInt(svns...)
+		case "<":
+			mval.MaxValueInt(svns[0] - 1)
+		case "<=":
+			mval.MaxValueInt(svns[0])
+		case ">":
+			mval.MinValueInt(svns[0] + 1)
+		case ">=":
+			mval.MinValueInt(svns[0])
+		}
+
+		return nil
+	},
+}
+
+var manifestQueryStringTarget = &queryStringTarget[*model.ManifestEntry, *ManifestQuery]{
+	newQuery: NewManifestQuery,
+	fields: mergeQueryStringFields(
+		manifestCommonQueryStringFields(func(q *ManifestQuery) *ManifestCommonQuery {
+			return &q.ManifestCommonQuery
+		}),
+		map[string]*queryStringField[*ManifestQuery]{
+			"id": {
+				ops: equalityOps,
+				apply: func(c *queryStringConjunction[*ManifestQuery], cond *queryStringCondition) error {
+					ids, err := cond.ints()
+					if err != nil {
+						return err
+					}
+
+					c.query.ManifestDbID(ids...)
+					return nil
+				},
+			},
+		},
+	),
+	extension: func(q *ManifestQuery, updater func(*ExtensionQuery)) { q.Extension(updater) },
+}
+
+var moduleTagQueryStringTarget = &queryStringTarget[*model.ModuleTagEntry, *ModuleTagQuery]{
+	newQuery: NewModuleTagQuery,
+	fields: mergeQueryStringFields(
+		manifestCommonQueryStringFields(func(q *ModuleTagQuery) *ManifestCommonQuery {
+			return &q.ManifestCommonQuery
+		}),
+		moduleTagCommonQueryStringFields(func(q *ModuleTagQuery) *ModuleTagCommonQuery {
+			return &q.ModuleTagCommonQuery
+		}),
+		map[string]*queryStringField[*ModuleTagQuery]{
+			"id": {
+				ops: equalityOps,
+				apply: func(c *queryStringConjunction[*ModuleTagQuery], cond *queryStringCondition) error {
+					ids, err := cond.ints()
+					if err != nil {
+						return err
+					}
+
+					c.query.ModuleTagDbID(ids...)
+					return nil
+				},
+			},
+		},
+	),
+	extension: func(q *ModuleTagQuery, updater func(*ExtensionQuery)) { q.Extension(updater) },
+}
+
+var valueTripleQueryStringTarget = &queryStringTarget[*model.ValueTripleEntry, *ValueTripleQuery]{
+	newQuery: NewValueTripleQuery,
+	fields: mergeQueryStringFields(
+		manifestCommonQueryStringFields(func(q *ValueTripleQuery) *ManifestCommonQuery {
+			return &q.ManifestCommonQuery
+		}),
+		moduleTagCommonQueryStringFields(func(q *ValueTripleQuery) *ModuleTagCommonQuery {
+			return &q.ModuleTagCommonQuery
+		}),
+		tripleQueryStringFields[*model.ValueTripleEntry](
+			[]model.ValueTripleType{model.ReferenceValueTriple, model.EndorsedValueTriple},
+		),
+		map[string]*queryStringField[*ValueTripleQuery]{
+			"svn": svnQueryStringField,
+		},
+	),
+	extension: func(q *ValueTripleQuery, updater func(*ExtensionQuery)) { q.Extension(updater) },
+	measurement: func(q *ValueTripleQuery) *MeasurementQuery {
+		var ret *MeasurementQuery
+		q.Measurement(func(mq *MeasurementQuery) { ret = mq })
+		return ret
+	},
+}
+
+var keyTripleQueryStringTarget = &queryStringTarget[*model.KeyTripleEntry, *KeyTripleQuery]{
+	newQuery: NewKeyTripleQuery,
+	fields: mergeQueryStringFields(
+		manifestCommonQueryStringFields(func(q *KeyTripleQuery) *ManifestCommonQuery {
+			return &q.ManifestCommonQuery
+		}),
+		moduleTagCommonQueryStringFields(func(q *KeyTripleQuery) *ModuleTagCommonQuery {
+			return &q.ModuleTagCommonQuery
+		}),
+		tripleQueryStringFields[*model.KeyTripleEntry](
+			[]model.KeyTripleType{model.AttestKeyTriple, model.IdentityKeyTriple},
+		),
+	),
+	extension: func(q *KeyTripleQuery, updater func(*ExtensionQuery)) { q.Extension(updater) },
+}
+
+func mergeQueryStringFields[Q any](maps ...map[string]*queryStringField[Q]) map[string]*queryStringField[Q] {
+	ret := make(map[string]*queryStringField[Q])
+	for _, m := range maps {
+		for k, v := range m {
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+func joinQueryStringValues[T ~string](values []T) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = string(v)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// queryStringExpr is a node in the parsed representation of a query string.
+type queryStringExpr interface {
+	// conjunctions returns the expression in disjunctive normal form,
+	// i.e. as a list of conjunctions of conditions, any one of which must
+	// be satisfied.
+	conjunctions() ([][]*queryStringCondition, error)
+}
+
+type queryStringAnd []queryStringExpr
+
+func (o queryStringAnd) conjunctions() ([][]*queryStringCondition, error) {
+	ret := [][]*queryStringCondition{nil}
+
+	for _, expr := range o {
+		sub, err := expr.conjunctions()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ret)*len(sub) > maxQueryStringConjunctions {
+			return nil, fmt.Errorf("%w: expands into more than %d queries",
+				ErrQueryString, maxQueryStringConjunctions)
+		}
+
+		product := make([][]*queryStringCondition, 0, len(ret)*len(sub))
+		for _, left := range ret {
+			for _, right := range sub {
+				product = append(product, append(slices.Clone(left), right...))
+			}
+		}
+
+		ret = product
+	}
+
+	return ret, nil
+}
+
+type queryStringOr []queryStringExpr
+
+func (o queryStringOr) conjunctions() ([][]*queryStringCondition, error) {
+	var ret [][]*queryStringCondition
+
+	for _, expr := range o {
+		sub, err := expr.conjunctions()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, sub...)
+		if len(ret) > maxQueryStringConjunctions {
+			return nil, fmt.Errorf("%w: expands into more than %d queries",
+				ErrQueryString, maxQueryStringConjunctions)
+		}
+	}
+
+	return ret, nil
+}
+
+type queryStringCondition struct {
+	field  *queryStringToken
+	op     string
+	opTok  *queryStringToken
+	values []*queryStringToken
+}
+
+func (o *queryStringCondition) conjunctions() ([][]*queryStringCondition, error) {
+	return [][]*queryStringCondition{{o}}, nil
+}
+
+// extensionName returns the name of the extension if the condition's field
+// refers to one (i.e. has the "ext." prefix). Extension names are
+// case-sensitive, so are not normalized.
+func (o *queryStringCondition) extensionName() (string, bool) {
+	if len(o.field.text) < 4 || !strings.EqualFold(o.field.text[:4], "ext.") {
+		return "", false
+	}
+
+	return o.field.text[4:], true
+}
+
+func (o *queryStringCondition) texts() []string {
+	ret := make([]string, len(o.values))
+	for i, val := range o.values {
+		ret[i] = val.text
+	}
+
+	return ret
+}
+
+func (o *queryStringCondition) ints() ([]int64, error) {
+	ret := make([]int64, len(o.values))
+	for i, val := range o.values {
+		var err error
+		if ret[i], err = val.toInt(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringCondition) fieldError(msg string) error {
+	return o.field.error(msg)
+}
+
+func (o *queryStringCondition) opError() error {
+	return o.opTok.error(fmt.Sprintf("operator %q is not supported for %q", o.op, o.field.text))
+}
+
+type queryStringTokenKind int
+
+const (
+	queryStringEOF queryStringTokenKind = iota
+	queryStringWord
+	queryStringQuoted
+	queryStringOperator
+	queryStringLParen
+	queryStringRParen
+	queryStringComma
+)
+
+type queryStringToken struct {
+	kind queryStringTokenKind
+	text string
+	// column is the one-based position of the token in the query string.
+	column int
+}
+
+func (o *queryStringToken) String() string {
+	switch o.kind {
+	case queryStringEOF:
+		return "end of query"
+	case queryStringQuoted:
+		return strconv.Quote(o.text)
+	default:
+		return fmt.Sprintf("%q", o.text)
+	}
+}
+
+// isKeyword returns true if the token is the specified keyword. Keywords are
+// case-insensitive, and quoting a keyword turns it into an ordinary value.
+func (o *queryStringToken) isKeyword(keyword string) bool {
+	return o.kind == queryStringWord && strings.EqualFold(o.text, keyword)
+}
+
+func (o *queryStringToken) isAnyKeyword() bool {
+	return o.isKeyword("and") || o.isKeyword("or") || o.isKeyword("in")
+}
+
+func (o *queryStringToken) error(msg string) error {
+	return &QueryStringError{Column: o.column, Message: msg}
+}
+
+func (o *queryStringToken) toInt() (int64, error) {
+	ret, err := strconv.ParseInt(o.text, 0, 64)
+	if err != nil {
+		return 0, o.error(fmt.Sprintf("expected an integer, found %s", o))
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringToken) toUint() (uint64, error) {
+	ret, err := strconv.ParseUint(o.text, 0, 64)
+	if err != nil {
+		return 0, o.error(fmt.Sprintf("expected an unsigned integer, found %s", o))
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringToken) toBool() (bool, error) {
+	ret, err := strconv.ParseBool(o.text)
+	if err != nil {
+		return false, o.error(fmt.Sprintf("expected true or false, found %s", o))
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringToken) toTime() (time.Time, error) {
+	if ret, err := time.Parse(time.RFC3339, o.text); err == nil {
+		return ret, nil
+	}
+
+	if ret, err := time.ParseInLocation(time.DateOnly, o.text, time.Local); err == nil {
+		return ret, nil
+	}
+
+	return time.Time{}, o.error(fmt.Sprintf(
+		"expected an RFC3339 timestamp or a YYYY-MM-DD date, found %s", o))
+}
+
+// queryStringParser is a recursive descent parser for the following grammar:
+//
+//	query      = [ or-expr ]
+//	or-expr    = and-expr { "or" and-expr }
+//	and-expr   = primary { "and" primary }
+//	primary    = condition | "(" or-expr ")"
+//	condition  = field operator value | field "in" "(" value { "," value } ")"
+//	operator   = "=" | "==" | "<" | "<=" | ">" | ">="
+type queryStringParser struct {
+	text   string
+	offset int
+	tok    *queryStringToken
+	err    error
+}
+
+func newQueryStringParser(text string) *queryStringParser {
+	ret := &queryStringParser{text: text}
+	ret.next()
+	return ret
+}
+
+// Parse returns the parsed expression, or nil if the query string is empty.
+func (o *queryStringParser) Parse() (queryStringExpr, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	if o.tok.kind == queryStringEOF {
+		return nil, nil
+	}
+
+	expr, err := o.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.tok.kind != queryStringEOF {
+		return nil, o.tok.error(fmt.Sprintf(`expected "and", "or", or end of query, found %s`, o.tok))
+	}
+
+	return expr, nil
+}
+
+func (o *queryStringParser) parseOr() (queryStringExpr, error) {
+	var ret queryStringOr
+
+	for {
+		expr, err := o.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, expr)
+
+		if !o.tok.isKeyword("or") {
+			break
+		}
+
+		if err := o.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ret) == 1 {
+		return ret[0], nil
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringParser) parseAnd() (queryStringExpr, error) {
+	var ret queryStringAnd
+
+	for {
+		expr, err := o.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, expr)
+
+		if !o.tok.isKeyword("and") {
+			break
+		}
+
+		if err := o.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ret) == 1 {
+		return ret[0], nil
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringParser) parsePrimary() (queryStringExpr, error) {
+	if o.tok.kind != queryStringLParen {
+		return o.parseCondition()
+	}
+
+	if err := o.next(); err != nil {
+		return nil, err
+	}
+
+	expr, err := o.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.tok.kind != queryStringRParen {
+		return nil, o.tok.error(fmt.Sprintf(`expected ")", found %s`, o.tok))
+	}
+
+	return expr, o.next()
+}
+
+func (o *queryStringParser) parseCondition() (queryStringExpr, error) {
+	if o.tok.kind != queryStringWord || o.tok.isAnyKeyword() {
+		return nil, o.tok.error(fmt.Sprintf("expected a field name, found %s", o.tok))
+	}
+
+	ret := &queryStringCondition{field: o.tok}
+	if err := o.next(); err != nil {
+		return nil, err
+	}
+
+	ret.opTok = o.tok
+
+	switch {
+	case o.tok.kind == queryStringOperator:
+		ret.op = o.tok.text
+		if ret.op == "==" {
+			ret.op = "="
+		} else if ret.op == "!=" {
+			return nil, o.tok.error(`operator "!=" is not supported`)
+		}
+
+		if err := o.next(); err != nil {
+			return nil, err
+		}
+
+		val, err := o.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		ret.values = []*queryStringToken{val}
+	case o.tok.isKeyword("in"):
+		ret.op = "in"
+
+		if err := o.next(); err != nil {
+			return nil, err
+		}
+
+		if o.tok.kind != queryStringLParen {
+			return nil, o.tok.error(fmt.Sprintf(`expected "(" after "in", found %s`, o.tok))
+		}
+
+		for {
+			if err := o.next(); err != nil {
+				return nil, err
+			}
+
+			val, err := o.parseValue()
+			if err != nil {
+				return nil, err
+			}
+
+			ret.values = append(ret.values, val)
+
+			if o.tok.kind != queryStringComma {
+				break
+			}
+		}
+
+		if o.tok.kind != queryStringRParen {
+			return nil, o.tok.error(fmt.Sprintf(`expected "," or ")", found %s`, o.tok))
+		}
+
+		if err := o.next(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, o.tok.error(fmt.Sprintf("expected an operator after %s, found %s", ret.field, o.tok))
+	}
+
+	return ret, nil
+}
+
+func (o *queryStringParser) parseValue() (*queryStringToken, error) {
+	if (o.tok.kind != queryStringWord && o.tok.kind != queryStringQuoted) || o.tok.isAnyKeyword() {
+		return nil, o.tok.error(fmt.Sprintf("expected a value, found %s", o.tok))
+	}
+
+	ret := o.tok
+	return ret, o.next()
+}
+
+// next advances to the next token in the query string.
+func (o *queryStringParser) next() error {
+	if o.err != nil {
+		return o.err
+	}
+
+	for o.offset < len(o.text) && isQueryStringSpace(o.text[o.offset]) {
+		o.offset++
+	}
+
+	start := o.offset
+	tok := &queryStringToken{column: start + 1}
+	o.tok = tok
+
+	if start >= len(o.text) {
+		tok.kind = queryStringEOF
+		return nil
+	}
+
+	c := o.text[start]
+	switch {
+	case c == '(':
+		tok.kind = queryStringLParen
+		o.offset++
+	case c == ')':
+		tok.kind = queryStringRParen
+		o.offset++
+	case c == ',':
+		tok.kind = queryStringComma
+		o.offset++
+	case strings.IndexByte("=<>!", c) != -1:
+		tok.kind = queryStringOperator
+		o.offset++
+		if o.offset < len(o.text) && o.text[o.offset] == '=' {
+			o.offset++
+		} else if c == '!' {
+			o.err = tok.error(`unexpected "!" (did you mean "!="?)`)
+			return o.err
+		}
+	case c == '"' || c == '\'':
+		tok.kind = queryStringQuoted
+		if err := o.scanQuoted(tok, c); err != nil {
+			o.err = err
+			return err
+		}
+		return nil
+	default:
+		tok.kind = queryStringWord
+		for o.offset < len(o.text) && isQueryStringWordChar(o.text[o.offset]) {
+			o.offset++
+		}
+	}
+
+	tok.text = o.text[start:o.offset]
+
+	return nil
+}
+
+func (o *queryStringParser) scanQuoted(tok *queryStringToken, quote byte) error {
+	var builder strings.Builder
+
+	o.offset++ // opening quote
+	for o.offset < len(o.text) {
+		c := o.text[o.offset]
+		o.offset++
+
+		switch c {
+		case quote:
+			tok.text = builder.String()
+			return nil
+		case '\\':
+			if o.offset >= len(o.text) {
+				return tok.error("unterminated string")
+			}
+			builder.WriteByte(o.text[o.offset])
+			o.offset++
+		default:
+			builder.WriteByte(c)
+		}
+	}
+
+	return tok.error("unterminated string")
+}
+
+func isQueryStringSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isQueryStringWordChar(c byte) bool {
+	return !isQueryStringSpace(c) && strings.IndexByte(`(),=<>!"'`, c) == -1
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+func TestParseValueTripleQuery(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"manifests.yaml":          manifestsFixture,
+		"module_tags.yaml":        moduleTagsFixture,
+		"triples.yaml":            triplesFixture,
+		"environments.yaml":       environmentsFixture,
+		"measurements.yaml":       measurementsFixture,
+		"measurement_values.yaml": measurementValuesFixture,
+		"extensions.yaml":         extensionsFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	testCases := []struct {
+		text     string
+		expected []int64
+	}{
+		{"", []int64{1, 2}},
+		{`label in ("baz", "qux")`, []int64{1, 2}},
+		{"vendor = foo", []int64{1}},
+		{"VENDOR == foo AND model = bar", []int64{1}},
+		{"svn >= 42", []int64{2}},
+		{"svn in (1, 42)", []int64{2}},
+		{"svn > 41 and svn < 43", []int64{2}},
+		{"vendor = foo or svn = 42", []int64{1, 2}},
+		{"(label = baz or label = qux) and active = true", []int64{1}},
+		{"type = endorsement and module_tag_id = bar", []int64{2}},
+		{"ext.vendor = ACME", []int64{1}},
+		{"vendor = foo and svn >= 3", nil},
+		{"svn > 42", nil},
+		{`label = "and"`, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			query, err := ParseValueTripleQuery(tc.text)
+			require.NoError(t, err)
+
+			result, err := query.Run(ctx, db)
+			if tc.expected == nil {
+				assert.ErrorIs(t, err, ErrNoMatch)
+				return
+			}
+			require.NoError(t, err)
+
+			ids := make([]int64, len(result))
+			for i, entry := range result {
+				ids[i] = entry.TripleDbID
+			}
+			assert.ElementsMatch(t, tc.expected, ids)
+		})
+	}
+
+	query, err := ParseValueTripleQuery("vendor = foo")
+	require.NoError(t, err)
+	assert.IsType(t, &ValueTripleQuery{}, query)
+
+	query, err = ParseValueTripleQuery("vendor = foo or vendor = baz")
+	require.NoError(t, err)
+	assert.IsType(t, &ValueTripleQueryGroup{}, query)
+}
+
+func TestParseQueryString_errors(t *testing.T) {
+	testCases := []struct {
+		text   string
+		column int
+		msg    string
+	}{
+		{"vendor foo", 8, `expected an operator after "vendor", found "foo"`},
+		{"vendor = ", 10, "expected a value, found end of query"},
+		{"(vendor = foo", 14, `expected ")", found end of query`},
+		{`label = "foo`, 9, "unterminated string"},
+		{"bogus = 1", 1, `unknown field "bogus"`},
+		{"added = 2026-01-01", 7, `operator "=" is not supported for "added"`},
+		{"added > yesterday", 9, "expected an RFC3339 timestamp or a YYYY-MM-DD date"},
+		{"label = a and label in (b)", 15, `"label" specified more than once`},
+		{"vendor != foo", 8, `operator "!=" is not supported`},
+		{"vendor ! foo", 8, `unexpected "!"`},
+		{"id = abc", 6, `expected an integer, found "abc"`},
+		{"label in (a, b", 15, `expected "," or ")"`},
+		{"label in a", 10, `expected "(" after "in"`},
+		{"vendor = foo bar", 14, `expected "and", "or", or end of query, found "bar"`},
+		{"and = foo", 1, `expected a field name, found "and"`},
+		{"type = foo", 8, `invalid triple type "foo"`},
+		{"active = maybe", 10, "expected true or false"},
+		{"svn >= 1 and svn >= 2", 14, `"svn" specified more than once`},
+		{"ext. = foo", 1, "extension name not specified"},
+		{"ext.foo > 1", 9, `operator ">" is not supported for "ext.foo"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			_, err := ParseValueTripleQuery(tc.text)
+			assert.ErrorIs(t, err, ErrQueryString)
+
+			var qsErr *QueryStringError
+			require.ErrorAs(t, err, &qsErr)
+			assert.Equal(t, tc.column, qsErr.Column)
+			assert.Contains(t, qsErr.Message, tc.msg)
+		})
+	}
+
+	text := strings.Repeat("(label = a or label = b) and ", 6) + "(label = a or label = b)"
+	_, err := ParseValueTripleQuery(text)
+	assert.ErrorIs(t, err, ErrQueryString)
+	assert.ErrorContains(t, err, "expands into more than 64 queries")
+
+	_, err = ParseKeyTripleQuery("svn = 1")
+	assert.ErrorContains(t, err, `unknown field "svn"`)
+}
+
+func TestStore_QueryString(t *testing.T) {
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"manifests.yaml":   manifestsFixture,
+		"module_tags.yaml": moduleTagsFixture,
+		"triples.yaml":     triplesFixture,
+		"extensions.yaml":  extensionsFixture,
+	})
+	store, err := OpenWithDB(context.Background(), db)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	result, err := store.QueryString(ManifestTarget, "added > 2026-02-01 and ext.level = 3")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "foo", result[0].(*model.ManifestEntry).ManifestID)
+
+	result, err = store.QueryString(ModuleTagTarget, "version in (1, 42) and language = en_US")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "bar", result[0].(*model.ModuleTagEntry).ModuleTagID)
+
+	result, err = store.QueryString(KeyTripleTarget, "type = attest or label = qux")
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	_, err = store.QueryString(ValueTripleTarget, "label = nope")
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.QueryString(ManifestTarget, "label =")
+	assert.ErrorIs(t, err, ErrQueryString)
+
+	_, err = store.QueryString("bogus", "")
+	assert.ErrorContains(t, err, "unsupported query string target")
+}
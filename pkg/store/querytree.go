@@ -0,0 +1,554 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+type boolOperator int
+
+const (
+	andOperator boolOperator = iota
+	orOperator
+	notOperator
+)
+
+// QueryTree combines queries of the same type into a boolean expression. Its
+// operands may be individual queries, query groups, or other QueryTree's,
+// allowing arbitrary nesting of AND, OR, and NOT expressions. Unlike
+// QueryGroup, which runs its members individually and combines their results,
+// a QueryTree is compiled into a single SQL statement, with the sub-queries of
+// its operands (e.g. the environment of a triple query) expressed as nested
+// SELECTs. For example, to select active reference values for vendor "foo",
+// except those for model "bar" or at layer 0:
+//
+//	query := And(
+//		NewValueTripleQuery().
+//			IsActive(true).
+//			TripleType(model.ReferenceValueTriple).
+//			Vendor("foo"),
+//		Not(Or(
+//			NewValueTripleQuery().Model("bar"),
+//			NewValueTripleQuery().Layer(0),
+//		)),
+//	)
+type QueryTree[T model.Model] struct {
+	operator boolOperator
+	operands []Query[T]
+	tenant   *string
+}
+
+// And returns a QueryTree matching entries that are matched by all of the
+// operands. If there are no operands, all entries are matched.
+func And[T model.Model](operands ...Query[T]) *QueryTree[T] {
+	return &QueryTree[T]{operator: andOperator, operands: operands}
+}
+
+// Or returns a QueryTree matching entries that are matched by at least one of
+// the operands. If there are no operands, no entries are matched.
+func Or[T model.Model](operands ...Query[T]) *QueryTree[T] {
+	return &QueryTree[T]{operator: orOperator, operands: operands}
+}
+
+// Not returns a QueryTree matching entries that are not matched by the
+// operand.
+func Not[T model.Model](operand Query[T]) *QueryTree[T] {
+	return &QueryTree[T]{operator: notOperator, operands: []Query[T]{operand}}
+}
+
+// Add appends operands to an AND or OR tree. An error is returned for NOT
+// trees, as those have exactly one operand.
+func (o *QueryTree[T]) Add(operands ...Query[T]) error {
+	if o.operator == notOperator {
+		return fmt.Errorf("cannot add operands to a NOT query")
+	}
+
+	o.operands = append(o.operands, operands...)
+	return nil
+}
+
+// UpdateSelectQuery updates the bun.SelectQuery with the boolean combination
+// of its operands' parameters. As with other queries, this does not include
+// the operands' sub-queries; those are only included by Run.
+func (o *QueryTree[T]) UpdateSelectQuery(query *bun.SelectQuery, dialect schema.Dialect) {
+	_ = o.update(query, dialect, func(operand Query[T], q *bun.SelectQuery) error {
+		operand.UpdateSelectQuery(q, dialect)
+		return nil
+	})
+}
+
+// Run compiles the tree, along with the sub-queries of its operands, into a
+// single statement and runs it against the provided database. An error is
+// returned if an operand cannot be compiled in this way.
+func (o *QueryTree[T]) Run(ctx context.Context, db bun.IDB) ([]T, error) {
+	var ret []T
+	bunQuery := db.NewSelect().Model(&ret)
+
+	if err := o.updateSelectQueryInline(bunQuery, db.Dialect()); err != nil {
+		return nil, err
+	}
+
+	return scanQuery(ctx, bunQuery, &ret)
+}
+
+// IsEmpty returns true if the tree matches all entries, i.e. if it is an AND
+// of empty queries, or an OR that has at least one empty operand.
+func (o *QueryTree[T]) IsEmpty() bool {
+	if o.tenant != nil {
+		return false
+	}
+
+	switch o.operator {
+	case andOperator:
+		for _, operand := range o.operands {
+			if !operand.IsEmpty() {
+				return false
+			}
+		}
+
+		return true
+	case orOperator:
+		for _, operand := range o.operands {
+			if operand.IsEmpty() {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return false
+	}
+}
+
+func (o *QueryTree[T]) restrictToTenant(name string) error {
+	for _, operand := range o.operands {
+		if err := restrictToTenant(operand, name); err != nil {
+			return err
+		}
+	}
+
+	// The operands' restrictions are not sufficient on their own, as they
+	// would be inverted by NOT.
+	o.tenant = &name
+	return nil
+}
+
+func (o *QueryTree[T]) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	return o.update(query, dialect, func(operand Query[T], q *bun.SelectQuery) error {
+		return updateSelectQueryInline(operand, q, dialect)
+	})
+}
+
+func (o *QueryTree[T]) update(
+	query *bun.SelectQuery,
+	dialect schema.Dialect,
+	updateOperand func(Query[T], *bun.SelectQuery) error,
+) error {
+	var err error
+
+	// Each operand's parameters are placed inside their own group. As bun
+	// omits empty groups, OR operands start with a tautology, so that an
+	// empty operand matches everything (rather than being dropped from the
+	// disjunction).
+	addOperand := func(q *bun.SelectQuery, operand Query[T], sep string, guard bool) {
+		q.WhereGroup(sep, func(q *bun.SelectQuery) *bun.SelectQuery {
+			if guard {
+				q.Where("1 = 1")
+			}
+
+			if opErr := updateOperand(operand, q); opErr != nil && err == nil {
+				err = opErr
+			}
+
+			return q
+		})
+	}
+
+	switch o.operator {
+	case andOperator:
+		for _, operand := range o.operands {
+			addOperand(query, operand, " AND ", false)
+		}
+	case orOperator:
+		if len(o.operands) == 0 {
+			query.Where("1 = 0")
+			break
+		}
+
+		query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, operand := range o.operands {
+				addOperand(q, operand, " OR ", true)
+			}
+
+			return q
+		})
+	case notOperator:
+		// Negating the operand's conditions directly would not match
+		// entries for which they evaluate to NULL (e.g. due to a NULL
+		// label), so instead exclude the IDs of the entries it matches.
+		column, colErr := dbIDColumn[T]()
+		if colErr != nil {
+			return colErr
+		}
+
+		var zero T
+		nested := query.NewSelect().Model(zero).Column(column)
+		if opErr := updateOperand(o.operands[0], nested); opErr != nil {
+			return opErr
+		}
+
+		query.Where(fmt.Sprintf("%s NOT IN (?)", identQuote(column, dialect)), nested)
+	default:
+		return fmt.Errorf("unexpected boolean operator: %d", o.operator)
+	}
+
+	if o.tenant != nil {
+		var zero T
+
+		switch any(zero).(type) {
+		case *model.Environment:
+			addTenantEnvironmentClause(query, dialect, *o.tenant)
+		case *model.Entity:
+			addTenantEntityClause(query, dialect, *o.tenant)
+		default:
+			query.Where(fmt.Sprintf("%s = ?", identQuote("label", dialect)), *o.tenant)
+		}
+	}
+
+	return err
+}
+
+// dbIDColumn returns the column containing the values returned by DbID() for
+// models of type M.
+func dbIDColumn[M model.Model]() (string, error) {
+	var zero M
+
+	if zero.IsTable() {
+		return "id", nil
+	}
+
+	switch any(zero).(type) {
+	case *model.ManifestEntry:
+		return "manifest_db_id", nil
+	case *model.ModuleTagEntry:
+		return "module_tag_db_id", nil
+	case *model.KeyTripleEntry, *model.ValueTripleEntry,
+		*model.ConditionalEndorsementTripleEntry, *model.ConditionalEndorsementSeriesTripleEntry,
+		*model.DomainDependencyTripleEntry, *model.DomainMembershipTripleEntry:
+		return "triple_db_id", nil
+	default:
+		return "", fmt.Errorf("cannot negate queries for %T", zero)
+	}
+}
+
+type ValueTripleQueryTree = QueryTree[*model.ValueTripleEntry]
+
+type KeyTripleQueryTree = QueryTree[*model.KeyTripleEntry]
+
+type ManifestQueryTree = QueryTree[*model.ManifestEntry]
+
+type ModuleTagQueryTree = QueryTree[*model.ModuleTagEntry]
+
+type EnvironmentQueryTree = QueryTree[*model.Environment]
+
+// inlineQuery is implemented by queries that are able to express their
+// sub-queries as nested SELECTs, so that they can be compiled into a single
+// statement along with their parameters.
+type inlineQuery interface {
+	updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error
+}
+
+func updateSelectQueryInline(query any, bunQuery *bun.SelectQuery, dialect schema.Dialect) error {
+	inliner, ok := query.(inlineQuery)
+	if !ok {
+		return fmt.Errorf("%T cannot be compiled into a single statement", query)
+	}
+
+	return inliner.updateSelectQueryInline(bunQuery, dialect)
+}
+
+// addInlineSubquery adds a "<column> IN (SELECT <subColumn> ...)" condition to
+// the query, with the nested SELECT constructed from the sub-query. If
+// ownerType is not empty, the nested SELECT is also restricted to that owner
+// type. Empty sub-queries are ignored.
+func addInlineSubquery[M model.Model](
+	query *bun.SelectQuery,
+	dialect schema.Dialect,
+	column string,
+	sub Query[M],
+	subColumn string,
+	ownerType string,
+) error {
+	if sub.IsEmpty() {
+		return nil
+	}
+
+	var zero M
+	nested := query.NewSelect().Model(zero).Column(subColumn)
+
+	if ownerType != "" {
+		nested.Where(fmt.Sprintf("%s = ?", identQuote("owner_type", dialect)), ownerType)
+	}
+
+	if err := updateSelectQueryInline(sub, nested, dialect); err != nil {
+		return err
+	}
+
+	query.Where(fmt.Sprintf("%s IN (?)", identQuote(column, dialect)), nested)
+
+	return nil
+}
+
+// addInlineOwnerConjunction is the equivalent of
+// OwnedModelQueryGroup.RunOwnerConjunction for compiled queries: column must
+// be in the owner IDs matched by every member of the group.
+func addInlineOwnerConjunction[M model.OwnedModel, Q Query[M]](
+	query *bun.SelectQuery,
+	dialect schema.Dialect,
+	column string,
+	group *OwnedModelQueryGroup[M, Q],
+) error {
+	for _, sub := range group.subqueries {
+		var zero M
+		nested := query.NewSelect().
+			Model(zero).
+			Column("owner_id").
+			Where(fmt.Sprintf("%s = ?", identQuote("owner_type", dialect)), group.OwnerName)
+
+		if err := updateSelectQueryInline(sub, nested, dialect); err != nil {
+			return err
+		}
+
+		query.Where(fmt.Sprintf("%s IN (?)", identQuote(column, dialect)), nested)
+	}
+
+	return nil
+}
+
+func (o *QueryGroup[M, Q]) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	return Or(o.operands()...).updateSelectQueryInline(query, dialect)
+}
+
+func (o *QueryGroup[M, Q]) operands() []Query[M] {
+	ret := make([]Query[M], len(o.subqueries))
+	for i, sub := range o.subqueries {
+		ret[i] = sub
+	}
+
+	return ret
+}
+
+func (o *TripleQuery[T, TT]) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "environment_db_id",
+		o.EnvironmentSubquery(), "id", ""); err != nil {
+		return fmt.Errorf("environment: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "triple_db_id",
+		o.CryptoKeysSubquery(), "owner_id", "key_triple"); err != nil {
+		return fmt.Errorf("crypto keys: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "triple_db_id",
+		o.AuthorizedBySubquery(), "owner_id", "key_triple_auth"); err != nil {
+		return fmt.Errorf("auth by: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "manifest_db_id",
+		o.ManifestEntitiesSubquery(), "owner_id", "manifest"); err != nil {
+		return fmt.Errorf("manifest entities: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "module_tag_db_id",
+		o.ModuleTagEntitiesSubquery(), "owner_id", "module_tag"); err != nil {
+		return fmt.Errorf("module tag entities: %w", err)
+	}
+
+	if err := addInlineOwnerConjunction(query, dialect, "triple_db_id", o.MeasurementGroup()); err != nil {
+		return fmt.Errorf("measurements: %w", err)
+	}
+
+	if err := addInlineOwnerConjunction(query, dialect, "module_tag_db_id", o.ExtensionGroup()); err != nil {
+		return fmt.Errorf("extensions: %w", err)
+	}
+
+	return nil
+}
+
+func (o *ManifestQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "manifest_db_id",
+		o.EntitiesSubquery(), "owner_id", "manifest"); err != nil {
+		return fmt.Errorf("entities: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "manifest_db_id",
+		o.DependentRIMsSubquery(), "manifest_id", ""); err != nil {
+		return fmt.Errorf("dependent RIMs: %w", err)
+	}
+
+	if err := addInlineOwnerConjunction(query, dialect, "manifest_db_id", o.ExtensionGroup()); err != nil {
+		return fmt.Errorf("extensions: %w", err)
+	}
+
+	return nil
+}
+
+func (o *ModuleTagQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "module_tag_db_id",
+		o.EntitiesSubquery(), "owner_id", "module_tag"); err != nil {
+		return fmt.Errorf("entities: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "module_tag_db_id",
+		o.LinkedTagsSubquery(), "module_id", ""); err != nil {
+		return fmt.Errorf("linked tags: %w", err)
+	}
+
+	if err := addInlineOwnerConjunction(query, dialect, "module_tag_db_id", o.ExtensionGroup()); err != nil {
+		return fmt.Errorf("extensions: %w", err)
+	}
+
+	return nil
+}
+
+func (o *MeasurementQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	// Partially masked raw values are compared after they have been
+	// selected (see runRawValueMatches), which cannot be done inside a
+	// nested SELECT.
+	if len(o.rawValues) != 0 {
+		return errors.New("raw value matches cannot be compiled into a single statement")
+	}
+
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.AuthorizedBySubquery(), "owner_id", "measurement"); err != nil {
+		return fmt.Errorf("auth_by: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.DigestsSubquery(), "owner_id", "measurement"); err != nil {
+		return fmt.Errorf("digests: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.ValueSubquery(), "measurement_id", ""); err != nil {
+		return fmt.Errorf("mval: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.IntegrityRegistersSubquery(), "measurement_id", ""); err != nil {
+		return fmt.Errorf("integrity registers: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.FlagsSubquery(), "measurement_id", ""); err != nil {
+		return fmt.Errorf("flags: %w", err)
+	}
+
+	if err := addInlineOwnerConjunction(query, dialect, "id", o.ExtensionGroup()); err != nil {
+		return fmt.Errorf("extensions: %w", err)
+	}
+
+	return nil
+}
+
+func (o *IntegrityRegisterQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.DigestsSubquery(), "owner_id", "integrity_register"); err != nil {
+		return fmt.Errorf("digests: %w", err)
+	}
+
+	return nil
+}
+
+func (o *LocatorQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.DigestsSubquery(), "owner_id", "locator"); err != nil {
+		return fmt.Errorf("digests: %w", err)
+	}
+
+	if err := addInlineSubquery(query, dialect, "id",
+		o.HrefSubquery(), "locator_id", ""); err != nil {
+		return fmt.Errorf("href: %w", err)
+	}
+
+	return nil
+}
+
+func (o *EntityQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+
+	if len(o.roles) != 0 {
+		roles := query.NewSelect().
+			Table("roles").
+			Column("entity_id").
+			Where(fmt.Sprintf("%s IN (?)", identQuote("role", dialect)), bun.In(o.roles))
+
+		query.Where(fmt.Sprintf("%s IN (?)", identQuote("id", dialect)), roles)
+	}
+
+	return nil
+}
+
+// The following queries do not have sub-queries, so their parameters are
+// already complete.
+
+func (o *EnvironmentQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *CryptoKeyQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	if o.err != nil {
+		return o.err
+	}
+
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *DigestQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *ExtensionQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *FlagQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *MeasurementValueQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *LinkedTagQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
+
+func (o *HrefQuery) updateSelectQueryInline(query *bun.SelectQuery, dialect schema.Dialect) error {
+	o.UpdateSelectQuery(query, dialect)
+	return nil
+}
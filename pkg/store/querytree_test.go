@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+func TestQueryTree_ValueTriples(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"manifests.yaml":          manifestsFixture,
+		"module_tags.yaml":        moduleTagsFixture,
+		"triples.yaml":            triplesFixture,
+		"environments.yaml":       environmentsFixture,
+		"measurements.yaml":       measurementsFixture,
+		"measurement_values.yaml": measurementValuesFixture,
+		"extensions.yaml":         extensionsFixture,
+		"entities.yaml":           entitiesFixture,
+		"roles.yaml":              rolesFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	testCases := []struct {
+		title    string
+		query    *ValueTripleQueryTree
+		expected []int64
+	}{
+		{
+			title: "and of environment and triple parameters",
+			query: And(
+				NewValueTripleQuery().IsActive(true).TripleType(model.ReferenceValueTriple),
+				NewValueTripleQuery().Vendor("foo"),
+			),
+			expected: []int64{1},
+		},
+		{
+			title: "except model",
+			query: And(
+				NewValueTripleQuery().Vendor("foo"),
+				Not(Or(
+					NewValueTripleQuery().Model("bar"),
+					NewValueTripleQuery().Layer(0),
+				)),
+			),
+			expected: nil,
+		},
+		{
+			title:    "not environment with NULL vendor",
+			query:    Not(NewValueTripleQuery().Vendor("foo")),
+			expected: []int64{2},
+		},
+		{
+			title: "or across sub-queries",
+			query: Or(
+				NewValueTripleQuery().Vendor("foo"),
+				NewValueTripleQuery().Measurement(func(mq *MeasurementQuery) {
+					mq.MVal(func(vq *MeasurementValueQuery) {
+						vq.CodePoint(model.MvalSvn).ValueInt(42)
+					})
+				}),
+			),
+			expected: []int64{1, 2},
+		},
+		{
+			title: "nested",
+			query: Or(
+				And(
+					NewValueTripleQuery().Label("qux"),
+					Not(NewValueTripleQuery().IsActive(true)),
+				),
+				NewValueTripleQuery().Extension(func(eq *ExtensionQuery) {
+					eq.Name("vendor").Value("ACME")
+				}),
+			),
+			expected: []int64{1, 2},
+		},
+		{
+			title: "group operand",
+			query: And(
+				NewValueTripleQueryGroup().Add(
+					NewValueTripleQuery().Label("baz"),
+					NewValueTripleQuery().Label("qux"),
+				),
+				Not(NewValueTripleQuery().ModuleTagID(model.StringTagID, "foo")),
+			),
+			expected: []int64{2},
+		},
+		{
+			title: "entities",
+			query: Or(
+				NewValueTripleQuery().ModuleTagEntity(func(eq *EntityQuery) {
+					eq.NameValue("qux").Role("tagCreator")
+				}),
+				NewValueTripleQuery().ManifestEntity(func(eq *EntityQuery) {
+					eq.NameValue("baz")
+				}),
+			),
+			expected: []int64{1, 2},
+		},
+		{
+			title: "not entity",
+			query: Not(NewValueTripleQuery().ManifestEntity(func(eq *EntityQuery) {
+				eq.Role("manifestSigner")
+			})),
+			expected: []int64{2},
+		},
+		{
+			title:    "empty and",
+			query:    And[*model.ValueTripleEntry](),
+			expected: []int64{1, 2},
+		},
+		{
+			title:    "empty or",
+			query:    Or[*model.ValueTripleEntry](),
+			expected: nil,
+		},
+		{
+			title:    "or with empty operand",
+			query:    Or(NewValueTripleQuery().Label("nope"), NewValueTripleQuery()),
+			expected: []int64{1, 2},
+		},
+		{
+			title:    "not empty",
+			query:    Not(NewValueTripleQuery()),
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			result, err := tc.query.Run(ctx, db)
+			if tc.expected == nil {
+				assert.ErrorIs(t, err, ErrNoMatch)
+				return
+			}
+			require.NoError(t, err)
+
+			ids := make([]int64, len(result))
+			for i, entry := range result {
+				ids[i] = entry.TripleDbID
+			}
+			assert.ElementsMatch(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestQueryTree_Environments(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"environments.yaml": environmentsFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	query := And(
+		Or(NewEnvironmentQuery(false).Vendor("baz"), NewEnvironmentQuery(false).Model("bar")),
+		Not(NewEnvironmentQuery(false).Layer(3)),
+	)
+
+	envs, err := query.Run(ctx, db)
+	require.NoError(t, err)
+
+	ids := make([]int64, len(envs))
+	for i, env := range envs {
+		ids[i] = env.ID
+	}
+	assert.ElementsMatch(t, []int64{1, 3}, ids)
+
+	assert.False(t, query.IsEmpty())
+	assert.True(t, And(NewEnvironmentQuery(false)).IsEmpty())
+	assert.True(t, Or(NewEnvironmentQuery(false).Layer(1), NewEnvironmentQuery(false)).IsEmpty())
+	assert.False(t, Not(NewEnvironmentQuery(false)).IsEmpty())
+
+	assert.NoError(t, query.Add(NewEnvironmentQuery(false).Index(0)))
+	envs, err = query.Run(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, envs, 1)
+	assert.Equal(t, int64(1), envs[0].ID)
+
+	assert.ErrorContains(t, Not(query).Add(query), "cannot add operands to a NOT query")
+}
+
+func TestQueryTree_raw_value_matches(t *testing.T) {
+	ctx := context.Background()
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"triples.yaml":      triplesFixture,
+		"environments.yaml": environmentsFixture,
+	})
+	defer func() { assert.NoError(t, db.Close()) }()
+
+	raw := func(mq *MeasurementQuery) {
+		mq.RawValueMatches([]byte{0x01, 0x02})
+	}
+
+	_, err := Or(NewValueTripleQuery().Measurement(raw)).Run(ctx, db)
+	assert.ErrorContains(t, err, "raw value matches cannot be compiled into a single statement")
+
+	_, err = Not(NewValueTripleQuery().Vendor("foo").Measurement(raw)).Run(ctx, db)
+	assert.ErrorContains(t, err, "raw value matches cannot be compiled into a single statement")
+}
+
+func TestQueryTree_Store(t *testing.T) {
+	db := model.NewTestDBWithFixtures(t, map[string][]byte{
+		"manifests.yaml": manifestsFixture,
+	})
+	store, err := OpenWithDB(context.Background(), db)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	query := Not(NewManifestQuery().ManifestDbID(2))
+
+	manifests, err := store.QueryManifestEntries(query)
+	require.NoError(t, err)
+	assert.Len(t, manifests, 2)
+
+	qux, err := store.ForTenant("qux")
+	require.NoError(t, err)
+
+	manifests, err = qux.QueryManifestEntries(query)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, int64(3), manifests[0].ManifestDbID)
+
+	_, err = qux.QueryManifestEntries(Not(NewManifestQuery().Label("baz")))
+	assert.ErrorIs(t, err, ErrTenantScope)
+
+	_, err = qux.QueryEnvironmentModels(Not(NewEnvironmentQuery(false).Vendor("foo")))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+	"github.com/veraison/corim/comid"
+)
+
+// registerDigest is a digest presented in evidence for an integrity register,
+// with its algorithm resolved to its canonical ID (see
+// util.CanonicalDigestAlgID).
+type registerDigest struct {
+	algID int64
+	value []byte
+}
+
+// FindValueTriplesByRegisters finds the value triples with measurements
+// specifying integrity registers (e.g. TPM PCRs or TDX RTMRs) that are all
+// satisfied by the evidence. A stored register is satisfied if the evidence
+// contains the same index, with at least one digest matching one of the
+// register's digests (i.e. the CoRIM "any-of digests" semantics). Digest
+// algorithms are matched regardless of how they were specified (see
+// util.CanonicalDigestAlgID). Evidence registers that are not specified by a
+// triple do not affect whether it matches. Triples without integrity
+// registers are never matched.
+//
+// The query, if not nil, further restricts the triples that are returned. If
+// the store is scoped to a tenant, only the tenant's triples are returned. If
+// no triples match, the returned error wraps ErrNoMatch.
+//
+// Expected register values may be computed from an event log using
+// util.ReplayEventLog.
+func (o *Store) FindValueTriplesByRegisters(
+	evidence *comid.IntegrityRegisters,
+	query Query[*model.ValueTripleEntry],
+) ([]*model.ValueTripleEntry, error) {
+	if evidence == nil || len(evidence.IndexMap) == 0 {
+		return nil, errors.New("evidence registers not specified")
+	}
+
+	if err := o.checkTenant("FindValueTriplesByRegisters"); err != nil {
+		return nil, err
+	}
+
+	registers, err := canonicalRegisters(evidence)
+	if err != nil {
+		return nil, err
+	}
+
+	candidateIDs, err := o.registerCandidateTripleIDs(registers)
+	if err != nil {
+		return nil, err
+	}
+
+	tripleIDs, err := o.satisfiedRegisterTripleIDs(candidateIDs, registers)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tripleIDs) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	var registersQuery Query[*model.ValueTripleEntry] = NewValueTripleQuery().TripleDbID(tripleIDs...)
+	if query != nil {
+		registersQuery = And(query, registersQuery)
+	}
+
+	return o.QueryValueTripleEntries(registersQuery)
+}
+
+// registerCandidateTripleIDs returns the IDs of the value triples containing
+// at least one integrity register with a digest in the evidence.
+func (o *Store) registerCandidateTripleIDs(registers map[any][]registerDigest) ([]int64, error) {
+	digestQuery := NewDigestQuery().OwnerType("integrity_register")
+	numDigests := 0
+	for _, digests := range registers {
+		for _, digest := range digests {
+			digestQuery.DigestIntAlg(digest.algID, digest.value)
+			numDigests++
+		}
+	}
+
+	if numDigests == 0 {
+		return nil, ErrNoMatch
+	}
+
+	digests, err := digestQuery.Run(o.Ctx, o.ReadDB())
+	if err != nil {
+		return nil, err
+	}
+
+	registerIDs := make([]int64, 0, len(digests))
+	for _, digest := range digests {
+		registerIDs = append(registerIDs, digest.OwnerID)
+	}
+
+	var ret []int64
+	err = o.ReadDB().NewSelect().
+		Model((*model.Measurement)(nil)).
+		Column("owner_id").
+		Distinct().
+		Where("owner_type = ?", "value_triple").
+		Where("id IN (?)", o.ReadDB().NewSelect().
+			Model((*model.IntegrityRegister)(nil)).
+			Column("measurement_id").
+			Where("id IN (?)", bun.In(registerIDs))).
+		Scan(o.Ctx, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// satisfiedRegisterTripleIDs returns the IDs of the specified value triples
+// whose integrity registers are all satisfied by the evidence.
+func (o *Store) satisfiedRegisterTripleIDs(
+	tripleIDs []int64,
+	registers map[any][]registerDigest,
+) ([]int64, error) {
+	if len(tripleIDs) == 0 {
+		return nil, nil
+	}
+
+	var measurements []*model.Measurement
+	err := o.ReadDB().NewSelect().
+		Model(&measurements).
+		Column("id", "owner_id").
+		Where("owner_type = ?", "value_triple").
+		Where("owner_id IN (?)", bun.In(tripleIDs)).
+		Scan(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	measurementTriples := make(map[int64]int64, len(measurements))
+	measurementIDs := make([]int64, 0, len(measurements))
+	for _, measurement := range measurements {
+		measurementTriples[measurement.ID] = measurement.OwnerID
+		measurementIDs = append(measurementIDs, measurement.ID)
+	}
+
+	var stored []*model.IntegrityRegister
+	err = o.ReadDB().NewSelect().
+		Model(&stored).
+		Relation("Digests").
+		Where("measurement_id IN (?)", bun.In(measurementIDs)).
+		Scan(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	satisfied := make(map[int64]bool, len(tripleIDs))
+	for _, reg := range stored {
+		tripleID := measurementTriples[reg.MeasurementID]
+
+		ok, err := registerSatisfied(reg, registers)
+		if err != nil {
+			return nil, fmt.Errorf("integrity register %d: %w", reg.ID, err)
+		}
+
+		// all of the triple's registers must be satisfied
+		prev, seen := satisfied[tripleID]
+		satisfied[tripleID] = ok && (prev || !seen)
+	}
+
+	return satisfiedIDs(satisfied), nil
+}
+
+// registerSatisfied returns true if the evidence contains the index of the
+// stored register, with at least one digest matching one of its digests.
+func registerSatisfied(reg *model.IntegrityRegister, registers map[any][]registerDigest) (bool, error) {
+	var index any
+	switch {
+	case reg.IndexText != nil:
+		index = *reg.IndexText
+	case reg.IndexUint != nil:
+		index = *reg.IndexUint
+	default:
+		return false, errors.New("neither index set")
+	}
+
+	for _, digest := range reg.Digests {
+		if digest.CanonicalAlgID == 0 {
+			continue
+		}
+
+		for _, evDigest := range registers[index] {
+			if evDigest.algID == digest.CanonicalAlgID && bytes.Equal(evDigest.value, digest.Value) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// canonicalRegisters returns the evidence registers, keyed by their canonical
+// indices (see util.CanonicalRegisterIndex). Digests with algorithms that are
+// not in the registry are ignored, as they cannot be matched.
+func canonicalRegisters(evidence *comid.IntegrityRegisters) (map[any][]registerDigest, error) {
+	ret := make(map[any][]registerDigest, len(evidence.IndexMap))
+
+	for index, digests := range evidence.IndexMap {
+		canonical, err := util.CanonicalRegisterIndex(index)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, digest := range digests {
+			algID := util.CanonicalDigestAlgID(digest.Algorithm)
+			if algID == 0 {
+				continue
+			}
+
+			ret[canonical] = append(ret[canonical], registerDigest{algID, digest.Value})
+		}
+	}
+
+	return ret, nil
+}
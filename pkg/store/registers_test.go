@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/corim/corim"
+)
+
+func TestStore_FindValueTriplesByRegisters(t *testing.T) {
+	foo := sha256.Sum256([]byte("foo"))
+	bar := sha256.Sum256([]byte("bar"))
+	qux := sha512.Sum384([]byte("qux"))
+
+	pcr0, err := util.ExtendRegister(comid.Sha256, make([]byte, sha256.Size), foo[:])
+	require.NoError(t, err)
+
+	newTriple := func(vendor string, registers map[any]comid.Digests) *comid.ValueTriple {
+		measurement := comid.MustNewUintMeasurement(uint64(1))
+		if registers != nil {
+			measurement.Val.IntegrityRegisters = comid.NewIntegrityRegisters()
+			for index, digests := range registers {
+				require.NoError(t, measurement.Val.IntegrityRegisters.AddDigests(index, digests))
+			}
+		} else {
+			measurement.AddDigest(comid.Sha256, pcr0)
+		}
+
+		var measurements comid.Measurements
+		measurements.Add(measurement)
+
+		return &comid.ValueTriple{
+			Environment:  comid.Environment{Class: comid.NewClassBytes([]byte{0x01}).SetVendor(vendor)},
+			Measurements: measurements,
+		}
+	}
+
+	tag := comid.NewComid().
+		SetTagIdentity("foo", 0).
+		AddReferenceValue(newTriple("ACME", map[any]comid.Digests{
+			uint64(0): {*comid.NewDigestIntAlg(comid.Sha256, pcr0)},
+			uint64(1): {
+				*comid.NewDigestIntAlg(comid.Sha256, bar[:]),
+				*comid.NewDigestIntAlg(comid.Sha384, qux[:]),
+			},
+		})).
+		AddReferenceValue(newTriple("Globex", map[any]comid.Digests{
+			uint64(0): {*comid.NewDigestIntAlg(comid.Sha256, pcr0)},
+			uint64(2): {*comid.NewDigestIntAlg(comid.Sha256, bar[:])},
+		})).
+		AddReferenceValue(newTriple("Initech", map[any]comid.Digests{
+			"rtmr0": {*comid.NewDigestStringAlg("SHA384", qux[:])},
+		})).
+		AddReferenceValue(newTriple("Umbrella", nil))
+
+	manifest := corim.NewUnsignedCorim().SetID("bar").AddComid(tag)
+
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	require.NoError(t, store.AddCoRIM(manifest, []byte{0x01}, "qux", true))
+
+	vendorTripleID := func(vendor string) int64 {
+		entries, err := store.QueryValueTripleEntries(NewValueTripleQuery().Vendor(vendor))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		return entries[0].TripleDbID
+	}
+
+	tripleIDs := func(entries []*model.ValueTripleEntry) []int64 {
+		ret := make([]int64, 0, len(entries))
+		for _, entry := range entries {
+			ret = append(ret, entry.TripleDbID)
+		}
+
+		return ret
+	}
+
+	evidence, err := util.ReplayEventLog(nil, []util.RegisterEvent{
+		{Index: 0, Digest: *comid.NewDigestIntAlg(comid.Sha256, foo[:])},
+	})
+	require.NoError(t, err)
+	require.NoError(t, evidence.AddDigest(uint64(1), *comid.NewDigestStringAlg("sha384", qux[:])))
+	require.NoError(t, evidence.AddDigest("rtmr0", *comid.NewDigestIntAlg(comid.Sha384, qux[:])))
+	require.NoError(t, evidence.AddDigest(uint64(3), *comid.NewDigestIntAlg(comid.Sha256, foo[:])))
+
+	entries, err := store.FindValueTriplesByRegisters(evidence, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{vendorTripleID("ACME"), vendorTripleID("Initech")}, tripleIDs(entries))
+
+	entries, err = store.FindValueTriplesByRegisters(evidence, NewValueTripleQuery().Vendor("Initech"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{vendorTripleID("Initech")}, tripleIDs(entries))
+
+	require.NoError(t, evidence.AddDigest(uint64(2), *comid.NewDigestIntAlg(comid.Sha256, bar[:])))
+
+	entries, err = store.FindValueTriplesByRegisters(evidence, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{
+		vendorTripleID("ACME"),
+		vendorTripleID("Globex"),
+		vendorTripleID("Initech"),
+	}, tripleIDs(entries))
+
+	mismatched := comid.NewIntegrityRegisters()
+	require.NoError(t, mismatched.AddDigest(uint64(0), *comid.NewDigestIntAlg(comid.Sha256, pcr0)))
+	require.NoError(t, mismatched.AddDigest(uint64(1), *comid.NewDigestIntAlg(comid.Sha256, qux[:32])))
+
+	_, err = store.FindValueTriplesByRegisters(mismatched, nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	unknownAlg := comid.NewIntegrityRegisters()
+	require.NoError(t, unknownAlg.AddDigest(uint64(0), *comid.NewDigestStringAlg("md5", pcr0)))
+
+	_, err = store.FindValueTriplesByRegisters(unknownAlg, nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = store.FindValueTriplesByRegisters(nil, nil)
+	assert.ErrorContains(t, err, "evidence registers not specified")
+
+	tenant, err := store.ForTenant("baz")
+	require.NoError(t, err)
+
+	_, err = tenant.FindValueTriplesByRegisters(evidence, nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
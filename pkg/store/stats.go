@@ -0,0 +1,360 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+// Stats summarises the contents of the store.
+type Stats struct {
+	// Generated is the time at which the statistics were gathered.
+	Generated time.Time
+	// Groups contains per-label/profile counts, ordered by label, then
+	// profile.
+	Groups []*GroupStats
+	// Environments, Measurements, and Tokens contain the total number of
+	// rows of the corresponding type in the store.
+	Environments int64
+	Measurements int64
+	Tokens       int64
+	// Expiring lists the manifests that expire (or have expired) before
+	// the end of the window specified when gathering the statistics,
+	// ordered by their expiry.
+	Expiring []*ExpiringManifest
+	// Tables contains the number of rows and, where supported by the
+	// DBMS, the size of each of the store's tables.
+	Tables []*TableStats
+}
+
+// GroupStats contains the counts for the manifests with a particular label
+// and profile. Empty Label or Profile indicates that they are not set.
+type GroupStats struct {
+	Label      string
+	Profile    string
+	Manifests  int64
+	ModuleTags int64
+	Tokens     int64
+	// Triples contains the counts for each triple type (see TripleTypes)
+	// present in the group.
+	Triples []*TripleStats
+}
+
+// TripleStats contains the counts of active and inactive triples of a
+// particular type.
+type TripleStats struct {
+	Type     string
+	Active   int64
+	Inactive int64
+}
+
+// ExpiringManifest identifies a manifest that expires within the window
+// specified when gathering the statistics.
+type ExpiringManifest struct {
+	ManifestID string
+	Label      string
+	Profile    string
+	NotAfter   time.Time
+
+	dbID int64
+}
+
+// TableStats contains the number of rows in a table, and its size in bytes
+// (including indexes), if it could be determined (otherwise, Size is nil).
+type TableStats struct {
+	Name string
+	Rows int64
+	Size *int64
+}
+
+// tripleSource describes where triples of a particular type are stored.
+// moduleColumn is the column referencing the module tag containing the
+// triple, and where, if set, further restricts the rows of the table.
+type tripleSource struct {
+	name         string
+	table        string
+	moduleColumn string
+	where        string
+}
+
+var tripleSources = []tripleSource{
+	{"key", "key_triples", "module_id", ""},
+	{"reference-value", "value_triples", "owner_id", "owner_type = 'module_tag' AND type = 'reference'"},
+	{"endorsed-value", "value_triples", "owner_id", "owner_type = 'module_tag' AND type = 'endorsement'"},
+	{"conditional-endorsement", "conditional_endorsement_triples", "module_id", ""},
+	{"conditional-endorsement-series", "conditional_endorsement_series_triples", "module_id", ""},
+	{"domain-dependency", "domain_dependency_triples", "module_id", ""},
+	{"domain-membership", "domain_membership_triples", "module_id", ""},
+}
+
+// TripleTypes returns the names of the triple types reported inside
+// GroupStats.
+func TripleTypes() []string {
+	ret := make([]string, 0, len(tripleSources))
+	for _, source := range tripleSources {
+		ret = append(ret, source.name)
+	}
+
+	return ret
+}
+
+type groupCount struct {
+	Label    string
+	Profile  string
+	IsActive bool
+	Count    int64
+}
+
+// Stats gathers statistics about the contents of the store. Manifests whose
+// NotAfter is before the end of the specified window (starting now) are
+// listed as expiring; a zero window lists the manifests that have already
+// expired.
+func (o *Store) Stats(expiryWindow time.Duration) (*Stats, error) {
+	if err := o.checkUnscoped("Stats"); err != nil {
+		return nil, err
+	}
+
+	ret := Stats{Generated: time.Now().UTC()}
+	groups := map[[2]string]*GroupStats{}
+
+	getGroup := func(label, profile string) *GroupStats {
+		key := [2]string{label, profile}
+		group, ok := groups[key]
+		if !ok {
+			group = &GroupStats{Label: label, Profile: profile}
+			groups[key] = group
+		}
+
+		return group
+	}
+
+	counts, err := o.countGroups("manifests AS man", "")
+	if err != nil {
+		return nil, fmt.Errorf("manifests: %w", err)
+	}
+
+	for _, count := range counts {
+		getGroup(count.Label, count.Profile).Manifests += count.Count
+	}
+
+	counts, err = o.countGroups("module_tags AS mt", "JOIN manifests AS man ON man.id = mt.manifest_id")
+	if err != nil {
+		return nil, fmt.Errorf("module tags: %w", err)
+	}
+
+	for _, count := range counts {
+		getGroup(count.Label, count.Profile).ModuleTags += count.Count
+	}
+
+	counts, err = o.countGroups("tokens AS tok", "JOIN manifests AS man ON man.manifest_id = tok.manifest_id")
+	if err != nil {
+		return nil, fmt.Errorf("tokens: %w", err)
+	}
+
+	for _, count := range counts {
+		getGroup(count.Label, count.Profile).Tokens += count.Count
+	}
+
+	for _, source := range tripleSources {
+		counts, err := o.countTriples(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s triples: %w", source.name, err)
+		}
+
+		for _, count := range counts {
+			group := getGroup(count.Label, count.Profile)
+
+			idx := slices.IndexFunc(group.Triples, func(s *TripleStats) bool { return s.Type == source.name })
+			if idx == -1 {
+				group.Triples = append(group.Triples, &TripleStats{Type: source.name})
+				idx = len(group.Triples) - 1
+			}
+
+			if count.IsActive {
+				group.Triples[idx].Active += count.Count
+			} else {
+				group.Triples[idx].Inactive += count.Count
+			}
+		}
+	}
+
+	for _, group := range groups {
+		ret.Groups = append(ret.Groups, group)
+	}
+
+	slices.SortFunc(ret.Groups, func(a, b *GroupStats) int {
+		if c := strings.Compare(a.Label, b.Label); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Profile, b.Profile)
+	})
+
+	for _, total := range []struct {
+		table string
+		dest  *int64
+	}{
+		{"environments", &ret.Environments},
+		{"measurements", &ret.Measurements},
+		{"tokens", &ret.Tokens},
+	} {
+		count, err := o.ReadDB().NewSelect().TableExpr("?", bun.Ident(total.table)).Count(o.Ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", total.table, err)
+		}
+
+		*total.dest = int64(count)
+	}
+
+	ret.Expiring, err = o.expiringManifests(ret.Generated.Add(expiryWindow))
+	if err != nil {
+		return nil, fmt.Errorf("expiring manifests: %w", err)
+	}
+
+	ret.Tables, err = o.tableStats()
+	if err != nil {
+		return nil, fmt.Errorf("tables: %w", err)
+	}
+
+	return &ret, nil
+}
+
+func (o *Store) countGroups(table, join string) ([]*groupCount, error) {
+	var ret []*groupCount
+
+	query := o.ReadDB().NewSelect().
+		TableExpr(table).
+		ColumnExpr("COALESCE(man.label, '') AS label").
+		ColumnExpr("COALESCE(man.profile, '') AS profile").
+		ColumnExpr("COUNT(*) AS count").
+		GroupExpr("man.label, man.profile")
+
+	if join != "" {
+		query.Join(join)
+	}
+
+	if err := query.Scan(o.Ctx, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (o *Store) countTriples(source tripleSource) ([]*groupCount, error) {
+	var ret []*groupCount
+
+	query := o.ReadDB().NewSelect().
+		TableExpr("? AS t", bun.Ident(source.table)).
+		Join("JOIN module_tags AS mt ON mt.id = t.?", bun.Ident(source.moduleColumn)).
+		Join("JOIN manifests AS man ON man.id = mt.manifest_id").
+		ColumnExpr("COALESCE(man.label, '') AS label").
+		ColumnExpr("COALESCE(man.profile, '') AS profile").
+		ColumnExpr("t.is_active AS is_active").
+		ColumnExpr("COUNT(*) AS count").
+		GroupExpr("man.label, man.profile, t.is_active")
+
+	if source.where != "" {
+		query.Where(source.where)
+	}
+
+	if err := query.Scan(o.Ctx, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (o *Store) expiringManifests(deadline time.Time) ([]*ExpiringManifest, error) {
+	var manifests []*model.Manifest
+
+	err := o.ReadDB().NewSelect().
+		Model(&manifests).
+		Where("not_after IS NOT NULL").
+		Where("not_after <= ?", deadline).
+		Order("not_after", "id").
+		Scan(o.Ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*ExpiringManifest, 0, len(manifests))
+	for _, manifest := range manifests {
+		ret = append(ret, &ExpiringManifest{
+			ManifestID: manifest.ManifestID,
+			Label:      manifest.Label,
+			Profile:    manifest.Profile,
+			NotAfter:   manifest.NotAfter.UTC(),
+			dbID:       manifest.ID,
+		})
+	}
+
+	return ret, nil
+}
+
+func (o *Store) tableStats() ([]*TableStats, error) {
+	sizes := o.tableSizes()
+
+	var ret []*TableStats
+
+	for _, name := range model.TableNames(o.ReadDB()) {
+		count, err := o.ReadDB().NewSelect().TableExpr("?", bun.Ident(name)).Count(o.Ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		stats := TableStats{Name: name, Rows: int64(count)}
+		if size, ok := sizes[name]; ok {
+			stats.Size = &size
+		}
+
+		ret = append(ret, &stats)
+	}
+
+	return ret, nil
+}
+
+// tableSizes returns the sizes of the tables in bytes (including their
+// indexes) using dialect-specific queries. Sizes are not available if the
+// DBMS does not support obtaining them (e.g. SQLite built without the dbstat
+// virtual table), in which case an empty map is returned.
+func (o *Store) tableSizes() map[string]int64 {
+	var rows []struct {
+		Name string
+		Size int64
+	}
+
+	var query string
+	switch o.ReadDB().Dialect().Name().String() {
+	case "pg":
+		query = `SELECT c.relname AS name, pg_total_relation_size(c.oid) AS size
+			FROM pg_class AS c JOIN pg_namespace AS n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind = 'r'`
+	case "mysql":
+		query = `SELECT table_name AS name, data_length + index_length AS size
+			FROM information_schema.tables WHERE table_schema = DATABASE()`
+	case "sqlite":
+		// index sizes are attributed to the tables they index
+		query = `SELECT COALESCE(m.tbl_name, s.name) AS name, SUM(s.pgsize) AS size
+			FROM dbstat AS s LEFT JOIN sqlite_master AS m ON m.name = s.name
+			GROUP BY COALESCE(m.tbl_name, s.name)`
+	default:
+		// coverage:ignore
+		return map[string]int64{}
+	}
+
+	ret := map[string]int64{}
+
+	if err := o.ReadDB().NewRaw(query).Scan(o.Ctx, &rows); err != nil {
+		return ret
+	}
+
+	for _, row := range rows {
+		ret[row.Name] = row.Size
+	}
+
+	return ret
+}
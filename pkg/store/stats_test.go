@@ -0,0 +1,78 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+)
+
+func TestStore_Stats(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	_, err := store.SetValueTriplesActive(NewValueTripleQuery().Label("cca"), true)
+	require.NoError(t, err)
+
+	stats, err := store.Stats(0)
+	require.NoError(t, err)
+
+	require.Len(t, stats.Groups, 1)
+	group := stats.Groups[0]
+	assert.Equal(t, "cca", group.Label)
+	assert.Equal(t, int64(3), group.Manifests)
+	assert.Equal(t, int64(3), group.ModuleTags)
+	assert.Equal(t, int64(3), group.Tokens)
+	assert.Equal(t, int64(3), stats.Tokens)
+	assert.NotZero(t, stats.Environments)
+	assert.NotZero(t, stats.Measurements)
+
+	var total int64
+	for _, triples := range group.Triples {
+		assert.Contains(t, TripleTypes(), triples.Type)
+
+		switch triples.Type {
+		case "reference-value", "endorsed-value":
+			assert.Zero(t, triples.Inactive)
+			total += triples.Active
+		default:
+			assert.Zero(t, triples.Active)
+			total += triples.Inactive
+		}
+	}
+
+	count, err := store.DB.NewSelect().Model((*model.KeyTriple)(nil)).Count(store.Ctx)
+	require.NoError(t, err)
+	valueCount, err := store.DB.NewSelect().Model((*model.ValueTriple)(nil)).Count(store.Ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(count+valueCount), total)
+
+	tables := map[string]int64{}
+	for _, table := range stats.Tables {
+		tables[table.Name] = table.Rows
+	}
+	assert.Equal(t, int64(3), tables["manifests"])
+	assert.Equal(t, stats.Environments, tables["environments"])
+
+	assert.Empty(t, stats.Expiring)
+
+	notAfter := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	_, err = store.DB.NewUpdate().
+		Model((*model.Manifest)(nil)).
+		Set("not_after = ?", notAfter).
+		Where("id = ?", 1).
+		Exec(store.Ctx)
+	require.NoError(t, err)
+
+	stats, err = store.Stats(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, stats.Expiring)
+
+	stats, err = store.Stats(72 * time.Hour)
+	require.NoError(t, err)
+	require.Len(t, stats.Expiring, 1)
+	assert.Equal(t, "cca", stats.Expiring[0].Label)
+	assert.True(t, notAfter.Equal(stats.Expiring[0].NotAfter))
+}
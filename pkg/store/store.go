@@ -24,12 +24,23 @@ import (
 
 var ErrNoLabel = errors.New("a label must be specified (required by store configuration)")
 var ErrNoMatch = errors.New("no match found")
+var ErrReadOnly = errors.New("store is read-only")
 
 type Store struct {
 	Ctx context.Context
 	DB  bun.IDB
 
-	cfg *Config
+	// replica, if not nil, is the connection to the read replica used
+	// for queries (see ReadDB()).
+	replica *bun.DB
+	cfg     *Config
+
+	// tenant, if not nil, is the tenant the Store is scoped to (see
+	// ForTenant).
+	tenant *Tenant
+	// admin is set for Stores used internally by administrative
+	// operations, which are exempt from Config.RequireTenant.
+	admin bool
 }
 
 // Open a Store configured according to provided Config that will use the
@@ -43,12 +54,21 @@ func Open(ctx context.Context, cfg *Config) (*Store, error) {
 		return nil, err
 	}
 
-	db, err := db.Open(cfg.DB())
+	primary, err := db.Open(cfg.DB())
 	if err != nil {
 		return nil, err
 	}
 
-	return &Store{Ctx: ctx, cfg: cfg, DB: db}, nil
+	var replica *bun.DB
+	if replicaCfg := cfg.ReplicaDB(); replicaCfg != nil {
+		replica, err = db.Open(replicaCfg)
+		if err != nil {
+			_ = primary.Close()
+			return nil, fmt.Errorf("replica: %w", err)
+		}
+	}
+
+	return &Store{Ctx: ctx, cfg: cfg, DB: primary, replica: replica}, nil
 }
 
 // OpenWithDB opens a store using an existing bun.DB and specified config options.
@@ -57,15 +77,65 @@ func OpenWithDB(ctx context.Context, db *bun.DB, options ...ConfigOption) (*Stor
 	return &Store{Ctx: ctx, cfg: cfg, DB: db}, nil
 }
 
+// OpenWithReplica opens a store using existing bun.DB's for the primary
+// database and its read replica, and specified config options.
+func OpenWithReplica(
+	ctx context.Context,
+	primary *bun.DB,
+	replica *bun.DB,
+	options ...ConfigOption,
+) (*Store, error) {
+	ret, err := OpenWithDB(ctx, primary, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.cfg.ReplicaDSN = "<USING EXISTING DB>"
+	ret.replica = replica
+
+	return ret, nil
+}
+
 // Close the Store. If the Store has a database connection (rather than a
-// transaction), the database connection will be closed as well.
+// transaction), the database connection will be closed as well (along with
+// the read replica connection, if there is one).
 func (o *Store) Close() error {
+	if o.tenant != nil {
+		// the connection belongs to the Store the tenant's Store was
+		// created from.
+		return nil
+	}
+
+	var err error
+
+	if o.replica != nil {
+		err = o.replica.Close()
+	}
+
 	db, ok := o.DB.(*bun.DB)
 	if ok {
-		return db.Close()
+		return errors.Join(db.Close(), err)
 	}
 
-	return nil
+	return err
+}
+
+// ReadDB returns the bun.IDB used for queries. This is the read replica, if
+// one has been configured, and the Store is not using a transaction;
+// otherwise, it is the same as Store.DB.
+func (o *Store) ReadDB() bun.IDB {
+	if o.replica != nil {
+		return o.replica
+	}
+
+	return o.DB
+}
+
+// IsReadOnly returns true if the Store has been opened in read-only mode, in
+// which case, all operations that would modify its contents fail with
+// ErrReadOnly.
+func (o *Store) IsReadOnly() bool {
+	return o.cfg.ReadOnly
 }
 
 // BeginTx starts an new transaction (bun.Tx) and returns a Store that uses
@@ -78,7 +148,13 @@ func (o *Store) BeginTx(opts *sql.TxOptions) (*Store, error) {
 		return nil, err
 	}
 
-	return &Store{Ctx: o.Ctx, DB: tx, cfg: o.cfg}, nil
+	// note: the replica is not propagated, so that queries made via the
+	// returned Store are part of the transaction.
+	ret := o.clone()
+	ret.DB = tx
+	ret.replica = nil
+
+	return ret, nil
 }
 
 // Tx return *bun.Tx pointing to the transaction the Store is using as its DB.
@@ -94,6 +170,14 @@ func (o *Store) Tx() *bun.Tx {
 
 // Init inializes a new database with the store's tables
 func (o *Store) Init() error {
+	if err := o.checkWritable("Init"); err != nil {
+		return err
+	}
+
+	if err := o.checkUnscoped("Init"); err != nil {
+		return err
+	}
+
 	db, ok := o.DB.(*bun.DB)
 	if !ok {
 		return errors.New("cannot Init via transaction")
@@ -111,6 +195,14 @@ func (o *Store) Init() error {
 // Migrate upates the tables in the associated database to be compatible with this store.
 // (note: there is no need to run this after invoking Store.Init().)
 func (o *Store) Migrate() error {
+	if err := o.checkWritable("Migrate"); err != nil {
+		return err
+	}
+
+	if err := o.checkUnscoped("Migrate"); err != nil {
+		return err
+	}
+
 	db, ok := o.DB.(*bun.DB)
 	if !ok {
 		return errors.New("cannot Migrate via transaction")
@@ -131,25 +223,20 @@ func (o *Store) Migrate() error {
 // buffer using keys in the provided store, and, if successful, add the CoRIM
 // to the store (as with AddBytes).
 func (o *Store) VerifyAndAddBytes(buf []byte, keys util.KeyStore, label string, activate bool) error {
-	if !util.IsSignedCoRIM(buf) {
-		return fmt.Errorf("input must be a signed CoRIM")
-	}
-
-	signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(buf)
-	if err != nil {
+	if err := o.checkWritable("VerifyAndAddBytes"); err != nil {
 		return err
 	}
 
-	key, err := keys.Get(signed)
+	label, err := o.scopeLabel("VerifyAndAddBytes", label)
 	if err != nil {
 		return err
 	}
 
-	if err = signed.Verify(key.PublicKey()); err != nil {
-		return err
+	if o.tenant != nil && o.tenant.SignerKeys != nil {
+		keys = o.tenant.SignerKeys
 	}
 
-	auth, err := model.NewCryptoKeyFromCoRIM(key.Authority())
+	token, unsigned, err := verifyBytes(buf, keys)
 	if err != nil {
 		return err
 	}
@@ -159,13 +246,6 @@ func (o *Store) VerifyAndAddBytes(buf []byte, keys util.KeyStore, label string,
 		return err
 	}
 
-	token := model.Token{
-		Data:       buf,
-		IsSigned:   true,
-		ManifestID: signed.UnsignedCorim.GetID(),
-		Authority:  []*model.CryptoKey{auth},
-	}
-
 	if err := token.Insert(txStore.Ctx, txStore.DB); err != nil {
 		_ = txStore.Tx().Rollback()
 		return err
@@ -173,7 +253,7 @@ func (o *Store) VerifyAndAddBytes(buf []byte, keys util.KeyStore, label string,
 
 	digest := o.Digest(buf)
 
-	if err := txStore.AddCoRIM(&signed.UnsignedCorim, digest, label, activate); err != nil {
+	if err := txStore.AddCoRIM(unsigned, digest, label, activate); err != nil {
 		_ = txStore.Tx().Rollback()
 		return err
 	}
@@ -189,62 +269,39 @@ func (o *Store) VerifyAndAddBytes(buf []byte, keys util.KeyStore, label string,
 // returned. If activate is true, the contained triples will be activated
 // before they are added.
 func (o *Store) AddBytes(buf []byte, label string, activate bool) error {
-	if len(buf) < 3 {
-		return fmt.Errorf("input too short")
+	if err := o.checkWritable("AddBytes"); err != nil {
+		return err
 	}
 
-	token := model.Token{Data: buf}
-	digest := o.Digest(buf)
+	if o.tenant != nil && o.tenant.SignerKeys != nil {
+		return o.VerifyAndAddBytes(buf, nil, label, activate)
+	}
 
-	txStore, err := o.BeginTx(nil)
+	label, err := o.scopeLabel("AddBytes", label)
 	if err != nil {
 		return err
 	}
 
-	if util.IsSignedCoRIM(buf) { // nolint:gocritic
-		if !o.cfg.Insecure {
-			_ = txStore.Tx().Rollback()
-			return errors.New("CoRIM signature must be verified")
-		}
-
-		signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(buf)
-		if err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
+	token, unsigned, err := o.decodeBytes(buf)
+	if err != nil {
+		return err
+	}
 
-		token.IsSigned = true
-		token.ManifestID = signed.UnsignedCorim.GetID()
-		if err := txStore.AddToken(&token); err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
+	digest := o.Digest(buf)
 
-		if err := txStore.AddCoRIM(&signed.UnsignedCorim, digest, label, activate); err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
-	} else if util.IsUnsignedCoRIM(buf) {
-		unsigned, err := corim.UnmarshalAndValidateUnsignedCorimFromCBOR(buf)
-		if err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		return err
+	}
 
-		token.IsSigned = false
-		token.ManifestID = unsigned.GetID()
-		if err := txStore.AddToken(&token); err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
+	if err := txStore.AddToken(token); err != nil {
+		_ = txStore.Tx().Rollback()
+		return err
+	}
 
-		if err := txStore.AddCoRIM(unsigned, digest, label, activate); err != nil {
-			_ = txStore.Tx().Rollback()
-			return err
-		}
-	} else {
+	if err := txStore.AddCoRIM(unsigned, digest, label, activate); err != nil {
 		_ = txStore.Tx().Rollback()
-		return fmt.Errorf("unrecognized input format")
+		return err
 	}
 
 	return txStore.Tx().Commit()
@@ -255,6 +312,10 @@ func (o *Store) AddBytes(buf []byte, label string, activate bool) error {
 // from. If activate is true, the contained triples will be activated
 // before they are added.
 func (o *Store) AddCoRIM(c *corim.UnsignedCorim, digest []byte, label string, activate bool) error {
+	if err := o.checkWritable("AddCoRIM"); err != nil {
+		return err
+	}
+
 	m, err := model.NewManifestFromCoRIM(c)
 	if err != nil {
 		return err
@@ -271,6 +332,18 @@ func (o *Store) AddCoRIM(c *corim.UnsignedCorim, digest []byte, label string, ac
 }
 
 func (o *Store) AddToken(token *model.Token) error {
+	if err := o.checkWritable("AddToken"); err != nil {
+		return err
+	}
+
+	if err := o.checkTenant("AddToken"); err != nil {
+		return err
+	}
+
+	if err := o.checkManifestOwner("AddToken", token.ManifestID); err != nil {
+		return err
+	}
+
 	var existing model.Token
 	err := o.DB.NewSelect().Model(&existing).Where("manifest_id = ?", token.ManifestID).Scan(o.Ctx)
 	if err == nil { // found
@@ -305,13 +378,27 @@ func (o *Store) AddToken(token *model.Token) error {
 
 // AddManifest adds the provided manifest to the store.
 func (o *Store) AddManifest(m *model.Manifest) error {
+	if err := o.checkWritable("AddManifest"); err != nil {
+		return err
+	}
+
+	label, err := o.scopeLabel("AddManifest", m.Label)
+	if err != nil {
+		return err
+	}
+	m.Label = label
+
+	if err := o.checkManifestAllowed("AddManifest", m.ManifestID, m.Profile); err != nil {
+		return err
+	}
+
 	var existing model.Manifest
 
 	if o.cfg.RequireLabel && m.Label == "" {
 		return ErrNoLabel
 	}
 
-	err := o.DB.NewSelect().Model(&existing).Where("manifest_id = ?", m.ManifestID).Scan(o.Ctx)
+	err = o.DB.NewSelect().Model(&existing).Where("manifest_id = ?", m.ManifestID).Scan(o.Ctx)
 	if err == nil { // found
 		if o.cfg.Force {
 			// select the existing manifest to fully populate its fields
@@ -354,7 +441,9 @@ func (o *Store) AddManifest(m *model.Manifest) error {
 		return err
 	}
 
-	m.TimeAdded = time.Now()
+	if m.TimeAdded.IsZero() {
+		m.TimeAdded = time.Now()
+	}
 
 	if err := m.Insert(o.Ctx, tx); err != nil {
 		_ = tx.Rollback()
@@ -370,14 +459,19 @@ func (o *Store) AddManifest(m *model.Manifest) error {
 func (o *Store) GetManifest(manifestID string, label string) (*model.Manifest, error) {
 	var ret model.Manifest
 
-	query := o.DB.NewSelect().Model(&ret).Where("manifest_id = ?", manifestID)
+	label, err := o.scopeLabel("GetManifest", label)
+	if err != nil {
+		return nil, err
+	}
+
+	query := o.ReadDB().NewSelect().Model(&ret).Where("manifest_id = ?", manifestID)
 	if label != "" {
 		query.Where("label = ?", label)
 	} else if o.cfg.RequireLabel {
 		return nil, ErrNoLabel
 	}
 
-	err := query.Scan(o.Ctx)
+	err = query.Scan(o.Ctx)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("manifest with ID %q not found ", manifestID)
@@ -387,7 +481,7 @@ func (o *Store) GetManifest(manifestID string, label string) (*model.Manifest, e
 	}
 
 	// fully populate nested structures
-	if err := ret.Select(o.Ctx, o.DB); err != nil {
+	if err := ret.Select(o.Ctx, o.ReadDB()); err != nil {
 		return nil, err
 	}
 
@@ -398,7 +492,11 @@ func (o *Store) GetManifest(manifestID string, label string) (*model.Manifest, e
 // and all its data, from the store. The ID is the unique ID of the manifest
 // extracted from its token (not the internal database entry ID).
 func (o *Store) DeleteManifest(manifestID string, label string) error {
-	manifest, err := o.GetManifest(manifestID, label)
+	if err := o.checkWritable("DeleteManifest"); err != nil {
+		return err
+	}
+
+	manifest, err := o.primary().GetManifest(manifestID, label)
 	if err != nil {
 		return err
 	}
@@ -460,7 +558,11 @@ func (o *Store) QueryManifestEntries(query Query[*model.ManifestEntry]) ([]*mode
 		query = NewManifestQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryManifestEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryManifestModels returns Manifest models that match the provided query.
@@ -475,7 +577,7 @@ func (o *Store) QueryManifestModels(query Query[*model.ManifestEntry]) ([]*model
 
 	ret := make([]*model.Manifest, len(entries))
 	for i, entry := range entries {
-		moduleTag, err := entry.ToManifest(o.Ctx, o.DB)
+		moduleTag, err := entry.ToManifest(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("manifest ID %d: %w", moduleTag.ID, err)
 		}
@@ -518,7 +620,11 @@ func (o *Store) QueryModuleTagEntries(query Query[*model.ModuleTagEntry]) ([]*mo
 		query = NewModuleTagQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryModuleTagEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryModuleTagModels returns ModuleTag models that match the provided query.
@@ -533,7 +639,7 @@ func (o *Store) QueryModuleTagModels(query Query[*model.ModuleTagEntry]) ([]*mod
 
 	ret := make([]*model.ModuleTag, len(entries))
 	for i, entry := range entries {
-		moduleTag, err := entry.ToModuleTag(o.Ctx, o.DB)
+		moduleTag, err := entry.ToModuleTag(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("module tag ID %d: %w", moduleTag.ID, err)
 		}
@@ -573,13 +679,17 @@ func (o *Store) QueryEntityModels(query Query[*model.Entity]) ([]*model.Entity,
 		query = NewEntityQuery()
 	}
 
-	models, err := query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryEntityModels", query); err != nil {
+		return nil, err
+	}
+
+	models, err := query.Run(o.Ctx, o.ReadDB())
 	if err != nil {
 		return nil, err
 	}
 
 	for _, model := range models {
-		if err := model.Select(o.Ctx, o.DB); err != nil {
+		if err := model.Select(o.Ctx, o.ReadDB()); err != nil {
 			return nil, fmt.Errorf("entity ID %d: %w", model.ID, err)
 		}
 	}
@@ -647,7 +757,11 @@ func (o *Store) QueryEnvironmentModels(query Query[*model.Environment]) ([]*mode
 		query = NewEnvironmentQuery(false)
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryEnvironmentModels", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryEnvironments returns comid.Environment's that match the provided query.
@@ -680,7 +794,11 @@ func (o *Store) QueryKeyTripleEntries(query Query[*model.KeyTripleEntry]) ([]*mo
 		query = NewKeyTripleQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryKeyTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryKeyTripleModels returns a []*model.KeyTriple containing triples matching
@@ -697,7 +815,7 @@ func (o *Store) QueryKeyTripleModels(query Query[*model.KeyTripleEntry]) ([]*mod
 
 	ret := make([]*model.KeyTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("key triple ID %d: %w", entry.TripleDbID, err)
 		}
@@ -736,7 +854,11 @@ func (o *Store) QueryValueTripleEntries(query Query[*model.ValueTripleEntry]) ([
 	if query == nil {
 		query = NewValueTripleQuery()
 	}
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryValueTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryValueTripleModels returns a []*model.ValueTriple containing triples matching
@@ -753,7 +875,7 @@ func (o *Store) QueryValueTripleModels(query Query[*model.ValueTripleEntry]) ([]
 
 	ret := make([]*model.ValueTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("entry for value triple with ID %d: %w", entry.TripleDbID, err)
 		}
@@ -796,7 +918,11 @@ func (o *Store) QueryConditionalEndorsementTripleEntries(
 		query = NewConditionalEndorsementTripleQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryConditionalEndorsementTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryConditionalEndorsementTripleModels returns a
@@ -816,7 +942,7 @@ func (o *Store) QueryConditionalEndorsementTripleModels(
 
 	ret := make([]*model.ConditionalEndorsementTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("conditional endorsement triple ID %d: %w",
 				entry.TripleDbID, err)
@@ -862,7 +988,11 @@ func (o *Store) QueryConditionalEndorsementSeriesTripleEntries(
 		query = NewConditionalEndorsementSeriesTripleQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryConditionalEndorsementSeriesTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryConditionalEndorsementSeriesTripleModels returns a
@@ -882,7 +1012,7 @@ func (o *Store) QueryConditionalEndorsementSeriesTripleModels(
 
 	ret := make([]*model.ConditionalEndorsementSeriesTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("conditional endorsement series triple ID %d: %w",
 				entry.TripleDbID, err)
@@ -929,7 +1059,11 @@ func (o *Store) QueryDomainDependencyTripleEntries(
 		query = NewDomainDependencyTripleQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryDomainDependencyTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryDomainDependencyTripleModels returns a []*model.DomainDependencyTriple
@@ -948,7 +1082,7 @@ func (o *Store) QueryDomainDependencyTripleModels(
 
 	ret := make([]*model.DomainDependencyTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("domain dependency triple ID %d: %w",
 				entry.TripleDbID, err)
@@ -995,7 +1129,11 @@ func (o *Store) QueryDomainMembershipTripleEntries(
 		query = NewDomainMembershipTripleQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryDomainMembershipTripleEntries", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // QueryDomainMembershipTripleModels returns a []*model.DomainMembershipTriple
@@ -1014,7 +1152,7 @@ func (o *Store) QueryDomainMembershipTripleModels(
 
 	ret := make([]*model.DomainMembershipTriple, len(entries))
 	for i, entry := range entries {
-		triple, err := entry.ToTriple(o.Ctx, o.DB)
+		triple, err := entry.ToTriple(o.Ctx, o.ReadDB())
 		if err != nil {
 			return nil, fmt.Errorf("domain membership triple ID %d: %w",
 				entry.TripleDbID, err)
@@ -1076,7 +1214,11 @@ func (o *Store) QueryTokenModels(query Query[*model.Token]) ([]*model.Token, err
 		query = NewTokenQuery()
 	}
 
-	return query.Run(o.Ctx, o.DB)
+	if err := o.scopeQuery("QueryTokenModels", query); err != nil {
+		return nil, err
+	}
+
+	return query.Run(o.Ctx, o.ReadDB())
 }
 
 // SetKeyTriplesActive sets the active status of key triples matching the
@@ -1087,7 +1229,11 @@ func (o *Store) SetKeyTriplesActive(
 	query Query[*model.KeyTripleEntry],
 	value bool,
 ) ([]*model.KeyTripleEntry, error) {
-	entries, err := o.QueryKeyTripleEntries(query)
+	if err := o.checkWritable("SetKeyTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryKeyTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1119,7 +1265,11 @@ func (o *Store) SetValueTriplesActive(
 	query Query[*model.ValueTripleEntry],
 	value bool,
 ) ([]*model.ValueTripleEntry, error) {
-	entries, err := o.QueryValueTripleEntries(query)
+	if err := o.checkWritable("SetValueTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryValueTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1152,7 +1302,11 @@ func (o *Store) SetConditionalEndorsementTriplesActive(
 	query Query[*model.ConditionalEndorsementTripleEntry],
 	value bool,
 ) ([]*model.ConditionalEndorsementTripleEntry, error) {
-	entries, err := o.QueryConditionalEndorsementTripleEntries(query)
+	if err := o.checkWritable("SetConditionalEndorsementTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryConditionalEndorsementTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1185,7 +1339,11 @@ func (o *Store) SetConditionalEndorsementSeriesTriplesActive(
 	query Query[*model.ConditionalEndorsementSeriesTripleEntry],
 	value bool,
 ) ([]*model.ConditionalEndorsementSeriesTripleEntry, error) {
-	entries, err := o.QueryConditionalEndorsementSeriesTripleEntries(query)
+	if err := o.checkWritable("SetConditionalEndorsementSeriesTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryConditionalEndorsementSeriesTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1218,7 +1376,11 @@ func (o *Store) SetDomainDependencyTriplesActive(
 	query Query[*model.DomainDependencyTripleEntry],
 	value bool,
 ) ([]*model.DomainDependencyTripleEntry, error) {
-	entries, err := o.QueryDomainDependencyTripleEntries(query)
+	if err := o.checkWritable("SetDomainDependencyTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryDomainDependencyTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1251,7 +1413,11 @@ func (o *Store) SetDomainMembershipTriplesActive(
 	query Query[*model.DomainMembershipTripleEntry],
 	value bool,
 ) ([]*model.DomainMembershipTripleEntry, error) {
-	entries, err := o.QueryDomainMembershipTripleEntries(query)
+	if err := o.checkWritable("SetDomainMembershipTriplesActive"); err != nil {
+		return nil, err
+	}
+
+	entries, err := o.primary().QueryDomainMembershipTripleEntries(query)
 	if err != nil {
 		return nil, err
 	}
@@ -1275,9 +1441,130 @@ func (o *Store) SetDomainMembershipTriplesActive(
 	return entries, nil
 }
 
+// decodeBytes decodes the CoRIM token in the provided buffer without
+// verifying its signature (if it is signed). Signed tokens are only accepted
+// if the Store is configured to be insecure.
+func (o *Store) decodeBytes(buf []byte) (*model.Token, *corim.UnsignedCorim, error) {
+	if len(buf) < 3 {
+		return nil, nil, fmt.Errorf("input too short")
+	}
+
+	token := model.Token{Data: buf}
+
+	if util.IsSignedCoRIM(buf) { // nolint:gocritic
+		if !o.cfg.Insecure {
+			return nil, nil, errors.New("CoRIM signature must be verified")
+		}
+
+		signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		token.IsSigned = true
+		token.ManifestID = signed.UnsignedCorim.GetID()
+
+		return &token, &signed.UnsignedCorim, nil
+	} else if util.IsUnsignedCoRIM(buf) {
+		unsigned, err := corim.UnmarshalAndValidateUnsignedCorimFromCBOR(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		token.IsSigned = false
+		token.ManifestID = unsigned.GetID()
+
+		return &token, unsigned, nil
+	} else {
+		return nil, nil, fmt.Errorf("unrecognized input format")
+	}
+}
+
+// verifyBytes decodes the signed CoRIM token in the provided buffer and
+// verifies its signature using a key obtained from the specified KeyStore.
+func verifyBytes(buf []byte, keys util.KeyStore) (*model.Token, *corim.UnsignedCorim, error) {
+	if !util.IsSignedCoRIM(buf) {
+		return nil, nil, fmt.Errorf("input must be a signed CoRIM")
+	}
+
+	signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := keys.Get(signed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = signed.Verify(key.PublicKey()); err != nil {
+		return nil, nil, err
+	}
+
+	auth, err := model.NewCryptoKeyFromCoRIM(key.Authority())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := model.Token{
+		Data:       buf,
+		IsSigned:   true,
+		ManifestID: signed.UnsignedCorim.GetID(),
+		Authority:  []*model.CryptoKey{auth},
+	}
+
+	return &token, &signed.UnsignedCorim, nil
+}
+
+// checkWritable returns an error wrapping ErrReadOnly if the store is
+// read-only.
+func (o *Store) checkWritable(operation string) error {
+	if o.cfg.ReadOnly {
+		return fmt.Errorf("%s: %w", operation, ErrReadOnly)
+	}
+
+	return nil
+}
+
+// primary returns a Store that uses the primary database for queries, as well
+// as for modifications. This should be used for queries made as part of
+// modifying the store's contents (as the replica may be lagging behind).
+func (o *Store) primary() *Store {
+	if o.replica == nil {
+		return o
+	}
+
+	ret := o.clone()
+	ret.replica = nil
+
+	return ret
+}
+
+// administrative returns a Store to be used internally by administrative
+// operations, that is exempt from Config.RequireTenant.
+func (o *Store) administrative() *Store {
+	ret := o.clone()
+	ret.admin = true
+
+	return ret
+}
+
+func (o *Store) clone() *Store {
+	ret := *o
+	return &ret
+}
+
 // Clear removes all data from store (effectively truncating the tables
 // containing CoRIM/CoMID data).
 func (o *Store) Clear() error {
+	if err := o.checkWritable("Clear"); err != nil {
+		return err
+	}
+
+	if err := o.checkUnscoped("Clear"); err != nil {
+		return err
+	}
+
 	db, ok := o.DB.(*bun.DB)
 	if !ok {
 		return errors.New("cannot Clear via transaction")
@@ -1286,6 +1573,32 @@ func (o *Store) Clear() error {
 	return model.ResetModels(o.Ctx, db)
 }
 
+// CollectGarbage recalculates the reference counts of environments, and
+// removes environments that are no longer referenced by any triples. It
+// returns the number of environments that were removed.
+func (o *Store) CollectGarbage() (int64, error) {
+	if err := o.checkWritable("CollectGarbage"); err != nil {
+		return 0, err
+	}
+
+	if err := o.checkUnscoped("CollectGarbage"); err != nil {
+		return 0, err
+	}
+
+	txStore, err := o.BeginTx(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := model.GarbageCollectEnvironments(o.Ctx, txStore.DB)
+	if err != nil {
+		_ = txStore.Tx().Rollback()
+		return 0, err
+	}
+
+	return count, txStore.Tx().Commit()
+}
+
 // StringAggregatorExpr returns an expression using a dialect-specific
 // function to aggregate the specified column (must be TEXT) into a
 // comma-separated list.
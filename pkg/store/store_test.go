@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uptrace/bun"
+	"github.com/veraison/corim-store/pkg/db"
 	"github.com/veraison/corim-store/pkg/model"
 	"github.com/veraison/corim-store/pkg/util"
 	"github.com/veraison/corim/comid"
@@ -766,6 +767,123 @@ func TestStore_QueryTokenModels(t *testing.T) {
 	assert.Len(t, tokens, 2)
 }
 
+func TestStore_CollectGarbage(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	count, err := store.CollectGarbage()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	envs, err := store.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+
+	err = store.DeleteManifest("cca-ref-realm", "cca")
+	require.NoError(t, err)
+
+	// deleting the manifest released its environments, so there should be
+	// nothing left to collect
+	count, err = store.CollectGarbage()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	remaining, err := store.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	assert.Less(t, len(remaining), len(envs))
+
+	vendor := "orphan"
+	orphan := model.Environment{Vendor: &vendor}
+	err = orphan.Insert(store.Ctx, store.DB)
+	require.NoError(t, err)
+
+	count, err = store.CollectGarbage()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestStore_read_only(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	store.cfg.ReadOnly = true
+	assert.True(t, store.IsReadOnly())
+
+	manifests, err := store.QueryManifestEntries(nil)
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 3)
+
+	bytes, err := os.ReadFile("../../sample/corim/unsigned-cca-ta.cbor")
+	require.NoError(t, err)
+
+	err = store.AddBytes(bytes, "other", false)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	err = store.DeleteManifest("cca-ta", "cca")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = store.SetValueTriplesActive(NewValueTripleQuery(), true)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = store.AddBatch([]BatchItem{{Name: "ta", Data: bytes}}, nil)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = store.CollectGarbage()
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	txStore, err := store.BeginTx(nil)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, txStore.Tx().Rollback()) }()
+
+	err = txStore.Clear()
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestStore_replica(t *testing.T) {
+	primaryDB := model.NewTestDB(t)
+	if primaryDB.Dialect().Name().String() != "sqlite" {
+		// the test DBs for other DBMSs are shared, so cannot be
+		// used to emulate a separate replica
+		assert.NoError(t, primaryDB.Close())
+		t.SkipNow()
+	}
+
+	replicaDB, err := db.Open(&db.Config{DBMS: "sqlite", DSN: "file::memory:"})
+	require.NoError(t, err)
+	replicaDB.SetMaxOpenConns(1) // each connection would get its own in-memory DB
+
+	replicaStore, err := OpenWithDB(context.Background(), replicaDB)
+	require.NoError(t, err)
+	require.NoError(t, replicaStore.Init())
+
+	store, err := OpenWithReplica(context.Background(), primaryDB, replicaDB)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	bytes, err := os.ReadFile("../../sample/corim/unsigned-cca-ta.cbor")
+	require.NoError(t, err)
+
+	err = store.AddBytes(bytes, "", true)
+	require.NoError(t, err)
+
+	// the addition went to the primary, and has not been replicated
+	_, err = store.QueryManifestEntries(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// modifications query the primary
+	entries, err := store.SetKeyTriplesActive(NewKeyTripleQuery(), false)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// as do transactions
+	txStore, err := store.BeginTx(nil)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, txStore.Tx().Rollback()) }()
+
+	manifests, err := txStore.QueryManifestEntries(nil)
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 1)
+}
+
 func newStoreWithSampleCoRIMs(t *testing.T) *Store {
 	db := model.NewTestDB(t)
 	store, err := OpenWithDB(context.Background(), db)
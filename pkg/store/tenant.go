@@ -0,0 +1,290 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+var ErrNoTenant = errors.New("a tenant must be specified (required by store configuration)")
+var ErrTenantScope = errors.New("outside of tenant scope")
+
+// Tenant contains the configuration of a tenant of the Store. Each tenant's
+// contents are stored under a label matching the tenant's name.
+type Tenant struct {
+	// Name of the tenant. This is used as the label for all of the
+	// tenant's contents.
+	Name string
+	// AllowedProfiles, if not empty, lists the profiles of the manifests
+	// the tenant is allowed to add.
+	AllowedProfiles []string
+	// SignerKeys, if set, contains the keys that must have been used to
+	// sign all CoRIMs the tenant adds. These take precedence over any
+	// keys passed to VerifyAndAddBytes, and unsigned CoRIMs are rejected.
+	SignerKeys util.KeyStore
+}
+
+type TenantOption func(t *Tenant)
+
+func TenantAllowedProfiles(profiles ...string) TenantOption {
+	return func(t *Tenant) {
+		t.AllowedProfiles = append(t.AllowedProfiles, profiles...)
+	}
+}
+
+func TenantSignerKeys(keys util.KeyStore) TenantOption {
+	return func(t *Tenant) {
+		t.SignerKeys = keys
+	}
+}
+
+// ForTenant returns a Store scoped to the specified tenant. All queries made
+// via the returned Store are restricted to the tenant's contents, and all
+// manifests added via it are placed under the tenant's label. Specifying a
+// different label (either as a parameter, or inside a query) results in an
+// error wrapping ErrTenantScope, as do administrative operations (such as
+// Clear), which cannot be scoped to a tenant. Entities are restricted to those
+// of the tenant's manifests and module tags, and environments (which may be
+// shared between tenants) to those referenced by the tenant's triples.
+//
+// The returned Store shares the database connection of this Store, so closing
+// it has no effect; this Store should be closed instead.
+func (o *Store) ForTenant(name string, options ...TenantOption) (*Store, error) {
+	if name == "" {
+		return nil, errors.New("tenant name not specified")
+	}
+
+	if o.tenant != nil {
+		return nil, fmt.Errorf("store already scoped to tenant %q", o.tenant.Name)
+	}
+
+	tenant := Tenant{Name: name}
+	for _, opt := range options {
+		opt(&tenant)
+	}
+
+	ret := o.clone()
+	ret.tenant = &tenant
+
+	return ret, nil
+}
+
+// Tenant returns the tenant the Store is scoped to, or nil if it is not
+// scoped to a tenant.
+func (o *Store) Tenant() *Tenant {
+	return o.tenant
+}
+
+// tenantRestricter is implemented by queries that can be restricted to a
+// tenant's contents.
+type tenantRestricter interface {
+	restrictToTenant(name string) error
+}
+
+func restrictToTenant(query any, name string) error {
+	restricter, ok := query.(tenantRestricter)
+	if !ok {
+		return fmt.Errorf("%T cannot be restricted to a tenant: %w", query, ErrTenantScope)
+	}
+
+	return restricter.restrictToTenant(name)
+}
+
+// checkTenant returns an error wrapping ErrNoTenant if the configuration
+// requires a tenant, but the store has not been scoped to one.
+func (o *Store) checkTenant(operation string) error {
+	if o.tenant == nil && o.cfg.RequireTenant && !o.admin {
+		return fmt.Errorf("%s: %w", operation, ErrNoTenant)
+	}
+
+	return nil
+}
+
+// checkUnscoped returns an error wrapping ErrTenantScope if the store has
+// been scoped to a tenant. This is used by administrative operations.
+func (o *Store) checkUnscoped(operation string) error {
+	if o.tenant != nil {
+		return fmt.Errorf("%s: %w", operation, ErrTenantScope)
+	}
+
+	return nil
+}
+
+// scopeQuery restricts the query to the store's tenant, if it has one.
+func (o *Store) scopeQuery(operation string, query any) error {
+	if o.tenant == nil {
+		return o.checkTenant(operation)
+	}
+
+	if err := restrictToTenant(query, o.tenant.Name); err != nil {
+		return fmt.Errorf("%s: %w", operation, err)
+	}
+
+	return nil
+}
+
+// scopeLabel returns the label to be used for the provided label, given the
+// store's tenant (if it has one).
+func (o *Store) scopeLabel(operation string, label string) (string, error) {
+	if o.tenant == nil {
+		return label, o.checkTenant(operation)
+	}
+
+	if label != "" && label != o.tenant.Name {
+		return "", fmt.Errorf("%s: label %q: %w", operation, label, ErrTenantScope)
+	}
+
+	return o.tenant.Name, nil
+}
+
+// checkManifestAllowed returns an error if the manifest may not be added by
+// the store's tenant (see also checkManifestOwner).
+func (o *Store) checkManifestAllowed(operation string, manifestID string, profile string) error {
+	if o.tenant == nil {
+		return nil
+	}
+
+	if len(o.tenant.AllowedProfiles) != 0 && !slices.Contains(o.tenant.AllowedProfiles, profile) {
+		return fmt.Errorf("%s: profile %q not allowed: %w", operation, profile, ErrTenantScope)
+	}
+
+	return o.checkManifestOwner(operation, manifestID)
+}
+
+// checkManifestOwner returns an error if the manifest ID is already used by a
+// manifest that does not belong to the store's tenant. Manifest IDs are
+// unique across the store, so such manifests (and their tokens) must not be
+// replaced, even with Force.
+func (o *Store) checkManifestOwner(operation string, manifestID string) error {
+	if o.tenant == nil {
+		return nil
+	}
+
+	exists, err := o.DB.NewSelect().
+		Model((*model.Manifest)(nil)).
+		Where("manifest_id = ?", manifestID).
+		Where("(label IS NULL OR label <> ?)", o.tenant.Name).
+		Exists(o.Ctx)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("%s: manifest %q belongs to another tenant: %w",
+			operation, manifestID, ErrTenantScope)
+	}
+
+	return nil
+}
+
+// tenantEnvironmentSources are the statements selecting the IDs of the
+// environments referenced by a tenant's contents. Each has a single
+// placeholder for the tenant's label.
+var tenantEnvironmentSources = []string{
+	"SELECT kte.environment_db_id FROM key_triple_entries AS kte WHERE kte.label = ?",
+	"SELECT vte.environment_db_id FROM value_triple_entries AS vte WHERE vte.label = ?",
+	"SELECT cste.environment_db_id FROM conditional_endorsement_series_triple_entries AS cste " +
+		"WHERE cste.label = ?",
+	"SELECT ddte.environment_db_id FROM domain_dependency_triple_entries AS ddte WHERE ddte.label = ?",
+	"SELECT dmte.environment_db_id FROM domain_membership_triple_entries AS dmte WHERE dmte.label = ?",
+	"SELECT senv.environment_id FROM stateful_environments AS senv " +
+		"INNER JOIN conditional_endorsement_triple_entries AS cete ON senv.triple_id = cete.triple_db_id " +
+		"WHERE cete.label = ?",
+	"SELECT vt.environment_id FROM value_triples AS vt " +
+		"INNER JOIN conditional_endorsement_triple_entries AS cete ON vt.owner_id = cete.triple_db_id " +
+		"AND vt.owner_type = 'conditional_endorsement_triple' WHERE cete.label = ?",
+	"SELECT de.environment_id FROM domain_entries AS de " +
+		"INNER JOIN domain_dependency_triple_entries AS ddte ON de.owner_id = ddte.triple_db_id " +
+		"AND de.owner_type = 'domain_dependency_triple' WHERE ddte.label = ?",
+	"SELECT de.environment_id FROM domain_entries AS de " +
+		"INNER JOIN domain_membership_triple_entries AS dmte ON de.owner_id = dmte.triple_db_id " +
+		"AND de.owner_type = 'domain_membership_triple' WHERE dmte.label = ?",
+}
+
+// addTenantEnvironmentClause restricts an environments query to the
+// environments referenced by the tenant's contents.
+func addTenantEnvironmentClause(query *bun.SelectQuery, dialect schema.Dialect, name string) {
+	args := make([]any, len(tenantEnvironmentSources))
+	for i := range args {
+		args[i] = name
+	}
+
+	query.Where(fmt.Sprintf("%s IN (%s)", identQuote("id", dialect),
+		strings.Join(tenantEnvironmentSources, " UNION ")), args...)
+}
+
+// addTenantEntityClause restricts an entities query to the entities of the
+// tenant's manifests and module tags.
+func addTenantEntityClause(query *bun.SelectQuery, dialect schema.Dialect, name string) {
+	ownerType := identQuote("owner_type", dialect)
+	ownerID := identQuote("owner_id", dialect)
+
+	query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+		q.WhereOr(fmt.Sprintf("%s = 'manifest' AND %s IN "+
+			"(SELECT man.id FROM manifests AS man WHERE man.label = ?)", ownerType, ownerID), name)
+		q.WhereOr(fmt.Sprintf("%s = 'module_tag' AND %s IN "+
+			"(SELECT mt.id FROM module_tags AS mt INNER JOIN manifests AS man ON mt.manifest_id = man.id "+
+			"WHERE man.label = ?)", ownerType, ownerID), name)
+
+		return q
+	})
+}
+
+// tenantMeasurementSources are the statements selecting the IDs of the
+// measurements of a tenant's triples. Each has a single placeholder for the
+// tenant's label.
+var tenantMeasurementSources = []string{
+	"SELECT mea.id FROM measurements AS mea " +
+		"INNER JOIN value_triple_entries AS vte ON mea.owner_id = vte.triple_db_id " +
+		"AND mea.owner_type = 'value_triple' WHERE vte.label = ?",
+	"SELECT mea.id FROM measurements AS mea " +
+		"INNER JOIN value_triples AS vt ON mea.owner_id = vt.id AND mea.owner_type = 'value_triple' " +
+		"INNER JOIN conditional_endorsement_triple_entries AS cete ON vt.owner_id = cete.triple_db_id " +
+		"AND vt.owner_type = 'conditional_endorsement_triple' WHERE cete.label = ?",
+	"SELECT mea.id FROM measurements AS mea " +
+		"INNER JOIN stateful_environments AS senv ON mea.owner_id = senv.id " +
+		"AND mea.owner_type = 'stateful_environment' " +
+		"INNER JOIN conditional_endorsement_triple_entries AS cete ON senv.triple_id = cete.triple_db_id " +
+		"WHERE cete.label = ?",
+	"SELECT mea.id FROM measurements AS mea " +
+		"INNER JOIN conditional_endorsement_series_triple_entries AS cste ON mea.owner_id = cste.triple_db_id " +
+		"AND mea.owner_type = 'ces_condition' WHERE cste.label = ?",
+	"SELECT mea.id FROM measurements AS mea " +
+		"INNER JOIN conditional_endorsement_series_records AS cesr ON mea.owner_id = cesr.id " +
+		"AND mea.owner_type IN ('ces_record_selection', 'ces_record_addition') " +
+		"INNER JOIN conditional_endorsement_series_triple_entries AS cste ON cesr.triple_id = cste.triple_db_id " +
+		"WHERE cste.label = ?",
+}
+
+// addTenantDigestClause restricts a digests query to the digests of the
+// tenant's measurements (directly, or via their integrity registers), and of
+// the dependent RIM locators of the tenant's manifests.
+func addTenantDigestClause(query *bun.SelectQuery, dialect schema.Dialect, name string) {
+	ownerType := identQuote("owner_type", dialect)
+	ownerID := identQuote("owner_id", dialect)
+
+	measurements := strings.Join(tenantMeasurementSources, " UNION ")
+	args := make([]any, len(tenantMeasurementSources))
+	for i := range args {
+		args[i] = name
+	}
+
+	query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+		q.WhereOr(fmt.Sprintf("%s = 'measurement' AND %s IN (%s)",
+			ownerType, ownerID, measurements), args...)
+		q.WhereOr(fmt.Sprintf("%s = 'integrity_register' AND %s IN "+
+			"(SELECT ir.id FROM integrity_registers AS ir WHERE ir.measurement_id IN (%s))",
+			ownerType, ownerID, measurements), args...)
+		q.WhereOr(fmt.Sprintf("%s = 'locator' AND %s IN "+
+			"(SELECT loc.id FROM locators AS loc INNER JOIN manifests AS man ON loc.manifest_id = man.id "+
+			"WHERE man.label = ?)", ownerType, ownerID), name)
+
+		return q
+	})
+}
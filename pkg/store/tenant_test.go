@@ -0,0 +1,222 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+func TestStore_ForTenant(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	_, err := store.ForTenant("")
+	assert.ErrorContains(t, err, "tenant name not specified")
+
+	acme, err := store.ForTenant("acme")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", acme.Tenant().Name)
+	assert.Nil(t, store.Tenant())
+
+	_, err = acme.ForTenant("cca")
+	assert.ErrorContains(t, err, `already scoped to tenant "acme"`)
+
+	// closing a tenant store does not close the underlying DB
+	assert.NoError(t, acme.Close())
+
+	cca, err := store.ForTenant("cca")
+	require.NoError(t, err)
+
+	manifests, err := cca.QueryManifestEntries(nil)
+	require.NoError(t, err)
+	assert.Len(t, manifests, 3)
+
+	_, err = acme.QueryManifestEntries(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = acme.QueryManifestEntries(NewManifestQuery().Label("cca"))
+	assert.ErrorIs(t, err, ErrTenantScope)
+
+	_, err = acme.QueryKeyTripleEntries(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = acme.QueryTokenModels(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	tokens, err := cca.QueryTokenModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 3)
+
+	_, err = acme.QueryEnvironmentModels(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	allEnvs, err := store.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	envs, err := cca.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	assert.Len(t, envs, len(allEnvs))
+
+	_, err = acme.QueryEntityModels(nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	allEntities, err := store.QueryEntityModels(nil)
+	require.NoError(t, err)
+	entities, err := cca.QueryEntityModels(NewEntityQuery())
+	require.NoError(t, err)
+	assert.Len(t, entities, len(allEntities))
+
+	_, err = acme.QueryEnvironmentModels(Not(NewEnvironmentQuery(false).ID(1)))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	_, err = acme.GetManifest("cca-ref-plat", "")
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = acme.GetManifest("cca-ref-plat", "cca")
+	assert.ErrorIs(t, err, ErrTenantScope)
+
+	manifest, err := cca.GetManifest("cca-ref-plat", "")
+	require.NoError(t, err)
+	assert.Equal(t, "cca", manifest.Label)
+
+	err = acme.DeleteManifest("cca-ref-plat", "")
+	assert.ErrorContains(t, err, "not found")
+
+	for _, op := range []func() error{
+		acme.Clear,
+		func() error { _, err := acme.CollectGarbage(); return err },
+		func() error { _, err := acme.Verify(nil); return err },
+		func() error { _, err := acme.Stats(0); return err },
+		func() error { _, err := acme.Backup(&bytes.Buffer{}); return err },
+		func() error { _, err := acme.ListLabels(); return err },
+		func() error { _, err := acme.DeleteLabel("cca"); return err },
+	} {
+		assert.ErrorIs(t, op(), ErrTenantScope)
+	}
+
+	buf, err := os.ReadFile("../../sample/corim/unsigned-cca-ta.cbor")
+	require.NoError(t, err)
+
+	err = acme.AddBytes(buf, "cca", false)
+	assert.ErrorIs(t, err, ErrTenantScope)
+
+	// the manifest ID is already used by the "cca" tenant
+	acme.cfg.Force = true
+	err = acme.AddBytes(buf, "", false)
+	assert.ErrorIs(t, err, ErrTenantScope)
+	acme.cfg.Force = false
+
+	// the original manifest has not been replaced
+	_, err = cca.GetManifest("cca-ta", "")
+	assert.NoError(t, err)
+
+	err = cca.DeleteManifest("cca-ref-plat", "")
+	require.NoError(t, err)
+
+	manifests, err = cca.QueryManifestEntries(nil)
+	require.NoError(t, err)
+	assert.Len(t, manifests, 2)
+}
+
+func TestStore_ForTenant_options(t *testing.T) {
+	db := model.NewTestDB(t)
+	store, err := OpenWithDB(context.Background(), db, OptionInsecure)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	unsigned, err := os.ReadFile("../../sample/corim/unsigned-cca-ta.cbor")
+	require.NoError(t, err)
+
+	signed, err := os.ReadFile("../../sample/corim/signed-cca-ta.cose")
+	require.NoError(t, err)
+
+	keys, err := util.KeyStoreFromJWKPath("../../sample/corim/key.pub.jwk")
+	require.NoError(t, err)
+
+	acme, err := store.ForTenant("acme", TenantAllowedProfiles("http://example.com/no-such-profile"))
+	require.NoError(t, err)
+
+	err = acme.AddBytes(unsigned, "", false)
+	assert.ErrorIs(t, err, ErrTenantScope)
+	assert.ErrorContains(t, err, "not allowed")
+
+	acme, err = store.ForTenant("acme", TenantSignerKeys(keys))
+	require.NoError(t, err)
+
+	err = acme.AddBytes(unsigned, "", false)
+	assert.Error(t, err)
+
+	err = acme.AddBytes(signed, "", false)
+	require.NoError(t, err)
+
+	manifests, err := acme.QueryManifestEntries(nil)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, "acme", manifests[0].Label)
+
+	other, err := store.ForTenant("other")
+	require.NoError(t, err)
+
+	plat, err := os.ReadFile("../../sample/corim/unsigned-cca-ref-plat.cbor")
+	require.NoError(t, err)
+	require.NoError(t, other.AddBytes(plat, "", false))
+
+	allEnvs, err := store.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	acmeEnvs, err := acme.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	otherEnvs, err := other.QueryEnvironmentModels(nil)
+	require.NoError(t, err)
+	assert.Less(t, len(acmeEnvs), len(allEnvs))
+	assert.Less(t, len(otherEnvs), len(allEnvs))
+
+	acmeEntities, err := acme.QueryEntityModels(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, acmeEntities)
+	for _, entity := range acmeEntities {
+		if entity.OwnerType == "manifest" {
+			assert.Equal(t, manifests[0].ManifestDbID, entity.OwnerID)
+		}
+	}
+}
+
+func TestStore_RequireTenant(t *testing.T) {
+	store := newStoreWithSampleCoRIMs(t)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	store.cfg.RequireTenant = true
+
+	_, err := store.QueryManifestEntries(nil)
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	_, err = store.GetManifest("cca-ref-plat", "cca")
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	buf, err := os.ReadFile("../../sample/corim/unsigned-cca-ta.cbor")
+	require.NoError(t, err)
+
+	err = store.AddBytes(buf, "cca", false)
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	// administrative operations are not affected
+	report, err := store.Verify(nil)
+	require.NoError(t, err)
+	assert.Empty(t, report.Issues)
+
+	var archive bytes.Buffer
+	count, err := store.Backup(&archive)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	cca, err := store.ForTenant("cca")
+	require.NoError(t, err)
+
+	manifests, err := cca.QueryManifestEntries(nil)
+	require.NoError(t, err)
+	assert.Len(t, manifests, 3)
+}
@@ -16,6 +16,9 @@ var (
 	//go:embed  fixtures/flags.yaml
 	flagsFixture []byte
 
+	//go:embed  fixtures/extensions.yaml
+	extensionsFixture []byte
+
 	//go:embed  fixtures/measurement_values.yaml
 	measurementValuesFixture []byte
 
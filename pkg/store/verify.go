@@ -0,0 +1,391 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim/corim"
+)
+
+// IssueKind identifies the kind of inconsistency found by Store.Verify.
+type IssueKind string
+
+const (
+	// InvalidTokenIssue indicates that a stored token could not be decoded.
+	InvalidTokenIssue IssueKind = "invalid-token"
+	// ManifestMismatchIssue indicates that a stored manifest differs from
+	// the manifest derived from its token.
+	ManifestMismatchIssue IssueKind = "manifest-mismatch"
+	// DigestMismatchIssue indicates that a manifest's digest does not
+	// match the digest of its token (calculated using the configured
+	// hash algorithm).
+	DigestMismatchIssue IssueKind = "digest-mismatch"
+	// DanglingReferenceIssue indicates a row referencing a row that does
+	// not exist (e.g. a measurement whose owner has been deleted).
+	DanglingReferenceIssue IssueKind = "dangling-reference"
+	// EnvironmentRefCountIssue indicates that an environment's reference
+	// count does not match the number of rows referencing it.
+	EnvironmentRefCountIssue IssueKind = "environment-ref-count"
+	// OrphanedEnvironmentIssue indicates an environment that is not
+	// referenced by any rows.
+	OrphanedEnvironmentIssue IssueKind = "orphaned-environment"
+)
+
+// VerifyOptions control the behaviour of Store.Verify.
+type VerifyOptions struct {
+	// Repair the issues found, where possible.
+	Repair bool
+}
+
+// VerifyIssue is an inconsistency found by Store.Verify.
+type VerifyIssue struct {
+	Kind IssueKind
+	// Table and ID identify the affected row.
+	Table string
+	ID    int64
+	// Detail contains a human-readable description of the issue.
+	Detail string
+	// Repaired is set if the issue has been repaired.
+	Repaired bool
+}
+
+func (o *VerifyIssue) String() string {
+	return fmt.Sprintf("%s: %s", o.Kind, o.Detail)
+}
+
+// VerifyReport summarises the outcome of Store.Verify.
+type VerifyReport struct {
+	// ManifestsChecked is the number of manifests that were compared
+	// against their tokens.
+	ManifestsChecked int
+	Issues           []*VerifyIssue
+}
+
+// NumUnrepaired returns the number of issues that have not been repaired.
+func (o *VerifyReport) NumUnrepaired() int {
+	ret := 0
+	for _, issue := range o.Issues {
+		if !issue.Repaired {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+func (o *VerifyReport) add(kind IssueKind, table string, id int64, format string, args ...any) *VerifyIssue {
+	issue := VerifyIssue{Kind: kind, Table: table, ID: id, Detail: fmt.Sprintf(format, args...)}
+	o.Issues = append(o.Issues, &issue)
+	return &issue
+}
+
+// Verify checks the consistency of the store's contents. Manifests are
+// re-derived from their tokens, and compared against the stored manifests;
+// manifest digests are validated against the tokens using the configured
+// hash algorithm; rows referencing non-existent rows, and environments with
+// incorrect reference counts are identified. If opts.Repair is set, issues are
+// repaired where possible: manifests are replaced with the ones derived from
+// their tokens (retaining their labels, activation state, and times added),
+// digests are corrected, dangling rows are deleted, and environment reference
+// counts are recalculated (deleting unreferenced environments).
+func (o *Store) Verify(opts *VerifyOptions) (*VerifyReport, error) {
+	if err := o.checkUnscoped("Verify"); err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = &VerifyOptions{}
+	}
+
+	if opts.Repair {
+		if err := o.checkWritable("Verify"); err != nil {
+			return nil, err
+		}
+	}
+
+	txStore, err := o.administrative().BeginTx(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var report VerifyReport
+
+	// environments are checked last, as repairing manifests and dangling
+	// references may affect their reference counts.
+	for _, check := range []func(*VerifyReport, bool) error{
+		txStore.verifyManifests,
+		txStore.verifyReferences,
+		txStore.verifyEnvironments,
+	} {
+		if err := check(&report, opts.Repair); err != nil {
+			_ = txStore.Tx().Rollback()
+			return nil, err
+		}
+	}
+
+	if !opts.Repair {
+		return &report, txStore.Tx().Rollback()
+	}
+
+	return &report, txStore.Tx().Commit()
+}
+
+func (o *Store) verifyManifests(report *VerifyReport, repair bool) error {
+	tokens, err := o.QueryTokenModels(nil)
+	if err != nil {
+		if errors.Is(err, ErrNoMatch) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, token := range tokens {
+		var stored model.Manifest
+		err := o.DB.NewSelect().Model(&stored).Where("manifest_id = ?", token.ManifestID).Scan(o.Ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the manifest has been deleted, leaving the token
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := stored.Select(o.Ctx, o.DB); err != nil {
+			return fmt.Errorf("manifest %q: %w", stored.ManifestID, err)
+		}
+
+		report.ManifestsChecked++
+
+		if err := o.verifyManifest(report, token, &stored, repair); err != nil {
+			return fmt.Errorf("manifest %q: %w", stored.ManifestID, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Store) verifyManifest(report *VerifyReport, token *model.Token, stored *model.Manifest, repair bool) error {
+	unsigned, err := decodeToken(token.IsSigned, token.Data)
+	if err != nil {
+		report.add(InvalidTokenIssue, "tokens", token.ID, "token for %q: %v", token.ManifestID, err)
+		return nil
+	}
+
+	derived, err := model.NewManifestFromCoRIM(unsigned)
+	if err != nil {
+		report.add(InvalidTokenIssue, "tokens", token.ID, "token for %q: %v", token.ManifestID, err)
+		return nil
+	}
+
+	digest := o.Digest(token.Data)
+	digestMatches := bytes.Equal(stored.Digest, digest)
+
+	if !digestMatches {
+		issue := report.add(DigestMismatchIssue, "manifests", stored.ID,
+			"manifest %q: digest does not match token (%s)", stored.ManifestID, o.cfg.HashAlg)
+
+		if repair {
+			_, err := o.DB.NewUpdate().
+				Model((*model.Manifest)(nil)).
+				Set("digest = ?", digest).
+				Where("id = ?", stored.ID).
+				Exec(o.Ctx)
+			if err != nil {
+				return err
+			}
+
+			issue.Repaired = true
+		}
+	}
+
+	matches, err := manifestsMatch(stored, derived)
+	if err != nil {
+		return err
+	}
+
+	if matches {
+		return nil
+	}
+
+	issue := report.add(ManifestMismatchIssue, "manifests", stored.ID,
+		"manifest %q: does not match its token", stored.ManifestID)
+
+	if !repair {
+		return nil
+	}
+
+	// the activation state of the stored triples is retained if the
+	// triples still line up with the derived ones; otherwise, the
+	// derived triples are left inactive.
+	entry, err := newBackupEntry(stored, nil)
+	if err != nil {
+		entry = &BackupEntry{}
+	}
+
+	if err := entry.applyActiveStates(derived); err != nil {
+		derived.SetActive(false)
+		issue.Detail += " (activation state could not be retained)"
+	}
+
+	derived.Label = stored.Label
+	derived.TimeAdded = stored.TimeAdded
+	derived.Digest = digest
+
+	if err := stored.Delete(o.Ctx, o.DB); err != nil {
+		return err
+	}
+
+	if err := derived.Insert(o.Ctx, o.DB); err != nil {
+		return err
+	}
+
+	issue.Repaired = true
+
+	return nil
+}
+
+func (o *Store) verifyReferences(report *VerifyReport, repair bool) error {
+	rows, err := model.FindDanglingRows(o.Ctx, o.DB)
+	if err != nil {
+		return err
+	}
+
+	var issues []*VerifyIssue
+	for _, row := range rows {
+		issues = append(issues, report.add(DanglingReferenceIssue, row.Table, row.ID, "%s", row))
+	}
+
+	if !repair || len(rows) == 0 {
+		return nil
+	}
+
+	if _, err := model.DeleteDanglingRows(o.Ctx, o.DB); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		issue.Repaired = true
+	}
+
+	return nil
+}
+
+func (o *Store) verifyEnvironments(report *VerifyReport, repair bool) error {
+	envs, err := model.FindInconsistentEnvironments(o.Ctx, o.DB)
+	if err != nil {
+		return err
+	}
+
+	var issues []*VerifyIssue
+	for _, env := range envs {
+		if env.References == 0 {
+			issues = append(issues, report.add(OrphanedEnvironmentIssue, "environments", env.ID,
+				"environment %d is not referenced", env.ID))
+		} else {
+			issues = append(issues, report.add(EnvironmentRefCountIssue, "environments", env.ID,
+				"environment %d has reference count %d, but is referenced %d time(s)",
+				env.ID, env.RefCount, env.References))
+		}
+	}
+
+	if !repair || len(envs) == 0 {
+		return nil
+	}
+
+	if _, err := model.GarbageCollectEnvironments(o.Ctx, o.DB); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		issue.Repaired = true
+	}
+
+	return nil
+}
+
+// decodeToken returns the unsigned CoRIM inside the provided token data,
+// without verifying its signature (if it is signed).
+func decodeToken(isSigned bool, data []byte) (*corim.UnsignedCorim, error) {
+	if !isSigned {
+		return corim.UnmarshalAndValidateUnsignedCorimFromCBOR(data)
+	}
+
+	signed, err := corim.UnmarshalAndValidateSignedCorimFromCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signed.UnsignedCorim, nil
+}
+
+// manifestsMatch returns true if the provided manifests are equivalent (i.e.
+// they produce the same CoRIM).
+func manifestsMatch(stored, derived *model.Manifest) (bool, error) {
+	// the order in which nested models are selected from the database is
+	// not guaranteed, so restore the order in which they were inserted.
+	sortModelTree(reflect.ValueOf(stored))
+
+	storedCoRIM, err := stored.ToCoRIM()
+	if err != nil {
+		// an invalid stored manifest cannot match a valid token
+		return false, nil // nolint:nilerr
+	}
+
+	storedBytes, err := storedCoRIM.ToCBOR()
+	if err != nil {
+		return false, nil // nolint:nilerr
+	}
+
+	derivedCoRIM, err := derived.ToCoRIM()
+	if err != nil {
+		return false, err
+	}
+
+	derivedBytes, err := derivedCoRIM.ToCBOR()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(storedBytes, derivedBytes), nil
+}
+
+// sortModelTree sorts slices of models nested inside the provided model by
+// their IDs.
+func sortModelTree(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			sortModelTree(value.Elem())
+		}
+	case reflect.Struct:
+		for i := range value.NumField() {
+			if value.Type().Field(i).IsExported() {
+				sortModelTree(value.Field(i))
+			}
+		}
+	case reflect.Slice:
+		elemType := value.Type().Elem()
+		if elemType.Kind() != reflect.Pointer || elemType.Elem().Kind() != reflect.Struct {
+			return
+		}
+
+		idField, ok := elemType.Elem().FieldByName("ID")
+		if !ok || idField.Type.Kind() != reflect.Int64 {
+			return
+		}
+
+		sort.SliceStable(value.Interface(), func(i, j int) bool {
+			return value.Index(i).Elem().FieldByIndex(idField.Index).Int() <
+				value.Index(j).Elem().FieldByIndex(idField.Index).Int()
+		})
+
+		for i := range value.Len() {
+			sortModelTree(value.Index(i))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim-store/pkg/model"
+	"github.com/veraison/corim-store/pkg/util"
+)
+
+func TestStore_Verify(t *testing.T) {
+	store, err := OpenWithDB(context.Background(), model.NewTestDB(t))
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, store.Close()) }()
+
+	unsigned, err := os.ReadFile("../../sample/corim/unsigned-cca-ref-plat.cbor")
+	require.NoError(t, err)
+
+	err = store.AddBytes(unsigned, "unsigned", false)
+	require.NoError(t, err)
+
+	signed, err := os.ReadFile("../../sample/corim/signed-cca-ta.cose")
+	require.NoError(t, err)
+
+	keys, err := util.KeyStoreFromJWKPath("../../sample/corim/key.pub.jwk")
+	require.NoError(t, err)
+
+	err = store.VerifyAndAddBytes(signed, keys, "signed", true)
+	require.NoError(t, err)
+
+	report, err := store.Verify(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.ManifestsChecked)
+	assert.Empty(t, report.Issues)
+
+	var manifest model.Manifest
+	err = store.DB.NewSelect().Model(&manifest).Where("label = ?", "signed").Scan(store.Ctx)
+	require.NoError(t, err)
+
+	_, err = store.DB.NewUpdate().
+		Model((*model.Manifest)(nil)).
+		Set("digest = ?", []byte{0xde, 0xad, 0xbe, 0xef}).
+		Where("id = ?", manifest.ID).
+		Exec(store.Ctx)
+	require.NoError(t, err)
+
+	var triple model.ValueTriple
+	err = store.DB.NewSelect().Model(&triple).Order("vt.id").Limit(1).Scan(store.Ctx)
+	require.NoError(t, err)
+
+	_, err = store.DB.NewDelete().Model(&triple).WherePK().Exec(store.Ctx)
+	require.NoError(t, err)
+
+	report, err = store.Verify(&VerifyOptions{})
+	require.NoError(t, err)
+
+	kinds := map[IssueKind]int{}
+	for _, issue := range report.Issues {
+		assert.False(t, issue.Repaired)
+		kinds[issue.Kind]++
+	}
+
+	assert.Equal(t, 1, kinds[DigestMismatchIssue])
+	assert.Equal(t, 1, kinds[ManifestMismatchIssue])
+	assert.NotZero(t, kinds[DanglingReferenceIssue])
+	assert.Equal(t, 1, kinds[EnvironmentRefCountIssue]+kinds[OrphanedEnvironmentIssue])
+
+	// checking without repairing does not modify the store
+	again, err := store.Verify(nil)
+	require.NoError(t, err)
+	assert.Len(t, again.Issues, len(report.Issues))
+
+	report, err = store.Verify(&VerifyOptions{Repair: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.Issues)
+	assert.Equal(t, 0, report.NumUnrepaired())
+
+	report, err = store.Verify(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.ManifestsChecked)
+	assert.Empty(t, report.Issues)
+
+	// repairs retain labels and activation state
+	entries, err := store.QueryKeyTripleEntries(NewKeyTripleQuery().Label("signed"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries)
+
+	for _, entry := range entries {
+		assert.True(t, entry.IsActive)
+	}
+
+	values, err := store.QueryValueTripleEntries(NewValueTripleQuery().Label("unsigned"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, values)
+
+	for _, entry := range values {
+		assert.False(t, entry.IsActive)
+	}
+
+	store.cfg.ReadOnly = true
+
+	_, err = store.Verify(&VerifyOptions{Repair: true})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
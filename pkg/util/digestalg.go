@@ -0,0 +1,77 @@
+package util
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/veraison/corim/comid"
+)
+
+// digestAlgAliases maps alternative names, commonly used for hash algorithms
+// (e.g. by OpenSSL and in TPM event logs), to their names in the IANA Named
+// Information Hash Algorithm Registry.
+var digestAlgAliases = map[string]string{
+	"sha256":   "sha-256",
+	"sha2-256": "sha-256",
+	"sha384":   "sha-384",
+	"sha2-384": "sha-384",
+	"sha512":   "sha-512",
+	"sha2-512": "sha-512",
+	"sha3256":  "sha3-256",
+	"sha3384":  "sha3-384",
+	"sha3512":  "sha3-512",
+}
+
+// CanonicalDigestAlgID returns the ID of the provided digest algorithm in the
+// IANA Named Information Hash Algorithm Registry. The algorithm may be
+// specified as an integer ID, or as a name (case-insensitively, and allowing
+// common aliases such as "SHA256"). Integer IDs (including ones specified as
+// decimal strings) are returned unchanged, even if they are not in the
+// registry, so that digests using unregistered algorithms can still be matched
+// against each other. Zero is returned if a name is not in the registry, or if
+// the ID does not fit in an int64.
+func CanonicalDigestAlgID(algID any) int64 {
+	switch t := algID.(type) {
+	case int:
+		return int64(t)
+	case int64:
+		return t
+	case uint64:
+		if t > math.MaxInt64 {
+			return 0
+		}
+
+		return int64(t)
+	case comid.DigestAlgorithm:
+		if t.IsString() {
+			return CanonicalDigestAlgID(t.String())
+		}
+
+		return int64(t.Int())
+	case *comid.DigestAlgorithm:
+		if t == nil {
+			return 0
+		}
+
+		return CanonicalDigestAlgID(*t)
+	case string:
+		name := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(t)), "_", "-")
+		if alias, ok := digestAlgAliases[name]; ok {
+			name = alias
+		}
+
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			return id
+		}
+
+		alg := comid.DigestAlgorithmFromString(name)
+		if alg.IsString() {
+			return 0
+		}
+
+		return int64(alg.Int())
+	default:
+		return 0
+	}
+}
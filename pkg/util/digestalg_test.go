@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/veraison/corim/comid"
+)
+
+func TestCanonicalDigestAlgID(t *testing.T) {
+	testCases := []struct {
+		algID    any
+		expected int64
+	}{
+		{1, 1},
+		{int64(7), 7},
+		{uint64(8), 8},
+		{uint64(1 << 63), 0},
+		{999, 999},
+		{"999", 999},
+		{comid.IntDigestAlgorithm(999), 999},
+		{"sha-256", 1},
+		{"SHA-256", 1},
+		{"sha256", 1},
+		{"SHA_384", 7},
+		{"sha3-256", 10},
+		{"sha-256-32", 6},
+		{"1", 1},
+		{"md5", 0},
+		{comid.IntDigestAlgorithm(comid.Sha512), 8},
+		{comid.StringDigestAlgorithm("SHA512"), 8},
+		{(*comid.DigestAlgorithm)(nil), 0},
+		{1.0, 0},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CanonicalDigestAlgID(tc.algID), "%v", tc.algID)
+	}
+}
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/veraison/corim/comid"
+)
+
+// KeyForms contains canonical forms of a crypto key, which do not depend on
+// the encoding the key was presented in. This allows, e.g., a key triple
+// containing a PEM certificate to be matched against a public key extracted
+// from evidence. Forms that cannot be derived from a key are nil.
+type KeyForms struct {
+	// SPKI is the DER encoding of the key's X.509 SubjectPublicKeyInfo.
+	SPKI []byte
+	// SPKIThumbprint is the SHA-256 digest of SPKI. This is also set for
+	// thumbprint keys using SHA-256, even though their SPKI is not known.
+	SPKIThumbprint []byte
+	// CertThumbprint is the SHA-256 digest of the DER encoding of the
+	// certificate containing the key (or, for certificate paths, the
+	// end-entity certificate). This is only set for certificates and
+	// SHA-256 certificate thumbprints.
+	CertThumbprint []byte
+}
+
+// DeriveKeyForms returns the canonical forms that can be derived from the
+// provided key.
+func DeriveKeyForms(key *comid.CryptoKey) KeyForms {
+	var ret KeyForms
+
+	if key == nil || key.Value == nil {
+		return ret
+	}
+
+	switch key.Type() {
+	case comid.PKIXBase64CertType, comid.PKIXBase64CertPathType:
+		// for paths, the end-entity certificate comes first
+		block, _ := pem.Decode([]byte(key.String()))
+		if block != nil && block.Type == "CERTIFICATE" {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				return CertificateKeyForms(cert)
+			}
+		}
+
+		return ret
+	case comid.PKIXAsn1DerCertType:
+		if cert, err := x509.ParseCertificate(key.Value.Bytes()); err == nil {
+			return CertificateKeyForms(cert)
+		}
+
+		return ret
+	case comid.ThumbprintType:
+		ret.SPKIThumbprint = sha256DigestValue(key.Value)
+		return ret
+	case comid.CertThumbprintType:
+		ret.CertThumbprint = sha256DigestValue(key.Value)
+		return ret
+	}
+
+	pub, err := key.PublicKey()
+	if err != nil {
+		return ret
+	}
+
+	if spki, err := x509.MarshalPKIXPublicKey(pub); err == nil {
+		ret.SPKI = spki
+		ret.SPKIThumbprint = sha256Sum(spki)
+	}
+
+	return ret
+}
+
+// CertificateKeyForms returns the canonical forms of the key contained in the
+// certificate.
+func CertificateKeyForms(cert *x509.Certificate) KeyForms {
+	return KeyForms{
+		SPKI:           cert.RawSubjectPublicKeyInfo,
+		SPKIThumbprint: sha256Sum(cert.RawSubjectPublicKeyInfo),
+		CertThumbprint: sha256Sum(cert.Raw),
+	}
+}
+
+// PublicKeyThumbprint returns the SHA-256 digest of the DER encoding of the
+// SubjectPublicKeyInfo of the public key.
+func PublicKeyThumbprint(pub crypto.PublicKey) ([]byte, error) {
+	if pub == nil {
+		return nil, errors.New("nil public key")
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return sha256Sum(spki), nil
+}
+
+func sha256DigestValue(value comid.ICryptoKeyValue) []byte {
+	var digest comid.Digest
+
+	switch t := value.(type) {
+	case *comid.TaggedThumbprint:
+		digest = t.Digest
+	case comid.TaggedThumbprint:
+		digest = t.Digest
+	case *comid.TaggedCertThumbprint:
+		digest = t.Digest
+	case comid.TaggedCertThumbprint:
+		digest = t.Digest
+	default:
+		return nil
+	}
+
+	if digest.Algorithm.Int() != comid.Sha256 || len(digest.Value) != sha256.Size {
+		return nil
+	}
+
+	return digest.Value
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
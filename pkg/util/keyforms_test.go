@@ -0,0 +1,73 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim/comid"
+	"github.com/veraison/go-cose"
+)
+
+func TestDeriveKeyForms(t *testing.T) {
+	block, _ := pem.Decode([]byte(comid.TestCert))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	certForms := CertificateKeyForms(cert)
+
+	block, _ = pem.Decode([]byte(comid.TestECPubKey))
+	require.NotNil(t, block)
+	pubSum := sha256.Sum256(block.Bytes)
+
+	key := comid.MustNewCryptoKey(comid.TestECPubKey, comid.PKIXBase64KeyType)
+	forms := DeriveKeyForms(key)
+	assert.Equal(t, block.Bytes, forms.SPKI)
+	assert.Equal(t, pubSum[:], forms.SPKIThumbprint)
+	assert.Nil(t, forms.CertThumbprint)
+
+	pub, err := key.PublicKey()
+	require.NoError(t, err)
+	thumbprint, err := PublicKeyThumbprint(pub)
+	require.NoError(t, err)
+	assert.Equal(t, forms.SPKIThumbprint, thumbprint)
+
+	coseKey, err := cose.NewKeyFromPublic(pub)
+	require.NoError(t, err)
+	coseBytes, err := coseKey.MarshalCBOR()
+	require.NoError(t, err)
+	forms = DeriveKeyForms(comid.MustNewCOSEKey(coseBytes))
+	assert.Equal(t, block.Bytes, forms.SPKI)
+	assert.Equal(t, pubSum[:], forms.SPKIThumbprint)
+	assert.Nil(t, forms.CertThumbprint)
+
+	key = comid.MustNewCryptoKey(comid.TestCert, comid.PKIXBase64CertType)
+	assert.Equal(t, certForms, DeriveKeyForms(key))
+
+	key = comid.MustNewCryptoKey(comid.TestCertPath, comid.PKIXBase64CertPathType)
+	forms = DeriveKeyForms(key)
+	assert.NotNil(t, forms.SPKIThumbprint)
+	assert.NotNil(t, forms.CertThumbprint)
+
+	key = comid.MustNewCryptoKey(comid.TestThumbprint, comid.ThumbprintType)
+	forms = DeriveKeyForms(key)
+	assert.Nil(t, forms.SPKI)
+	assert.Len(t, forms.SPKIThumbprint, sha256.Size)
+	assert.Nil(t, forms.CertThumbprint)
+
+	key = comid.MustNewCryptoKey(comid.TestThumbprint, comid.CertThumbprintType)
+	forms = DeriveKeyForms(key)
+	assert.Nil(t, forms.SPKIThumbprint)
+	assert.Len(t, forms.CertThumbprint, sha256.Size)
+
+	key = comid.MustNewCryptoKey(comid.TestBytes, comid.BytesType)
+	assert.Equal(t, KeyForms{}, DeriveKeyForms(key))
+
+	assert.Equal(t, KeyForms{}, DeriveKeyForms(nil))
+
+	_, err = PublicKeyThumbprint(nil)
+	assert.ErrorContains(t, err, "nil public key")
+}
@@ -0,0 +1,190 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256" // registers the SHA-256 hash function
+	_ "crypto/sha3"   // registers the SHA3 hash functions
+	_ "crypto/sha512" // registers the SHA-384 and SHA-512 hash functions
+	"fmt"
+
+	"github.com/veraison/corim/comid"
+)
+
+// registerHashes maps the IDs of digest algorithms in the IANA Named
+// Information Hash Algorithm Registry to the hash functions used to extend
+// integrity registers. Truncated algorithms cannot be used for extending
+// registers, and so are not included.
+var registerHashes = map[int64]crypto.Hash{
+	int64(comid.Sha256):   crypto.SHA256,
+	int64(comid.Sha384):   crypto.SHA384,
+	int64(comid.Sha512):   crypto.SHA512,
+	int64(comid.Sha3_224): crypto.SHA3_224,
+	int64(comid.Sha3_256): crypto.SHA3_256,
+	int64(comid.Sha3_384): crypto.SHA3_384,
+	int64(comid.Sha3_512): crypto.SHA3_512,
+}
+
+// RegisterEvent is an entry of an event log (e.g. a TPM or TDX event log),
+// recording the digest of a measurement extended into an integrity register.
+type RegisterEvent struct {
+	// Index of the register the digest was extended into. This is either
+	// an unsigned integer (e.g. a PCR number), or a string.
+	Index  any
+	Digest comid.Digest
+}
+
+// CanonicalRegisterIndex returns the provided integrity register index as a
+// uint64 if it is an integer, or as a string. An error is returned for
+// negative integers and for other types.
+func CanonicalRegisterIndex(index any) (any, error) {
+	switch t := index.(type) {
+	case uint64:
+		return t, nil
+	case uint:
+		return uint64(t), nil
+	case uint32:
+		return uint64(t), nil
+	case int:
+		if t < 0 {
+			return nil, fmt.Errorf("negative register index: %d", t)
+		}
+
+		return uint64(t), nil
+	case int64:
+		if t < 0 {
+			return nil, fmt.Errorf("negative register index: %d", t)
+		}
+
+		return uint64(t), nil
+	case string:
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unexpected register index type %T for index %v", t, index)
+	}
+}
+
+// RegisterHash returns the hash function used to extend integrity registers
+// with digests of the specified algorithm (see CanonicalDigestAlgID).
+func RegisterHash(algID any) (crypto.Hash, error) {
+	hash, ok := registerHashes[CanonicalDigestAlgID(algID)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported register digest algorithm: %v", algID)
+	}
+
+	return hash, nil
+}
+
+// ExtendRegister returns the value of an integrity register with the current
+// value after the measured digest has been extended into it, i.e.
+// H(current || measured), where H is the hash function of the specified
+// algorithm. Both the current value and the measured digest must be the size
+// of the hash function's output.
+func ExtendRegister(algID any, current, measured []byte) ([]byte, error) {
+	hash, err := RegisterHash(algID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(current) != hash.Size() {
+		return nil, fmt.Errorf("register value size %d does not match %s (%d)",
+			len(current), hash, hash.Size())
+	}
+
+	if len(measured) != hash.Size() {
+		return nil, fmt.Errorf("measured digest size %d does not match %s (%d)",
+			len(measured), hash, hash.Size())
+	}
+
+	h := hash.New()
+	h.Write(current)
+	h.Write(measured)
+
+	return h.Sum(nil), nil
+}
+
+// ReplayEventLog computes the expected values of integrity registers by
+// extending the digests of the events, in order, into the registers (see
+// ExtendRegister). Each register has a separate value for each algorithm used
+// by the events extending it. Registers start with their value in initial,
+// if it has one for the algorithm, or with all bytes zero otherwise (as is
+// the case for TPM PCRs 0 to 15, and TDX RTMRs). initial may be nil.
+func ReplayEventLog(initial *comid.IntegrityRegisters, events []RegisterEvent) (*comid.IntegrityRegisters, error) {
+	type registerBank struct {
+		index  any
+		algIDs []int64
+		values map[int64][]byte
+	}
+
+	var order []*registerBank
+	banks := make(map[any]*registerBank)
+
+	for i, event := range events {
+		index, err := CanonicalRegisterIndex(event.Index)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+
+		algID := CanonicalDigestAlgID(event.Digest.Algorithm)
+		hash, err := RegisterHash(algID)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+
+		bank, ok := banks[index]
+		if !ok {
+			bank = &registerBank{index: index, values: make(map[int64][]byte)}
+			banks[index] = bank
+			order = append(order, bank)
+		}
+
+		current, ok := bank.values[algID]
+		if !ok {
+			current = initialRegisterValue(initial, index, algID, hash)
+			bank.algIDs = append(bank.algIDs, algID)
+		}
+
+		bank.values[algID], err = ExtendRegister(algID, current, event.Digest.Value)
+		if err != nil {
+			return nil, fmt.Errorf("event %d (register %v): %w", i, index, err)
+		}
+	}
+
+	ret := comid.NewIntegrityRegisters()
+	for _, bank := range order {
+		for _, algID := range bank.algIDs {
+			// algID is one of the registerHashes keys, and so
+			// fits in an int.
+			digest := comid.NewDigestIntAlg(int(algID), bank.values[algID])
+			if err := ret.AddDigest(bank.index, *digest); err != nil {
+				return nil, fmt.Errorf("register %v: %w", bank.index, err)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func initialRegisterValue(
+	initial *comid.IntegrityRegisters,
+	index any,
+	algID int64,
+	hash crypto.Hash,
+) []byte {
+	if initial != nil {
+		for initIndex, digests := range initial.IndexMap {
+			canonical, err := CanonicalRegisterIndex(initIndex)
+			if err != nil || canonical != index {
+				continue
+			}
+
+			for _, digest := range digests {
+				if CanonicalDigestAlgID(digest.Algorithm) == algID {
+					return bytes.Clone(digest.Value)
+				}
+			}
+		}
+	}
+
+	return make([]byte, hash.Size())
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/veraison/corim/comid"
+)
+
+func TestCanonicalRegisterIndex(t *testing.T) {
+	for _, index := range []any{uint64(3), uint(3), uint32(3), 3, int64(3)} {
+		ret, err := CanonicalRegisterIndex(index)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), ret)
+	}
+
+	ret, err := CanonicalRegisterIndex("rtmr0")
+	require.NoError(t, err)
+	assert.Equal(t, "rtmr0", ret)
+
+	_, err = CanonicalRegisterIndex(-1)
+	assert.ErrorContains(t, err, "negative register index: -1")
+
+	_, err = CanonicalRegisterIndex(1.0)
+	assert.ErrorContains(t, err, "unexpected register index type float64")
+}
+
+func TestExtendRegister(t *testing.T) {
+	measured := sha256.Sum256([]byte("foo"))
+	zero := make([]byte, sha256.Size)
+
+	expected := sha256.Sum256(append(zero, measured[:]...))
+
+	ret, err := ExtendRegister("sha-256", zero, measured[:])
+	require.NoError(t, err)
+	assert.Equal(t, expected[:], ret)
+
+	ret, err = ExtendRegister(comid.Sha256, zero, measured[:])
+	require.NoError(t, err)
+	assert.Equal(t, expected[:], ret)
+
+	_, err = ExtendRegister("sha-256-32", zero, measured[:])
+	assert.ErrorContains(t, err, "unsupported register digest algorithm: sha-256-32")
+
+	_, err = ExtendRegister(comid.Sha256, zero[:4], measured[:])
+	assert.ErrorContains(t, err, "register value size 4 does not match SHA-256 (32)")
+
+	_, err = ExtendRegister(comid.Sha384, make([]byte, sha512.Size384), measured[:])
+	assert.ErrorContains(t, err, "measured digest size 32 does not match SHA-384 (48)")
+}
+
+func TestReplayEventLog(t *testing.T) {
+	foo := sha256.Sum256([]byte("foo"))
+	bar := sha256.Sum256([]byte("bar"))
+	qux := sha512.Sum384([]byte("qux"))
+
+	events := []RegisterEvent{
+		{Index: 0, Digest: *comid.NewDigestIntAlg(comid.Sha256, foo[:])},
+		{Index: uint64(0), Digest: *comid.NewDigestStringAlg("sha-256", bar[:])},
+		{Index: "rtmr1", Digest: *comid.NewDigestIntAlg(comid.Sha384, qux[:])},
+		{Index: uint(0), Digest: *comid.NewDigestIntAlg(comid.Sha384, qux[:])},
+	}
+
+	ret, err := ReplayEventLog(nil, events)
+	require.NoError(t, err)
+	require.Len(t, ret.IndexMap, 2)
+
+	pcr0, err := ExtendRegister(comid.Sha256, make([]byte, sha256.Size), foo[:])
+	require.NoError(t, err)
+	pcr0, err = ExtendRegister(comid.Sha256, pcr0, bar[:])
+	require.NoError(t, err)
+
+	pcr0Sha384, err := ExtendRegister(comid.Sha384, make([]byte, sha512.Size384), qux[:])
+	require.NoError(t, err)
+
+	assert.Equal(t, comid.Digests{
+		*comid.NewDigestIntAlg(comid.Sha256, pcr0),
+		*comid.NewDigestIntAlg(comid.Sha384, pcr0Sha384),
+	}, ret.IndexMap[uint64(0)])
+
+	assert.Equal(t, comid.Digests{
+		*comid.NewDigestIntAlg(comid.Sha384, pcr0Sha384),
+	}, ret.IndexMap["rtmr1"])
+
+	initial := comid.NewIntegrityRegisters()
+	ones := make([]byte, sha256.Size)
+	for i := range ones {
+		ones[i] = 0xff
+	}
+	require.NoError(t, initial.AddDigest(uint64(17), *comid.NewDigestIntAlg(comid.Sha256, ones)))
+
+	ret, err = ReplayEventLog(initial, []RegisterEvent{
+		{Index: 17, Digest: *comid.NewDigestIntAlg(comid.Sha256, foo[:])},
+	})
+	require.NoError(t, err)
+
+	pcr17, err := ExtendRegister(comid.Sha256, ones, foo[:])
+	require.NoError(t, err)
+	assert.Equal(t, pcr17, ret.IndexMap[uint64(17)][0].Value)
+
+	_, err = ReplayEventLog(nil, []RegisterEvent{
+		{Index: 0, Digest: *comid.NewDigestIntAlg(comid.Sha256, foo[:])},
+		{Index: 0, Digest: *comid.NewDigestIntAlg(comid.Sha256, qux[:])},
+	})
+	assert.ErrorContains(t, err, "event 1 (register 0): measured digest size 48 does not match SHA-256 (32)")
+
+	_, err = ReplayEventLog(nil, []RegisterEvent{
+		{Index: -1, Digest: *comid.NewDigestIntAlg(comid.Sha256, foo[:])},
+	})
+	assert.ErrorContains(t, err, "event 0: negative register index: -1")
+}
@@ -47,3 +47,21 @@ function do_get() {
         do_get "$ROOT_DIR"/sample/config/postgres.yaml \| jq ".\"reference-values\" | length"
     assert_output "1"
 }
+
+@test "SQLite3 get repeated class IDs" {
+    config_path="$ROOT_DIR"/sample/config/sqlite3.yaml
+    $CORIM_STORE --config "$config_path" corim add "$ROOT_DIR"/sample/corim/*cbor &>/dev/null
+
+    run bats_pipe \
+        $CORIM_STORE --config "$config_path" get \
+            --class-id bytes:f0VMRgIBAQAAAAAAAAAAAAMAPgABAAAAUFgAAAAAAAA= \
+            --class-id hex:0102 \
+        \| jq ".\"reference-values\" | length"
+    assert_output "1"
+
+    run $CORIM_STORE --config "$config_path" get \
+        --class-id bytes:f0VMRgIBAQAAAAAAAAAAAAMAPgABAAAAUFgAAAAAAAA= \
+        --class-id hex*:0102
+    assert_failure
+    assert_output --partial "class-id: cannot mix IDs that match the type with IDs that only match the value"
+}